@@ -0,0 +1,61 @@
+package apiavailability
+
+const (
+	ConfigurationFileKey = "apiAvailability"
+
+	defaultReleaseName           = "rancher"
+	defaultNamespace             = "cattle-system"
+	defaultIntervalMilliseconds  = 500
+	defaultRequestTimeoutSeconds = 5
+	defaultSettleSeconds         = 30
+)
+
+// Config is the configuration of a Rancher server rollout measured by the API availability prober.
+//
+// ChartRepo is the repo/chart reference the upgrade is installed from, e.g. rancher-latest/rancher, and
+// ChartRepoURL the URL the repo is added from. ChartVersion is the version to upgrade to. HelmArgs are passed as is to
+// helm upgrade, e.g. ["--set", "hostname=rancher.example.com"], and the current values are always reused.
+// Rollback rolls the release back after the upgrade, to RollbackRevision, 0 being the previous revision.
+//
+// ClusterID is the downstream cluster whose proxy is probed. DowntimeBudgetSeconds is the longest any endpoint may
+// stay down during each rollout, and is required. SettleSeconds is how long probing continues after the rollout to capture the recovery.
+type Config struct {
+	ReleaseName           string   `json:"releaseName" yaml:"releaseName"`
+	Namespace             string   `json:"namespace" yaml:"namespace"`
+	ChartRepoName         string   `json:"chartRepoName" yaml:"chartRepoName"`
+	ChartRepoURL          string   `json:"chartRepoURL" yaml:"chartRepoURL"`
+	ChartRepo             string   `json:"chartRepo" yaml:"chartRepo"`
+	ChartVersion          string   `json:"chartVersion" yaml:"chartVersion"`
+	HelmArgs              []string `json:"helmArgs" yaml:"helmArgs"`
+	Rollback              bool     `json:"rollback" yaml:"rollback"`
+	RollbackRevision      int      `json:"rollbackRevision" yaml:"rollbackRevision"`
+	ClusterID             string   `json:"clusterID" yaml:"clusterID"`
+	IntervalMilliseconds  int      `json:"intervalMilliseconds" yaml:"intervalMilliseconds"`
+	RequestTimeoutSeconds int      `json:"requestTimeoutSeconds" yaml:"requestTimeoutSeconds"`
+	SettleSeconds         int      `json:"settleSeconds" yaml:"settleSeconds"`
+	DowntimeBudgetSeconds int      `json:"downtimeBudgetSeconds" yaml:"downtimeBudgetSeconds"`
+	ResultsFile           string   `json:"resultsFile" yaml:"resultsFile"`
+}
+
+// SetDefaults is a helper function that fills in the optional fields of the config left empty.
+func (c *Config) SetDefaults() {
+	if c.ReleaseName == "" {
+		c.ReleaseName = defaultReleaseName
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+
+	if c.IntervalMilliseconds == 0 {
+		c.IntervalMilliseconds = defaultIntervalMilliseconds
+	}
+
+	if c.RequestTimeoutSeconds == 0 {
+		c.RequestTimeoutSeconds = defaultRequestTimeoutSeconds
+	}
+
+	if c.SettleSeconds == 0 {
+		c.SettleSeconds = defaultSettleSeconds
+	}
+}
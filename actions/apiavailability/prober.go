@@ -0,0 +1,232 @@
+package apiavailability
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rancher/shepherd/clients/rancher"
+)
+
+const (
+	V3Endpoint           = "v3"
+	SteveListEndpoint    = "steveList"
+	ClusterProxyEndpoint = "clusterProxy"
+	WatchEndpoint        = "watch"
+
+	v3Path            = "/v3"
+	steveListPath     = "/v1/management.cattle.io.clusters"
+	clusterProxyPath  = "/k8s/clusters/%s/api/v1/namespaces?limit=1"
+	subscribePath     = "/v1/subscribe"
+	watchResourceType = "management.cattle.io.cluster"
+	watchStartEvent   = "resource.start"
+
+	timeoutErrorCode   = "timeout"
+	transportErrorCode = "transport"
+)
+
+// Sample is the outcome of a single request to an endpoint. ErrorCode is the HTTP status code of a failed request, or
+// timeout and transport for requests that got no response.
+type Sample struct {
+	Time      time.Time     `json:"time"`
+	Latency   time.Duration `json:"latency"`
+	Succeeded bool          `json:"succeeded"`
+	ErrorCode string        `json:"errorCode,omitempty"`
+}
+
+// Prober keeps hitting the Rancher API endpoints in the background and records every request made.
+type Prober struct {
+	host       string
+	token      string
+	clusterID  string
+	interval   time.Duration
+	httpClient *http.Client
+	dialer     *websocket.Dialer
+
+	mu      sync.Mutex
+	samples map[string][]Sample
+	start   time.Time
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewProber is a constructor that creates a prober authenticated as the client. The downstream cluster proxy is probed
+// through clusterID.
+func NewProber(client *rancher.Client, clusterID string, interval, requestTimeout time.Duration) *Prober {
+	tlsConfig := &tls.Config{InsecureSkipVerify: client.Management.Opts.Insecure}
+
+	return &Prober{
+		host:      client.RancherConfig.Host,
+		token:     client.Management.Opts.TokenKey,
+		clusterID: clusterID,
+		interval:  interval,
+		httpClient: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		dialer: &websocket.Dialer{
+			TLSClientConfig:  tlsConfig,
+			HandshakeTimeout: requestTimeout,
+		},
+		samples: map[string][]Sample{},
+	}
+}
+
+// Start is a helper function that starts probing every endpoint in the background until Stop is called.
+func (p *Prober) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.start = time.Now()
+
+	endpoints := map[string]func() (int, error){
+		V3Endpoint:           func() (int, error) { return p.get(v3Path) },
+		SteveListEndpoint:    func() (int, error) { return p.get(steveListPath) },
+		ClusterProxyEndpoint: func() (int, error) { return p.get(fmt.Sprintf(clusterProxyPath, p.clusterID)) },
+		WatchEndpoint:        p.watch,
+	}
+
+	for name, probe := range endpoints {
+		p.wg.Add(1)
+		go p.run(ctx, name, probe)
+	}
+}
+
+// Stop is a helper function that stops probing and returns the report of every request made since Start.
+func (p *Prober) Stop() *Report {
+	p.cancel()
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return newReport(p.start, time.Now(), p.samples)
+}
+
+// run is a private helper function that probes a single endpoint every interval until the context is done.
+func (p *Prober) run(ctx context.Context, name string, probe func() (int, error)) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		start := time.Now()
+		statusCode, err := probe()
+		sample := Sample{
+			Time:      start,
+			Latency:   time.Since(start),
+			Succeeded: err == nil,
+		}
+
+		if err != nil {
+			sample.ErrorCode = errorCode(statusCode, err)
+		}
+
+		p.mu.Lock()
+		p.samples[name] = append(p.samples[name], sample)
+		p.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// get is a private helper function that makes an authenticated GET request to the Rancher API and errors on any
+// status other than 200.
+func (p *Prober) get(path string) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, "https://"+p.host+path, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("%s returned %d", path, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// watch is a private helper function that opens a Steve websocket, subscribes to clusters and waits for the watch to
+// start.
+func (p *Prober) watch() (int, error) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+p.token)
+
+	conn, resp, err := p.dialer.Dial("wss://"+p.host+subscribePath, header)
+	if err != nil {
+		if resp != nil {
+			return resp.StatusCode, err
+		}
+
+		return 0, err
+	}
+	defer conn.Close()
+
+	err = conn.WriteJSON(map[string]string{"resourceType": watchResourceType})
+	if err != nil {
+		return 0, err
+	}
+
+	err = conn.SetReadDeadline(time.Now().Add(p.httpClient.Timeout))
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+
+		event := struct {
+			Name string `json:"name"`
+		}{}
+		err = json.Unmarshal(message, &event)
+		if err != nil {
+			return 0, err
+		}
+
+		if event.Name == watchStartEvent {
+			return http.StatusSwitchingProtocols, nil
+		}
+	}
+}
+
+// errorCode is a private helper function that classifies a failed request by its status code, or by whether it timed
+// out when there was no response.
+func errorCode(statusCode int, err error) string {
+	if statusCode != 0 && statusCode != http.StatusOK && statusCode != http.StatusSwitchingProtocols {
+		return strconv.Itoa(statusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return timeoutErrorCode
+	}
+
+	return transportErrorCode
+}
@@ -0,0 +1,146 @@
+package apiavailability
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+// DowntimeWindow is a period an endpoint kept failing, from its first failed request to its next successful one.
+type DowntimeWindow struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration time.Duration `json:"duration"`
+	Requests int           `json:"requests"`
+}
+
+// EndpointReport is the availability of a single endpoint during the rollout. The p99 latency only counts successful
+// requests, failed requests are reported through the downtime windows and error codes instead.
+type EndpointReport struct {
+	Requests        int              `json:"requests"`
+	Failures        int              `json:"failures"`
+	ErrorCodes      map[string]int   `json:"errorCodes"`
+	DowntimeWindows []DowntimeWindow `json:"downtimeWindows"`
+	TotalDowntime   time.Duration    `json:"totalDowntime"`
+	LongestDowntime time.Duration    `json:"longestDowntime"`
+	P99Latency      time.Duration    `json:"p99Latency"`
+}
+
+// Report is the availability of every probed endpoint during the rollout, keyed by endpoint name.
+type Report struct {
+	Start     time.Time                  `json:"start"`
+	End       time.Time                  `json:"end"`
+	Endpoints map[string]*EndpointReport `json:"endpoints"`
+}
+
+// newReport is a private constructor that builds the report from the samples of every endpoint. A window still open
+// when probing stopped ends at the stop time.
+func newReport(start, end time.Time, samples map[string][]Sample) *Report {
+	report := &Report{
+		Start:     start,
+		End:       end,
+		Endpoints: map[string]*EndpointReport{},
+	}
+
+	for name, endpointSamples := range samples {
+		endpointReport := &EndpointReport{
+			Requests:   len(endpointSamples),
+			ErrorCodes: map[string]int{},
+		}
+
+		var latencies []time.Duration
+		var window *DowntimeWindow
+		for _, sample := range endpointSamples {
+			if sample.Succeeded {
+				latencies = append(latencies, sample.Latency)
+				if window != nil {
+					window.End = sample.Time
+					endpointReport.addWindow(window)
+					window = nil
+				}
+
+				continue
+			}
+
+			endpointReport.Failures++
+			endpointReport.ErrorCodes[sample.ErrorCode]++
+			if window == nil {
+				window = &DowntimeWindow{Start: sample.Time}
+			}
+
+			window.Requests++
+		}
+
+		if window != nil {
+			window.End = end
+			endpointReport.addWindow(window)
+		}
+
+		endpointReport.P99Latency = percentile(latencies, 0.99)
+		report.Endpoints[name] = endpointReport
+	}
+
+	return report
+}
+
+// addWindow is a private helper function that adds a closed downtime window to the endpoint totals.
+func (e *EndpointReport) addWindow(window *DowntimeWindow) {
+	window.Duration = window.End.Sub(window.Start)
+	e.DowntimeWindows = append(e.DowntimeWindows, *window)
+	e.TotalDowntime += window.Duration
+
+	if window.Duration > e.LongestDowntime {
+		e.LongestDowntime = window.Duration
+	}
+}
+
+// VerifyDowntimeBudget is a helper function that errors for every endpoint that was down longer than the budget in
+// total during the rollout, or that was never probed.
+func (r *Report) VerifyDowntimeBudget(budget time.Duration) error {
+	var budgetErrors []error
+	for _, name := range []string{V3Endpoint, SteveListEndpoint, ClusterProxyEndpoint, WatchEndpoint} {
+		endpointReport, ok := r.Endpoints[name]
+		if !ok || endpointReport.Requests == 0 {
+			budgetErrors = append(budgetErrors, fmt.Errorf("endpoint %s was not probed", name))
+			continue
+		}
+
+		if endpointReport.TotalDowntime > budget {
+			budgetErrors = append(budgetErrors, fmt.Errorf("endpoint %s was down for %s over %d windows, budget is %s, errors %v",
+				name, endpointReport.TotalDowntime, len(endpointReport.DowntimeWindows), budget, endpointReport.ErrorCodes))
+		}
+	}
+
+	return errors.Join(budgetErrors...)
+}
+
+// Write is a helper function that writes the report as JSON, so it can be archived with the test results.
+func (r *Report) Write(filePath string) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, content, 0644)
+}
+
+// percentile is a private helper function that returns the nearest-rank percentile of the latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	index := int(math.Ceil(float64(len(sorted))*p)) - 1
+	if index < 0 {
+		index = 0
+	}
+
+	return sorted[index]
+}
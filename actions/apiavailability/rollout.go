@@ -0,0 +1,87 @@
+package apiavailability
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	shepherdhelm "github.com/rancher/shepherd/clients/helm"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/tests/actions/kubeapi/helm"
+	"github.com/sirupsen/logrus"
+)
+
+const rollbackResultsSuffix = "-rollback"
+
+// MeasureUpgrade is a helper function that upgrades the Rancher release to the configured chart version while probing
+// the Rancher API, and returns the availability report of the rollout.
+func MeasureUpgrade(client *rancher.Client, cfg *Config) (*Report, error) {
+	if cfg.ChartRepoName != "" && cfg.ChartRepoURL != "" {
+		err := shepherdhelm.AddHelmRepo(cfg.ChartRepoName, cfg.ChartRepoURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return measureRollout(client, cfg, cfg.ResultsFile, func() error {
+		logrus.Infof("Upgrading release %s/%s to %s %s", cfg.Namespace, cfg.ReleaseName, cfg.ChartRepo, cfg.ChartVersion)
+		return helm.UpgradeRancher(cfg.ReleaseName, cfg.ChartRepo, cfg.Namespace, cfg.ChartVersion, cfg.HelmArgs...)
+	})
+}
+
+// MeasureRollback is a helper function that rolls the Rancher release back to the configured revision while probing
+// the Rancher API, and returns the availability report of the rollout. The report is written next to the results file
+// with a -rollback suffix, so it does not overwrite the report of the upgrade.
+func MeasureRollback(client *rancher.Client, cfg *Config) (*Report, error) {
+	var resultsFile string
+	if cfg.ResultsFile != "" {
+		extension := filepath.Ext(cfg.ResultsFile)
+		resultsFile = strings.TrimSuffix(cfg.ResultsFile, extension) + rollbackResultsSuffix + extension
+	}
+
+	return measureRollout(client, cfg, resultsFile, func() error {
+		logrus.Infof("Rolling back release %s/%s to revision %d", cfg.Namespace, cfg.ReleaseName, cfg.RollbackRevision)
+		return helm.RollbackRancher(cfg.ReleaseName, cfg.Namespace, cfg.RollbackRevision)
+	})
+}
+
+// measureRollout is a private helper function that probes the Rancher API from before the rollout until the settle
+// time after it. The report is written to the results file when one is given, even if the rollout failed.
+func measureRollout(client *rancher.Client, cfg *Config, resultsFile string, rollout func() error) (*Report, error) {
+	cfg.SetDefaults()
+
+	clusterID := cfg.ClusterID
+	if clusterID == "" {
+		var err error
+		clusterID, err = clusters.GetClusterIDByName(client, client.RancherConfig.ClusterName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	prober := NewProber(client, clusterID, time.Duration(cfg.IntervalMilliseconds)*time.Millisecond, time.Duration(cfg.RequestTimeoutSeconds)*time.Second)
+	prober.Start()
+
+	rolloutErr := rollout()
+	if rolloutErr == nil {
+		logrus.Infof("Rollout done, probing for another %d seconds", cfg.SettleSeconds)
+		time.Sleep(time.Duration(cfg.SettleSeconds) * time.Second)
+	}
+
+	report := prober.Stop()
+	for name, endpointReport := range report.Endpoints {
+		logrus.Infof("Endpoint %s: %d requests, %d failed, down for %s over %d windows, p99 latency %s, errors %v", name,
+			endpointReport.Requests, endpointReport.Failures, endpointReport.TotalDowntime, len(endpointReport.DowntimeWindows),
+			endpointReport.P99Latency, endpointReport.ErrorCodes)
+	}
+
+	if resultsFile != "" {
+		err := report.Write(resultsFile)
+		if err != nil {
+			logrus.Warnf("Failed to write availability report to %s: %v", resultsFile, err)
+		}
+	}
+
+	return report, rolloutErr
+}
@@ -0,0 +1,172 @@
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	readyCondition = "Ready"
+	conditionTrue  = "True"
+)
+
+var (
+	BackupGroupVersionResource = schema.GroupVersionResource{
+		Group:    "resources.cattle.io",
+		Version:  "v1",
+		Resource: "backups",
+	}
+	RestoreGroupVersionResource = schema.GroupVersionResource{
+		Group:    "resources.cattle.io",
+		Version:  "v1",
+		Resource: "restores",
+	}
+)
+
+// LocalClusterConfig is a helper function that returns the rest config of the local cluster from KUBECONFIG, the same
+// kubeconfig the helm CLI uses. Rancher is scaled down during a rollback, so the local cluster can not be reached
+// through the Rancher proxy.
+func LocalClusterConfig() (*rest.Config, error) {
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(clientcmd.NewDefaultClientConfigLoadingRules(), &clientcmd.ConfigOverrides{})
+
+	return clientConfig.ClientConfig()
+}
+
+// CreateBackup is a helper function that takes a one-time rancher-backup of the resource set to the default storage
+// location, waits for it to complete and returns the backup filename.
+func CreateBackup(restConfig *rest.Config, resourceSetName, encryptionConfigSecretName string) (string, error) {
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return "", err
+	}
+
+	spec := map[string]interface{}{
+		"resourceSetName": resourceSetName,
+	}
+
+	if encryptionConfigSecretName != "" {
+		spec["encryptionConfigSecretName"] = encryptionConfigSecretName
+	}
+
+	backup := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": BackupGroupVersionResource.GroupVersion().String(),
+		"kind":       "Backup",
+		"metadata": map[string]interface{}{
+			"name": namegen.AppendRandomString("backup"),
+		},
+		"spec": spec,
+	}}
+
+	logrus.Infof("Creating backup %s of resource set %s", backup.GetName(), resourceSetName)
+	backup, err = dynamicClient.Resource(BackupGroupVersionResource).Create(context.TODO(), backup, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	backup, err = waitForReady(dynamicClient, BackupGroupVersionResource, backup.GetName(), defaults.TenMinuteTimeout)
+	if err != nil {
+		return "", err
+	}
+
+	filename, _, err := unstructured.NestedString(backup.Object, "status", "filename")
+	if err != nil {
+		return "", err
+	} else if filename == "" {
+		return "", fmt.Errorf("backup %s is ready without a filename", backup.GetName())
+	}
+
+	return filename, nil
+}
+
+// CreateRestore is a helper function that restores a rancher-backup from the default storage location and waits for
+// the restore to complete. Prune deletes the resources of the resource set that are not in the backup.
+func CreateRestore(restConfig *rest.Config, backupFilename, encryptionConfigSecretName string, prune bool) error {
+	spec := map[string]interface{}{
+		"backupFilename": backupFilename,
+		"prune":          prune,
+	}
+
+	if encryptionConfigSecretName != "" {
+		spec["encryptionConfigSecretName"] = encryptionConfigSecretName
+	}
+
+	return createRestore(restConfig, spec)
+}
+
+// createRestore is a private helper function that creates a restore with the given spec and waits for it to
+// complete.
+func createRestore(restConfig *rest.Config, spec map[string]interface{}) error {
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	restore := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": RestoreGroupVersionResource.GroupVersion().String(),
+		"kind":       "Restore",
+		"metadata": map[string]interface{}{
+			"name": namegen.AppendRandomString("restore"),
+		},
+		"spec": spec,
+	}}
+
+	logrus.Infof("Creating restore %s of backup %s with prune %t", restore.GetName(), spec["backupFilename"], spec["prune"])
+	restore, err = dynamicClient.Resource(RestoreGroupVersionResource).Create(context.TODO(), restore, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = waitForReady(dynamicClient, RestoreGroupVersionResource, restore.GetName(), defaults.ThirtyMinuteTimeout)
+
+	return err
+}
+
+// waitForReady is a private helper function that waits for a cluster-scoped rancher-backup object to have a true
+// Ready condition, and returns the object.
+func waitForReady(dynamicClient dynamic.Interface, groupVersionResource schema.GroupVersionResource, name string, timeout time.Duration) (*unstructured.Unstructured, error) {
+	var object *unstructured.Unstructured
+	var message string
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, timeout, true, func(ctx context.Context) (done bool, err error) {
+		object, err = dynamicClient.Resource(groupVersionResource).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		conditions, _, err := unstructured.NestedSlice(object.Object, "status", "conditions")
+		if err != nil {
+			return false, err
+		}
+
+		for _, condition := range conditions {
+			conditionMap, ok := condition.(map[string]interface{})
+			if !ok || conditionMap["type"] != readyCondition {
+				continue
+			}
+
+			message, _ = conditionMap["message"].(string)
+			if conditionMap["status"] == conditionTrue {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("%s %s is not ready: %s", groupVersionResource.Resource, name, message), err)
+	}
+
+	return object, nil
+}
@@ -0,0 +1,75 @@
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// ClusterState is the state of a downstream cluster compared before the upgrade and after the rollback.
+type ClusterState struct {
+	Name              string
+	State             string
+	KubernetesVersion string
+}
+
+// CaptureClusterStates is a helper function that returns the state of every downstream cluster, keyed by cluster ID.
+func CaptureClusterStates(client *rancher.Client) (map[string]ClusterState, error) {
+	clusterList, err := client.Management.Cluster.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[string]ClusterState{}
+	for _, cluster := range clusterList.Data {
+		if cluster.ID == localClusterID {
+			continue
+		}
+
+		state := ClusterState{
+			Name:  cluster.Name,
+			State: cluster.State,
+		}
+
+		if cluster.Version != nil {
+			state.KubernetesVersion = cluster.Version.GitVersion
+		}
+
+		states[cluster.ID] = state
+	}
+
+	return states, nil
+}
+
+// VerifyClusterStates is a helper function that waits for every downstream cluster to be back to its captured state,
+// as the cluster agents reconnect after a rollback. Clusters that are not captured are not checked.
+func VerifyClusterStates(client *rancher.Client, states map[string]ClusterState) error {
+	var mismatches []error
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.FifteenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		currentStates, err := CaptureClusterStates(client)
+		if err != nil {
+			return false, nil
+		}
+
+		mismatches = nil
+		for clusterID, state := range states {
+			currentState, ok := currentStates[clusterID]
+			if !ok {
+				mismatches = append(mismatches, fmt.Errorf("cluster %s (%s) is gone", state.Name, clusterID))
+			} else if currentState != state {
+				mismatches = append(mismatches, fmt.Errorf("cluster %s (%s) is %+v, expected %+v", state.Name, clusterID, currentState, state))
+			}
+		}
+
+		return len(mismatches) == 0, nil
+	})
+	if err != nil {
+		return errors.Join(append(mismatches, err)...)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package backuprestore
+
+const (
+	ConfigurationFileKey          = "rancherRollbackInput"
+	MigrationConfigurationFileKey = "rancherMigrationInput"
+
+	defaultReleaseName     = "rancher"
+	defaultNamespace       = "cattle-system"
+	defaultResourceSetName = "rancher-resource-set-full"
+	basicResourceSetName   = "rancher-resource-set-basic"
+)
+
+// Config is the configuration of a Rancher upgrade rolled back with rancher-backup.
+//
+// ChartRepo is the repo/chart reference the upgrade is installed from, e.g. rancher-latest/rancher, and ChartRepoURL
+// the URL the repo is added from. ChartVersion is the version to upgrade to. HelmArgs are passed as is to helm
+// upgrade, and the current values are always reused. ResourceSetName is the rancher-backup ResourceSet the backup is
+// taken with; the backup is stored in the default storage location of the rancher-backup chart.
+type Config struct {
+	ReleaseName                string   `json:"releaseName" yaml:"releaseName"`
+	Namespace                  string   `json:"namespace" yaml:"namespace"`
+	ChartRepoName              string   `json:"chartRepoName" yaml:"chartRepoName"`
+	ChartRepoURL               string   `json:"chartRepoURL" yaml:"chartRepoURL"`
+	ChartRepo                  string   `json:"chartRepo" yaml:"chartRepo"`
+	ChartVersion               string   `json:"chartVersion" yaml:"chartVersion"`
+	HelmArgs                   []string `json:"helmArgs" yaml:"helmArgs"`
+	ResourceSetName            string   `json:"resourceSetName" yaml:"resourceSetName"`
+	EncryptionConfigSecretName string   `json:"encryptionConfigSecretName" yaml:"encryptionConfigSecretName"`
+}
+
+// SetDefaults is a helper function that fills in the optional fields of the config left empty.
+func (c *Config) SetDefaults() {
+	if c.ReleaseName == "" {
+		c.ReleaseName = defaultReleaseName
+	}
+
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+
+	if c.ResourceSetName == "" {
+		c.ResourceSetName = defaultResourceSetName
+	}
+}
+
+// MigrationConfig is the configuration of a Rancher migration to a fresh cluster with rancher-backup.
+//
+// TargetKubeconfig is the kubeconfig of the fresh cluster. Hostname is the hostname of the migrated Rancher, the same
+// one as the original. TargetHost is the address the new Rancher is reached on by the test until DNS is switched,
+// defaulting to Hostname. ResourceSetName defaults to rancher-resource-set-full for encrypted backups, which hold every
+// secret, and to rancher-resource-set-basic otherwise. DNSUpdateCommand is run with sh once Rancher is installed, to point Hostname to the fresh
+// cluster so the downstream agents reconnect to it.
+type MigrationConfig struct {
+	TargetKubeconfig   string `json:"targetKubeconfig" yaml:"targetKubeconfig"`
+	Hostname           string `json:"hostname" yaml:"hostname"`
+	TargetHost         string `json:"targetHost" yaml:"targetHost"`
+	RancherVersion     string `json:"rancherVersion" yaml:"rancherVersion"`
+	BackupChartVersion string `json:"backupChartVersion" yaml:"backupChartVersion"`
+	ResourceSetName    string `json:"resourceSetName" yaml:"resourceSetName"`
+	EncryptBackup      bool   `json:"encryptBackup" yaml:"encryptBackup"`
+	DNSUpdateCommand   string `json:"dnsUpdateCommand" yaml:"dnsUpdateCommand"`
+}
+
+// SetDefaults is a helper function that fills in the optional fields of the migration config left empty.
+func (c *MigrationConfig) SetDefaults() {
+	if c.ResourceSetName != "" {
+		return
+	}
+
+	c.ResourceSetName = basicResourceSetName
+	if c.EncryptBackup {
+		c.ResourceSetName = defaultResourceSetName
+	}
+}
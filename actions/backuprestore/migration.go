@@ -0,0 +1,229 @@
+package backuprestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	shepherdhelm "github.com/rancher/shepherd/clients/helm"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/kubeapi/helm"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	BackupNamespace                 = "cattle-resources-system"
+	encryptionConfigSecretKey       = "encryption-provider-config.yaml"
+	backupChartsRepoName            = "rancher-charts"
+	backupChartsRepoURL             = "https://charts.rancher.io"
+	backupCRDChartName              = "rancher-backup-crd"
+	backupChartName                 = "rancher-backup"
+	s3AccessKey                     = "accessKey"
+	s3SecretKey                     = "secretKey"
+	encryptionConfigurationTemplate = `apiVersion: apiserver.config.k8s.io/v1
+kind: EncryptionConfiguration
+resources:
+  - resources:
+      - "*.*"
+    providers:
+      - aescbc:
+          keys:
+            - name: key1
+              secret: %s
+`
+)
+
+// S3Location is the S3 bucket the backup is stored in, restored from on the fresh cluster. EndpointCA is the PEM
+// encoded CA certificate of a self-signed endpoint, e.g. a MinIO server, and InsecureTLSSkipVerify skips its
+// verification instead.
+type S3Location struct {
+	BucketName            string
+	Folder                string
+	Region                string
+	Endpoint              string
+	EndpointCA            string
+	InsecureTLSSkipVerify bool
+	AccessKey             string
+	SecretKey             string
+}
+
+// TargetClusterConfig is a helper function that returns the rest config of the fresh cluster from its kubeconfig.
+func TargetClusterConfig(cfg *MigrationConfig) (*rest.Config, error) {
+	return clientcmd.BuildConfigFromFlags("", cfg.TargetKubeconfig)
+}
+
+// NewEncryptionConfig is a helper function that returns an EncryptionConfiguration with a random aescbc key, to
+// encrypt a backup with.
+func NewEncryptionConfig() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(fmt.Sprintf(encryptionConfigurationTemplate, base64.StdEncoding.EncodeToString(key))), nil
+}
+
+// CreateEncryptionConfigSecret is a helper function that creates the secret rancher-backup reads an
+// EncryptionConfiguration from. The same secret must exist on both clusters to restore an encrypted backup.
+func CreateEncryptionConfigSecret(restConfig *rest.Config, name string, encryptionConfig []byte) error {
+	return createSecret(restConfig, name, map[string][]byte{encryptionConfigSecretKey: encryptionConfig})
+}
+
+// MigrateRancher is a helper function that follows the documented Rancher migration procedure on a fresh cluster:
+// rancher-backup is installed, the backup is restored from S3 without prune, then cert-manager and Rancher are
+// installed with the original hostname. An empty encryptionConfig restores an unencrypted backup.
+//
+// The helm CLI installs to the fresh cluster through its kubeconfig. The charts are installed with a session that is
+// never cleaned up, as their cleanup would uninstall from the original cluster.
+func MigrateRancher(targetRestConfig *rest.Config, cfg *MigrationConfig, backupFilename string, location *S3Location, encryptionConfig []byte) error {
+	installSession := session.NewSession()
+
+	err := shepherdhelm.AddHelmRepo(backupChartsRepoName, backupChartsRepoURL)
+	if err != nil {
+		return err
+	}
+
+	kubeconfigArgs := []string{"--kubeconfig", cfg.TargetKubeconfig}
+
+	logrus.Info("Installing rancher-backup on the target cluster")
+	err = shepherdhelm.InstallChart(installSession, backupCRDChartName, backupChartsRepoName+"/"+backupCRDChartName, BackupNamespace, cfg.BackupChartVersion, append(kubeconfigArgs, "--create-namespace")...)
+	if err != nil {
+		return err
+	}
+
+	err = shepherdhelm.InstallChart(installSession, backupChartName, backupChartsRepoName+"/"+backupChartName, BackupNamespace, cfg.BackupChartVersion, kubeconfigArgs...)
+	if err != nil {
+		return err
+	}
+
+	credentialSecretName := namegen.AppendRandomString("migration-s3")
+	err = createSecret(targetRestConfig, credentialSecretName, map[string][]byte{
+		s3AccessKey: []byte(location.AccessKey),
+		s3SecretKey: []byte(location.SecretKey),
+	})
+	if err != nil {
+		return err
+	}
+
+	s3 := map[string]interface{}{
+		"credentialSecretName":      credentialSecretName,
+		"credentialSecretNamespace": BackupNamespace,
+		"bucketName":                location.BucketName,
+		"folder":                    location.Folder,
+		"region":                    location.Region,
+		"endpoint":                  location.Endpoint,
+		"insecureTLSSkipVerify":     location.InsecureTLSSkipVerify,
+	}
+
+	// rancher-backup reads the endpoint CA as a base64 string
+	if location.EndpointCA != "" {
+		s3["endpointCA"] = base64.StdEncoding.EncodeToString([]byte(location.EndpointCA))
+	}
+
+	spec := map[string]interface{}{
+		"backupFilename": backupFilename,
+		"prune":          false,
+		"storageLocation": map[string]interface{}{
+			"s3": s3,
+		},
+	}
+
+	if len(encryptionConfig) > 0 {
+		encryptionConfigSecretName := namegen.AppendRandomString("migration-encryption")
+		err = CreateEncryptionConfigSecret(targetRestConfig, encryptionConfigSecretName, encryptionConfig)
+		if err != nil {
+			return err
+		}
+
+		spec["encryptionConfigSecretName"] = encryptionConfigSecretName
+	}
+
+	err = createRestore(targetRestConfig, spec)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Installing Rancher %s on the target cluster with hostname %s", cfg.RancherVersion, cfg.Hostname)
+	installArgs := []string{"--set", "hostname=" + cfg.Hostname}
+	if cfg.RancherVersion != "" {
+		installArgs = append(installArgs, "--version", cfg.RancherVersion)
+	}
+
+	err = helm.InstallRancherToKubeconfig(installSession, cfg.TargetKubeconfig, installArgs...)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DNSUpdateCommand != "" {
+		logrus.Infof("Pointing %s to the target cluster", cfg.Hostname)
+		msg, err := exec.Command("sh", "-c", cfg.DNSUpdateCommand).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("updating DNS: %w: %s", err, string(msg))
+		}
+	}
+
+	return nil
+}
+
+// NewTargetClient is a helper function that returns a client of the migrated Rancher server, reached on TargetHost,
+// once it answers. The client token must be in the backup to be valid on the migrated server.
+func NewTargetClient(client *rancher.Client, cfg *MigrationConfig) (*rancher.Client, error) {
+	rancherConfig := *client.RancherConfig
+	rancherConfig.Host = cfg.TargetHost
+	if rancherConfig.Host == "" {
+		rancherConfig.Host = cfg.Hostname
+	}
+
+	var targetClient *rancher.Client
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.FifteenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		targetClient, err = rancher.NewClientForConfig(client.Management.Opts.TokenKey, &rancherConfig, client.Session)
+		if err != nil {
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("rancher on %s did not come up after the migration", rancherConfig.Host), err)
+	}
+
+	return targetClient, nil
+}
+
+// createSecret is a private helper function that creates an opaque secret in the rancher-backup namespace.
+func createSecret(restConfig *rest.Config, name string, data map[string][]byte) error {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: BackupNamespace,
+		},
+		Data: data,
+		Type: corev1.SecretTypeOpaque,
+	}
+
+	_, err = clientset.CoreV1().Secrets(BackupNamespace).Create(context.TODO(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		_, err = clientset.CoreV1().Secrets(BackupNamespace).Update(context.TODO(), secret, metav1.UpdateOptions{})
+	}
+
+	return err
+}
@@ -0,0 +1,123 @@
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// MigrationState is the Rancher state captured on the original server, by resource ID, and verified on the migrated
+// one.
+type MigrationState struct {
+	Users                       []string
+	Projects                    []string
+	ClusterRoleTemplateBindings []string
+	ProjectRoleTemplateBindings []string
+	CloudCredentials            []string
+	Clusters                    map[string]ClusterState
+}
+
+// CaptureMigrationState is a helper function that captures the users, projects, role template bindings, cloud
+// credentials and downstream clusters of the Rancher server.
+func CaptureMigrationState(client *rancher.Client) (*MigrationState, error) {
+	state := &MigrationState{}
+
+	userList, err := client.Management.User.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, user := range userList.Data {
+		state.Users = append(state.Users, user.ID)
+	}
+
+	projectList, err := client.Management.Project.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, project := range projectList.Data {
+		state.Projects = append(state.Projects, project.ID)
+	}
+
+	crtbList, err := client.Management.ClusterRoleTemplateBinding.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, crtb := range crtbList.Data {
+		state.ClusterRoleTemplateBindings = append(state.ClusterRoleTemplateBindings, crtb.ID)
+	}
+
+	prtbList, err := client.Management.ProjectRoleTemplateBinding.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prtb := range prtbList.Data {
+		state.ProjectRoleTemplateBindings = append(state.ProjectRoleTemplateBindings, prtb.ID)
+	}
+
+	cloudCredentialList, err := client.Management.CloudCredential.ListAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cloudCredential := range cloudCredentialList.Data {
+		state.CloudCredentials = append(state.CloudCredentials, cloudCredential.ID)
+	}
+
+	state.Clusters, err = CaptureClusterStates(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// VerifyMigrationState is a helper function that waits for every captured resource to exist on the migrated Rancher
+// server and for every downstream cluster agent to reconnect to it. Resources that are not captured are not checked.
+func VerifyMigrationState(client *rancher.Client, state *MigrationState) error {
+	var missing []error
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		current, err := CaptureMigrationState(client)
+		if err != nil {
+			return false, nil
+		}
+
+		missing = nil
+		missing = append(missing, missingIDs("user", state.Users, current.Users)...)
+		missing = append(missing, missingIDs("project", state.Projects, current.Projects)...)
+		missing = append(missing, missingIDs("cluster role template binding", state.ClusterRoleTemplateBindings, current.ClusterRoleTemplateBindings)...)
+		missing = append(missing, missingIDs("project role template binding", state.ProjectRoleTemplateBindings, current.ProjectRoleTemplateBindings)...)
+		missing = append(missing, missingIDs("cloud credential", state.CloudCredentials, current.CloudCredentials)...)
+
+		return len(missing) == 0, nil
+	})
+	if err != nil {
+		return errors.Join(append(missing, err)...)
+	}
+
+	return VerifyClusterStates(client, state.Clusters)
+}
+
+// missingIDs is a private helper function that returns an error for every expected ID that is not in the current IDs.
+func missingIDs(resource string, expected, current []string) []error {
+	currentIDs := map[string]bool{}
+	for _, id := range current {
+		currentIDs[id] = true
+	}
+
+	var missing []error
+	for _, id := range expected {
+		if !currentIDs[id] {
+			missing = append(missing, fmt.Errorf("%s %s is missing", resource, id))
+		}
+	}
+
+	return missing
+}
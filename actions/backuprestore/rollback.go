@@ -0,0 +1,117 @@
+package backuprestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	shepherdhelm "github.com/rancher/shepherd/clients/helm"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/tests/actions/kubeapi/helm"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	localClusterID = "local"
+	rancherLabel   = "app=rancher"
+)
+
+// UpgradeRancher is a helper function that upgrades the Rancher release to the configured chart version.
+func UpgradeRancher(cfg *Config) error {
+	cfg.SetDefaults()
+
+	if cfg.ChartRepoName != "" && cfg.ChartRepoURL != "" {
+		err := shepherdhelm.AddHelmRepo(cfg.ChartRepoName, cfg.ChartRepoURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("Upgrading release %s/%s to %s %s", cfg.Namespace, cfg.ReleaseName, cfg.ChartRepo, cfg.ChartVersion)
+
+	return helm.UpgradeRancher(cfg.ReleaseName, cfg.ChartRepo, cfg.Namespace, cfg.ChartVersion, cfg.HelmArgs...)
+}
+
+// RollbackRancher is a helper function that follows the documented Rancher rollback procedure: Rancher is scaled
+// down, the backup taken before the upgrade is restored with prune and the release is rolled back to its previous
+// chart version. It returns once Rancher answers again.
+func RollbackRancher(client *rancher.Client, restConfig *rest.Config, cfg *Config, backupFilename string) error {
+	cfg.SetDefaults()
+
+	err := ScaleRancher(restConfig, cfg, 0)
+	if err != nil {
+		return err
+	}
+
+	err = CreateRestore(restConfig, backupFilename, cfg.EncryptionConfigSecretName, true)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Rolling back release %s/%s to its previous revision", cfg.Namespace, cfg.ReleaseName)
+	err = helm.RollbackRancher(cfg.ReleaseName, cfg.Namespace, 0)
+	if err != nil {
+		return err
+	}
+
+	return waitForRancher(client)
+}
+
+// ScaleRancher is a helper function that scales the Rancher deployment and waits for the Rancher pods to match.
+func ScaleRancher(restConfig *rest.Config, cfg *Config, replicas int32) error {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+
+	scale, err := clientset.AppsV1().Deployments(cfg.Namespace).GetScale(context.TODO(), cfg.ReleaseName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Scaling deployment %s/%s from %d to %d replicas", cfg.Namespace, cfg.ReleaseName, scale.Spec.Replicas, replicas)
+	scale.Spec.Replicas = replicas
+	_, err = clientset.AppsV1().Deployments(cfg.Namespace).UpdateScale(context.TODO(), cfg.ReleaseName, scale, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+
+	var runningPods int
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		podList, err := clientset.CoreV1().Pods(cfg.Namespace).List(ctx, metav1.ListOptions{LabelSelector: rancherLabel})
+		if err != nil {
+			return false, nil
+		}
+
+		runningPods = len(podList.Items)
+
+		return runningPods == int(replicas), nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("deployment %s/%s has %d pods, expected %d", cfg.Namespace, cfg.ReleaseName, runningPods, replicas), err)
+	}
+
+	return nil
+}
+
+// waitForRancher is a private helper function that waits for the Rancher API to serve the local cluster again.
+func waitForRancher(client *rancher.Client) error {
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FifteenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		_, err = client.Management.Cluster.ByID(localClusterID)
+		if err != nil {
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return errors.Join(errors.New("rancher did not come back after the rollback"), err)
+	}
+
+	return nil
+}
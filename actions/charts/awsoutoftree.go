@@ -50,6 +50,11 @@ func InstallAWSOutOfTreeChart(client *rancher.Client, installOptions *InstallOpt
 		return err
 	}
 
+	err = ValidateChartInstallActionValues(catalogClient, repoName, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, repoName)
 	if err != nil {
 		return err
@@ -69,8 +74,11 @@ func InstallAWSOutOfTreeChart(client *rancher.Client, installOptions *InstallOpt
 		"node-role.kubernetes.io/controlplane": "true",
 	}
 	err = updateHelmNodeSelectors(steveclient, kubeSystemNamespace, AwsUpstreamChartName, chartNodeSelector)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return verifyChartImageSources(client, installOptions.Cluster.ID, kubeSystemNamespace, AwsUpstreamChartName, registrySetting.Value)
 }
 
 // awsChartInstallAction is a helper function that returns a chartInstallAction for aws out-of-tree chart.
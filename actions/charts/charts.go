@@ -1,7 +1,12 @@
 package charts
 
 import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
 	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/tests/actions/registries"
 )
 
 const (
@@ -70,6 +75,8 @@ type RancherBackupOpts struct {
 	Endpoint                  string
 	Folder                    string
 	Region                    string
+	EndpointCA                string
+	InsecureTLSSkipVerify     bool
 }
 
 // GetChartCaseEndpointResult is a struct that GetChartCaseEndpoint helper function returns.
@@ -78,3 +85,45 @@ type GetChartCaseEndpointResult struct {
 	Ok   bool
 	Body string
 }
+
+// VerifyChartImageSources is a helper function that checks every image of an installed chart App is pulled from the
+// system default registry or a configured private registry, for charts whose install helpers do not read the setting
+// themselves.
+func VerifyChartImageSources(client *rancher.Client, clusterID, namespace, name string) error {
+	registrySetting, err := client.Management.Setting.ByID(defaultRegistrySettingID)
+	if err != nil {
+		return err
+	}
+
+	return verifyChartImageSources(client, clusterID, namespace, name, registrySetting.Value)
+}
+
+// verifyChartImageSources is a private helper function that checks every image of a chart App is pulled from the
+// system default registry, the private registries of the chart images config or the private registries of the
+// cluster. It is a no-op when none is set, unless the config marks the run as airgapped.
+func verifyChartImageSources(client *rancher.Client, clusterID, namespace, name, defaultRegistry string) error {
+	chartImagesConfig := new(registries.ChartImagesConfig)
+	config.LoadConfig(registries.ChartImagesConfigurationFileKey, chartImagesConfig)
+
+	clusterRegistries, err := registries.ClusterRegistries(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	var allowedRegistries []string
+	for _, registry := range append(append([]string{defaultRegistry}, chartImagesConfig.Registries...), clusterRegistries...) {
+		if registry != "" {
+			allowedRegistries = append(allowedRegistries, registry)
+		}
+	}
+
+	if len(allowedRegistries) == 0 {
+		if chartImagesConfig.Airgap {
+			return fmt.Errorf("chart %s/%s cannot be checked in an airgapped run: no system default registry or private registry is set", namespace, name)
+		}
+
+		return nil
+	}
+
+	return registries.VerifyChartImageSources(client, clusterID, namespace, name, allowedRegistries...)
+}
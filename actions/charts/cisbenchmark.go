@@ -140,6 +140,11 @@ func InstallHardenedChart(client *rancher.Client, ChartInstallActionPayload *Pay
 		})
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -167,7 +172,7 @@ func InstallHardenedChart(client *rancher.Client, ChartInstallActionPayload *Pay
 		return err
 	}
 
-	return nil
+	return verifyChartImageSources(client, ChartInstallActionPayload.InstallOptions.Cluster.ID, ChartInstallActionPayload.Namespace, ChartInstallActionPayload.Name, ChartInstallActionPayload.DefaultRegistry)
 }
 
 // newCISBenchmarkChartInstallAction is a private helper function that returns chart install action with CIS benchmark and payload options.
@@ -208,6 +213,11 @@ func UpgradeCISBenchmarkChart(client *rancher.Client, installOptions *InstallOpt
 		return err
 	}
 
+	err = ValidateChartUpgradeActionValues(catalogClient, catalog.RancherChartRepo, chartUpgradeAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.UpgradeChart(chartUpgradeAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
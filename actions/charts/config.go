@@ -2,8 +2,17 @@ package charts
 
 const (
 	ConfigurationFileKey = "chartUpgrade"
+
+	// ValuesValidationConfigurationFileKey is the key of the chart values validation configuration in the config file
+	ValuesValidationConfigurationFileKey = "chartValuesValidation"
 )
 
 type Config struct {
 	IsUpgradable bool `json:"isUpgradable" yaml:"isUpgradable"`
 }
+
+// ValuesValidationConfig turns off the validation of chart values against their schema and defaults before every chart
+// install and upgrade.
+type ValuesValidationConfig struct {
+	Disabled bool `json:"disabled" yaml:"disabled"`
+}
@@ -140,6 +140,11 @@ func InstallRancherAlertingChart(client *rancher.Client, installOptions *Install
 		return nil
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -154,7 +159,7 @@ func InstallRancherAlertingChart(client *rancher.Client, installOptions *Install
 		return err
 	}
 
-	return wait.WatchWait(watchAppInterface, func(event watch.Event) (ready bool, err error) {
+	err = wait.WatchWait(watchAppInterface, func(event watch.Event) (ready bool, err error) {
 		app := event.Object.(*catalogv1.App)
 
 		state := app.Status.Summary.State
@@ -163,6 +168,11 @@ func InstallRancherAlertingChart(client *rancher.Client, installOptions *Install
 		}
 		return false, nil
 	})
+	if err != nil {
+		return err
+	}
+
+	return verifyChartImageSources(client, installOptions.Cluster.ID, RancherAlertingNamespace, RancherAlertingName, registrySetting.Value)
 }
 
 func newAlertingChartInstallAction(p *PayloadOpts, opts *RancherAlertingOpts) *types.ChartInstallAction {
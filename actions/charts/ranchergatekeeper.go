@@ -158,6 +158,11 @@ func InstallRancherGatekeeperChart(client *rancher.Client, installOptions *Insta
 		})
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -184,7 +189,13 @@ func InstallRancherGatekeeperChart(client *rancher.Client, installOptions *Insta
 	if err != nil {
 		return err
 	}
-	return nil
+
+	err = verifyChartImageSources(client, installOptions.Cluster.ID, RancherGatekeeperNamespace, RancherGatekeeperCRDName, registrySetting.Value)
+	if err != nil {
+		return err
+	}
+
+	return verifyChartImageSources(client, installOptions.Cluster.ID, RancherGatekeeperNamespace, RancherGatekeeperName, registrySetting.Value)
 }
 
 // newGatekeeperChartInstallAction is a helper function that returns an array of NewChartInstallActions for installing the gatekeeper and gatekeepr-crd charts
@@ -226,6 +237,11 @@ func UpgradeRancherGatekeeperChart(client *rancher.Client, installOptions *Insta
 		return err
 	}
 
+	err = ValidateChartUpgradeActionValues(catalogClient, catalog.RancherChartRepo, chartUpgradeAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.UpgradeChart(chartUpgradeAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -123,6 +123,11 @@ func InstallRancherIstioChart(client *rancher.Client, installOptions *InstallOpt
 		})
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -149,7 +154,8 @@ func InstallRancherIstioChart(client *rancher.Client, installOptions *InstallOpt
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return verifyChartImageSources(client, installOptions.Cluster.ID, RancherIstioNamespace, RancherIstioName, registrySetting.Value)
 }
 
 // newIstioChartInstallAction is a private helper function that returns chart install action with istio and payload options.
@@ -212,6 +218,11 @@ func UpgradeRancherIstioChart(client *rancher.Client, installOptions *InstallOpt
 		return err
 	}
 
+	err = ValidateChartUpgradeActionValues(catalogClient, catalog.RancherChartRepo, chartUpgradeAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.UpgradeChart(chartUpgradeAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -153,6 +153,11 @@ func InstallRancherLoggingChart(client *rancher.Client, installOptions *InstallO
 		})
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -179,7 +184,8 @@ func InstallRancherLoggingChart(client *rancher.Client, installOptions *InstallO
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return verifyChartImageSources(client, installOptions.Cluster.ID, RancherLoggingNamespace, RancherLoggingName, registrySetting.Value)
 }
 
 // newLoggingChartInstallAction is a private helper function that returns chart install action with logging and payload options.
@@ -161,6 +161,11 @@ func InstallRancherMonitoringChart(client *rancher.Client, installOptions *Insta
 		})
 	})
 
+	err = ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -187,7 +192,8 @@ func InstallRancherMonitoringChart(client *rancher.Client, installOptions *Insta
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return verifyChartImageSources(client, installOptions.Cluster.ID, RancherMonitoringNamespace, RancherMonitoringName, registrySetting.Value)
 }
 
 // newMonitoringChartInstallAction is a private helper function that returns chart install action with monitoring and payload options.
@@ -250,6 +256,11 @@ func UpgradeRancherMonitoringChart(client *rancher.Client, installOptions *Insta
 		return err
 	}
 
+	err = ValidateChartUpgradeActionValues(catalogClient, catalog.RancherChartRepo, chartUpgradeAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.UpgradeChart(chartUpgradeAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
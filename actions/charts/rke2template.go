@@ -70,6 +70,11 @@ func InstallTemplateChart(client *rancher.Client, repoName, templateName, cluste
 		return err
 	}
 
+	err = ValidateChartInstallActionValues(client.Catalog, repoName, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = client.Catalog.InstallChart(chartInstallAction, repoName)
 	if err != nil {
 		return err
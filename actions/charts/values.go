@@ -0,0 +1,320 @@
+package charts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	clusterReposURL      = "v1/catalog.cattle.io.clusterrepos/"
+	valuesSchemaFileName = "values.schema.json"
+	chartFileName        = "Chart.yaml"
+	subchartsDirName     = "charts"
+	// globalValuesKey holds the values Rancher injects into every chart, e.g. global.cattle.clusterId, so it is not checked against the defaults
+	globalValuesKey = "global"
+)
+
+// GetChartValuesSchema fetches the chart archive of the exact chart version from the cluster repo index and returns its
+// values.schema.json. It returns nil if the chart does not ship a schema.
+func GetChartValuesSchema(catalogClient *catalog.Client, repoName, chartName, chartVersion string) ([]byte, error) {
+	archive, err := getChartArchive(catalogClient, repoName, chartName, chartVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return archive.schema, nil
+}
+
+// chartArchive is the content of a chart archive that values are validated against.
+type chartArchive struct {
+	schema    []byte
+	subcharts []string
+}
+
+// chartDependencies is the subset of Chart.yaml listing the subcharts of a chart.
+type chartDependencies struct {
+	Dependencies []struct {
+		Name  string `yaml:"name"`
+		Alias string `yaml:"alias"`
+	} `yaml:"dependencies"`
+}
+
+// getChartArchive is a private helper function that fetches the chart archive of the exact chart version from the
+// cluster repo index.
+func getChartArchive(catalogClient *catalog.Client, repoName, chartName, chartVersion string) (*chartArchive, error) {
+	archive, err := catalogClient.RESTClient().Get().
+		AbsPath(clusterReposURL+repoName).Param("link", "chart").Param("chartName", chartName).Param("version", chartVersion).
+		Do(context.Background()).Raw()
+	if err != nil {
+		return nil, err
+	}
+
+	return readChartArchive(archive)
+}
+
+// readChartArchive is a private helper function that reads the values schema and the subchart names of a chart from
+// its gzipped tar archive. Subcharts are the dependencies of Chart.yaml, by alias when they have one, and the charts
+// vendored in the charts directory.
+func readChartArchive(archive []byte) (*chartArchive, error) {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+
+	result := &chartArchive{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// files of the chart itself live at <chart>/<file>, subcharts are vendored at <chart>/charts/<subchart>
+		parts := strings.Split(path.Clean(header.Name), "/")
+		switch {
+		case len(parts) == 2 && parts[1] == valuesSchemaFileName:
+			result.schema, err = io.ReadAll(tarReader)
+			if err != nil {
+				return nil, err
+			}
+		case len(parts) == 2 && parts[1] == chartFileName:
+			content, err := io.ReadAll(tarReader)
+			if err != nil {
+				return nil, err
+			}
+
+			dependencies := &chartDependencies{}
+			err = yaml.Unmarshal(content, dependencies)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", header.Name, err)
+			}
+
+			for _, dependency := range dependencies.Dependencies {
+				if dependency.Alias != "" {
+					result.subcharts = appendUnique(result.subcharts, dependency.Alias)
+					continue
+				}
+				result.subcharts = appendUnique(result.subcharts, dependency.Name)
+			}
+		case len(parts) == 4 && parts[1] == subchartsDirName && parts[3] == chartFileName:
+			result.subcharts = appendUnique(result.subcharts, parts[2])
+		}
+	}
+
+	sort.Strings(result.subcharts)
+
+	return result, nil
+}
+
+// ValidateChartValues validates the values of a chart against the values.schema.json and the default values.yaml of the
+// exact chart version. Schema violations and keys that are not present in the chart defaults are returned as an error.
+// The values of subcharts are only checked by the schema, as the defaults of the parent chart do not list them.
+func ValidateChartValues(catalogClient *catalog.Client, repoName, chartName, chartVersion string, values map[string]interface{}) error {
+	defaultValues, err := catalogClient.GetChartValues(repoName, chartName, chartVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get default values of %s %s: %w", chartName, chartVersion, err)
+	}
+
+	archive, err := getChartArchive(catalogClient, repoName, chartName, chartVersion)
+	if err != nil {
+		return fmt.Errorf("failed to get chart archive of %s %s: %w", chartName, chartVersion, err)
+	}
+
+	var errs []error
+	unknownKeys := UnknownValuesKeys(defaultValues, values, archive.subcharts)
+	if len(unknownKeys) > 0 {
+		errs = append(errs, fmt.Errorf("keys not present in the chart defaults: %s", strings.Join(unknownKeys, ", ")))
+	}
+
+	if archive.schema != nil {
+		// helm validates the user values coalesced with the chart defaults, so do the same
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(archive.schema), gojsonschema.NewGoLoader(mergeValues(defaultValues, values)))
+		if err != nil {
+			return fmt.Errorf("failed to validate values of %s %s: %w", chartName, chartVersion, err)
+		}
+
+		for _, schemaErr := range result.Errors() {
+			errs = append(errs, fmt.Errorf("schema violation: %s", schemaErr.String()))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid values for chart %s %s: %w", chartName, chartVersion, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// ValidateChartInstallActionValues validates the values of every chart of a chart install action before it is installed,
+// unless chart values validation is disabled in the config.
+func ValidateChartInstallActionValues(catalogClient *catalog.Client, repoName string, chartInstallAction *types.ChartInstallAction) error {
+	if valuesValidationDisabled() {
+		return nil
+	}
+
+	for _, chartInstall := range chartInstallAction.Charts {
+		err := ValidateChartValues(catalogClient, repoName, chartInstall.ChartName, chartInstall.Version, toValuesMap(chartInstall.Values))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateChartUpgradeActionValues validates the values of every chart of a chart upgrade action before it is upgraded,
+// unless chart values validation is disabled in the config.
+func ValidateChartUpgradeActionValues(catalogClient *catalog.Client, repoName string, chartUpgradeAction *types.ChartUpgradeAction) error {
+	if valuesValidationDisabled() {
+		return nil
+	}
+
+	for _, chartUpgrade := range chartUpgradeAction.Charts {
+		err := ValidateChartValues(catalogClient, repoName, chartUpgrade.ChartName, chartUpgrade.Version, toValuesMap(chartUpgrade.Values))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnknownValuesKeys returns the dotted paths of the keys in values that are not present in the chart defaults.
+// Keys under a default that is an empty map or null are free-form and are not reported, neither are the global key and
+// the keys of the given subcharts, whose defaults live in the subcharts themselves.
+func UnknownValuesKeys(defaultValues, values map[string]interface{}, subcharts []string) []string {
+	var unknownKeys []string
+	collectUnknownKeys("", defaultValues, values, subcharts, &unknownKeys)
+	sort.Strings(unknownKeys)
+
+	return unknownKeys
+}
+
+// collectUnknownKeys is a private helper function that walks values and defaults side by side.
+func collectUnknownKeys(prefix string, defaultValues, values map[string]interface{}, subcharts []string, unknownKeys *[]string) {
+	for key, value := range values {
+		if prefix == "" && (key == globalValuesKey || slices.Contains(subcharts, key)) {
+			continue
+		}
+
+		keyPath := key
+		if prefix != "" {
+			keyPath = prefix + "." + key
+		}
+
+		defaultValue, ok := defaultValues[key]
+		if !ok {
+			*unknownKeys = append(*unknownKeys, keyPath)
+			continue
+		}
+
+		defaultMap, defaultIsMap := defaultValue.(map[string]interface{})
+		valueMap, valueIsMap := toMap(value)
+		if !defaultIsMap || !valueIsMap || len(defaultMap) == 0 {
+			continue
+		}
+
+		collectUnknownKeys(keyPath, defaultMap, valueMap, nil, unknownKeys)
+	}
+}
+
+// mergeValues is a private helper function that coalesces values over the chart defaults.
+func mergeValues(defaultValues, values map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range defaultValues {
+		merged[key] = value
+	}
+
+	for key, value := range values {
+		defaultMap, defaultIsMap := merged[key].(map[string]interface{})
+		valueMap, valueIsMap := toMap(value)
+		if defaultIsMap && valueIsMap {
+			merged[key] = mergeValues(defaultMap, valueMap)
+			continue
+		}
+
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// valuesValidationDisabled is a private helper function that returns whether chart values validation is disabled in the
+// config.
+func valuesValidationDisabled() bool {
+	valuesValidationConfig := new(ValuesValidationConfig)
+	config.LoadConfig(ValuesValidationConfigurationFileKey, valuesValidationConfig)
+
+	if valuesValidationConfig.Disabled {
+		logrus.Warn("Chart values validation is disabled")
+	}
+
+	return valuesValidationConfig.Disabled
+}
+
+// appendUnique is a private helper function that appends a value to a slice unless it is already in it.
+func appendUnique(values []string, value string) []string {
+	if slices.Contains(values, value) {
+		return values
+	}
+
+	return append(values, value)
+}
+
+// toValuesMap is a private helper function that converts the typed chart values to a plain JSON map.
+func toValuesMap(values v3.MapStringInterface) map[string]interface{} {
+	valuesMap, _ := toMap(map[string]interface{}(values))
+
+	return valuesMap
+}
+
+// toMap is a private helper function that normalizes nested values such as map[string]string or structs into
+// map[string]interface{} by round tripping them through JSON.
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	if valueMap, ok := value.(map[string]interface{}); ok {
+		normalized := map[string]interface{}{}
+		for key, nested := range valueMap {
+			if nestedMap, ok := toMap(nested); ok {
+				normalized[key] = nestedMap
+				continue
+			}
+			normalized[key] = nested
+		}
+
+		return normalized, true
+	}
+
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+
+	valueMap := map[string]interface{}{}
+	if json.Unmarshal(content, &valueMap) != nil {
+		return nil, false
+	}
+
+	return valueMap, true
+}
@@ -0,0 +1,150 @@
+package charts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownValuesKeys(t *testing.T) {
+	defaultValues := map[string]interface{}{
+		"replicas": 1,
+		"image": map[string]interface{}{
+			"repository": "rancher/app",
+			"tag":        "v1",
+		},
+		"annotations":  map[string]interface{}{},
+		"nodeSelector": nil,
+	}
+
+	tests := []struct {
+		name      string
+		values    map[string]interface{}
+		subcharts []string
+		expected  []string
+	}{
+		{
+			name:     "known keys",
+			values:   map[string]interface{}{"replicas": 3, "image": map[string]interface{}{"tag": "v2"}},
+			expected: nil,
+		},
+		{
+			name:     "unknown top level and nested keys",
+			values:   map[string]interface{}{"replica": 3, "image": map[string]interface{}{"tags": "v2"}},
+			expected: []string{"image.tags", "replica"},
+		},
+		{
+			name:     "free-form defaults",
+			values:   map[string]interface{}{"annotations": map[string]interface{}{"a": "b"}, "nodeSelector": map[string]string{"os": "linux"}},
+			expected: nil,
+		},
+		{
+			name:     "global key",
+			values:   map[string]interface{}{"global": map[string]interface{}{"cattle": map[string]interface{}{"clusterId": "c-1"}}},
+			expected: nil,
+		},
+		{
+			name:      "subchart keys",
+			values:    map[string]interface{}{"grafana": map[string]interface{}{"enabled": true}, "prometheus": map[string]interface{}{"enabled": true}},
+			subcharts: []string{"grafana"},
+			expected:  []string{"prometheus"},
+		},
+		{
+			name:      "subchart names only apply to top level keys",
+			values:    map[string]interface{}{"image": map[string]interface{}{"grafana": "v1"}},
+			subcharts: []string{"grafana"},
+			expected:  []string{"image.grafana"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, UnknownValuesKeys(defaultValues, tt.values, tt.subcharts))
+		})
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	defaultValues := map[string]interface{}{
+		"replicas": 1,
+		"image": map[string]interface{}{
+			"repository": "rancher/app",
+			"tag":        "v1",
+		},
+		"tolerations": []interface{}{"a"},
+	}
+
+	values := map[string]interface{}{
+		"image":       map[string]string{"tag": "v2"},
+		"tolerations": []interface{}{"b"},
+		"extra":       true,
+	}
+
+	expected := map[string]interface{}{
+		"replicas": 1,
+		"image": map[string]interface{}{
+			"repository": "rancher/app",
+			"tag":        "v2",
+		},
+		"tolerations": []interface{}{"b"},
+		"extra":       true,
+	}
+
+	assert.Equal(t, expected, mergeValues(defaultValues, values))
+	assert.Equal(t, "v1", defaultValues["image"].(map[string]interface{})["tag"], "defaults must not be modified")
+}
+
+func TestReadChartArchive(t *testing.T) {
+	archive := newChartArchive(t, map[string]string{
+		"app/Chart.yaml":                        "name: app\ndependencies:\n- name: grafana\n- name: kube-state-metrics\n  alias: ksm\n",
+		"app/values.yaml":                       "replicas: 1\n",
+		"app/values.schema.json":                `{"type": "object"}`,
+		"app/charts/grafana/Chart.yaml":         "name: grafana\n",
+		"app/charts/grafana/values.yaml":        "enabled: true\n",
+		"app/charts/windows/Chart.yaml":         "name: windows\n",
+		"app/charts/windows/values.schema.json": `{"type": "string"}`,
+	})
+
+	result, err := readChartArchive(archive)
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"type": "object"}`, string(result.schema))
+	assert.Equal(t, []string{"grafana", "ksm", "windows"}, result.subcharts)
+}
+
+func TestReadChartArchiveWithoutSchema(t *testing.T) {
+	archive := newChartArchive(t, map[string]string{
+		"app/Chart.yaml":  "name: app\n",
+		"app/values.yaml": "replicas: 1\n",
+	})
+
+	result, err := readChartArchive(archive)
+	require.NoError(t, err)
+
+	assert.Nil(t, result.schema)
+	assert.Empty(t, result.subcharts)
+}
+
+// newChartArchive is a helper function that returns a gzipped tar archive of the given files.
+func newChartArchive(t *testing.T, files map[string]string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for name, content := range files {
+		err := tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))})
+		require.NoError(t, err)
+
+		_, err = tarWriter.Write([]byte(content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+
+	return buffer.Bytes()
+}
@@ -109,6 +109,11 @@ func InstallVsphereOutOfTreeCharts(client *rancher.Client, repoName, clusterName
 	}
 
 	logrus.Infof("executing chart install")
+	err = ValidateChartInstallActionValues(catalogClient, repoName, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, repoName)
 	if err != nil {
 		return err
@@ -141,12 +146,28 @@ func InstallVsphereOutOfTreeCharts(client *rancher.Client, repoName, clusterName
 	}
 
 	logrus.Infof("executing chart install")
+	err = ValidateChartInstallActionValues(catalogClient, repoName, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, repoName)
 	if err != nil {
 		return err
 	}
 
-	return err
+	logrus.Infof("verifying chart install")
+	err = charts.WaitChartInstall(catalogClient, kubeSystemNamespace, vsphereCSIchartName)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChartImageSources(client, cluster.ID, kubeSystemNamespace, vsphereCPIchartName, registrySetting.Value)
+	if err != nil {
+		return err
+	}
+
+	return verifyChartImageSources(client, cluster.ID, kubeSystemNamespace, vsphereCSIchartName, registrySetting.Value)
 }
 
 // vsphereCPIChartInstallAction is a helper function that returns a chartInstallAction for vsphere out-of-tree chart.
@@ -300,6 +321,11 @@ func UpgradeVsphereOutOfTreeCharts(client *rancher.Client, repoName, clusterName
 			return err
 		}
 
+		err = ValidateChartUpgradeActionValues(catalogClient, repoName, chartUpgradeAction)
+		if err != nil {
+			return err
+		}
+
 		err = catalogClient.UpgradeChart(chartUpgradeAction, repoName)
 		if err != nil {
 			return err
@@ -340,6 +366,11 @@ func UpgradeVsphereOutOfTreeCharts(client *rancher.Client, repoName, clusterName
 		}
 
 		logrus.Infof("executing chart upgrade")
+		err = ValidateChartUpgradeActionValues(catalogClient, repoName, chartUpgradeAction)
+		if err != nil {
+			return err
+		}
+
 		err = catalogClient.UpgradeChart(chartUpgradeAction, repoName)
 		if err != nil {
 			return err
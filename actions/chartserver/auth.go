@@ -0,0 +1,56 @@
+package chartserver
+
+import (
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"golang.org/x/crypto/bcrypt"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	helmSecretUsername = "username"
+	helmSecretPassword = "password"
+	helmSecretCACerts  = "cacerts"
+)
+
+// CreateHelmSecret is a helper function that creates a secret with the chart server CA certificate and, with auth, its
+// credentials in a namespace of the local cluster, e.g. fleet-default. It returns the secret name to be used as a
+// GitRepo helmSecretName, so fleet can pull charts from both the OCI registry and the Helm repo.
+func (c *ChartServer) CreateHelmSecret(namespace string) (string, error) {
+	data := map[string][]byte{
+		helmSecretCACerts: c.CACert,
+	}
+
+	if c.Username != "" {
+		data[helmSecretUsername] = []byte(c.Username)
+		data[helmSecretPassword] = []byte(c.Password)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegenerator.AppendRandomString("chartserver-helm"),
+			Namespace: namespace,
+		},
+		Data: data,
+		Type: corev1.SecretTypeOpaque,
+	}
+
+	secretResp, err := secrets.CreateSecretForCluster(c.client, secret, localClusterID, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	return secretResp.Name, nil
+}
+
+// htpasswd is a private helper function that returns an htpasswd file with a single bcrypt hashed user, the only hash
+// the OCI registry accepts.
+func htpasswd(username, password string) ([]byte, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(username + ":" + string(hash) + "\n"), nil
+}
@@ -0,0 +1,283 @@
+package chartserver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	chartFile              = "Chart.yaml"
+	indexFile              = "index.yaml"
+	helmConfigMediaType    = "application/vnd.cncf.helm.config.v1+json"
+	helmChartMediaType     = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	ociManifestMediaType   = "application/vnd.oci.image.manifest.v1+json"
+	octetStreamContentType = "application/octet-stream"
+)
+
+// chartMetadata is the subset of Chart.yaml the fixture charts use.
+type chartMetadata struct {
+	APIVersion  string `json:"apiVersion" yaml:"apiVersion"`
+	Name        string `json:"name" yaml:"name"`
+	Version     string `json:"version" yaml:"version"`
+	AppVersion  string `json:"appVersion,omitempty" yaml:"appVersion,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Type        string `json:"type,omitempty" yaml:"type,omitempty"`
+}
+
+type helmIndexEntry struct {
+	chartMetadata `yaml:",inline"`
+	Digest        string    `yaml:"digest"`
+	URLs          []string  `yaml:"urls"`
+	Created       time.Time `yaml:"created"`
+}
+
+type helmIndex struct {
+	APIVersion string                      `yaml:"apiVersion"`
+	Entries    map[string][]helmIndexEntry `yaml:"entries"`
+	Generated  time.Time                   `yaml:"generated"`
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int    `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+// PushChart is a helper function that packages a fixture chart, a directory of FixturesPath, with the given version
+// and pushes it to both the OCI registry and the Helm repo. Pushing a new version of a chart keeps the previous ones.
+func (c *ChartServer) PushChart(chartName, version string) error {
+	metadata, archive, err := packageChart(filepath.Join(c.FixturesPath, chartName), version)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Pushing chart %s %s to the OCI registry %s", metadata.Name, version, c.RegistryHost())
+	err = c.pushOCIChart(metadata, archive)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Pushing chart %s %s to the Helm repo %s", metadata.Name, version, c.HelmRepoHost())
+
+	return c.pushHelmRepoChart(metadata, archive)
+}
+
+// pushOCIChart is a private helper function that pushes a packaged chart to the OCI registry, tagged with its version.
+// The blobs are uploaded from the Helm repo pod, as the registry is only reachable in-cluster.
+func (c *ChartServer) pushOCIChart(metadata *chartMetadata, archive []byte) error {
+	config, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociManifestMediaType,
+		Config:        ociDescriptor{MediaType: helmConfigMediaType, Digest: digest(config), Size: len(config)},
+		Layers:        []ociDescriptor{{MediaType: helmChartMediaType, Digest: digest(archive), Size: len(archive)}},
+	}
+
+	encodedManifest, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	workDir := "/tmp/" + namegenerator.AppendRandomString(metadata.Name)
+	defer func() {
+		_, err := c.exec("rm -rf " + pods.ShellQuote(workDir))
+		if err != nil {
+			logrus.Warnf("Failed to remove %s from the Helm repo: %v", workDir, err)
+		}
+	}()
+
+	repository := fmt.Sprintf("https://%s/v2/%s/%s", c.RegistryHost(), OCINamespace, metadata.Name)
+	for _, blob := range [][]byte{config, archive} {
+		blobPath := workDir + "/" + strings.TrimPrefix(digest(blob), "sha256:")
+		err = c.writeFile(blobPath, blob)
+		if err != nil {
+			return err
+		}
+
+		script := fmt.Sprintf(`set -e; location=$(curl -sSfk %s -X POST -D - -o /dev/null %s | sed -n 's/^[Ll]ocation: *//p' | tr -d '\r'); `+
+			`case "$location" in /*) location="https://%s$location";; esac; `+
+			`curl -sSfk %s -X PUT -H 'Content-Type: %s' --data-binary @%s "$location&digest=%s"`,
+			c.curlAuth(), pods.ShellQuote(repository+"/blobs/uploads/"), c.RegistryHost(), c.curlAuth(), octetStreamContentType, pods.ShellQuote(blobPath), digest(blob))
+
+		_, err = c.exec(script)
+		if err != nil {
+			return fmt.Errorf("uploading blob %s of chart %s failed: %w", digest(blob), metadata.Name, err)
+		}
+	}
+
+	manifestPath := workDir + "/manifest.json"
+	err = c.writeFile(manifestPath, encodedManifest)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.exec(fmt.Sprintf("curl -sSfk %s -X PUT -H 'Content-Type: %s' --data-binary @%s %s",
+		c.curlAuth(), ociManifestMediaType, pods.ShellQuote(manifestPath), pods.ShellQuote(repository+"/manifests/"+strings.ReplaceAll(metadata.Version, "+", "_"))))
+	if err != nil {
+		return fmt.Errorf("pushing manifest of chart %s failed: %w", metadata.Name, err)
+	}
+
+	return nil
+}
+
+// pushHelmRepoChart is a private helper function that writes a packaged chart to the Helm repo and regenerates its
+// index with every chart version pushed so far.
+func (c *ChartServer) pushHelmRepoChart(metadata *chartMetadata, archive []byte) error {
+	archiveName := fmt.Sprintf("%s-%s.tgz", metadata.Name, metadata.Version)
+	err := c.writeFile(helmRepoDataPath+"/"+archiveName, archive)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	c.index.Entries[metadata.Name] = append(c.index.Entries[metadata.Name], helmIndexEntry{
+		chartMetadata: *metadata,
+		Digest:        strings.TrimPrefix(digest(archive), "sha256:"),
+		URLs:          []string{c.HelmRepoURL() + "/" + archiveName},
+		Created:       now,
+	})
+	c.index.Generated = now
+
+	index, err := yaml.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+
+	return c.writeFile(helmRepoDataPath+"/"+indexFile, index)
+}
+
+// curlAuth is a private helper function that returns the curl basic auth option of the chart server, if any.
+func (c *ChartServer) curlAuth() string {
+	if c.Username == "" {
+		return ""
+	}
+
+	return "-u " + pods.ShellQuote(c.Username+":"+c.Password)
+}
+
+// writeFile is a private helper function that writes a file inside the Helm repo pod.
+func (c *ChartServer) writeFile(filePath string, content []byte) error {
+	return pods.WriteFile(c.restConfig, c.Namespace, c.helmRepoPodName, filePath, content)
+}
+
+// packageChart is a private helper function that packages a chart directory as a gzipped tarball, the way helm package
+// does, with the version of its Chart.yaml replaced.
+func packageChart(chartPath, version string) (*chartMetadata, []byte, error) {
+	chartYAML, err := os.ReadFile(filepath.Join(chartPath, chartFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata := &chartMetadata{}
+	err = yaml.Unmarshal(chartYAML, metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata.Version = version
+	chartYAML, err = yaml.Marshal(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := map[string][]byte{chartFile: chartYAML}
+	err = filepath.WalkDir(chartPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(chartPath, filePath)
+		if err != nil {
+			return err
+		}
+
+		relativePath = filepath.ToSlash(relativePath)
+		if relativePath == chartFile {
+			return nil
+		}
+
+		files[relativePath], err = os.ReadFile(filePath)
+
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, filePath := range filePaths {
+		err = tarWriter.WriteHeader(&tar.Header{
+			Name:    metadata.Name + "/" + filePath,
+			Mode:    0644,
+			Size:    int64(len(files[filePath])),
+			ModTime: time.Now(),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		_, err = tarWriter.Write(files[filePath])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = gzipWriter.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return metadata, buffer.Bytes(), nil
+}
+
+// newHelmIndex is a private helper function that returns an empty Helm repo index.
+func newHelmIndex() *helmIndex {
+	return &helmIndex{
+		APIVersion: "v1",
+		Entries:    map[string][]helmIndexEntry{},
+	}
+}
+
+// digest is a private helper function that returns the OCI digest of a blob.
+func digest(blob []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(blob))
+}
@@ -0,0 +1,353 @@
+package chartserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	kubenamespaces "github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	actionssecrets "github.com/rancher/tests/actions/secrets"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	restclient "k8s.io/client-go/rest"
+)
+
+const (
+	// ChartServerConfigurationFileKey is the key of the optional chart server configuration in the config file
+	ChartServerConfigurationFileKey = "chartServerInput"
+
+	// DefaultRegistryImage is the OCI registry image deployed when no image is configured
+	DefaultRegistryImage = "registry:2"
+	// DefaultHelmRepoImage is the image serving the static Helm repo when no image is configured. It must ship curl, as
+	// charts are pushed to the OCI registry from inside its pod.
+	DefaultHelmRepoImage = "nginx:1.27-alpine"
+	// DefaultFixturesPath is the directory of fixture charts, relative to the suite, that charts are packaged from
+	DefaultFixturesPath = "./resources/charts"
+	// OCINamespace is the registry namespace every chart is pushed to
+	OCINamespace = "charts"
+
+	localClusterID   = "local"
+	chartServerName  = "chartserver"
+	registryName     = "registry"
+	helmRepoName     = "helmrepo"
+	chartUsername    = "fleet"
+	authRealm        = "chartserver"
+	registryPort     = 5000
+	helmRepoPort     = 8443
+	configVolume     = "config"
+	dataVolume       = "data"
+	configPath       = "/etc/chartserver"
+	registryDataPath = "/var/lib/registry"
+	helmRepoDataPath = "/usr/share/nginx/html"
+	nginxConfPath    = "/etc/nginx/conf.d/default.conf"
+	tlsCertFile      = "tls.crt"
+	tlsKeyFile       = "tls.key"
+	htpasswdFile     = "htpasswd"
+	nginxConfFile    = "default.conf"
+)
+
+// Config is the optional chart server configuration, e.g. to pull the images from a private registry in airgapped
+// environments or to package charts from another fixtures directory.
+type Config struct {
+	RegistryImage string `json:"registryImage,omitempty" yaml:"registryImage,omitempty"`
+	HelmRepoImage string `json:"helmRepoImage,omitempty" yaml:"helmRepoImage,omitempty"`
+	FixturesPath  string `json:"fixturesPath,omitempty" yaml:"fixturesPath,omitempty"`
+}
+
+// ChartServer is an OCI registry and a static Helm repo running in the local cluster, reachable by fleet through their
+// services over https. Both serve the same charts, behind basic auth when Username is set.
+type ChartServer struct {
+	client          *rancher.Client
+	restConfig      *restclient.Config
+	helmRepoPodName string
+	Namespace       string
+	Username        string
+	Password        string
+	FixturesPath    string
+	CACert          []byte
+	index           *helmIndex
+}
+
+// LoadConfig is a helper function that loads the chart server configuration, falling back to the defaults for every
+// unset field.
+func LoadConfig() *Config {
+	chartServerConfig := new(Config)
+	config.LoadConfig(ChartServerConfigurationFileKey, chartServerConfig)
+
+	if chartServerConfig.RegistryImage == "" {
+		chartServerConfig.RegistryImage = DefaultRegistryImage
+	}
+
+	if chartServerConfig.HelmRepoImage == "" {
+		chartServerConfig.HelmRepoImage = DefaultHelmRepoImage
+	}
+
+	if chartServerConfig.FixturesPath == "" {
+		chartServerConfig.FixturesPath = DefaultFixturesPath
+	}
+
+	return chartServerConfig
+}
+
+// DeployChartServer is a helper function that deploys an OCI registry and a static Helm repo in a new namespace of the
+// local cluster and waits for them to be ready. With auth both require the basic auth credentials of the chart server.
+// Every resource it creates, including the namespace, is deleted on session cleanup.
+func DeployChartServer(client *rancher.Client, chartServerConfig *Config, auth bool) (*ChartServer, error) {
+	chartServer := &ChartServer{
+		client:       client,
+		Namespace:    namegenerator.AppendRandomString(chartServerName),
+		FixturesPath: chartServerConfig.FixturesPath,
+		index:        newHelmIndex(),
+	}
+
+	if auth {
+		chartServer.Username = chartUsername
+		chartServer.Password = namegenerator.RandStringLower(16)
+	}
+
+	certificate, privateKey, err := actionssecrets.GenerateServerCert(chartServerName, chartServer.RegistryHost(), chartServer.HelmRepoHost())
+	if err != nil {
+		return nil, err
+	}
+
+	chartServer.CACert = certificate
+
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: chartServer.Namespace,
+		},
+	}
+
+	logrus.Infof("Creating chart server namespace %s", chartServer.Namespace)
+	_, err = dynamicClient.Resource(kubenamespaces.NamespaceGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(namespace), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	configData := map[string][]byte{
+		tlsCertFile:   certificate,
+		tlsKeyFile:    privateKey,
+		nginxConfFile: []byte(nginxConf(auth)),
+	}
+
+	if auth {
+		configData[htpasswdFile], err = htpasswd(chartServer.Username, chartServer.Password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      chartServerName + "-" + configVolume,
+			Namespace: chartServer.Namespace,
+		},
+		Data: configData,
+	}
+
+	_, err = secrets.CreateSecretForCluster(client, configSecret, localClusterID, chartServer.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deploying OCI registry %s/%s", chartServer.Namespace, registryName)
+	registrySelector, err := deployWithService(client, chartServer.Namespace, registryName, registryPort, registryPodTemplate(chartServerConfig.RegistryImage, configSecret.Name, auth))
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deploying Helm repo %s/%s", chartServer.Namespace, helmRepoName)
+	helmRepoSelector, err := deployWithService(client, chartServer.Namespace, helmRepoName, helmRepoPort, helmRepoPodTemplate(chartServerConfig.HelmRepoImage, configSecret.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = pods.WaitForReadyPod(client, chartServer.Namespace, registrySelector)
+	if err != nil {
+		return nil, err
+	}
+
+	helmRepoPod, err := pods.WaitForReadyPod(client, chartServer.Namespace, helmRepoSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	chartServer.helmRepoPodName = helmRepoPod.Name
+
+	clientConfig, err := kubeconfig.GetKubeconfig(client, localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	chartServer.restConfig, err = (*clientConfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return chartServer, nil
+}
+
+// RegistryHost returns the in-cluster DNS name and port of the OCI registry service.
+func (c *ChartServer) RegistryHost() string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local:%d", registryName, c.Namespace, registryPort)
+}
+
+// HelmRepoHost returns the in-cluster DNS name and port of the Helm repo service.
+func (c *ChartServer) HelmRepoHost() string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local:%d", helmRepoName, c.Namespace, helmRepoPort)
+}
+
+// OCIChartURL returns the oci:// reference fleet pulls a chart from, to be used as the chart of a fleet.yaml.
+func (c *ChartServer) OCIChartURL(chartName string) string {
+	return fmt.Sprintf("oci://%s/%s/%s", c.RegistryHost(), OCINamespace, chartName)
+}
+
+// HelmRepoURL returns the url of the Helm repo, to be used as the repo of a fleet.yaml.
+func (c *ChartServer) HelmRepoURL() string {
+	return "https://" + c.HelmRepoHost()
+}
+
+// deployWithService is a private helper function that creates a deployment and a service exposing a single port of it,
+// returning the pod selector of the deployment.
+func deployWithService(client *rancher.Client, namespace, name string, port int32, podTemplate corev1.PodTemplateSpec) (*metav1.LabelSelector, error) {
+	_, err := deployments.CreateDeployment(client, localClusterID, name, namespace, podTemplate, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := client.WranglerContext.Apps.Deployment().Get(namespace, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = services.CreateService(client, localClusterID, name, namespace, corev1.ServiceSpec{
+		Selector: deployment.Spec.Selector.MatchLabels,
+		Ports: []corev1.ServicePort{
+			{
+				Name:       "https",
+				Port:       port,
+				TargetPort: intstr.FromInt32(port),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deployment.Spec.Selector, nil
+}
+
+// registryPodTemplate is a private helper function that returns the OCI registry pod template, serving https with the
+// chart server certificate and, with auth, checking the htpasswd of the chart server.
+func registryPodTemplate(image, configSecretName string, auth bool) corev1.PodTemplateSpec {
+	env := []corev1.EnvVar{
+		{Name: "REGISTRY_HTTP_ADDR", Value: fmt.Sprintf(":%d", registryPort)},
+		{Name: "REGISTRY_HTTP_TLS_CERTIFICATE", Value: configPath + "/" + tlsCertFile},
+		{Name: "REGISTRY_HTTP_TLS_KEY", Value: configPath + "/" + tlsKeyFile},
+	}
+
+	if auth {
+		env = append(env,
+			corev1.EnvVar{Name: "REGISTRY_AUTH", Value: "htpasswd"},
+			corev1.EnvVar{Name: "REGISTRY_AUTH_HTPASSWD_REALM", Value: authRealm},
+			corev1.EnvVar{Name: "REGISTRY_AUTH_HTPASSWD_PATH", Value: configPath + "/" + htpasswdFile},
+		)
+	}
+
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  registryName,
+					Image: image,
+					Env:   env,
+					Ports: []corev1.ContainerPort{
+						{Name: "https", ContainerPort: registryPort},
+					},
+					ReadinessProbe: tcpProbe(registryPort),
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: configVolume, MountPath: configPath, ReadOnly: true},
+						{Name: dataVolume, MountPath: registryDataPath},
+					},
+				},
+			},
+			Volumes: chartServerVolumes(configSecretName),
+		},
+	}
+}
+
+// helmRepoPodTemplate is a private helper function that returns the pod template of the nginx server serving the
+// static Helm repo over https.
+func helmRepoPodTemplate(image, configSecretName string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  helmRepoName,
+					Image: image,
+					Ports: []corev1.ContainerPort{
+						{Name: "https", ContainerPort: helmRepoPort},
+					},
+					ReadinessProbe: tcpProbe(helmRepoPort),
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: configVolume, MountPath: configPath, ReadOnly: true},
+						{Name: configVolume, MountPath: nginxConfPath, SubPath: nginxConfFile, ReadOnly: true},
+						{Name: dataVolume, MountPath: helmRepoDataPath},
+					},
+				},
+			},
+			Volumes: chartServerVolumes(configSecretName),
+		},
+	}
+}
+
+// chartServerVolumes is a private helper function that returns the volumes shared by both pod templates.
+func chartServerVolumes(configSecretName string) []corev1.Volume {
+	return []corev1.Volume{
+		{Name: configVolume, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: configSecretName}}},
+		{Name: dataVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+}
+
+// tcpProbe is a private helper function that returns a readiness probe on a port. Both servers may require auth, so
+// they are not probed over https.
+func tcpProbe(port int32) *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt32(port),
+			},
+		},
+	}
+}
+
+// nginxConf is a private helper function that returns the nginx server configuration of the Helm repo.
+func nginxConf(auth bool) string {
+	var authConf string
+	if auth {
+		authConf = fmt.Sprintf("    auth_basic %q;\n    auth_basic_user_file %s/%s;\n", authRealm, configPath, htpasswdFile)
+	}
+
+	return fmt.Sprintf("server {\n    listen %d ssl;\n    ssl_certificate %s/%s;\n    ssl_certificate_key %s/%s;\n    root %s;\n%s}\n",
+		helmRepoPort, configPath, tlsCertFile, configPath, tlsKeyFile, helmRepoDataPath, authConf)
+}
+
+// exec is a private helper function that runs a shell script in the Helm repo pod and returns its output.
+func (c *ChartServer) exec(script string) (string, error) {
+	return pods.ExecScript(c.restConfig, c.Namespace, c.helmRepoPodName, script)
+}
@@ -950,3 +950,25 @@ func DeletePSACT(client *rancher.Client, psactID string) error {
 
 	return nil
 }
+
+// ManagementClusterID is a helper function that returns the management cluster ID of a provisioning cluster.
+func ManagementClusterID(cluster *steveV1.SteveAPIObject) (string, error) {
+	status := &provv1.ClusterStatus{}
+	err := steveV1.ConvertToK8sType(cluster.Status, status)
+	if err != nil {
+		return "", err
+	}
+
+	return status.ClusterName, nil
+}
+
+// NodeReady is a helper function that returns whether the Ready condition of a node is true.
+func NodeReady(node *corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,65 @@
+package clusters
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// UpgradeMinorVersions is a helper function that returns how many minor versions a Kubernetes upgrade from current to
+// target crosses. It errors when target is not newer than current or has another major version. rke2 and k3s only
+// support upgrades of at most one minor version.
+func UpgradeMinorVersions(current, target string) (int, error) {
+	currentVersion, err := version.ParseSemantic(current)
+	if err != nil {
+		return 0, err
+	}
+
+	targetVersion, err := version.ParseSemantic(target)
+	if err != nil {
+		return 0, err
+	}
+
+	if !currentVersion.LessThan(targetVersion) {
+		return 0, fmt.Errorf("kubernetes version %s is not newer than %s", target, current)
+	}
+
+	if currentVersion.Major() != targetVersion.Major() {
+		return 0, fmt.Errorf("kubernetes version %s has another major version than %s", target, current)
+	}
+
+	return int(targetVersion.Minor() - currentVersion.Minor()), nil
+}
+
+// PreviousMinorVersion is a helper function that returns the newest of a list of Kubernetes versions that is one minor
+// version below target, so a cluster on it can be upgraded to target.
+func PreviousMinorVersion(versions []string, target string) (string, error) {
+	targetVersion, err := version.ParseSemantic(target)
+	if err != nil {
+		return "", err
+	}
+
+	var previous string
+	var previousVersion *version.Version
+	for _, candidate := range versions {
+		candidateVersion, err := version.ParseSemantic(candidate)
+		if err != nil {
+			continue
+		}
+
+		if candidateVersion.Major() != targetVersion.Major() || candidateVersion.Minor()+1 != targetVersion.Minor() {
+			continue
+		}
+
+		if previousVersion == nil || previousVersion.LessThan(candidateVersion) {
+			previous = candidate
+			previousVersion = candidateVersion
+		}
+	}
+
+	if previous == "" {
+		return "", fmt.Errorf("no kubernetes version is one minor version below %s", target)
+	}
+
+	return previous, nil
+}
@@ -0,0 +1,28 @@
+package connectivity
+
+import (
+	"github.com/rancher/shepherd/pkg/config"
+)
+
+const (
+	ConfigurationFileKey = "connectivityInput"
+)
+
+// Config is the image of the probe pods, for clusters that can not pull ProbeImage from registry.k8s.io.
+type Config struct {
+	ProbeImage string `json:"probeImage" yaml:"probeImage"`
+}
+
+// GetProbeImage is a helper function that returns the image of the probe pods: the image of the config when it is set,
+// otherwise ProbeImage. The system default registry of Rancher only mirrors Rancher images, so clusters that can not
+// reach registry.k8s.io, e.g. airgapped clusters, must mirror ProbeImage and set it in the config.
+func GetProbeImage() string {
+	connectivityConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, connectivityConfig)
+
+	if connectivityConfig.ProbeImage != "" {
+		return connectivityConfig.ProbeImage
+	}
+
+	return ProbeImage
+}
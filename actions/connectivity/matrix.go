@@ -0,0 +1,305 @@
+package connectivity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	probeTimeout = "3s"
+	reachable    = "1"
+	unreachable  = "0"
+)
+
+// Target is a probe pod or service that every probe pod probes.
+type Target struct {
+	Name      string
+	Namespace string
+	IP        string
+	Service   bool
+}
+
+// String returns the namespaced name of the target.
+func (t Target) String() string {
+	if t.Service {
+		return "service " + t.Namespace + "/" + t.Name
+	}
+
+	return t.Namespace + "/" + t.Name
+}
+
+// Mismatch is a probe whose observed reachability is not the expected one.
+type Mismatch struct {
+	Source   ProbePod
+	Target   Target
+	Protocol Protocol
+	Expected bool
+}
+
+// String returns a description of the mismatch.
+func (m Mismatch) String() string {
+	expected, observed := "reachable", "unreachable"
+	if !m.Expected {
+		expected, observed = observed, expected
+	}
+
+	return fmt.Sprintf("%s/%s -> %s over %s: expected %s, observed %s", m.Source.Namespace, m.Source.Name, m.Target, m.Protocol, expected, observed)
+}
+
+type cell struct {
+	source   int
+	target   int
+	protocol Protocol
+}
+
+// Matrix is the reachability of every target from every probe pod, per protocol. A cell missing from an expected
+// matrix is not asserted, e.g. a service with backends the policies treat differently.
+type Matrix struct {
+	Sources []ProbePod
+	Targets []Target
+	cells   map[cell]bool
+}
+
+// Targets returns the probe pods, then the probe services, every probe pod probes.
+func (p *Probes) Targets() []Target {
+	var targets []Target
+	for _, pod := range p.Pods {
+		targets = append(targets, Target{Name: pod.Name, Namespace: pod.Namespace, IP: pod.IP})
+	}
+
+	for _, service := range p.Services {
+		targets = append(targets, Target{Name: service.Name, Namespace: service.Namespace, IP: service.ClusterIP, Service: true})
+	}
+
+	return targets
+}
+
+// Reachable returns whether a target is reachable from a source over a protocol, and whether the cell is set.
+func (m *Matrix) Reachable(source, target int, protocol Protocol) (bool, bool) {
+	isReachable, ok := m.cells[cell{source: source, target: target, protocol: protocol}]
+	return isReachable, ok
+}
+
+// set is a private helper function that sets the reachability of a cell.
+func (m *Matrix) set(source, target int, protocol Protocol, isReachable bool) {
+	m.cells[cell{source: source, target: target, protocol: protocol}] = isReachable
+}
+
+// newMatrix is a private constructor that returns an empty matrix of the probes.
+func newMatrix(probes *Probes) *Matrix {
+	return &Matrix{
+		Sources: probes.Pods,
+		Targets: probes.Targets(),
+		cells:   map[cell]bool{},
+	}
+}
+
+// ObserveMatrix is a helper function that probes every target from every probe pod over TCP, UDP and HTTP and returns
+// the observed matrix. Each probe pod runs all of its probes in a single exec.
+func ObserveMatrix(client *rancher.Client, probes *Probes) (*Matrix, error) {
+	matrix := newMatrix(probes)
+	for source, pod := range matrix.Sources {
+		output, err := ExecInProbe(client, probes.ClusterID, pod, probeScript(matrix.Targets))
+		if err != nil {
+			return nil, err
+		}
+
+		err = matrix.parse(source, output)
+		if err != nil {
+			return nil, fmt.Errorf("probing from pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+
+	return matrix, nil
+}
+
+// ExecInProbe is a helper function that runs a shell script in a probe pod and returns its output.
+func ExecInProbe(client *rancher.Client, clusterID string, pod ProbePod, script string) (string, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	restConfig, err := (*clientConfig).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := kubeconfig.KubectlExec(restConfig, pod.Name, pod.Namespace, []string{"sh", "-c", script})
+	if err != nil {
+		return "", fmt.Errorf("running probes in pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	return strings.ReplaceAll(output.String(), "\r", ""), nil
+}
+
+// ValidateConnectivity is a helper function that derives the expected matrix of the probes for the CNI of the cluster,
+// then observes the matrix until it matches, allowing the CNI time to apply new policies. It returns an error with
+// the mismatches rendered as a grid if it does not.
+func ValidateConnectivity(client *rancher.Client, probes *Probes, cni string) error {
+	expected, err := ExpectedMatrix(client, probes, cni)
+	if err != nil {
+		return err
+	}
+
+	var mismatches []Mismatch
+	var observed *Matrix
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		observed, err = ObserveMatrix(client, probes)
+		if err != nil {
+			return false, err
+		}
+
+		mismatches = Compare(expected, observed)
+		if len(mismatches) > 0 {
+			logrus.Infof("Connectivity matrix of cluster %s has %d mismatches, probing again", probes.ClusterID, len(mismatches))
+		}
+
+		return len(mismatches) == 0, nil
+	})
+	if len(mismatches) > 0 {
+		return fmt.Errorf("connectivity matrix of cluster %s does not match the expected one:\n%s", probes.ClusterID, Render(expected, observed))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Connectivity matrix of cluster %s matches the expected one:\n%s", probes.ClusterID, Render(expected, observed))
+
+	return nil
+}
+
+// Compare is a helper function that returns the cells of the observed matrix that do not match the expected matrix.
+func Compare(expected, observed *Matrix) []Mismatch {
+	var mismatches []Mismatch
+	for source := range expected.Sources {
+		for target := range expected.Targets {
+			for _, protocol := range Protocols {
+				expectedReachable, ok := expected.Reachable(source, target, protocol)
+				if !ok {
+					continue
+				}
+
+				observedReachable, _ := observed.Reachable(source, target, protocol)
+				if observedReachable != expectedReachable {
+					mismatches = append(mismatches, Mismatch{
+						Source:   expected.Sources[source],
+						Target:   expected.Targets[target],
+						Protocol: protocol,
+						Expected: expectedReachable,
+					})
+				}
+			}
+		}
+	}
+
+	return mismatches
+}
+
+// Render is a helper function that renders a grid per protocol, with a row per source and a column per target, where
+// a cell is . when the observed reachability matches the expected one, X when it does not and ? when it is not
+// asserted, followed by a legend and the mismatches.
+func Render(expected, observed *Matrix) string {
+	var builder strings.Builder
+	for _, protocol := range Protocols {
+		builder.WriteString(string(protocol) + "\n")
+		builder.WriteString(fmt.Sprintf("%-5s", ""))
+		for target := range expected.Targets {
+			builder.WriteString(fmt.Sprintf("%-5s", "t"+strconv.Itoa(target)))
+		}
+
+		builder.WriteString("\n")
+
+		for source := range expected.Sources {
+			builder.WriteString(fmt.Sprintf("%-5s", "s"+strconv.Itoa(source)))
+			for target := range expected.Targets {
+				mark := "?"
+				if expectedReachable, ok := expected.Reachable(source, target, protocol); ok {
+					mark = "."
+					if observedReachable, _ := observed.Reachable(source, target, protocol); observedReachable != expectedReachable {
+						mark = "X"
+					}
+				}
+
+				builder.WriteString(fmt.Sprintf("%-5s", mark))
+			}
+
+			builder.WriteString("\n")
+		}
+
+		builder.WriteString("\n")
+	}
+
+	for source, pod := range expected.Sources {
+		builder.WriteString(fmt.Sprintf("s%d: %s/%s on %s in project %q\n", source, pod.Namespace, pod.Name, pod.Node, pod.Project))
+	}
+
+	for target, probeTarget := range expected.Targets {
+		builder.WriteString(fmt.Sprintf("t%d: %s\n", target, probeTarget))
+	}
+
+	for _, mismatch := range Compare(expected, observed) {
+		builder.WriteString(mismatch.String() + "\n")
+	}
+
+	return builder.String()
+}
+
+// probeScript is a private helper function that returns a shell script probing every target over every protocol, which
+// prints a record with the target index, the protocol and 1 or 0 for each probe. Records end with a semicolon rather
+// than a newline, as the exec output streamer trims the whitespace of every chunk it reads.
+func probeScript(targets []Target) string {
+	var script strings.Builder
+	for target, probeTarget := range targets {
+		for _, protocol := range Protocols {
+			address := net.JoinHostPort(probeTarget.IP, strconv.Itoa(Port(protocol)))
+
+			var command string
+			switch protocol {
+			case HTTP:
+				command = fmt.Sprintf("curl -s -f -m %s -o /dev/null http://%s/", strings.TrimSuffix(probeTimeout, "s"), address)
+			default:
+				command = fmt.Sprintf("/agnhost connect %s --timeout=%s --protocol=%s", address, probeTimeout, strings.ToLower(string(protocol)))
+			}
+
+			script.WriteString(fmt.Sprintf("if %s > /dev/null 2>&1; then echo '%d,%s,%s;'; else echo '%d,%s,%s;'; fi; ", command, target, protocol, reachable, target, protocol, unreachable))
+		}
+	}
+
+	return script.String()
+}
+
+// parse is a private helper function that sets the cells of a source from the output of its probe script.
+func (m *Matrix) parse(source int, output string) error {
+	probed := 0
+	for _, record := range strings.Split(output, ";") {
+		fields := strings.Split(strings.TrimSpace(record), ",")
+		if len(fields) != 3 {
+			continue
+		}
+
+		target, err := strconv.Atoi(fields[0])
+		if err != nil || target < 0 || target >= len(m.Targets) {
+			continue
+		}
+
+		m.set(source, target, Protocol(fields[1]), fields[2] == reachable)
+		probed++
+	}
+
+	if probed != len(m.Targets)*len(Protocols) {
+		return fmt.Errorf("expected %d probe results, got %d: %s", len(m.Targets)*len(Protocols), probed, output)
+	}
+
+	return nil
+}
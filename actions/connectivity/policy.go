@@ -0,0 +1,336 @@
+package connectivity
+
+import (
+	"context"
+	"net"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/api/scheme"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// projectNetworkIsolationPolicy is the policy Rancher creates in every project namespace when Project Network
+	// Isolation is enabled, which the expected matrix models as project isolation instead of evaluating it.
+	projectNetworkIsolationPolicy = "np-default"
+	// hostNetworkPolicy is the policy Rancher creates for host network traffic when Project Network Isolation is
+	// enabled, which does not apply to probe pods.
+	hostNetworkPolicy = "hn-nodes"
+	flannelCNI        = "flannel"
+)
+
+// NetworkPolicyGroupVersionResource is the required Group Version Resource for accessing network policies in a
+// cluster, using the dynamic client.
+var NetworkPolicyGroupVersionResource = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "networkpolicies",
+}
+
+// policyEvaluator derives the reachability of probe pods from NetworkPolicies and Project Network Isolation.
+type policyEvaluator struct {
+	enforced                bool
+	projectNetworkIsolation bool
+	namespaceLabels         map[string]labels.Set
+	policies                map[string][]networkingv1.NetworkPolicy
+}
+
+// EnforcesNetworkPolicy returns whether a CNI enforces NetworkPolicies. Standalone flannel only provides the pod
+// network, so every probe is expected to be reachable on it.
+func EnforcesNetworkPolicy(cni string) bool {
+	return cni != flannelCNI
+}
+
+// CreateNetworkPolicy is a helper function that uses the dynamic client to create a NetworkPolicy in a downstream
+// cluster.
+func CreateNetworkPolicy(client *rancher.Client, clusterID string, policy *networkingv1.NetworkPolicy) (*networkingv1.NetworkPolicy, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredResp, err := dynamicClient.Resource(NetworkPolicyGroupVersionResource).Namespace(policy.Namespace).Create(context.TODO(), unstructured.MustToUnstructured(policy), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	newPolicy := &networkingv1.NetworkPolicy{}
+	err = scheme.Scheme.Convert(unstructuredResp, newPolicy, unstructuredResp.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+
+	return newPolicy, nil
+}
+
+// ExpectedMatrix is a helper function that derives the expected matrix of the probes from the NetworkPolicies of the
+// probe namespaces and, when the cluster has Project Network Isolation enabled, the isolation of their projects. A
+// service is expected to be reachable when all of its probe pods are and unreachable when none are; otherwise it is
+// not asserted, as the probe lands on either.
+func ExpectedMatrix(client *rancher.Client, probes *Probes, cni string) (*Matrix, error) {
+	evaluator := &policyEvaluator{
+		enforced:        EnforcesNetworkPolicy(cni),
+		namespaceLabels: map[string]labels.Set{},
+		policies:        map[string][]networkingv1.NetworkPolicy{},
+	}
+
+	cluster, err := client.Management.Cluster.ByID(probes.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluator.projectNetworkIsolation = cluster.EnableNetworkPolicy != nil && *cluster.EnableNetworkPolicy
+
+	dynamicClient, err := client.GetDownStreamClusterClient(probes.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, namespace := range probes.Namespaces {
+		evaluator.namespaceLabels[namespace.Name] = labels.Set(namespace.Labels)
+
+		policyList, err := dynamicClient.Resource(NetworkPolicyGroupVersionResource).Namespace(namespace.Name).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, unstructuredPolicy := range policyList.Items {
+			if unstructuredPolicy.GetName() == projectNetworkIsolationPolicy || unstructuredPolicy.GetName() == hostNetworkPolicy {
+				continue
+			}
+
+			policy := networkingv1.NetworkPolicy{}
+			err = scheme.Scheme.Convert(&unstructuredPolicy, &policy, unstructuredPolicy.GroupVersionKind())
+			if err != nil {
+				return nil, err
+			}
+
+			evaluator.policies[namespace.Name] = append(evaluator.policies[namespace.Name], policy)
+		}
+	}
+
+	matrix := newMatrix(probes)
+	for source, sourcePod := range matrix.Sources {
+		for target, probeTarget := range matrix.Targets {
+			for _, protocol := range Protocols {
+				if !probeTarget.Service {
+					matrix.set(source, target, protocol, evaluator.allowed(sourcePod, probes.Pods[target], protocol))
+					continue
+				}
+
+				var backends, allowed int
+				for _, pod := range probes.Pods {
+					if pod.Namespace != probeTarget.Namespace {
+						continue
+					}
+
+					backends++
+					if evaluator.allowed(sourcePod, pod, protocol) {
+						allowed++
+					}
+				}
+
+				if allowed == 0 || allowed == backends {
+					matrix.set(source, target, protocol, allowed == backends)
+				}
+			}
+		}
+	}
+
+	return matrix, nil
+}
+
+// allowed is a private helper function that returns whether a probe pod is expected to reach another one over a
+// protocol: the egress of the source and the ingress of the target must both allow it.
+func (e *policyEvaluator) allowed(source, target ProbePod, protocol Protocol) bool {
+	if !e.enforced {
+		return true
+	}
+
+	return e.ingressAllowed(source, target, protocol) && e.egressAllowed(source, target, protocol)
+}
+
+// ingressAllowed is a private helper function that returns whether the ingress of the target allows the source. Project
+// Network Isolation isolates the pods of project namespaces and allows the pods of the same project, in addition to
+// the NetworkPolicies selecting the target.
+func (e *policyEvaluator) ingressAllowed(source, target ProbePod, protocol Protocol) bool {
+	isolated := false
+	if e.projectNetworkIsolation && target.Project != "" {
+		isolated = true
+		if source.Project == target.Project {
+			return true
+		}
+	}
+
+	for _, policy := range e.policies[target.Namespace] {
+		ingress, _ := policyTypes(policy)
+		if !ingress || !selectorMatches(&policy.Spec.PodSelector, target.Labels) {
+			continue
+		}
+
+		isolated = true
+		for _, rule := range policy.Spec.Ingress {
+			if e.peersMatch(rule.From, policy.Namespace, source) && portsMatch(rule.Ports, protocol) {
+				return true
+			}
+		}
+	}
+
+	return !isolated
+}
+
+// egressAllowed is a private helper function that returns whether the egress of the source allows the target.
+func (e *policyEvaluator) egressAllowed(source, target ProbePod, protocol Protocol) bool {
+	isolated := false
+	for _, policy := range e.policies[source.Namespace] {
+		_, egress := policyTypes(policy)
+		if !egress || !selectorMatches(&policy.Spec.PodSelector, source.Labels) {
+			continue
+		}
+
+		isolated = true
+		for _, rule := range policy.Spec.Egress {
+			if e.peersMatch(rule.To, policy.Namespace, target) && portsMatch(rule.Ports, protocol) {
+				return true
+			}
+		}
+	}
+
+	return !isolated
+}
+
+// peersMatch is a private helper function that returns whether a pod matches any peer of a policy rule. A rule
+// without peers matches every pod, and a peer without a namespace selector matches the namespace of the policy.
+func (e *policyEvaluator) peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, pod ProbePod) bool {
+	if len(peers) == 0 {
+		return true
+	}
+
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if ipBlockContains(peer.IPBlock, pod.IP) {
+				return true
+			}
+
+			continue
+		}
+
+		if peer.NamespaceSelector == nil {
+			if pod.Namespace != policyNamespace {
+				continue
+			}
+		} else if !selectorMatches(peer.NamespaceSelector, e.namespaceLabels[pod.Namespace]) {
+			continue
+		}
+
+		if peer.PodSelector != nil && !selectorMatches(peer.PodSelector, pod.Labels) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// portsMatch is a private helper function that returns whether the ports of a policy rule include the port a probe pod
+// serves a protocol on. A rule without ports matches every port.
+func portsMatch(ports []networkingv1.NetworkPolicyPort, protocol Protocol) bool {
+	if len(ports) == 0 {
+		return true
+	}
+
+	probeProtocol, portName := corev1.ProtocolTCP, "tcp"
+	switch protocol {
+	case UDP:
+		probeProtocol, portName = corev1.ProtocolUDP, "udp"
+	case HTTP:
+		portName = "http"
+	}
+
+	port := int32(Port(protocol))
+	for _, policyPort := range ports {
+		policyProtocol := corev1.ProtocolTCP
+		if policyPort.Protocol != nil {
+			policyProtocol = *policyPort.Protocol
+		}
+
+		if policyProtocol != probeProtocol {
+			continue
+		}
+
+		if policyPort.Port == nil {
+			return true
+		}
+
+		if policyPort.Port.Type == intstr.String {
+			if policyPort.Port.StrVal == portName {
+				return true
+			}
+
+			continue
+		}
+
+		if policyPort.Port.IntVal == port || (policyPort.EndPort != nil && port >= policyPort.Port.IntVal && port <= *policyPort.EndPort) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// policyTypes is a private helper function that returns whether a policy applies to ingress and egress. A policy
+// without policy types applies to ingress, and to egress when it has egress rules.
+func policyTypes(policy networkingv1.NetworkPolicy) (bool, bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+
+	var ingress, egress bool
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+
+	return ingress, egress
+}
+
+// selectorMatches is a private helper function that returns whether a label selector matches a set of labels.
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+
+	return labelSelector.Matches(labels.Set(set))
+}
+
+// ipBlockContains is a private helper function that returns whether an IP block contains an IP address, outside of its
+// exceptions.
+func ipBlockContains(ipBlock *networkingv1.IPBlock, address string) bool {
+	ip := net.ParseIP(address)
+
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil || ip == nil || !cidr.Contains(ip) {
+		return false
+	}
+
+	for _, except := range ipBlock.Except {
+		_, exceptCIDR, err := net.ParseCIDR(except)
+		if err == nil && exceptCIDR.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
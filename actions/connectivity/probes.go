@@ -0,0 +1,426 @@
+package connectivity
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/api/scheme"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+// Protocol is a protocol a probe pod serves and is probed on.
+type Protocol string
+
+const (
+	TCP  Protocol = "TCP"
+	UDP  Protocol = "UDP"
+	HTTP Protocol = "HTTP"
+
+	// ProbeImage serves and probes every protocol: serve-hostname answers TCP, UDP and HTTP, connect probes TCP and
+	// UDP, and the image ships curl for HTTP.
+	ProbeImage = "registry.k8s.io/e2e-test-images/agnhost:2.53"
+
+	probeName    = "probe"
+	probeLabel   = "connectivity.cattle.io/probe"
+	tcpPort      = 8080
+	udpPort      = 8081
+	httpPort     = 8082
+	probeUser    = int64(1000)
+	linuxOSLabel = "kubernetes.io/os"
+	linuxOS      = "linux"
+	workerRole   = "node-role.kubernetes.io/worker"
+)
+
+// Protocols are the protocols every probe pod is probed on.
+var Protocols = []Protocol{TCP, UDP, HTTP}
+
+// ProbeNamespace is a namespace to place probe pods in. Project is the name of the Rancher project of the namespace,
+// e.g. p-xxxxx, and an empty project leaves the namespace outside of any project.
+type ProbeNamespace struct {
+	Project string
+	Labels  map[string]string
+}
+
+// ProbePod is a probe pod, placed in a namespace on a node.
+type ProbePod struct {
+	Name      string
+	Namespace string
+	Project   string
+	Node      string
+	IP        string
+	IPs       []string
+	Labels    map[string]string
+}
+
+// ProbeService is a ClusterIP service in front of the probe pods of a namespace.
+type ProbeService struct {
+	Name       string
+	Namespace  string
+	Project    string
+	ClusterIP  string
+	ClusterIPs []string
+}
+
+// Probes are the probe pods and services deployed in a downstream cluster: one pod per namespace and schedulable node,
+// and one service per namespace.
+type Probes struct {
+	ClusterID  string
+	Namespaces []*corev1.Namespace
+	Pods       []ProbePod
+	Services   []ProbeService
+}
+
+// DeployProbes is a helper function that creates the namespaces, then places a probe pod on every schedulable linux
+// node in each of them and a ClusterIP service in front of them, and waits for the pods to be ready. The namespaces,
+// and with them the probes, are deleted on session cleanup.
+func DeployProbes(client *rancher.Client, clusterID string, probeNamespaces []ProbeNamespace) (*Probes, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := probeNodes(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	image := GetProbeImage()
+
+	probes := &Probes{ClusterID: clusterID}
+	for _, probeNamespace := range probeNamespaces {
+		namespaceName := namegen.AppendRandomString(probeName)
+		namespace, err := createNamespace(client, clusterID, probeNamespace, namespaceName)
+		if err != nil {
+			return nil, err
+		}
+
+		probes.Namespaces = append(probes.Namespaces, namespace)
+
+		for _, node := range nodes {
+			logrus.Infof("Creating probe pod in namespace %s on node %s", namespaceName, node)
+			pod, err := wranglerContext.Core.Pod().Create(NewProbePod(namespaceName, node, image))
+			if err != nil {
+				return nil, err
+			}
+
+			probes.Pods = append(probes.Pods, ProbePod{
+				Name:      pod.Name,
+				Namespace: namespaceName,
+				Project:   probeNamespace.Project,
+				Node:      node,
+				Labels:    pod.Labels,
+			})
+		}
+
+		service, err := services.CreateService(client, clusterID, namegen.AppendRandomString(probeName), namespaceName, NewProbeServiceSpec(corev1.ServiceTypeClusterIP))
+		if err != nil {
+			return nil, err
+		}
+
+		probes.Services = append(probes.Services, ProbeService{
+			Name:       service.Name,
+			Namespace:  namespaceName,
+			Project:    probeNamespace.Project,
+			ClusterIP:  service.Spec.ClusterIP,
+			ClusterIPs: service.Spec.ClusterIPs,
+		})
+	}
+
+	err = probes.waitForPods(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return probes, nil
+}
+
+// Port returns the port a probe pod serves a protocol on.
+func Port(protocol Protocol) int {
+	switch protocol {
+	case UDP:
+		return udpPort
+	case HTTP:
+		return httpPort
+	default:
+		return tcpPort
+	}
+}
+
+// ForFamily returns a copy of the probes addressed over an IP family, so the matrix of each family of a dual-stack
+// cluster is probed separately. It returns an error if a probe pod or service has no address of the family.
+func (p *Probes) ForFamily(family corev1.IPFamily) (*Probes, error) {
+	familyProbes := &Probes{
+		ClusterID:  p.ClusterID,
+		Namespaces: p.Namespaces,
+	}
+
+	for _, pod := range p.Pods {
+		address := FamilyAddress(pod.IPs, family)
+		if address == "" {
+			return nil, fmt.Errorf("probe pod %s/%s has no %s address: %v", pod.Namespace, pod.Name, family, pod.IPs)
+		}
+
+		pod.IP = address
+		familyProbes.Pods = append(familyProbes.Pods, pod)
+	}
+
+	for _, service := range p.Services {
+		address := FamilyAddress(service.ClusterIPs, family)
+		if address == "" {
+			return nil, fmt.Errorf("probe service %s/%s has no %s address: %v", service.Namespace, service.Name, family, service.ClusterIPs)
+		}
+
+		service.ClusterIP = address
+		familyProbes.Services = append(familyProbes.Services, service)
+	}
+
+	return familyProbes, nil
+}
+
+// FamilyAddress returns the first address of an IP family, or an empty string if there is none.
+func FamilyAddress(addresses []string, family corev1.IPFamily) string {
+	for _, address := range addresses {
+		if AddressFamily(address) == family {
+			return address
+		}
+	}
+
+	return ""
+}
+
+// AddressFamily returns the IP family of an address, or an empty family if it is not an IP address.
+func AddressFamily(address string) corev1.IPFamily {
+	ip := net.ParseIP(address)
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return corev1.IPv4Protocol
+	default:
+		return corev1.IPv6Protocol
+	}
+}
+
+// createNamespace is a private helper function that creates a probe namespace. Namespaces outside of any project are
+// created with the dynamic client, as the namespace helper waits for the project role of the namespace.
+func createNamespace(client *rancher.Client, clusterID string, probeNamespace ProbeNamespace, namespaceName string) (*corev1.Namespace, error) {
+	if probeNamespace.Project != "" {
+		return namespaces.CreateNamespace(client, clusterID, probeNamespace.Project, namespaceName, "", probeNamespace.Labels, nil)
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredResp, err := dynamicClient.Resource(namespaces.NamespaceGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(&corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   namespaceName,
+			Labels: probeNamespace.Labels,
+		},
+	}), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := &corev1.Namespace{}
+	err = scheme.Scheme.Convert(unstructuredResp, namespace, unstructuredResp.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace, nil
+}
+
+// waitForPods is a private helper function that waits for every probe pod to be ready and records its IP.
+func (p *Probes) waitForPods(client *rancher.Client) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, p.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for %d probe pods to be ready", len(p.Pods))
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		for i := range p.Pods {
+			pod, err := wranglerContext.Core.Pod().Get(p.Pods[i].Namespace, p.Pods[i].Name, metav1.GetOptions{})
+			if err != nil {
+				return false, nil
+			}
+
+			if !PodReady(pod) {
+				return false, nil
+			}
+
+			p.Pods[i].IP = pod.Status.PodIP
+			p.Pods[i].IPs = nil
+			for _, podIP := range pod.Status.PodIPs {
+				p.Pods[i].IPs = append(p.Pods[i].IPs, podIP.IP)
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// probeNodes is a private helper function that returns the names of the linux nodes probe pods can be scheduled on,
+// preferring worker nodes when the cluster has dedicated ones.
+func probeNodes(client *rancher.Client, clusterID string) ([]string, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeList, err := wranglerContext.Core.Node().List(metav1.ListOptions{
+		LabelSelector: linuxOSLabel + "=" + linuxOS,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var schedulable, workers []string
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable || hasNoScheduleTaint(node) {
+			continue
+		}
+
+		schedulable = append(schedulable, node.Name)
+		if node.Labels[workerRole] == "true" {
+			workers = append(workers, node.Name)
+		}
+	}
+
+	if len(workers) > 0 {
+		schedulable = workers
+	}
+
+	if len(schedulable) == 0 {
+		return nil, fmt.Errorf("cluster %s has no schedulable linux nodes for probe pods", clusterID)
+	}
+
+	sort.Strings(schedulable)
+
+	return schedulable, nil
+}
+
+// NewProbePod is a constructor that returns a probe pod of an image, see GetProbeImage, pinned to a node, or left to
+// the scheduler without one, serving TCP, UDP and HTTP from a container each, with a restricted security context so it
+// runs on hardened clusters.
+func NewProbePod(namespace, node, image string) *corev1.Pod {
+	allowPrivilegeEscalation := false
+	runAsNonRoot := true
+	runAsUser := probeUser
+
+	securityContext := &corev1.SecurityContext{
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		RunAsNonRoot:             &runAsNonRoot,
+		RunAsUser:                &runAsUser,
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+	}
+
+	container := func(name string, protocol corev1.Protocol, port int, args ...string) corev1.Container {
+		return corev1.Container{
+			Name:            name,
+			Image:           image,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Args:            append([]string{"serve-hostname", "--port", strconv.Itoa(port)}, args...),
+			Ports: []corev1.ContainerPort{{
+				Name:          name,
+				ContainerPort: int32(port),
+				Protocol:      protocol,
+			}},
+			SecurityContext: securityContext,
+		}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString(probeName),
+			Namespace: namespace,
+			Labels: map[string]string{
+				probeLabel: probeName,
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: node,
+			Containers: []corev1.Container{
+				container("tcp", corev1.ProtocolTCP, tcpPort, "--tcp", "--http=false"),
+				container("udp", corev1.ProtocolUDP, udpPort, "--udp", "--http=false"),
+				container("http", corev1.ProtocolTCP, httpPort),
+			},
+			RestartPolicy: corev1.RestartPolicyAlways,
+		},
+	}
+}
+
+// NewProbeServiceSpec is a constructor that returns the spec of a service of the given type in front of the probe pods
+// of a namespace. It prefers dual-stack, so the service has an address of each family the cluster supports.
+func NewProbeServiceSpec(serviceType corev1.ServiceType) corev1.ServiceSpec {
+	servicePort := func(name string, protocol corev1.Protocol, port int) corev1.ServicePort {
+		return corev1.ServicePort{
+			Name:       name,
+			Protocol:   protocol,
+			Port:       int32(port),
+			TargetPort: intstr.FromInt(port),
+		}
+	}
+
+	ipFamilyPolicy := corev1.IPFamilyPolicyPreferDualStack
+
+	return corev1.ServiceSpec{
+		Type:           serviceType,
+		IPFamilyPolicy: &ipFamilyPolicy,
+		Selector: map[string]string{
+			probeLabel: probeName,
+		},
+		Ports: []corev1.ServicePort{
+			servicePort("tcp", corev1.ProtocolTCP, tcpPort),
+			servicePort("udp", corev1.ProtocolUDP, udpPort),
+			servicePort("http", corev1.ProtocolTCP, httpPort),
+		},
+	}
+}
+
+// PodReady is a helper function that returns whether a pod is running with its containers ready.
+func PodReady(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+		return false
+	}
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// hasNoScheduleTaint is a private helper function that returns whether a node repels pods without tolerations.
+func hasNoScheduleTaint(node corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return true
+		}
+	}
+
+	return false
+}
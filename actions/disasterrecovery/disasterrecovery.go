@@ -0,0 +1,146 @@
+package disasterrecovery
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	shepherdcharts "github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	shepherdsnapshot "github.com/rancher/shepherd/extensions/etcdsnapshot"
+	nodestat "github.com/rancher/shepherd/extensions/nodes"
+	"github.com/rancher/tests/actions/etcdsnapshot"
+	"github.com/rancher/tests/actions/workloads/deployment"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Scenario is a disaster the cluster is recovered from by restoring an etcd snapshot onto replacement machines.
+type Scenario string
+
+const (
+	// EtcdQuorumLoss destroys a majority of the etcd machines, so etcd loses quorum while the other machines survive.
+	EtcdQuorumLoss Scenario = "etcdQuorumLoss"
+	// ControlPlaneLoss destroys every control plane machine.
+	ControlPlaneLoss Scenario = "controlPlaneLoss"
+	// TotalLoss destroys every machine of the cluster, so only the S3 snapshots survive.
+	TotalLoss Scenario = "totalLoss"
+
+	storageAnnotation = "etcdsnapshot.rke.io/storage"
+	s3Storage         = "s3"
+	restoreNone       = "none"
+	workloadNamespace = "default"
+	workloadReplicas  = 2
+)
+
+// RunScenario is a helper function that runs a disaster recovery scenario on an RKE2 or K3s node driver cluster,
+// following the documented Rancher restore path. It deploys a workload and seeds the etcd integrity corpus, takes a
+// snapshot, changes the corpus, destroys the machines of the scenario out of band and deletes them from Rancher so
+// their machine pools replace them. It then restores the snapshot onto the replacement machines and verifies the
+// cluster is ready, the workload is available and the corpus matches the snapshot.
+//
+// The client must be an admin client, to download the SSH keys of the machines. The cluster must store its snapshots in
+// S3, as the local snapshots of the destroyed machines are lost with them.
+func RunScenario(client *rancher.Client, clusterName string, scenario Scenario) error {
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	cluster, _, err := clusters.GetProvisioningClusterByName(client, clusterName, namespaces.FleetDefault)
+	if err != nil {
+		return err
+	}
+
+	if cluster.Spec.RKEConfig == nil || cluster.Spec.RKEConfig.ETCD == nil || cluster.Spec.RKEConfig.ETCD.S3 == nil {
+		return fmt.Errorf("cluster %s does not store its snapshots in S3, the only copy that survives the destroyed machines", clusterName)
+	}
+
+	allMachines, err := ListMachines(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	machines, err := ScenarioMachines(client, clusterName, scenario)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Deploying a workload on cluster %s", clusterName)
+	workload, err := deployment.CreateDeployment(client, clusterID, workloadNamespace, workloadReplicas, "", "", false, false, false, true)
+	if err != nil {
+		return err
+	}
+
+	corpus, err := etcdsnapshot.SeedIntegrityCorpus(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	snapshotID, err := takeS3Snapshot(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = corpus.ModifyAfterSnapshot(client)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Running disaster recovery scenario %s on cluster %s: destroying %d machines", scenario, clusterName, len(machines))
+	err = DestroyMachines(client, machines)
+	if err != nil {
+		return err
+	}
+
+	// Without etcd quorum the replacement etcd machines can not join, so the snapshot is restored onto the surviving
+	// etcd machine right away. Otherwise the replacements must be provisioned for the snapshot to be restored onto.
+	if scenario != EtcdQuorumLoss {
+		err = WaitForReplacementMachines(client, clusterName, machines, len(allMachines))
+		if err != nil {
+			return err
+		}
+	}
+
+	logrus.Infof("Restoring snapshot %s onto the replacement machines of cluster %s", snapshotID, clusterName)
+	err = etcdsnapshot.RestoreAndValidateSnapshotV2Prov(client, snapshotID, &etcdsnapshot.Config{
+		SnapshotRestore:   restoreNone,
+		RecurringRestores: 1,
+	}, cluster, clusterID)
+	if err != nil {
+		return err
+	}
+
+	err = nodestat.AllMachineReady(client, clusterID, defaults.ThirtyMinuteTimeout)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Verifying workload %s/%s is available", workload.Namespace, workload.Name)
+	err = shepherdcharts.WatchAndWaitDeployments(client, clusterID, workload.Namespace, metav1.ListOptions{
+		FieldSelector: "metadata.name=" + workload.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	return corpus.VerifyRestored(client)
+}
+
+// takeS3Snapshot is a private helper function that takes an etcd snapshot of the cluster and returns the ID of its S3
+// copy.
+func takeS3Snapshot(client *rancher.Client, clusterName string) (string, error) {
+	snapshots, err := shepherdsnapshot.CreateRKE2K3SSnapshot(client, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, snapshot := range snapshots {
+		if snapshot.Annotations[storageAnnotation] == s3Storage {
+			return snapshot.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("no snapshot of cluster %s was uploaded to s3", clusterName)
+}
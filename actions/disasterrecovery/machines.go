@@ -0,0 +1,171 @@
+package disasterrecovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	"github.com/rancher/shepherd/extensions/sshkeys"
+	"github.com/rancher/shepherd/pkg/nodes"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	shutdownCommand       = "sudo shutdown -h now"
+	clusterNameLabel      = "cluster.x-k8s.io/cluster-name"
+	etcdRoleLabel         = "rke.cattle.io/etcd-role"
+	controlPlaneRoleLabel = "rke.cattle.io/control-plane-role"
+	initNodeLabel         = "rke.cattle.io/init-node"
+	provisionedPhase      = "Provisioned"
+	runningPhase          = "Running"
+	minimumQuorumMachines = 3
+)
+
+// ListMachines is a helper function that returns the machines of an RKE2 or K3s node driver cluster.
+func ListMachines(client *rancher.Client, clusterName string) ([]steveV1.SteveAPIObject, error) {
+	query, err := url.ParseQuery("labelSelector=" + clusterNameLabel + "=" + clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	machineList, err := client.Steve.SteveType(stevetypes.Machine).NamespacedSteveClient(namespaces.FleetDefault).List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return machineList.Data, nil
+}
+
+// ScenarioMachines is a helper function that returns the machines a disaster recovery scenario destroys. For
+// EtcdQuorumLoss it is a majority of the etcd machines, sparing the init node, which requires at least three etcd
+// machines for a survivor to be left.
+func ScenarioMachines(client *rancher.Client, clusterName string, scenario Scenario) ([]steveV1.SteveAPIObject, error) {
+	machines, err := ListMachines(client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	var scenarioMachines []steveV1.SteveAPIObject
+	switch scenario {
+	case EtcdQuorumLoss:
+		for _, machine := range machines {
+			if machine.Labels[etcdRoleLabel] == "true" {
+				scenarioMachines = append(scenarioMachines, machine)
+			}
+		}
+
+		if len(scenarioMachines) < minimumQuorumMachines {
+			return nil, fmt.Errorf("cluster %s has %d etcd machines, at least %d are required to lose quorum with a survivor", clusterName, len(scenarioMachines), minimumQuorumMachines)
+		}
+
+		sort.SliceStable(scenarioMachines, func(i, j int) bool {
+			return scenarioMachines[i].Labels[initNodeLabel] != "true" && scenarioMachines[j].Labels[initNodeLabel] == "true"
+		})
+
+		scenarioMachines = scenarioMachines[:len(scenarioMachines)/2+1]
+	case ControlPlaneLoss:
+		for _, machine := range machines {
+			if machine.Labels[controlPlaneRoleLabel] == "true" {
+				scenarioMachines = append(scenarioMachines, machine)
+			}
+		}
+	case TotalLoss:
+		scenarioMachines = machines
+	default:
+		return nil, fmt.Errorf("unknown disaster recovery scenario %s", scenario)
+	}
+
+	if len(scenarioMachines) == 0 {
+		return nil, fmt.Errorf("cluster %s has no machines to destroy for scenario %s", clusterName, scenario)
+	}
+
+	return scenarioMachines, nil
+}
+
+// DestroyMachines is a helper function that shuts the machines down over ssh, outside of Rancher, to simulate their
+// loss, then deletes them so their machine pools provision replacements. It does not wait for the deletion, which can
+// not complete for etcd machines until etcd is restored.
+func DestroyMachines(client *rancher.Client, machines []steveV1.SteveAPIObject) error {
+	var sshNodes []*nodes.Node
+	for _, machine := range machines {
+		sshKey, sshUser, sshIPAddress, err := sshkeys.DownloadSSHCredentials(client, machine.Name)
+		if err != nil {
+			return err
+		}
+
+		sshNodes = append(sshNodes, &nodes.Node{
+			NodeID:          machine.ID,
+			PublicIPAddress: sshIPAddress,
+			SSHUser:         sshUser,
+			SSHKey:          []byte(sshKey),
+		})
+	}
+
+	for i, sshNode := range sshNodes {
+		logrus.Infof("Shutting down machine %s", machines[i].Name)
+		_, err := sshNode.ExecuteCommand(shutdownCommand)
+		if err != nil && !errors.Is(err, &ssh.ExitMissingError{}) {
+			return err
+		}
+	}
+
+	for i := range machines {
+		logrus.Infof("Deleting machine %s", machines[i].Name)
+		err := client.Steve.SteveType(stevetypes.Machine).Delete(&machines[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitForReplacementMachines is a helper function that waits for the cluster to have as many provisioned machines as
+// before the disaster, not counting the destroyed machines.
+func WaitForReplacementMachines(client *rancher.Client, clusterName string, destroyed []steveV1.SteveAPIObject, expected int) error {
+	destroyedNames := map[string]bool{}
+	for _, machine := range destroyed {
+		destroyedNames[machine.Name] = true
+	}
+
+	logrus.Infof("Waiting for %d replacement machines of cluster %s", len(destroyed), clusterName)
+	var provisioned int
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.ThirtyMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		machines, err := ListMachines(client, clusterName)
+		if err != nil {
+			return false, nil
+		}
+
+		provisioned = 0
+		for _, machine := range machines {
+			if destroyedNames[machine.Name] || machine.DeletionTimestamp != nil {
+				continue
+			}
+
+			status, ok := machine.Status.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if status["phase"] == provisionedPhase || status["phase"] == runningPhase {
+				provisioned++
+			}
+		}
+
+		return provisioned >= expected, nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("cluster %s has %d of %d provisioned machines", clusterName, provisioned, expected), err)
+	}
+
+	return nil
+}
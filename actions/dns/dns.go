@@ -0,0 +1,322 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/pkg/config"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	defaultClusterDomain = "cluster.local"
+	clusterDomainKey     = "cluster-domain"
+	headlessServiceName  = "headless"
+	externalServiceName  = "external"
+	// SlowQueryTime is the query time of a cluster record above which the query is logged as slow.
+	SlowQueryTime = time.Second
+
+	ConfigurationFileKey = "dnsInput"
+)
+
+// Config is the longest a query of a cluster record may take. Slow queries only fail the verification when
+// MaxQueryTimeMilliseconds is set, as the query time depends on the infrastructure of the cluster.
+type Config struct {
+	MaxQueryTimeMilliseconds int `json:"maxQueryTimeMilliseconds" yaml:"maxQueryTimeMilliseconds"`
+}
+
+// Record is a DNS name queried from the probe pods and the addresses it is expected to resolve to. Search names are
+// resolved through the search path of the pod.
+type Record struct {
+	Description string
+	Name        string
+	Addresses   []string
+	Search      bool
+}
+
+// Result is the resolution of a record from a probe pod.
+type Result struct {
+	Pod       connectivity.ProbePod
+	Record    Record
+	Addresses []string
+	QueryTime time.Duration
+}
+
+// VerifyClusterDNS is a helper function that verifies in-cluster DNS of an RKE2 or K3s cluster from a probe pod on
+// every node: service, headless service pod, ExternalName and search path records resolve to the expected addresses,
+// within the max query time of the config when one is set, and NodeLocal DNSCache answers the queries when it is
+// enabled on the cluster. Records of newly created services are retried until they have propagated.
+func VerifyClusterDNS(t *testing.T, client *rancher.Client, cluster *steveV1.SteveAPIObject) {
+	status := &provv1.ClusterStatus{}
+	err := steveV1.ConvertToK8sType(cluster.Status, status)
+	require.NoError(t, err)
+
+	spec := &provv1.ClusterSpec{}
+	err = steveV1.ConvertToK8sType(cluster.Spec, spec)
+	require.NoError(t, err)
+
+	dnsConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, dnsConfig)
+	maxQueryTime := time.Duration(dnsConfig.MaxQueryTimeMilliseconds) * time.Millisecond
+
+	logrus.Infof("Deploying DNS probe pods (%s)", cluster.Name)
+	probes, err := connectivity.DeployProbes(client, status.ClusterName, []connectivity.ProbeNamespace{{}, {}})
+	require.NoError(t, err)
+
+	records, err := ClusterRecords(client, probes, ClusterDomain(spec))
+	require.NoError(t, err)
+
+	nodeLocal := NodeLocalDNSCache(spec)
+	if nodeLocal != nil {
+		logrus.Infof("Verifying NodeLocal DNSCache %s (%s)", nodeLocal.Address, cluster.Name)
+		err = VerifyNodeLocalDNSCache(client, probes, nodeLocal, records)
+		require.NoError(t, err)
+	}
+
+	logrus.Infof("Resolving %d records from %d probe pods (%s)", len(records), len(probes.Pods), cluster.Name)
+	var resultsErr error
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		results, err := Resolve(client, probes, records)
+		if err != nil {
+			resultsErr = err
+			return false, nil
+		}
+
+		resultsErr = VerifyResults(results, maxQueryTime)
+
+		return resultsErr == nil, nil
+	})
+	require.NoError(t, errors.Join(resultsErr, err))
+}
+
+// ClusterDomain returns the cluster domain of an RKE2 or K3s cluster.
+func ClusterDomain(spec *provv1.ClusterSpec) string {
+	if spec.RKEConfig != nil && spec.RKEConfig.MachineGlobalConfig.Data != nil {
+		if domain, ok := spec.RKEConfig.MachineGlobalConfig.Data[clusterDomainKey].(string); ok && domain != "" {
+			return domain
+		}
+	}
+
+	return defaultClusterDomain
+}
+
+// ClusterRecords is a helper function that creates a headless service and an ExternalName service in the first probe
+// namespace and returns the records to query from every probe pod: the service of each probe namespace by its fully
+// qualified name, the headless service and a pod record of each of its endpoints, the ExternalName service, and the
+// search path short names of the services, as the service name in the first namespace and service.namespace for the
+// others.
+func ClusterRecords(client *rancher.Client, probes *connectivity.Probes, clusterDomain string) ([]Record, error) {
+	namespace := probes.Namespaces[0].Name
+
+	headlessSpec := connectivity.NewProbeServiceSpec(corev1.ServiceTypeClusterIP)
+	headlessSpec.ClusterIP = corev1.ClusterIPNone
+
+	headlessService, err := services.CreateService(client, probes.ClusterID, namegen.AppendRandomString(headlessServiceName), namespace, headlessSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for i, service := range probes.Services {
+		serviceName := fqdn(service.Name, service.Namespace, clusterDomain)
+		records = append(records, Record{
+			Description: "service",
+			Name:        serviceName,
+			Addresses:   service.ClusterIPs,
+		})
+
+		shortName := service.Name
+		if i > 0 {
+			shortName = service.Name + "." + service.Namespace
+		}
+
+		records = append(records, Record{
+			Description: "search path short name",
+			Name:        shortName,
+			Addresses:   service.ClusterIPs,
+			Search:      true,
+		})
+	}
+
+	var endpointAddresses []string
+	for _, pod := range probes.Pods {
+		if pod.Namespace != namespace {
+			continue
+		}
+
+		endpointAddresses = append(endpointAddresses, pod.IPs...)
+		for _, address := range pod.IPs {
+			records = append(records, Record{
+				Description: "headless service pod",
+				Name:        dashed(address) + "." + fqdn(headlessService.Name, namespace, clusterDomain),
+				Addresses:   []string{address},
+			})
+		}
+	}
+
+	records = append(records, Record{
+		Description: "headless service",
+		Name:        fqdn(headlessService.Name, namespace, clusterDomain),
+		Addresses:   endpointAddresses,
+	})
+
+	externalService, err := services.CreateService(client, probes.ClusterID, namegen.AppendRandomString(externalServiceName), namespace, corev1.ServiceSpec{
+		Type:         corev1.ServiceTypeExternalName,
+		ExternalName: fqdn(probes.Services[len(probes.Services)-1].Name, probes.Services[len(probes.Services)-1].Namespace, clusterDomain),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	records = append(records, Record{
+		Description: "ExternalName service",
+		Name:        fqdn(externalService.Name, namespace, clusterDomain),
+		Addresses:   probes.Services[len(probes.Services)-1].ClusterIPs,
+	})
+
+	return records, nil
+}
+
+// Resolve is a helper function that queries every record from every probe pod, over A and AAAA, and returns the
+// results. Each probe pod runs all of its queries in a single exec.
+func Resolve(client *rancher.Client, probes *connectivity.Probes, records []Record) ([]Result, error) {
+	var results []Result
+	for _, pod := range probes.Pods {
+		output, err := connectivity.ExecInProbe(client, probes.ClusterID, pod, resolveScript(records))
+		if err != nil {
+			return nil, err
+		}
+
+		podResults, err := parseResults(pod, records, output)
+		if err != nil {
+			return nil, fmt.Errorf("resolving from pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+
+		results = append(results, podResults...)
+	}
+
+	return results, nil
+}
+
+// VerifyResults is a helper function that verifies that every record resolved to all of its expected addresses, and
+// only to them, within maxQueryTime unless it is zero, and logs the median and maximum query times. Queries slower
+// than SlowQueryTime are logged as warnings.
+func VerifyResults(results []Result, maxQueryTime time.Duration) error {
+	var errs []error
+	var queryTimes []time.Duration
+	for _, result := range results {
+		queryTimes = append(queryTimes, result.QueryTime)
+
+		source := result.Pod.Namespace + "/" + result.Pod.Name + " on " + result.Pod.Node
+		for _, address := range result.Record.Addresses {
+			if !slices.Contains(result.Addresses, address) {
+				errs = append(errs, fmt.Errorf("%s record %s resolved to %v from %s, expected %v", result.Record.Description, result.Record.Name, result.Addresses, source, result.Record.Addresses))
+				break
+			}
+		}
+
+		for _, address := range result.Addresses {
+			if !slices.Contains(result.Record.Addresses, address) {
+				errs = append(errs, fmt.Errorf("%s record %s resolved to unexpected address %s from %s", result.Record.Description, result.Record.Name, address, source))
+				break
+			}
+		}
+
+		if maxQueryTime > 0 && result.QueryTime > maxQueryTime {
+			errs = append(errs, fmt.Errorf("%s record %s took %s to resolve from %s, longer than %s", result.Record.Description, result.Record.Name, result.QueryTime, source, maxQueryTime))
+		} else if result.QueryTime > SlowQueryTime {
+			logrus.Warnf("%s record %s took %s to resolve from %s", result.Record.Description, result.Record.Name, result.QueryTime, source)
+		}
+	}
+
+	if len(queryTimes) > 0 {
+		sort.Slice(queryTimes, func(i, j int) bool { return queryTimes[i] < queryTimes[j] })
+		logrus.Infof("Resolved %d queries, median query time %s, maximum query time %s", len(queryTimes), queryTimes[len(queryTimes)/2], queryTimes[len(queryTimes)-1])
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolveScript is a private helper function that returns a shell script querying every record over A and AAAA, which
+// prints a record with the index of the record, the resolved addresses and the summed query time in milliseconds.
+// Records end with a semicolon rather than a newline, as the exec output streamer trims the whitespace of every chunk
+// it reads.
+func resolveScript(records []Record) string {
+	var script strings.Builder
+	for i, record := range records {
+		search := "+nosearch"
+		if record.Search {
+			search = "+search"
+		}
+
+		script.WriteString(fmt.Sprintf("out=$(dig %[1]s +noall +answer +stats -t A %[2]s; dig %[1]s +noall +answer +stats -t AAAA %[2]s); ", search, record.Name))
+		script.WriteString(`addresses=$(echo "$out" | awk '$4 == "A" || $4 == "AAAA" { printf "%s ", $5 }'); `)
+		script.WriteString(`time=$(echo "$out" | awk '/Query time:/ { sum += $4 } END { printf "%d", sum }'); `)
+		script.WriteString(fmt.Sprintf(`echo "%d|$addresses|$time;"; `, i))
+	}
+
+	return script.String()
+}
+
+// parseResults is a private helper function that returns the results of a probe pod from the output of its resolve
+// script.
+func parseResults(pod connectivity.ProbePod, records []Record, output string) ([]Result, error) {
+	var results []Result
+	for _, line := range strings.Split(output, ";") {
+		fields := strings.Split(strings.TrimSpace(line), "|")
+		if len(fields) != 3 {
+			continue
+		}
+
+		index, err := strconv.Atoi(fields[0])
+		if err != nil || index < 0 || index >= len(records) {
+			continue
+		}
+
+		milliseconds, err := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("parsing query time of %s: %w", records[index].Name, err)
+		}
+
+		results = append(results, Result{
+			Pod:       pod,
+			Record:    records[index],
+			Addresses: strings.Fields(fields[1]),
+			QueryTime: time.Duration(milliseconds) * time.Millisecond,
+		})
+	}
+
+	if len(results) != len(records) {
+		return nil, fmt.Errorf("expected %d results, got %d: %s", len(records), len(results), output)
+	}
+
+	return results, nil
+}
+
+// fqdn is a private helper function that returns the fully qualified name of a service.
+func fqdn(service, namespace, clusterDomain string) string {
+	return service + "." + namespace + ".svc." + clusterDomain
+}
+
+// dashed is a private helper function that returns the dashed form of an address used by the pod records of headless
+// services.
+func dashed(address string) string {
+	return strings.NewReplacer(".", "-", ":", "-").Replace(address)
+}
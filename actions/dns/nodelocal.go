@@ -0,0 +1,129 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/tests/actions/connectivity"
+	"github.com/rancher/tests/actions/kubeapi/workloads/daemonsets"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	corednsChart            = "rke2-coredns"
+	nodeLocalValuesKey      = "nodelocal"
+	nodeLocalAddressKey     = "ip_address"
+	nodeLocalEnabledKey     = "enabled"
+	defaultNodeLocalAddress = "169.254.20.10"
+	nodeLocalDaemonSet      = "node-local-dns"
+	nodeLocalMetricsPort    = 9253
+	kubeSystemNamespace     = "kube-system"
+	requestsMetric          = "coredns_dns_requests_total"
+)
+
+// NodeLocal is the NodeLocal DNSCache of a cluster, listening on the link-local address of every node.
+type NodeLocal struct {
+	Address string
+}
+
+// NodeLocalDNSCache returns the NodeLocal DNSCache of an RKE2 cluster from the rke2-coredns chart values, or nil when it
+// is not enabled.
+func NodeLocalDNSCache(spec *provv1.ClusterSpec) *NodeLocal {
+	if spec.RKEConfig == nil || spec.RKEConfig.ChartValues.Data == nil {
+		return nil
+	}
+
+	corednsValues, ok := spec.RKEConfig.ChartValues.Data[corednsChart].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	nodeLocalValues, ok := corednsValues[nodeLocalValuesKey].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if enabled, ok := nodeLocalValues[nodeLocalEnabledKey].(bool); !ok || !enabled {
+		return nil
+	}
+
+	address, ok := nodeLocalValues[nodeLocalAddressKey].(string)
+	if !ok || address == "" {
+		address = defaultNodeLocalAddress
+	}
+
+	return &NodeLocal{Address: address}
+}
+
+// VerifyNodeLocalDNSCache is a helper function that verifies that the NodeLocal DNSCache daemonset of a cluster is ready
+// and that it answers the queries of the probe pods: the request count of the cache on the node of every probe pod
+// increases while the pod resolves the records.
+func VerifyNodeLocalDNSCache(client *rancher.Client, probes *connectivity.Probes, nodeLocal *NodeLocal, records []Record) error {
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		daemonSet, err := daemonsets.GetDaemonsetByName(client, probes.ClusterID, kubeSystemNamespace, nodeLocalDaemonSet)
+		if err != nil {
+			return false, nil
+		}
+
+		return daemonSet.Status.DesiredNumberScheduled > 0 && daemonSet.Status.NumberReady == daemonSet.Status.DesiredNumberScheduled, nil
+	})
+	if err != nil {
+		return fmt.Errorf("daemonset %s/%s is not ready: %w", kubeSystemNamespace, nodeLocalDaemonSet, err)
+	}
+
+	metricsURL := "http://" + net.JoinHostPort(nodeLocal.Address, strconv.Itoa(nodeLocalMetricsPort)) + "/metrics"
+
+	var errs []error
+	for _, pod := range probes.Pods {
+		before, err := nodeLocalRequests(client, probes.ClusterID, pod, metricsURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		_, err = connectivity.ExecInProbe(client, probes.ClusterID, pod, resolveScript(records))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		after, err := nodeLocalRequests(client, probes.ClusterID, pod, metricsURL)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if after <= before {
+			errs = append(errs, fmt.Errorf("NodeLocal DNSCache on node %s did not answer the queries of pod %s/%s: %s stayed at %d", pod.Node, pod.Namespace, pod.Name, requestsMetric, before))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// nodeLocalRequests is a private helper function that returns the total request count of the NodeLocal DNSCache on the
+// node of a probe pod, as scraped from the pod.
+func nodeLocalRequests(client *rancher.Client, clusterID string, pod connectivity.ProbePod, metricsURL string) (int64, error) {
+	command := fmt.Sprintf(`curl -s -m 5 '%s' | awk '/^%s/ { sum += $NF } END { printf "%%d;", sum }'`, metricsURL, requestsMetric)
+
+	output, err := connectivity.ExecInProbe(client, clusterID, pod, command)
+	if err != nil {
+		return 0, fmt.Errorf("scraping NodeLocal DNSCache metrics from pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	value, _, _ := strings.Cut(strings.TrimSpace(output), ";")
+
+	requests, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing NodeLocal DNSCache metrics from pod %s/%s: %q", pod.Namespace, pod.Name, output)
+	}
+
+	return requests, nil
+}
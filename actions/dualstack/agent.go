@@ -0,0 +1,115 @@
+package dualstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/rancher/norman/types"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	cattleSystemNamespace = "cattle-system"
+	clusterAgentSelector  = "app=cattle-cluster-agent"
+	connectedCondition    = "Connected"
+	conditionTrue         = "True"
+	activeState           = "active"
+)
+
+// VerifyAgentTunnels is a helper function that verifies that the Rancher agents of a cluster are connected over the
+// addresses of its families: the cluster agent tunnel is connected and proxies requests, its pods have addresses of
+// the cluster families and every node is active in Rancher. On an IPv6 only cluster it also verifies that the nodes
+// have IPv6 only addresses, so the tunnels can not be using IPv4.
+func VerifyAgentTunnels(client *rancher.Client, clusterID string, families []corev1.IPFamily) error {
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		cluster, err := client.Management.Cluster.ByID(clusterID)
+		if err != nil {
+			return false, nil
+		}
+
+		for _, condition := range cluster.Conditions {
+			if condition.Type == connectedCondition {
+				return condition.Status == conditionTrue, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("cluster %s agent tunnel is not connected: %w", clusterID, err)
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	nodeList, err := wranglerContext.Core.Node().List(metav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("proxying through the cluster %s agent tunnel: %w", clusterID, err)
+	}
+
+	var errs []error
+	if len(families) == 1 && families[0] == corev1.IPv6Protocol {
+		for _, node := range nodeList.Items {
+			err = verifyFamilies("node "+node.Name, NodeAddresses(node), families, true)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	agentPods, err := wranglerContext.Core.Pod().List(cattleSystemNamespace, metav1.ListOptions{
+		LabelSelector: clusterAgentSelector,
+	})
+	if err != nil {
+		return err
+	}
+
+	runningAgents := 0
+	for _, pod := range agentPods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		runningAgents++
+
+		var addresses []string
+		for _, podIP := range pod.Status.PodIPs {
+			addresses = append(addresses, podIP.IP)
+		}
+
+		for _, address := range addresses {
+			if !slices.Contains(families, connectivity.AddressFamily(address)) {
+				errs = append(errs, fmt.Errorf("cluster agent pod %s has %s address %s, expected only %v", pod.Name, connectivity.AddressFamily(address), address, families))
+			}
+		}
+	}
+
+	if runningAgents == 0 {
+		errs = append(errs, fmt.Errorf("cluster %s has no running cluster agent", clusterID))
+	}
+
+	nodes, err := client.Management.Node.List(&types.ListOpts{Filters: map[string]interface{}{
+		"clusterId": clusterID,
+	}})
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes.Data {
+		if node.State != activeState {
+			errs = append(errs, fmt.Errorf("node %s of cluster %s is %s in Rancher", node.NodeName, clusterID, node.State))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,167 @@
+package dualstack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/ingresses"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	nodePortServiceName = "nodeport"
+	ingressName         = "ingress"
+	ingressHostSuffix   = ".dualstack.local"
+	ingressPort         = 80
+	httpOK              = "200"
+)
+
+// VerifyFamilyConnectivity is a helper function that verifies connectivity over each IP family separately: the
+// pod-to-pod and pod-to-service matrix of the probes, and HTTP from a probe pod to a NodePort service and an ingress on
+// the address of the family of every probe node.
+func VerifyFamilyConnectivity(client *rancher.Client, probes *connectivity.Probes, families []corev1.IPFamily) error {
+	namespace := probes.Namespaces[0].Name
+
+	nodePortService, err := services.CreateService(client, probes.ClusterID, namegen.AppendRandomString(nodePortServiceName), namespace, connectivity.NewProbeServiceSpec(corev1.ServiceTypeNodePort))
+	if err != nil {
+		return err
+	}
+
+	var nodePort int32
+	for _, port := range nodePortService.Spec.Ports {
+		if port.Port == int32(connectivity.Port(connectivity.HTTP)) {
+			nodePort = port.NodePort
+		}
+	}
+
+	host := namegen.AppendRandomString(ingressName) + ingressHostSuffix
+	pathType := networkingv1.PathTypePrefix
+	_, err = ingresses.CreateIngress(client, probes.ClusterID, namegen.AppendRandomString(ingressName), namespace, &networkingv1.IngressSpec{
+		Rules: []networkingv1.IngressRule{{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{{
+						Path:     "/",
+						PathType: &pathType,
+						Backend: networkingv1.IngressBackend{
+							Service: &networkingv1.IngressServiceBackend{
+								Name: probes.Services[0].Name,
+								Port: networkingv1.ServiceBackendPort{Number: int32(connectivity.Port(connectivity.HTTP))},
+							},
+						},
+					}},
+				},
+			},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	nodeAddresses, err := probeNodeAddresses(client, probes)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, family := range families {
+		logrus.Infof("Verifying the connectivity matrix over %s", family)
+		familyProbes, err := probes.ForFamily(family)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		err = connectivity.ValidateConnectivity(client, familyProbes, "")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", family, err))
+		}
+
+		for node, addresses := range nodeAddresses {
+			address := connectivity.FamilyAddress(addresses, family)
+			if address == "" {
+				errs = append(errs, fmt.Errorf("node %s has no %s address: %v", node, family, addresses))
+				continue
+			}
+
+			logrus.Infof("Verifying NodePort %d and ingress %s on node %s over %s", nodePort, host, node, family)
+			err = probeHTTP(client, probes, "http://"+net.JoinHostPort(address, strconv.Itoa(int(nodePort)))+"/", "")
+			if err != nil {
+				errs = append(errs, fmt.Errorf("NodePort on node %s over %s: %w", node, family, err))
+			}
+
+			err = probeHTTP(client, probes, "http://"+net.JoinHostPort(address, strconv.Itoa(ingressPort))+"/", host)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ingress on node %s over %s: %w", node, family, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// probeNodeAddresses is a private helper function that returns the addresses of the nodes the probe pods run on.
+func probeNodeAddresses(client *rancher.Client, probes *connectivity.Probes) (map[string][]string, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, probes.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeAddresses := map[string][]string{}
+	for _, pod := range probes.Pods {
+		if _, ok := nodeAddresses[pod.Node]; ok {
+			continue
+		}
+
+		node, err := wranglerContext.Core.Node().Get(pod.Node, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		nodeAddresses[pod.Node] = NodeAddresses(*node)
+	}
+
+	return nodeAddresses, nil
+}
+
+// probeHTTP is a private helper function that requests a URL from the first probe pod, with the host header when it is
+// set, until it answers with 200 OK.
+func probeHTTP(client *rancher.Client, probes *connectivity.Probes, url, host string) error {
+	command := "curl -s -m 5 -o /dev/null -w '%{http_code}' "
+	if host != "" {
+		command += "-H 'Host: " + host + "' "
+	}
+
+	command += "'" + url + "'"
+
+	var output string
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		var err error
+		output, err = connectivity.ExecInProbe(client, probes.ClusterID, probes.Pods[0], command)
+		if err != nil {
+			return false, nil
+		}
+
+		return strings.TrimSpace(output) == httpOK, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s answered %q: %w", url, output, err)
+	}
+
+	return nil
+}
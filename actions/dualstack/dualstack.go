@@ -0,0 +1,256 @@
+package dualstack
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/provisioninginput"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ExpectedFamilies is a helper function that returns the IP families a cluster is provisioned with, in the order of
+// its cluster CIDRs. Without cluster CIDRs the cluster is IPv6 only when it is an IPv6 cluster, IPv4 only otherwise.
+func ExpectedFamilies(networking *provisioninginput.Networking, ipv6Cluster bool) []corev1.IPFamily {
+	var families []corev1.IPFamily
+	if networking != nil {
+		for _, cidr := range strings.Split(networking.ClusterCIDR, ",") {
+			address, _, _ := strings.Cut(strings.TrimSpace(cidr), "/")
+
+			family := connectivity.AddressFamily(address)
+			if family != "" && !slices.Contains(families, family) {
+				families = append(families, family)
+			}
+		}
+	}
+
+	if len(families) > 0 {
+		return families
+	}
+
+	if ipv6Cluster {
+		return []corev1.IPFamily{corev1.IPv6Protocol}
+	}
+
+	return []corev1.IPFamily{corev1.IPv4Protocol}
+}
+
+// VerifyDualStack is a helper function that verifies the networking of a cluster provisioned with the given IP
+// families: the addresses of its nodes, pods and services, the ipFamilyPolicy variants of ClusterIP services,
+// connectivity over each family and the Rancher agent tunnels.
+func VerifyDualStack(t *testing.T, client *rancher.Client, cluster *steveV1.SteveAPIObject, families []corev1.IPFamily) {
+	clusterID, err := clusters.GetClusterIDByName(client, cluster.Name)
+	require.NoError(t, err)
+
+	logrus.Infof("Verifying the %v addresses of nodes, pods and services (%s)", families, cluster.Name)
+	err = VerifyAddressFamilies(client, clusterID, families)
+	require.NoError(t, err)
+
+	logrus.Infof("Deploying probe pods (%s)", cluster.Name)
+	probes, err := connectivity.DeployProbes(client, clusterID, []connectivity.ProbeNamespace{{}})
+	require.NoError(t, err)
+
+	logrus.Infof("Verifying ipFamilyPolicy variants of ClusterIP services (%s)", cluster.Name)
+	err = VerifyIPFamilyPolicies(client, clusterID, probes.Namespaces[0].Name, families)
+	require.NoError(t, err)
+
+	logrus.Infof("Verifying connectivity over %v (%s)", families, cluster.Name)
+	err = VerifyFamilyConnectivity(client, probes, families)
+	require.NoError(t, err)
+
+	logrus.Infof("Verifying the Rancher agent tunnels (%s)", cluster.Name)
+	err = VerifyAgentTunnels(client, clusterID, families)
+	require.NoError(t, err)
+}
+
+// VerifyAddressFamilies is a helper function that verifies that every node, pod and service of a cluster has
+// addresses of the expected families, and of no other family.
+func VerifyAddressFamilies(client *rancher.Client, clusterID string, families []corev1.IPFamily) error {
+	return errors.Join(
+		VerifyNodeFamilies(client, clusterID, families),
+		VerifyPodFamilies(client, clusterID, families),
+		VerifyServiceFamilies(client, clusterID, families),
+	)
+}
+
+// VerifyNodeFamilies is a helper function that verifies that every node of a cluster has an internal or external
+// address of each expected family, and pod CIDRs of the expected families.
+func VerifyNodeFamilies(client *rancher.Client, clusterID string, families []corev1.IPFamily) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	nodeList, err := wranglerContext.Core.Node().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, node := range nodeList.Items {
+		addresses := NodeAddresses(node)
+
+		err = verifyFamilies("node "+node.Name, addresses, families, false)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if len(node.Spec.PodCIDRs) == 0 {
+			continue
+		}
+
+		var podCIDRAddresses []string
+		for _, podCIDR := range node.Spec.PodCIDRs {
+			address, _, _ := strings.Cut(podCIDR, "/")
+			podCIDRAddresses = append(podCIDRAddresses, address)
+		}
+
+		err = verifyFamilies("pod CIDRs of node "+node.Name, podCIDRAddresses, families, true)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifyPodFamilies is a helper function that verifies that every running pod of a cluster not on the host network
+// has an address of each expected family, and of no other family.
+func VerifyPodFamilies(client *rancher.Client, clusterID string, families []corev1.IPFamily) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	podList, err := wranglerContext.Core.Pod().List("", metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, pod := range podList.Items {
+		if pod.Spec.HostNetwork || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+
+		var addresses []string
+		for _, podIP := range pod.Status.PodIPs {
+			addresses = append(addresses, podIP.IP)
+		}
+
+		err = verifyFamilies("pod "+pod.Namespace+"/"+pod.Name, addresses, families, true)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifyServiceFamilies is a helper function that verifies that the cluster IPs of every service of a cluster match
+// its IP families, are of the expected families, and that dual-stack services have an address of each family of a
+// dual-stack cluster.
+func VerifyServiceFamilies(client *rancher.Client, clusterID string, families []corev1.IPFamily) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	serviceList, err := wranglerContext.Core.Service().List("", metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, service := range serviceList.Items {
+		if service.Spec.Type == corev1.ServiceTypeExternalName || service.Spec.ClusterIP == corev1.ClusterIPNone {
+			continue
+		}
+
+		err = verifyServiceClusterIPs(&service, families)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NodeAddresses is a helper function that returns the internal and external IP addresses of a node.
+func NodeAddresses(node corev1.Node) []string {
+	var addresses []string
+	for _, address := range node.Status.Addresses {
+		if address.Type == corev1.NodeInternalIP || address.Type == corev1.NodeExternalIP {
+			addresses = append(addresses, address.Address)
+		}
+	}
+
+	return addresses
+}
+
+// verifyServiceClusterIPs is a private helper function that verifies the cluster IPs of a service against its IP
+// families and the families of the cluster.
+func verifyServiceClusterIPs(service *corev1.Service, families []corev1.IPFamily) error {
+	name := "service " + service.Namespace + "/" + service.Name
+
+	if len(service.Spec.ClusterIPs) != len(service.Spec.IPFamilies) {
+		return fmt.Errorf("%s has cluster IPs %v for IP families %v", name, service.Spec.ClusterIPs, service.Spec.IPFamilies)
+	}
+
+	for i, clusterIP := range service.Spec.ClusterIPs {
+		if connectivity.AddressFamily(clusterIP) != service.Spec.IPFamilies[i] {
+			return fmt.Errorf("%s has cluster IP %s for IP family %s", name, clusterIP, service.Spec.IPFamilies[i])
+		}
+	}
+
+	for _, family := range service.Spec.IPFamilies {
+		if !slices.Contains(families, family) {
+			return fmt.Errorf("%s has IP family %s, expected only %v", name, family, families)
+		}
+	}
+
+	dualStack := service.Spec.IPFamilyPolicy != nil && *service.Spec.IPFamilyPolicy != corev1.IPFamilyPolicySingleStack
+	if dualStack {
+		return verifyFamilies(name, service.Spec.ClusterIPs, families, true)
+	}
+
+	return nil
+}
+
+// verifyFamilies is a private helper function that verifies that addresses cover each expected family and, when
+// exact, that they are of no other family.
+func verifyFamilies(name string, addresses []string, families []corev1.IPFamily, exact bool) error {
+	var found []corev1.IPFamily
+	for _, address := range addresses {
+		family := connectivity.AddressFamily(address)
+		if family == "" {
+			continue
+		}
+
+		if exact && !slices.Contains(families, family) {
+			return fmt.Errorf("%s has %s address %s, expected only %v", name, family, address, families)
+		}
+
+		if !slices.Contains(found, family) {
+			found = append(found, family)
+		}
+	}
+
+	for _, family := range families {
+		if !slices.Contains(found, family) {
+			return fmt.Errorf("%s has no %s address: %v", name, family, addresses)
+		}
+	}
+
+	return nil
+}
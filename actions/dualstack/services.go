@@ -0,0 +1,103 @@
+package dualstack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const familyServiceName = "family"
+
+// familyPolicyCase is a ClusterIP service variant and the IP families it is expected to get, or whether its creation
+// is expected to be rejected.
+type familyPolicyCase struct {
+	name       string
+	policy     corev1.IPFamilyPolicy
+	ipFamilies []corev1.IPFamily
+	expected   []corev1.IPFamily
+	rejected   bool
+}
+
+// VerifyIPFamilyPolicies is a helper function that creates a ClusterIP service for each ipFamilyPolicy variant in a
+// namespace and verifies its cluster IPs: SingleStack gets the primary family of the cluster, or the family it
+// requests, PreferDualStack gets every family of the cluster and RequireDualStack gets both families of a dual-stack
+// cluster and is rejected by a single-stack one.
+func VerifyIPFamilyPolicies(client *rancher.Client, clusterID, namespace string, families []corev1.IPFamily) error {
+	dualStack := len(families) > 1
+
+	cases := []familyPolicyCase{
+		{name: "SingleStack", policy: corev1.IPFamilyPolicySingleStack, expected: families[:1]},
+		{name: "PreferDualStack", policy: corev1.IPFamilyPolicyPreferDualStack, expected: families},
+		{name: "RequireDualStack", policy: corev1.IPFamilyPolicyRequireDualStack, expected: families, rejected: !dualStack},
+	}
+
+	for _, family := range families {
+		cases = append(cases, familyPolicyCase{
+			name:       "SingleStack " + string(family),
+			policy:     corev1.IPFamilyPolicySingleStack,
+			ipFamilies: []corev1.IPFamily{family},
+			expected:   []corev1.IPFamily{family},
+		})
+	}
+
+	if dualStack {
+		cases = append(cases, familyPolicyCase{
+			name:       "RequireDualStack secondary family first",
+			policy:     corev1.IPFamilyPolicyRequireDualStack,
+			ipFamilies: []corev1.IPFamily{families[1], families[0]},
+			expected:   []corev1.IPFamily{families[1], families[0]},
+		})
+	}
+
+	var errs []error
+	for _, familyCase := range cases {
+		logrus.Infof("Creating a %s ClusterIP service in namespace %s", familyCase.name, namespace)
+
+		spec := connectivity.NewProbeServiceSpec(corev1.ServiceTypeClusterIP)
+		spec.IPFamilyPolicy = &familyCase.policy
+		spec.IPFamilies = familyCase.ipFamilies
+
+		service, err := services.CreateService(client, clusterID, namegen.AppendRandomString(familyServiceName), namespace, spec)
+		if familyCase.rejected {
+			if err == nil {
+				errs = append(errs, fmt.Errorf("%s service %s was not rejected by a single-stack cluster", familyCase.name, service.Name))
+			}
+
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("creating %s service: %w", familyCase.name, err))
+			continue
+		}
+
+		err = verifyClusterIPOrder(service, familyCase.expected)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s service: %w", familyCase.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifyClusterIPOrder is a private helper function that verifies that a service has a cluster IP of each expected
+// family, in order, and no other cluster IP.
+func verifyClusterIPOrder(service *corev1.Service, expected []corev1.IPFamily) error {
+	if len(service.Spec.ClusterIPs) != len(expected) {
+		return fmt.Errorf("service %s/%s has cluster IPs %v, expected %v", service.Namespace, service.Name, service.Spec.ClusterIPs, expected)
+	}
+
+	for i, clusterIP := range service.Spec.ClusterIPs {
+		if connectivity.AddressFamily(clusterIP) != expected[i] {
+			return fmt.Errorf("service %s/%s has cluster IPs %v, expected %v", service.Namespace, service.Name, service.Spec.ClusterIPs, expected)
+		}
+	}
+
+	return verifyServiceClusterIPs(service, expected)
+}
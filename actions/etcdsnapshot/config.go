@@ -19,4 +19,5 @@ type Config struct {
 	WorkerUnavailableValue       string        `json:"workerUnavailableValue" yaml:"workerUnavailableValue"`
 	RecurringRestores            int           `json:"recurringRestores" yaml:"recurringRestores"`
 	ReplaceRoles                 *ReplaceRoles `json:"replaceRoles" yaml:"replaceRoles"`
+	VerifyDataIntegrity          bool          `json:"verifyDataIntegrity" yaml:"verifyDataIntegrity"`
 }
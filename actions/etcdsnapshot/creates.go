@@ -25,6 +25,8 @@ import (
 	"github.com/rancher/tests/actions/config/defaults"
 	"github.com/rancher/tests/actions/scalinginput"
 	"github.com/rancher/tests/actions/services"
+	"github.com/rancher/tests/actions/upgradeinput"
+	"github.com/rancher/tests/actions/upgradestrategy"
 	deploy "github.com/rancher/tests/actions/workloads/deployment"
 	"github.com/sirupsen/logrus"
 	v1 "k8s.io/api/apps/v1"
@@ -80,17 +82,39 @@ func CreateAndValidateSnapshotRestore(client *rancher.Client, clusterName string
 		return err
 	}
 
+	var corpus *IntegrityCorpus
+	if etcdRestore.VerifyDataIntegrity {
+		corpus, err = SeedIntegrityCorpus(client, clusterID)
+		if err != nil {
+			return err
+		}
+	}
+
 	if isRKE1 {
 		cluster, snapshotName, postDeploymentResp, postServiceResp, err := CreateAndValidateSnapshotRKE1(client, podTemplate, deploymentTemplate, clusterName, clusterID, etcdRestore, isRKE1)
 		if err != nil {
 			return err
 		}
 
+		if corpus != nil {
+			err = corpus.ModifyAfterSnapshot(client)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = RestoreAndValidateSnapshotRKE1(client, snapshotName, etcdRestore, cluster, clusterID)
 		if err != nil {
 			return err
 		}
 
+		if corpus != nil {
+			err = corpus.VerifyRestored(client)
+			if err != nil {
+				return err
+			}
+		}
+
 		_, err = steveclient.SteveType(stevetypes.Deployment).ByID(postDeploymentResp.ID)
 		if err == nil {
 			return errors.New("expecting cluster restore to remove resource")
@@ -107,11 +131,25 @@ func CreateAndValidateSnapshotRestore(client *rancher.Client, clusterName string
 			return err
 		}
 
+		if corpus != nil {
+			err = corpus.ModifyAfterSnapshot(client)
+			if err != nil {
+				return err
+			}
+		}
+
 		err = RestoreAndValidateSnapshotV2Prov(client, snapshotName, etcdRestore, cluster, clusterID)
 		if err != nil {
 			return err
 		}
 
+		if corpus != nil {
+			err = corpus.VerifyRestored(client)
+			if err != nil {
+				return err
+			}
+		}
+
 		_, err = steveclient.SteveType(stevetypes.Deployment).ByID(postDeploymentResp.ID)
 		if err == nil {
 			return errors.New("expecting cluster restore to remove resource")
@@ -390,9 +428,17 @@ func CreateAndValidateSnapshotV2Prov(client *rancher.Client, podTemplate *corev1
 
 		clusterObject.Spec.KubernetesVersion = upgradeKubernetesVersion
 
+		var observer *upgradestrategy.Observer
 		if etcdRestore.SnapshotRestore == all && etcdRestore.ControlPlaneConcurrencyValue != "" && etcdRestore.WorkerConcurrencyValue != "" {
 			clusterObject.Spec.RKEConfig.UpgradeStrategy.ControlPlaneConcurrency = etcdRestore.ControlPlaneConcurrencyValue
 			clusterObject.Spec.RKEConfig.UpgradeStrategy.WorkerConcurrency = etcdRestore.WorkerConcurrencyValue
+
+			if upgradeinput.LoadVerifyUpgradeStrategy() {
+				observer, err = upgradestrategy.Observe(client, clusterResponse, nil)
+				if err != nil {
+					return nil, "", nil, nil, err
+				}
+			}
 		}
 
 		logrus.Infof("Upgrading K8s version to %s on cluster: %s", clusterObject.Spec.KubernetesVersion, clusterObject.Name)
@@ -406,6 +452,19 @@ func CreateAndValidateSnapshotV2Prov(client *rancher.Client, podTemplate *corev1
 			return nil, "", nil, nil, err
 		}
 
+		if observer != nil {
+			err = observer.WaitForKubeletVersion(upgradeKubernetesVersion)
+			observer.Stop()
+			if err != nil {
+				return nil, "", nil, nil, err
+			}
+
+			err = observer.Verify(clusterObject.Spec.RKEConfig.UpgradeStrategy)
+			if err != nil {
+				return nil, "", nil, nil, err
+			}
+		}
+
 		podErrors := pods.StatusPods(client, clusterID)
 		if len(podErrors) != 0 {
 			return nil, "", nil, nil, errors.New("cluster's pods not in good health post upgrade")
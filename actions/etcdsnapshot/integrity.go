@@ -0,0 +1,435 @@
+package etcdsnapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	extdefault "github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/kubeapi/configmaps"
+	kubenamespaces "github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/rbac"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	integrityLabelPrefix   = "integrity.etcdsnapshot.cattle.io/"
+	integrityGroup         = "integrity.etcdsnapshot.cattle.io"
+	integrityName          = "integrity"
+	integrityNamespaces    = 2
+	integrityConfigMaps    = 3
+	integritySecrets       = 2
+	integrityCustomObjects = 2
+)
+
+var customResourceDefinitionGroupVersionResource = schema.GroupVersionResource{
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// IntegrityObject is an object of the integrity corpus, with the content hash and resource version it had when the
+// snapshot was taken. Objects whose resource version is bumped by controllers, namespaces and custom resource
+// definitions, are not expected to keep it.
+type IntegrityObject struct {
+	GroupVersionResource schema.GroupVersionResource
+	Namespace            string
+	Name                 string
+	Hash                 string
+	ResourceVersion      string
+	checkVersion         bool
+}
+
+// IntegrityCorpus is a set of resources seeded in a downstream cluster before an etcd snapshot: labeled namespaces,
+// configmaps, secrets, a custom resource definition with custom resources and RBAC. Objects are the objects seeded
+// before the snapshot and PostSnapshotObjects the objects created after it, which a restore must remove.
+type IntegrityCorpus struct {
+	ClusterID           string
+	Objects             []IntegrityObject
+	PostSnapshotObjects []IntegrityObject
+	customResource      schema.GroupVersionResource
+	customKind          string
+}
+
+// SeedIntegrityCorpus is a helper function that creates the integrity corpus in a downstream cluster and records the
+// content hash and resource version of every object, to be verified after an etcd snapshot restore.
+func SeedIntegrityCorpus(client *rancher.Client, clusterID string) (*IntegrityCorpus, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := namegen.RandStringLower(5)
+	corpus := &IntegrityCorpus{
+		ClusterID: clusterID,
+		customResource: schema.GroupVersionResource{
+			Group:    integrityGroup,
+			Version:  "v1",
+			Resource: "items" + suffix,
+		},
+		customKind: "Item" + strings.ToUpper(suffix),
+	}
+
+	logrus.Infof("Seeding the etcd integrity corpus on cluster %s", clusterID)
+	_, err = corpus.create(dynamicClient, customResourceDefinitionGroupVersionResource, corpus.customResourceDefinition(), false, &corpus.Objects)
+	if err != nil {
+		return nil, err
+	}
+
+	err = waitForCustomResource(dynamicClient, corpus.customResource)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterRoleName := namegen.AppendRandomString(integrityName)
+	_, err = corpus.create(dynamicClient, rbac.ClusterRoleGroupVersionResource, newIntegrityObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", clusterRoleName, map[string]interface{}{
+		"rules": []interface{}{
+			map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"configmaps"}, "verbs": []interface{}{"get", "list"}},
+		},
+	}), true, &corpus.Objects)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = corpus.create(dynamicClient, rbac.ClusterRoleBindingGroupVersionResource, newIntegrityObject("rbac.authorization.k8s.io/v1", "ClusterRoleBinding", "", namegen.AppendRandomString(integrityName), map[string]interface{}{
+		"roleRef":  map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "ClusterRole", "name": clusterRoleName},
+		"subjects": []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "default", "namespace": "default"}},
+	}), true, &corpus.Objects)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < integrityNamespaces; i++ {
+		namespaceName := namegen.AppendRandomString(integrityName)
+		_, err = corpus.create(dynamicClient, kubenamespaces.NamespaceGroupVersionResource, newIntegrityObject("v1", "Namespace", "", namespaceName, nil), false, &corpus.Objects)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := 0; j < integrityConfigMaps; j++ {
+			_, err = corpus.create(dynamicClient, configmaps.ConfigMapGroupVersionResource, newIntegrityObject("v1", "ConfigMap", namespaceName, namegen.AppendRandomString(integrityName), map[string]interface{}{
+				"data": map[string]interface{}{"value": namegen.RandStringLower(32)},
+			}), true, &corpus.Objects)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for j := 0; j < integritySecrets; j++ {
+			_, err = corpus.create(dynamicClient, secrets.SecretGroupVersionResource, newIntegrityObject("v1", "Secret", namespaceName, namegen.AppendRandomString(integrityName), map[string]interface{}{
+				"type":       "Opaque",
+				"stringData": map[string]interface{}{"value": namegen.RandStringLower(32)},
+			}), true, &corpus.Objects)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for j := 0; j < integrityCustomObjects; j++ {
+			_, err = corpus.create(dynamicClient, corpus.customResource, corpus.newCustomObject(namespaceName), true, &corpus.Objects)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		roleName := namegen.AppendRandomString(integrityName)
+		_, err = corpus.create(dynamicClient, rbac.RoleGroupVersionResource, newIntegrityObject("rbac.authorization.k8s.io/v1", "Role", namespaceName, roleName, map[string]interface{}{
+			"rules": []interface{}{
+				map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"secrets"}, "verbs": []interface{}{"get"}},
+			},
+		}), true, &corpus.Objects)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = corpus.create(dynamicClient, rbac.RoleBindingGroupVersionResource, newIntegrityObject("rbac.authorization.k8s.io/v1", "RoleBinding", namespaceName, namegen.AppendRandomString(integrityName), map[string]interface{}{
+			"roleRef":  map[string]interface{}{"apiGroup": "rbac.authorization.k8s.io", "kind": "Role", "name": roleName},
+			"subjects": []interface{}{map[string]interface{}{"kind": "ServiceAccount", "name": "default", "namespace": namespaceName}},
+		}), true, &corpus.Objects)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logrus.Infof("Seeded %d objects in the etcd integrity corpus", len(corpus.Objects))
+
+	return corpus, nil
+}
+
+// ModifyAfterSnapshot is a helper function that changes the corpus after the snapshot is taken: it updates the
+// configmaps, custom resources and namespace labels, deletes the secrets and creates new objects, all of which a
+// restore must undo.
+func (c *IntegrityCorpus) ModifyAfterSnapshot(client *rancher.Client) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(c.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Modifying the etcd integrity corpus on cluster %s", c.ClusterID)
+	for _, object := range c.Objects {
+		resource := dynamicClient.Resource(object.GroupVersionResource).Namespace(object.Namespace)
+
+		switch object.GroupVersionResource {
+		case secrets.SecretGroupVersionResource:
+			err = resource.Delete(context.TODO(), object.Name, metav1.DeleteOptions{})
+		case configmaps.ConfigMapGroupVersionResource, c.customResource, kubenamespaces.NamespaceGroupVersionResource:
+			err = updateObject(resource, object.Name, func(current *unstructured.Unstructured) {
+				labels := current.GetLabels()
+				labels[integrityLabelPrefix+"modified"] = "true"
+				current.SetLabels(labels)
+
+				if object.GroupVersionResource != kubenamespaces.NamespaceGroupVersionResource {
+					current.Object["data"] = map[string]interface{}{"value": namegen.RandStringLower(32)}
+				}
+			})
+		default:
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if object.GroupVersionResource != kubenamespaces.NamespaceGroupVersionResource {
+			continue
+		}
+
+		_, err = c.create(dynamicClient, configmaps.ConfigMapGroupVersionResource, newIntegrityObject("v1", "ConfigMap", object.Name, namegen.AppendRandomString(integrityName), map[string]interface{}{
+			"data": map[string]interface{}{"value": namegen.RandStringLower(32)},
+		}), false, &c.PostSnapshotObjects)
+		if err != nil {
+			return err
+		}
+
+		_, err = c.create(dynamicClient, c.customResource, c.newCustomObject(object.Name), false, &c.PostSnapshotObjects)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = c.create(dynamicClient, kubenamespaces.NamespaceGroupVersionResource, newIntegrityObject("v1", "Namespace", "", namegen.AppendRandomString(integrityName), nil), false, &c.PostSnapshotObjects)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.create(dynamicClient, rbac.ClusterRoleGroupVersionResource, newIntegrityObject("rbac.authorization.k8s.io/v1", "ClusterRole", "", namegen.AppendRandomString(integrityName), map[string]interface{}{
+		"rules": []interface{}{},
+	}), false, &c.PostSnapshotObjects)
+
+	return err
+}
+
+// VerifyRestored is a helper function that waits for every object seeded before the snapshot to have its recorded
+// content hash again and every object created after it to be gone. Objects whose resource version is not bumped by
+// controllers must also be back to their resource version at the time of the snapshot.
+func (c *IntegrityCorpus) VerifyRestored(client *rancher.Client) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(c.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Verifying the etcd integrity corpus on cluster %s", c.ClusterID)
+	var integrityErrors []error
+	err = kwait.PollUntilContextTimeout(context.TODO(), extdefault.TenSecondTimeout, extdefault.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		integrityErrors = nil
+
+		for _, object := range c.Objects {
+			current, err := dynamicClient.Resource(object.GroupVersionResource).Namespace(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+			if err != nil {
+				integrityErrors = append(integrityErrors, fmt.Errorf("%s %s was not restored: %w", object.GroupVersionResource.Resource, objectName(object), err))
+				continue
+			}
+
+			hash, err := contentHash(current)
+			if err != nil {
+				return false, err
+			}
+
+			if hash != object.Hash {
+				integrityErrors = append(integrityErrors, fmt.Errorf("%s %s content does not match the snapshot", object.GroupVersionResource.Resource, objectName(object)))
+			}
+
+			if object.checkVersion && current.GetResourceVersion() != object.ResourceVersion {
+				integrityErrors = append(integrityErrors, fmt.Errorf("%s %s resource version %s does not match the snapshot resource version %s",
+					object.GroupVersionResource.Resource, objectName(object), current.GetResourceVersion(), object.ResourceVersion))
+			}
+		}
+
+		for _, object := range c.PostSnapshotObjects {
+			_, err := dynamicClient.Resource(object.GroupVersionResource).Namespace(object.Namespace).Get(ctx, object.Name, metav1.GetOptions{})
+			if err == nil {
+				integrityErrors = append(integrityErrors, fmt.Errorf("%s %s created after the snapshot still exists", object.GroupVersionResource.Resource, objectName(object)))
+			} else if !apierrors.IsNotFound(err) {
+				integrityErrors = append(integrityErrors, err)
+			}
+		}
+
+		return len(integrityErrors) == 0, nil
+	})
+	if err != nil {
+		return errors.Join(append(integrityErrors, err)...)
+	}
+
+	logrus.Infof("All %d etcd integrity corpus objects match the snapshot", len(c.Objects))
+
+	return nil
+}
+
+// create is a private helper function that creates an object of the corpus and records it in objects.
+func (c *IntegrityCorpus) create(dynamicClient dynamic.Interface, groupVersionResource schema.GroupVersionResource, object *unstructured.Unstructured, checkVersion bool, objects *[]IntegrityObject) (*unstructured.Unstructured, error) {
+	created, err := dynamicClient.Resource(groupVersionResource).Namespace(object.GetNamespace()).Create(context.TODO(), object, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := contentHash(created)
+	if err != nil {
+		return nil, err
+	}
+
+	*objects = append(*objects, IntegrityObject{
+		GroupVersionResource: groupVersionResource,
+		Namespace:            created.GetNamespace(),
+		Name:                 created.GetName(),
+		Hash:                 hash,
+		ResourceVersion:      created.GetResourceVersion(),
+		checkVersion:         checkVersion,
+	})
+
+	return created, nil
+}
+
+// customResourceDefinition is a private helper function that returns the namespaced custom resource definition of the
+// corpus, storing arbitrary data.
+func (c *IntegrityCorpus) customResourceDefinition() *unstructured.Unstructured {
+	return newIntegrityObject("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", c.customResource.Resource+"."+integrityGroup, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group": integrityGroup,
+			"scope": "Namespaced",
+			"names": map[string]interface{}{
+				"plural":   c.customResource.Resource,
+				"singular": strings.ToLower(c.customKind),
+				"kind":     c.customKind,
+			},
+			"versions": []interface{}{
+				map[string]interface{}{
+					"name":    c.customResource.Version,
+					"served":  true,
+					"storage": true,
+					"schema": map[string]interface{}{
+						"openAPIV3Schema": map[string]interface{}{
+							"type":                                 "object",
+							"x-kubernetes-preserve-unknown-fields": true,
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+// newCustomObject is a private helper function that returns a custom resource of the corpus with random data.
+func (c *IntegrityCorpus) newCustomObject(namespace string) *unstructured.Unstructured {
+	return newIntegrityObject(integrityGroup+"/"+c.customResource.Version, c.customKind, namespace, namegen.AppendRandomString(integrityName), map[string]interface{}{
+		"data": map[string]interface{}{"value": namegen.RandStringLower(32)},
+	})
+}
+
+// newIntegrityObject is a private helper function that returns an object labeled as part of the corpus with the given
+// top-level fields.
+func newIntegrityObject(apiVersion, kind, namespace, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	object := &unstructured.Unstructured{Object: map[string]interface{}{}}
+	for key, value := range fields {
+		object.Object[key] = value
+	}
+
+	object.SetAPIVersion(apiVersion)
+	object.SetKind(kind)
+	object.SetNamespace(namespace)
+	object.SetName(name)
+	object.SetLabels(map[string]string{integrityLabelPrefix + "seed": namegen.RandStringLower(8)})
+
+	return object
+}
+
+// contentHash is a private helper function that returns the sha256 of the content of an object: its name, namespace,
+// uid and corpus labels, and every top-level field but metadata and status. Annotations and labels added by
+// controllers are left out.
+func contentHash(object *unstructured.Unstructured) (string, error) {
+	content := map[string]interface{}{}
+	for key, value := range object.Object {
+		if key == "metadata" || key == "status" {
+			continue
+		}
+
+		content[key] = value
+	}
+
+	labels := map[string]string{}
+	for key, value := range object.GetLabels() {
+		if strings.HasPrefix(key, integrityLabelPrefix) {
+			labels[key] = value
+		}
+	}
+
+	content["metadata"] = map[string]interface{}{
+		"name":      object.GetName(),
+		"namespace": object.GetNamespace(),
+		"uid":       string(object.GetUID()),
+		"labels":    labels,
+	}
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// updateObject is a private helper function that gets an object, applies a change to it and updates it.
+func updateObject(resource dynamic.ResourceInterface, name string, change func(*unstructured.Unstructured)) error {
+	current, err := resource.Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	change(current)
+
+	_, err = resource.Update(context.TODO(), current, metav1.UpdateOptions{})
+
+	return err
+}
+
+// waitForCustomResource is a private helper function that waits for the custom resource of the corpus to be served.
+func waitForCustomResource(dynamicClient dynamic.Interface, groupVersionResource schema.GroupVersionResource) error {
+	return kwait.PollUntilContextTimeout(context.TODO(), extdefault.FiveSecondTimeout, extdefault.OneMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		_, err = dynamicClient.Resource(groupVersionResource).List(ctx, metav1.ListOptions{})
+
+		return err == nil, nil
+	})
+}
+
+// objectName is a private helper function that returns the namespaced name of a corpus object.
+func objectName(object IntegrityObject) string {
+	if object.Namespace == "" {
+		return object.Name
+	}
+
+	return object.Namespace + "/" + object.Name
+}
@@ -0,0 +1,82 @@
+package gitserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	sshPrivateKey = "ssh-privatekey"
+	sshPublicKey  = "ssh-publickey"
+	knownHosts    = "known_hosts"
+)
+
+// KnownHosts returns the known_hosts line of the git server ssh host key.
+func (g *GitServer) KnownHosts() string {
+	return knownhosts.Line([]string{knownhosts.Normalize(fmt.Sprintf("%s:%d", g.Host(), sshPort))}, g.hostPublicKey)
+}
+
+// CreateHTTPAuthSecret is a helper function that creates a basic auth secret with the git server credentials in a
+// namespace of the local cluster, e.g. fleet-default, and returns its name to be used as a GitRepo clientSecretName.
+func (g *GitServer) CreateHTTPAuthSecret(namespace string) (string, error) {
+	secret := secrets.NewBasicAuthSecret(namegenerator.AppendRandomString("gitserver-http"), namespace, g.Username, g.Password)
+
+	secretResp, err := secrets.CreateSecretForCluster(g.client, secret, localClusterID, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	return secretResp.Name, nil
+}
+
+// CreateSSHAuthSecret is a helper function that generates an ssh key, registers it with the git server user and creates
+// an ssh auth secret, including the git server known_hosts, in a namespace of the local cluster. It returns the secret
+// name to be used as a GitRepo clientSecretName.
+func (g *GitServer) CreateSSHAuthSecret(namespace string) (string, error) {
+	publicKey, privateKey, err := newSSHKeyPair()
+	if err != nil {
+		return "", err
+	}
+
+	secretName := namegenerator.AppendRandomString("gitserver-ssh")
+	body, err := json.Marshal(map[string]string{
+		"title": secretName,
+		"key":   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = g.apiRequest("POST", "/user/keys", body)
+	if err != nil {
+		return "", err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      secretName,
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			sshPrivateKey: privateKey,
+			sshPublicKey:  ssh.MarshalAuthorizedKey(publicKey),
+			knownHosts:    []byte(g.KnownHosts()),
+		},
+		Type: corev1.SecretTypeSSHAuth,
+	}
+
+	secretResp, err := secrets.CreateSecretForCluster(g.client, secret, localClusterID, namespace)
+	if err != nil {
+		return "", err
+	}
+
+	return secretResp.Name, nil
+}
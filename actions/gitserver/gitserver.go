@@ -0,0 +1,295 @@
+package gitserver
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	kubenamespaces "github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	restclient "k8s.io/client-go/rest"
+)
+
+const (
+	// GitServerConfigurationFileKey is the key of the optional git server configuration in the config file
+	GitServerConfigurationFileKey = "gitServerInput"
+
+	// DefaultImage is the gitea image deployed when no image is configured. The rootless image serves both http and ssh.
+	DefaultImage = "gitea/gitea:1.22-rootless"
+	// DefaultFixturesPath is the directory of fixture bundles, relative to the suite, that repos are seeded from
+	DefaultFixturesPath = "./resources/gitrepos"
+	// DefaultBranch is the branch every repo of the git server is created with
+	DefaultBranch = "master"
+
+	localClusterID = "local"
+	gitServerName  = "gitserver"
+	gitUsername    = "fleet"
+	httpPort       = 3000
+	sshPort        = 2222
+	configVolume   = "config"
+	dataVolume     = "data"
+	hostKeyVolume  = "host-key"
+	hostKeyFile    = "ssh_host_ed25519_key"
+	hostKeyPath    = "/etc/gitea-ssh"
+	configPath     = "/etc/gitea"
+	dataPath       = "/var/lib/gitea"
+)
+
+// Config is the optional git server configuration, e.g. to pull the gitea image from a private registry in airgapped
+// environments or to seed repos from another fixtures directory.
+type Config struct {
+	Image        string `json:"image,omitempty" yaml:"image,omitempty"`
+	FixturesPath string `json:"fixturesPath,omitempty" yaml:"fixturesPath,omitempty"`
+}
+
+// GitServer is a gitea server running in the local cluster, reachable by fleet through its service.
+type GitServer struct {
+	client        *rancher.Client
+	restConfig    *restclient.Config
+	podName       string
+	Namespace     string
+	Name          string
+	Username      string
+	Password      string
+	FixturesPath  string
+	hostPublicKey ssh.PublicKey
+}
+
+// LoadConfig is a helper function that loads the git server configuration, falling back to the defaults for every
+// unset field.
+func LoadConfig() *Config {
+	gitServerConfig := new(Config)
+	config.LoadConfig(GitServerConfigurationFileKey, gitServerConfig)
+
+	if gitServerConfig.Image == "" {
+		gitServerConfig.Image = DefaultImage
+	}
+
+	if gitServerConfig.FixturesPath == "" {
+		gitServerConfig.FixturesPath = DefaultFixturesPath
+	}
+
+	return gitServerConfig
+}
+
+// DeployGitServer is a helper function that deploys a gitea server in a new namespace of the local cluster and waits
+// for it to be ready. Every resource it creates, including the namespace, is deleted on session cleanup.
+func DeployGitServer(client *rancher.Client, gitServerConfig *Config) (*GitServer, error) {
+	hostPublicKey, hostPrivateKey, err := newSSHKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	gitServer := &GitServer{
+		client:        client,
+		Namespace:     namegenerator.AppendRandomString(gitServerName),
+		Name:          gitServerName,
+		Username:      gitUsername,
+		Password:      namegenerator.RandStringLower(16),
+		FixturesPath:  gitServerConfig.FixturesPath,
+		hostPublicKey: hostPublicKey,
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: gitServer.Namespace,
+		},
+	}
+
+	logrus.Infof("Creating git server namespace %s", gitServer.Namespace)
+	_, err = dynamicClient.Resource(kubenamespaces.NamespaceGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(namespace), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      gitServer.Name + "-" + hostKeyVolume,
+			Namespace: gitServer.Namespace,
+		},
+		Data: map[string][]byte{
+			hostKeyFile: hostPrivateKey,
+		},
+	}
+
+	_, err = secrets.CreateSecretForCluster(client, hostKeySecret, localClusterID, gitServer.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deploying git server %s/%s", gitServer.Namespace, gitServer.Name)
+	_, err = deployments.CreateDeployment(client, localClusterID, gitServer.Name, gitServer.Namespace, gitServer.podTemplate(gitServerConfig.Image, hostKeySecret.Name), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := client.WranglerContext.Apps.Deployment().Get(gitServer.Namespace, gitServer.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = services.CreateService(client, localClusterID, gitServer.Name, gitServer.Namespace, corev1.ServiceSpec{
+		Selector: deployment.Spec.Selector.MatchLabels,
+		Ports: []corev1.ServicePort{
+			{
+				Name:       "http",
+				Port:       httpPort,
+				TargetPort: intstr.FromInt(httpPort),
+			},
+			{
+				Name:       "ssh",
+				Port:       sshPort,
+				TargetPort: intstr.FromInt(sshPort),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := pods.WaitForReadyPod(client, gitServer.Namespace, deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	gitServer.podName = pod.Name
+
+	clientConfig, err := kubeconfig.GetKubeconfig(client, localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	gitServer.restConfig, err = (*clientConfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return gitServer, nil
+}
+
+// Host returns the in-cluster DNS name of the git server service.
+func (g *GitServer) Host() string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local", g.Name, g.Namespace)
+}
+
+// HTTPURL returns the url fleet clones a repo of the git server from over http.
+func (g *GitServer) HTTPURL(repoName string) string {
+	return fmt.Sprintf("http://%s:%d/%s/%s.git", g.Host(), httpPort, g.Username, repoName)
+}
+
+// SSHURL returns the url fleet clones a repo of the git server from over ssh.
+func (g *GitServer) SSHURL(repoName string) string {
+	return fmt.Sprintf("ssh://git@%s:%d/%s/%s.git", g.Host(), sshPort, g.Username, repoName)
+}
+
+// podTemplate is a private helper function that returns the gitea pod template. The init container writes the
+// configuration, migrates the database and creates the user owning every repo before the server starts.
+func (g *GitServer) podTemplate(image, hostKeySecretName string) corev1.PodTemplateSpec {
+	env := []corev1.EnvVar{
+		{Name: "GITEA__security__INSTALL_LOCK", Value: "true"},
+		{Name: "GITEA__database__DB_TYPE", Value: "sqlite3"},
+		{Name: "GITEA__server__ROOT_URL", Value: fmt.Sprintf("http://%s:%d/", g.Host(), httpPort)},
+		{Name: "GITEA__server__HTTP_PORT", Value: fmt.Sprint(httpPort)},
+		{Name: "GITEA__server__START_SSH_SERVER", Value: "true"},
+		{Name: "GITEA__server__SSH_DOMAIN", Value: g.Host()},
+		{Name: "GITEA__server__SSH_PORT", Value: fmt.Sprint(sshPort)},
+		{Name: "GITEA__server__SSH_LISTEN_PORT", Value: fmt.Sprint(sshPort)},
+		{Name: "GITEA__server__SSH_SERVER_HOST_KEYS", Value: hostKeyPath + "/" + hostKeyFile},
+		{Name: "GITEA__service__DISABLE_REGISTRATION", Value: "true"},
+		{Name: "GITEA__repository__DEFAULT_BRANCH", Value: DefaultBranch},
+	}
+
+	volumeMounts := []corev1.VolumeMount{
+		{Name: configVolume, MountPath: configPath},
+		{Name: dataVolume, MountPath: dataPath},
+		{Name: hostKeyVolume, MountPath: hostKeyPath, ReadOnly: true},
+	}
+
+	setupCommand := fmt.Sprintf("/usr/local/bin/docker-setup.sh && gitea migrate && gitea admin user create --username %s --password %s --email %s@%s --admin --must-change-password=false",
+		g.Username, g.Password, g.Username, g.Host())
+
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{
+					Name:         "setup",
+					Image:        image,
+					Command:      []string{"sh", "-c", setupCommand},
+					Env:          env,
+					VolumeMounts: volumeMounts,
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:  gitServerName,
+					Image: image,
+					Env:   env,
+					Ports: []corev1.ContainerPort{
+						{Name: "http", ContainerPort: httpPort},
+						{Name: "ssh", ContainerPort: sshPort},
+					},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							HTTPGet: &corev1.HTTPGetAction{
+								Path: "/api/healthz",
+								Port: intstr.FromInt(httpPort),
+							},
+						},
+					},
+					VolumeMounts: volumeMounts,
+				},
+			},
+			Volumes: []corev1.Volume{
+				{Name: configVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: dataVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+				{Name: hostKeyVolume, VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: hostKeySecretName}}},
+			},
+		},
+	}
+}
+
+// exec is a private helper function that runs a shell script in the git server pod and returns its output.
+func (g *GitServer) exec(script string) (string, error) {
+	return pods.ExecScript(g.restConfig, g.Namespace, g.podName, script)
+}
+
+// newSSHKeyPair is a private helper function that generates an ed25519 key pair, returning the public key and the
+// private key in the OpenSSH PEM format.
+func newSSHKeyPair() (ssh.PublicKey, []byte, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pemBlock, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sshPublicKey, pem.EncodeToMemory(pemBlock), nil
+}
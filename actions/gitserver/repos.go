@@ -0,0 +1,180 @@
+package gitserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	commitAuthor = "fleet-tests"
+)
+
+// CreateRepo is a helper function that creates an empty repo owned by the git server user. Private repos can only be
+// cloned with one of the auth secrets of the git server.
+func (g *GitServer) CreateRepo(repoName string, private bool) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"name":           repoName,
+		"private":        private,
+		"default_branch": DefaultBranch,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = g.apiRequest("POST", "/user/repos", body)
+
+	return err
+}
+
+// SeedRepo is a helper function that creates a repo and commits every file of a fixture bundle to it. The bundle is
+// a directory of FixturesPath. It returns the seeded commit.
+func (g *GitServer) SeedRepo(repoName, bundle string, private bool) (string, error) {
+	files, err := g.FixtureFiles(bundle)
+	if err != nil {
+		return "", err
+	}
+
+	err = g.CreateRepo(repoName, private)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Seeding git repo %s from fixture bundle %s", repoName, bundle)
+
+	return g.Commit(repoName, "Seed "+bundle, files)
+}
+
+// FixtureFiles is a helper function that reads every file of a fixture bundle, keyed by its path in the bundle, e.g.
+// to commit several bundles to a single repo.
+func (g *GitServer) FixtureFiles(bundle string) (map[string][]byte, error) {
+	return readFixtureBundle(filepath.Join(g.FixturesPath, bundle))
+}
+
+// Commit is a helper function that writes the files, keyed by their path in the repo, to the default branch of a repo
+// in a single commit and returns the new commit. A commit without files is allowed.
+func (g *GitServer) Commit(repoName, message string, files map[string][]byte) (string, error) {
+	workDir := "/tmp/" + namegenerator.AppendRandomString(repoName)
+	defer func() {
+		_, err := g.exec("rm -rf " + pods.ShellQuote(workDir))
+		if err != nil {
+			logrus.Warnf("Failed to remove %s from the git server: %v", workDir, err)
+		}
+	}()
+
+	_, err := g.exec(fmt.Sprintf("git clone -q %s %s", pods.ShellQuote(g.localURL(repoName)), pods.ShellQuote(workDir)))
+	if err != nil {
+		return "", err
+	}
+
+	filePaths := make([]string, 0, len(files))
+	for filePath := range files {
+		filePaths = append(filePaths, filePath)
+	}
+	sort.Strings(filePaths)
+
+	for _, filePath := range filePaths {
+		err = g.writeFile(workDir, filePath, files[filePath])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	script := fmt.Sprintf("set -e; cd %s; git add -A; git -c user.name=%s -c user.email=%s@%s commit -q --allow-empty -m %s; git push -q origin HEAD:%s; git rev-parse HEAD",
+		pods.ShellQuote(workDir), commitAuthor, commitAuthor, g.Host(), pods.ShellQuote(message), DefaultBranch)
+
+	output, err := g.exec(script)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(output, "\n")
+	commit := strings.TrimSpace(lines[len(lines)-1])
+	logrus.Infof("Pushed commit %s to git repo %s", commit, repoName)
+
+	return commit, nil
+}
+
+// HeadCommit is a helper function that returns the commit the default branch of a repo points to.
+func (g *GitServer) HeadCommit(repoName string) (string, error) {
+	output, err := g.exec(fmt.Sprintf("git ls-remote %s refs/heads/%s", pods.ShellQuote(g.localURL(repoName)), DefaultBranch))
+	if err != nil {
+		return "", err
+	}
+
+	fields := strings.Fields(output)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("branch %s of git repo %s has no commits", DefaultBranch, repoName)
+	}
+
+	return fields[0], nil
+}
+
+// apiRequest is a private helper function that calls the gitea API from inside the git server pod as the git server user.
+func (g *GitServer) apiRequest(method, apiPath string, body []byte) (string, error) {
+	script := fmt.Sprintf("curl -sSf -u %s -X %s -H 'Content-Type: application/json' http://localhost:%d/api/v1%s",
+		pods.ShellQuote(g.Username+":"+g.Password), method, httpPort, apiPath)
+	if body != nil {
+		script += " -d " + pods.ShellQuote(string(body))
+	}
+
+	output, err := g.exec(script)
+	if err != nil {
+		return "", fmt.Errorf("gitea API %s %s failed: %w", method, apiPath, err)
+	}
+
+	return output, nil
+}
+
+// localURL is a private helper function that returns the authenticated url of a repo from inside the git server pod.
+func (g *GitServer) localURL(repoName string) string {
+	return fmt.Sprintf("http://%s:%s@localhost:%d/%s/%s.git", g.Username, g.Password, httpPort, g.Username, repoName)
+}
+
+// writeFile is a private helper function that writes a file in a working copy inside the git server pod.
+func (g *GitServer) writeFile(workDir, filePath string, content []byte) error {
+	return pods.WriteFile(g.restConfig, g.Namespace, g.podName, workDir+path.Clean("/"+filePath), content)
+}
+
+// readFixtureBundle is a private helper function that reads every file of a fixture bundle, keyed by its slash
+// separated path relative to the bundle.
+func readFixtureBundle(bundlePath string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	err := filepath.WalkDir(bundlePath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(bundlePath, filePath)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		files[filepath.ToSlash(relativePath)] = content
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("fixture bundle %s has no files", bundlePath)
+	}
+
+	return files, nil
+}
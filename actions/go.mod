@@ -65,12 +65,15 @@ require (
 
 require (
 	github.com/aws/aws-sdk-go v1.55.8
+	github.com/gorilla/websocket v1.5.4-0.20250319132907-e064f32e3674
 	github.com/pkg/errors v0.9.1
+	github.com/rancher/fleet/pkg/apis v0.14.0-rc.1
 	github.com/rancher/norman v0.8.0
 	github.com/rancher/rancher v0.0.0-20251203234820-b95b2fb0d738
 	github.com/rancher/wrangler v1.1.2
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.11.1
+	github.com/xeipuuv/gojsonschema v1.2.0
 	golang.org/x/crypto v0.43.0
 	gopkg.in/yaml.v2 v2.4.0
 	k8s.io/api v0.34.1
@@ -85,6 +88,8 @@ require (
 	github.com/imdario/mergo v0.3.16 // indirect
 	github.com/morikuni/aec v1.0.0 // indirect
 	github.com/rancher/ali-operator v1.13.0-rc.2 // indirect
+	github.com/xeipuuv/gojsonpointer v0.0.0-20190905194746-02993c407bfb // indirect
+	github.com/xeipuuv/gojsonreference v0.0.0-20180127040603-bd5ef7bd5415 // indirect
 	gopkg.in/validator.v2 v2.0.1 // indirect
 	gotest.tools/v3 v3.5.2 // indirect
 	k8s.io/component-helpers v0.34.1 // indirect
@@ -137,7 +142,6 @@ require (
 	github.com/google/gnostic-models v0.7.0 // indirect
 	github.com/google/go-cmp v0.7.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.4-0.20250319132907-e064f32e3674 // indirect
 	github.com/gregjones/httpcache v0.0.0-20190611155906-901d90724c79 // indirect
 	github.com/huandu/xstrings v1.5.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -176,7 +180,6 @@ require (
 	github.com/rancher/aks-operator v1.13.0-rc.4 // indirect
 	github.com/rancher/apiserver v0.8.0 // indirect
 	github.com/rancher/eks-operator v1.13.0-rc.4 // indirect
-	github.com/rancher/fleet/pkg/apis v0.14.0-rc.1 // indirect
 	github.com/rancher/gke-operator v1.13.0-rc.3 // indirect
 	github.com/rancher/lasso v0.2.5 // indirect
 	github.com/rancher/rke v1.8.0 // indirect
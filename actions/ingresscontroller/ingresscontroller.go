@@ -0,0 +1,304 @@
+package ingresscontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/pkg/api/scheme"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/ingresses"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/rancher/tests/actions/secrets"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	NginxController   = "k8s.io/ingress-nginx"
+	TraefikController = "traefik.io/ingress-controller"
+
+	// HostSuffix is the domain of the hosts of the conformance ingresses. Requests carry the hosts in their Host header,
+	// or resolve them to a node address for TLS, so the domain does not need to exist.
+	HostSuffix = ".ingress.local"
+
+	backendLabel        = "ingresscontroller.cattle.io/backend"
+	backendPort         = 80
+	defaultClassKey     = "ingressclass.kubernetes.io/is-default-class"
+	defaultClassEnabled = "true"
+)
+
+// IngressClassGroupVersionResource is the required Group Version Resource for accessing ingress classes in a cluster,
+// using the dynamic client.
+var IngressClassGroupVersionResource = schema.GroupVersionResource{
+	Group:    "networking.k8s.io",
+	Version:  "v1",
+	Resource: "ingressclasses",
+}
+
+// Backend is a pod answering HTTP with its name and the service in front of it, which conformance ingresses route to.
+type Backend struct {
+	Name    string
+	Pod     string
+	Service string
+}
+
+// Fixture is the namespace conformance ingresses are created in, with their backends, the ingress class of the
+// bundled ingress controller of the cluster, the probe pod requests are sent from and the addresses of the nodes they
+// are sent to.
+type Fixture struct {
+	ClusterID  string
+	Namespace  string
+	Class      *networkingv1.IngressClass
+	Source     connectivity.ProbePod
+	Nodes      map[string]string
+	Backends   map[string]Backend
+	TLSHost    string
+	TLSSecret  string
+	caCertData string
+}
+
+// NewFixture is a helper function that finds the ingress class of the bundled ingress controller of a cluster, deploys
+// probe pods in a new namespace to send requests from, and a backend per name in the same namespace. The namespace,
+// and with it everything in it, is deleted on session cleanup.
+func NewFixture(client *rancher.Client, clusterID string, backendNames ...string) (*Fixture, error) {
+	class, err := BundledIngressClass(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deploying probe pods to send ingress requests from")
+	probes, err := connectivity.DeployProbes(client, clusterID, []connectivity.ProbeNamespace{{}})
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		ClusterID: clusterID,
+		Namespace: probes.Namespaces[0].Name,
+		Class:     class,
+		Source:    probes.Pods[0],
+		Nodes:     map[string]string{},
+		Backends:  map[string]Backend{},
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pod := range probes.Pods {
+		if _, ok := fixture.Nodes[pod.Node]; ok {
+			continue
+		}
+
+		node, err := wranglerContext.Core.Node().Get(pod.Node, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				fixture.Nodes[pod.Node] = address.Address
+				break
+			}
+		}
+
+		if fixture.Nodes[pod.Node] == "" {
+			return nil, fmt.Errorf("node %s has no internal address", pod.Node)
+		}
+	}
+
+	for _, name := range backendNames {
+		err = fixture.createBackend(client, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = fixture.waitForBackends(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture, nil
+}
+
+// BundledIngressClass is a helper function that returns the ingress class of the ingress controller bundled with an
+// RKE2 or K3s cluster, rke2-ingress-nginx or Traefik, preferring the default class when there are several.
+func BundledIngressClass(client *rancher.Client, clusterID string) (*networkingv1.IngressClass, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredClasses, err := dynamicClient.Resource(IngressClassGroupVersionResource).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var bundled *networkingv1.IngressClass
+	for _, unstructuredClass := range unstructuredClasses.Items {
+		class := &networkingv1.IngressClass{}
+		err = scheme.Scheme.Convert(&unstructuredClass, class, unstructuredClass.GroupVersionKind())
+		if err != nil {
+			return nil, err
+		}
+
+		if class.Spec.Controller != NginxController && class.Spec.Controller != TraefikController {
+			continue
+		}
+
+		if bundled == nil || class.Annotations[defaultClassKey] == defaultClassEnabled {
+			bundled = class
+		}
+	}
+
+	if bundled == nil {
+		return nil, fmt.Errorf("cluster %s has no ingress class of %s or %s", clusterID, NginxController, TraefikController)
+	}
+
+	return bundled, nil
+}
+
+// ControllerName returns a short name of the controller of an ingress class, e.g. Nginx or Traefik.
+func ControllerName(class *networkingv1.IngressClass) string {
+	switch class.Spec.Controller {
+	case NginxController:
+		return "Nginx"
+	case TraefikController:
+		return "Traefik"
+	default:
+		return class.Name
+	}
+}
+
+// CreateIngress is a helper function that creates an ingress in the fixture namespace. Ingresses without an ingress
+// class name are given the class of the bundled controller.
+func (f *Fixture) CreateIngress(client *rancher.Client, name string, spec networkingv1.IngressSpec) (*networkingv1.Ingress, error) {
+	if spec.IngressClassName == nil {
+		spec.IngressClassName = &f.Class.Name
+	}
+
+	logrus.Infof("Creating ingress %s/%s with class %s", f.Namespace, name, *spec.IngressClassName)
+	return ingresses.CreateIngress(client, f.ClusterID, namegen.AppendRandomString(name), f.Namespace, &spec)
+}
+
+// CreateTLSSecret is a helper function that generates a self-signed certificate, stores it in a TLS secret in the
+// fixture namespace and keeps it to verify the certificate served for TLSHost, the host the certificate is for.
+func (f *Fixture) CreateTLSSecret(client *rancher.Client) error {
+	certData, keyData, err := secrets.GenerateSelfSignedCert()
+	if err != nil {
+		return err
+	}
+
+	secret, err := secrets.CreateSecret(client, f.ClusterID, f.Namespace, map[string][]byte{
+		corev1.TLSCertKey:       []byte(certData),
+		corev1.TLSPrivateKeyKey: []byte(keyData),
+	}, corev1.SecretTypeTLS, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	f.TLSHost = secrets.DefaultCommonName
+	f.TLSSecret = secret.Name
+	f.caCertData = certData
+
+	return nil
+}
+
+// Path is a constructor that returns an HTTP ingress path of a path type to a backend of the fixture.
+func (f *Fixture) Path(path string, pathType networkingv1.PathType, backend string) networkingv1.HTTPIngressPath {
+	return networkingv1.HTTPIngressPath{
+		Path:     path,
+		PathType: &pathType,
+		Backend:  f.IngressBackend(backend),
+	}
+}
+
+// IngressBackend is a constructor that returns the ingress backend of a backend of the fixture.
+func (f *Fixture) IngressBackend(backend string) networkingv1.IngressBackend {
+	return networkingv1.IngressBackend{
+		Service: &networkingv1.IngressServiceBackend{
+			Name: f.Backends[backend].Service,
+			Port: networkingv1.ServiceBackendPort{Number: backendPort},
+		},
+	}
+}
+
+// Rule is a constructor that returns an ingress rule of a host with HTTP paths.
+func Rule(host string, paths ...networkingv1.HTTPIngressPath) networkingv1.IngressRule {
+	return networkingv1.IngressRule{
+		Host: host,
+		IngressRuleValue: networkingv1.IngressRuleValue{
+			HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+		},
+	}
+}
+
+// createBackend is a private helper function that creates a backend pod answering HTTP with its name and a ClusterIP
+// service in front of it.
+func (f *Fixture) createBackend(client *rancher.Client, name string) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, f.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	image := connectivity.GetProbeImage()
+
+	pod := connectivity.NewProbePod(f.Namespace, "", image)
+	pod.Name = namegen.AppendRandomString(name)
+	pod.Labels = map[string]string{backendLabel: pod.Name}
+
+	logrus.Infof("Creating ingress backend %s/%s", f.Namespace, pod.Name)
+	pod, err = wranglerContext.Core.Pod().Create(pod)
+	if err != nil {
+		return err
+	}
+
+	service, err := services.CreateService(client, f.ClusterID, pod.Name, f.Namespace, corev1.ServiceSpec{
+		Type:     corev1.ServiceTypeClusterIP,
+		Selector: map[string]string{backendLabel: pod.Name},
+		Ports: []corev1.ServicePort{{
+			Name:       strings.ToLower(string(connectivity.HTTP)),
+			Protocol:   corev1.ProtocolTCP,
+			Port:       backendPort,
+			TargetPort: intstr.FromInt(connectivity.Port(connectivity.HTTP)),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	f.Backends[name] = Backend{Name: name, Pod: pod.Name, Service: service.Name}
+
+	return nil
+}
+
+// waitForBackends is a private helper function that waits for every backend pod to be ready.
+func (f *Fixture) waitForBackends(client *rancher.Client) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, f.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		for _, backend := range f.Backends {
+			pod, err := wranglerContext.Core.Pod().Get(f.Namespace, backend.Pod, metav1.GetOptions{})
+			if err != nil || !connectivity.PodReady(pod) {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
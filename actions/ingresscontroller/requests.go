@@ -0,0 +1,179 @@
+package ingresscontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/tests/actions/connectivity"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	httpOK      = "200"
+	caCertPath  = "/tmp/ingress-ca.pem"
+	bodyPath    = "/tmp/ingress-body"
+	maxBodySize = 64
+)
+
+// Case is a request sent to every node through the bundled ingress controller and the backend expected to answer it.
+// An empty backend expects the request not to be routed to any backend of the fixture. TLS requests resolve the host
+// to the node address, so the controller selects the certificate by SNI, and verify the served certificate against
+// the one of CreateTLSSecret.
+type Case struct {
+	Name    string
+	Host    string
+	Path    string
+	TLS     bool
+	Backend string
+}
+
+// Response is the backend that answered a case on a node, empty when none of the fixture backends did, and the HTTP
+// status code of the response.
+type Response struct {
+	Case    Case
+	Node    string
+	Backend string
+	Code    string
+}
+
+// VerifyCases is a helper function that sends every case to every node until each is answered by its expected
+// backend, allowing the ingress controller time to apply ingress changes. It returns an error listing the cases that
+// were not.
+func (f *Fixture) VerifyCases(client *rancher.Client, cases []Case) error {
+	var mismatches []Response
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		responses, err := f.Send(client, cases)
+		if err != nil {
+			logrus.Warnf("Sending ingress requests: %v", err)
+			return false, nil
+		}
+
+		mismatches = nil
+		for _, response := range responses {
+			if response.Backend != response.Case.Backend {
+				mismatches = append(mismatches, response)
+			}
+		}
+
+		return len(mismatches) == 0, nil
+	})
+	if err == nil {
+		return nil
+	}
+
+	errs := []error{err}
+	for _, mismatch := range mismatches {
+		expected := mismatch.Case.Backend
+		if expected == "" {
+			expected = "no backend"
+		}
+
+		observed := mismatch.Backend
+		if observed == "" {
+			observed = "no backend"
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %s on node %s was answered by %s with %s, expected %s", mismatch.Case.Name, mismatch.Case.url(), mismatch.Node, observed, mismatch.Code, expected))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Send is a helper function that sends every case to every node from the fixture probe pod, in a single exec, and
+// returns the responses.
+func (f *Fixture) Send(client *rancher.Client, cases []Case) ([]Response, error) {
+	var nodes []string
+	for node := range f.Nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Strings(nodes)
+
+	output, err := connectivity.ExecInProbe(client, f.ClusterID, f.Source, f.requestScript(nodes, cases))
+	if err != nil {
+		return nil, err
+	}
+
+	backends := map[string]string{}
+	for name, backend := range f.Backends {
+		backends[backend.Pod] = name
+	}
+
+	var responses []Response
+	for _, record := range strings.Split(output, ";") {
+		var nodeIndex, caseIndex int
+
+		fields := strings.Split(strings.TrimSpace(record), "|")
+		if len(fields) != 4 {
+			continue
+		}
+
+		_, err := fmt.Sscanf(fields[0]+" "+fields[1], "%d %d", &nodeIndex, &caseIndex)
+		if err != nil || nodeIndex >= len(nodes) || caseIndex >= len(cases) {
+			continue
+		}
+
+		code, body := fields[2], fields[3]
+
+		response := Response{Case: cases[caseIndex], Node: nodes[nodeIndex], Code: code}
+		if code == httpOK {
+			response.Backend = backends[body]
+		}
+
+		responses = append(responses, response)
+	}
+
+	if len(responses) != len(nodes)*len(cases) {
+		return nil, fmt.Errorf("expected %d responses, got %d: %s", len(nodes)*len(cases), len(responses), output)
+	}
+
+	return responses, nil
+}
+
+// requestScript is a private helper function that returns a shell script sending every case to every node, which
+// prints a record with the node and case indexes, the status code and the start of the body. Records end with a
+// semicolon rather than a newline, as the exec output streamer trims the whitespace of every chunk it reads.
+func (f *Fixture) requestScript(nodes []string, cases []Case) string {
+	var script strings.Builder
+	if f.caCertData != "" {
+		script.WriteString(fmt.Sprintf("printf '%%s' '%s' > %s; ", f.caCertData, caCertPath))
+	}
+
+	for i, node := range nodes {
+		address := f.Nodes[node]
+		for j, c := range cases {
+			args := fmt.Sprintf("-H 'Host: %s' 'http://%s%s'", c.Host, net.JoinHostPort(address, "80"), c.Path)
+			if c.TLS {
+				resolveAddress := address
+				if connectivity.AddressFamily(address) == corev1.IPv6Protocol {
+					resolveAddress = "[" + address + "]"
+				}
+
+				args = fmt.Sprintf("--cacert %s --resolve '%s:443:%s' 'https://%s%s'", caCertPath, c.Host, resolveAddress, c.Host, c.Path)
+			}
+
+			script.WriteString(fmt.Sprintf("rm -f %[1]s; code=$(curl -s -m 5 -o %[1]s -w '%%{http_code}' %[2]s); ", bodyPath, args))
+			script.WriteString(fmt.Sprintf("body=$(head -c %d %s 2>/dev/null | tr -cd 'a-z0-9-'); ", maxBodySize, bodyPath))
+			script.WriteString(fmt.Sprintf(`echo "%d|%d|$code|$body;"; `, i, j))
+		}
+	}
+
+	return script.String()
+}
+
+// url is a private helper function that returns the URL of a case.
+func (c Case) url() string {
+	if c.TLS {
+		return "https://" + c.Host + c.Path
+	}
+
+	return "http://" + c.Host + c.Path
+}
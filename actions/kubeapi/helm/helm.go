@@ -2,23 +2,51 @@ package helm
 
 import (
 	"context"
+	"os/exec"
+	"strconv"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 
+	"github.com/pkg/errors"
 	"github.com/rancher/shepherd/clients/helm"
 	"github.com/rancher/shepherd/pkg/session"
 )
 
+// helmCmd is the helm CLI binary, the same one the shepherd helm client runs.
+const helmCmd = "helm_v3"
+
 // InstallRancher installs latest version of rancher including cert-manager
 // using helm CLI with some predefined values set such as
 // - BootstrapPassword : admin
 // - Hostname          : Localhost
 // - BundledMode       : True
 // - Replicas          : 1
-func InstallRancher(ts *session.Session, restConfig *rest.Config) error {
+// Send the helm set command strings such as "--set", "hostname=rancher.example.com"
+// in the args argument to be appended to the rancher install command, overriding
+// the predefined values.
+func InstallRancher(ts *session.Session, restConfig *rest.Config, args ...string) error {
+	return installRancher(ts, restConfig, nil, args)
+}
+
+// InstallRancherToKubeconfig installs Rancher and cert-manager like InstallRancher, to the cluster of a kubeconfig
+// instead of the cluster of KUBECONFIG.
+func InstallRancherToKubeconfig(ts *session.Session, kubeconfigPath string, args ...string) error {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", kubeconfigPath)
+	if err != nil {
+		return err
+	}
+
+	return installRancher(ts, restConfig, []string{"--kubeconfig", kubeconfigPath}, args)
+}
+
+// installRancher is a private helper function that installs cert-manager and Rancher, passing the helm args to both
+// installs and the rancher args to the Rancher install only.
+func installRancher(ts *session.Session, restConfig *rest.Config, helmArgs, rancherArgs []string) error {
 	//  ClientSet of kubernetes
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
@@ -28,12 +56,12 @@ func InstallRancher(ts *session.Session, restConfig *rest.Config) error {
 	// Create namespace cattle-system
 	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cattle-system"}}
 	_, err = clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metav1.CreateOptions{})
-	if err != nil {
+	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return err
 	}
 
 	// Install cert-manager chart
-	err = InstallCertManager(ts, restConfig)
+	err = InstallCertManager(ts, restConfig, helmArgs...)
 	if err != nil {
 		return err
 	}
@@ -45,10 +73,7 @@ func InstallRancher(ts *session.Session, restConfig *rest.Config) error {
 	}
 
 	// Install Rancher Chart
-	err = helm.InstallChart(ts, "rancher",
-		"rancher-stable/rancher",
-		"cattle-system",
-		"",
+	installArgs := []string{
 		"--set",
 		"hostname=localhost",
 		"--set",
@@ -56,7 +81,15 @@ func InstallRancher(ts *session.Session, restConfig *rest.Config) error {
 		"--set",
 		"useBundledSystemChart=true",
 		"--set",
-		"replicas=1")
+		"replicas=1",
+	}
+	installArgs = append(installArgs, helmArgs...)
+
+	err = helm.InstallChart(ts, "rancher",
+		"rancher-stable/rancher",
+		"cattle-system",
+		"",
+		append(installArgs, rancherArgs...)...)
 	if err != nil {
 		return err
 	}
@@ -65,8 +98,10 @@ func InstallRancher(ts *session.Session, restConfig *rest.Config) error {
 }
 
 // InstallCertManager installs latest version cert manager available through helm
-// CLI. It sets the installCRDs as true to install crds as well.
-func InstallCertManager(ts *session.Session, restConfig *rest.Config) error {
+// CLI. It sets the installCRDs as true to install crds as well. Send helm args
+// such as "--kubeconfig", "/path/to/kubeconfig" in the args argument to be
+// appended to the install command.
+func InstallCertManager(ts *session.Session, restConfig *rest.Config, args ...string) error {
 	//  ClientSet of kubernetes
 	clientset, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
@@ -76,7 +111,7 @@ func InstallCertManager(ts *session.Session, restConfig *rest.Config) error {
 	// Create namespace cert-manager
 	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "cert-manager"}}
 	_, err = clientset.CoreV1().Namespaces().Create(context.Background(), namespace, metav1.CreateOptions{})
-	if err != nil {
+	if err != nil && !apierrors.IsAlreadyExists(err) {
 		return err
 	}
 
@@ -87,10 +122,60 @@ func InstallCertManager(ts *session.Session, restConfig *rest.Config) error {
 	}
 
 	// Install cert-manager Chart
-	err = helm.InstallChart(ts, "cert-manager", "jetstack/cert-manager", "cert-manager", "", "--set", "installCRDs=true")
+	err = helm.InstallChart(ts, "cert-manager", "jetstack/cert-manager", "cert-manager", "", append([]string{"--set", "installCRDs=true"}, args...)...)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// UpgradeRancher upgrades the Rancher release to the given chart version using
+// helm CLI, reusing the values of the current release. Unlike helm.UpgradeChart
+// no cleanup is registered, as uninstalling Rancher is never a cleanup.
+// Send the helm set command strings such as "--set", "replicas=3" in the args
+// argument to be appended to the helm upgrade command.
+func UpgradeRancher(releaseName, chart, namespace, version string, args ...string) error {
+	commandArgs := []string{
+		"upgrade",
+		releaseName,
+		chart,
+		"--namespace",
+		namespace,
+		"--reuse-values",
+		"--wait",
+	}
+
+	commandArgs = append(commandArgs, args...)
+
+	if version != "" {
+		commandArgs = append(commandArgs, "--version", version)
+	}
+
+	msg, err := exec.Command(helmCmd, commandArgs...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "UpgradeRancher: "+string(msg))
+	}
+
+	return nil
+}
+
+// RollbackRancher rolls the Rancher release back to the given revision using
+// helm CLI. A revision of 0 rolls back to the previous release.
+func RollbackRancher(releaseName, namespace string, revision int) error {
+	commandArgs := []string{
+		"rollback",
+		releaseName,
+		strconv.Itoa(revision),
+		"--namespace",
+		namespace,
+		"--wait",
+	}
+
+	msg, err := exec.Command(helmCmd, commandArgs...).CombinedOutput()
+	if err != nil {
+		return errors.Wrap(err, "RollbackRancher: "+string(msg))
+	}
+
+	return nil
+}
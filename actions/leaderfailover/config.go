@@ -0,0 +1,10 @@
+package leaderfailover
+
+const (
+	ConfigurationFileKey = "leaderFailoverInput"
+)
+
+// Config enables the leader failover tests, which repeatedly delete the rancher leader pod of the Rancher under test.
+type Config struct {
+	Enabled bool `json:"enabled" yaml:"enabled"`
+}
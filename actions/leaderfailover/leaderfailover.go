@@ -0,0 +1,124 @@
+package leaderfailover
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/tests/actions/rancherleader"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultDelay          = 2 * time.Minute
+	defaultInterval       = 3 * time.Minute
+	defaultElectionBudget = 2 * time.Minute
+)
+
+// Failover is how the rancher leader is failed over while an operation runs: its pod is deleted Delay after the
+// operation starts, then again every Interval until it has been deleted Repeat times or the operation is done. Each new
+// leader must be elected within ElectionBudget.
+type Failover struct {
+	Delay          time.Duration
+	Interval       time.Duration
+	Repeat         int
+	ElectionBudget time.Duration
+}
+
+// Election is a leader failover that happened during an operation.
+type Election struct {
+	PreviousLeader string
+	NewLeader      string
+	Duration       time.Duration
+}
+
+// withDefaults is a private helper function that returns the failover with defaults for its unset fields.
+func (f Failover) withDefaults() Failover {
+	if f.Delay == 0 {
+		f.Delay = defaultDelay
+	}
+
+	if f.Interval == 0 {
+		f.Interval = defaultInterval
+	}
+
+	if f.Repeat == 0 {
+		f.Repeat = 1
+	}
+
+	if f.ElectionBudget == 0 {
+		f.ElectionBudget = defaultElectionBudget
+	}
+
+	return f
+}
+
+// Run is a helper function that runs an operation while failing over the rancher leader, and returns the elections
+// that happened during it. It fails when the operation fails, when no leader was failed over before the operation was
+// done, or when a new leader was not elected within the budget.
+func Run(client *rancher.Client, failover Failover, operation func() error) ([]Election, error) {
+	failover = failover.withDefaults()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- operation()
+	}()
+
+	var elections []Election
+	var errs []error
+
+	timer := time.NewTimer(failover.Delay)
+	defer timer.Stop()
+
+	var operationErr error
+	operationDone := false
+	for !operationDone {
+		select {
+		case operationErr = <-done:
+			operationDone = true
+		case <-timer.C:
+			election, err := failOver(client, failover.ElectionBudget)
+			if err != nil {
+				errs = append(errs, err)
+			} else {
+				elections = append(elections, *election)
+			}
+
+			if len(elections)+len(errs) < failover.Repeat {
+				timer.Reset(failover.Interval)
+			}
+		}
+	}
+
+	if operationErr != nil {
+		errs = append(errs, fmt.Errorf("operation did not complete through the rancher leader failover: %w", operationErr))
+	}
+
+	if len(elections) == 0 && len(errs) == 0 {
+		errs = append(errs, fmt.Errorf("operation was done within %s, before the rancher leader was failed over", failover.Delay))
+	}
+
+	return elections, errors.Join(errs...)
+}
+
+// failOver is a private helper function that deletes the rancher leader pod and waits for a new leader.
+func failOver(client *rancher.Client, budget time.Duration) (*Election, error) {
+	previousLeader, err := rancherleader.DeleteRancherLeaderPod(client)
+	if err != nil {
+		return nil, err
+	}
+
+	newLeader, duration, err := rancherleader.WaitForNewRancherLeader(client, previousLeader, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Rancher leader failed over from %s to %s", previousLeader, newLeader)
+
+	return &Election{
+		PreviousLeader: previousLeader,
+		NewLeader:      newLeader,
+		Duration:       duration,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package leaderfailover
+
+import (
+	"context"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/cloudcredentials"
+	shepherdclusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/etcdsnapshot"
+	"github.com/rancher/tests/actions/machinepools"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/sirupsen/logrus"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const restoreNone = "none"
+
+// ProvisionCluster is a helper function that provisions an RKE2 or K3s node driver cluster while failing over the
+// rancher leader, and waits for the cluster to be ready.
+func ProvisionCluster(client *rancher.Client, failover Failover, provider provisioning.Provider, credentialSpec cloudcredentials.CloudCredential,
+	clusterConfig *clusters.ClusterConfig, machineConfigSpec machinepools.MachineConfigs) (*steveV1.SteveAPIObject, []Election, error) {
+	var cluster *steveV1.SteveAPIObject
+
+	elections, err := Run(client, failover, func() error {
+		var err error
+		cluster, err = provisioning.CreateProvisioningCluster(client, provider, credentialSpec, clusterConfig, machineConfigSpec, nil)
+		if err != nil {
+			return err
+		}
+
+		logrus.Infof("Waiting for cluster %s to be ready through the rancher leader failover", cluster.Name)
+		return WaitForClusterReady(client, cluster.Name)
+	})
+
+	return cluster, elections, err
+}
+
+// UpgradeCluster is a helper function that upgrades the Kubernetes version of an RKE2 or K3s cluster while failing
+// over the rancher leader, and waits for the cluster to be ready.
+func UpgradeCluster(client *rancher.Client, failover Failover, clusterName, kubernetesVersion string) ([]Election, error) {
+	return Run(client, failover, func() error {
+		logrus.Infof("Upgrading cluster %s to %s through the rancher leader failover", clusterName, kubernetesVersion)
+		_, err := provisioning.UpgradeClusterK8sVersion(client, &clusterName, &kubernetesVersion)
+		if err != nil {
+			return err
+		}
+
+		return WaitForClusterReady(client, clusterName)
+	})
+}
+
+// RestoreSnapshot is a helper function that restores an etcd snapshot of an RKE2 or K3s cluster while failing over the
+// rancher leader, and waits for the cluster to be ready.
+func RestoreSnapshot(client *rancher.Client, failover Failover, clusterName, snapshotID string) ([]Election, error) {
+	clusterID, err := shepherdclusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	cluster, _, err := shepherdclusters.GetProvisioningClusterByName(client, clusterName, namespaces.FleetDefault)
+	if err != nil {
+		return nil, err
+	}
+
+	return Run(client, failover, func() error {
+		logrus.Infof("Restoring snapshot %s of cluster %s through the rancher leader failover", snapshotID, clusterName)
+		err := etcdsnapshot.RestoreAndValidateSnapshotV2Prov(client, snapshotID, &etcdsnapshot.Config{
+			SnapshotRestore:   restoreNone,
+			RecurringRestores: 1,
+		}, cluster, clusterID)
+		if err != nil {
+			return err
+		}
+
+		return WaitForClusterReady(client, clusterName)
+	})
+}
+
+// WaitForClusterReady is a helper function that waits for an RKE2 or K3s cluster to be ready. Errors reaching rancher
+// are retried, as the API is briefly unavailable while the leader fails over.
+func WaitForClusterReady(client *rancher.Client, clusterName string) error {
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.ThirtyMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		cluster, err := client.Steve.SteveType(stevetypes.Provisioning).ByID(namespaces.FleetDefault + "/" + clusterName)
+		if err != nil {
+			return false, nil
+		}
+
+		status := &provv1.ClusterStatus{}
+		err = steveV1.ConvertToK8sType(cluster.Status, status)
+		if err != nil {
+			return false, nil
+		}
+
+		return status.Ready && !cluster.State.Transitioning && !cluster.State.Error, nil
+	})
+}
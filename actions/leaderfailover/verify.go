@@ -0,0 +1,140 @@
+package leaderfailover
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	shepherdclusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	clusterNameLabel     = "rke.cattle.io/cluster-name"
+	machineNameLabel     = "rke.cattle.io/machine-name"
+	machinePoolNameLabel = "rke.cattle.io/rke-machine-pool-name"
+	machinePlanType      = "rke.cattle.io/machine-plan"
+)
+
+// VerifyNoDuplicateMachines is a helper function that verifies that a node driver cluster has exactly as many machines
+// in each machine pool as the pool quantity, none of them being deleted, and no two machines backing the same node,
+// which would be left behind by a rancher leader reconciling a machine twice.
+func VerifyNoDuplicateMachines(client *rancher.Client, clusterName string) error {
+	cluster, _, err := shepherdclusters.GetProvisioningClusterByName(client, clusterName, namespaces.FleetDefault)
+	if err != nil {
+		return err
+	}
+
+	machines, err := listMachines(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	poolMachines := map[string]int{}
+	nodeMachines := map[string]string{}
+	for _, machine := range machines {
+		if machine.DeletionTimestamp != nil {
+			errs = append(errs, fmt.Errorf("machine %s of cluster %s is still being deleted", machine.Name, clusterName))
+			continue
+		}
+
+		poolMachines[machine.Labels[machinePoolNameLabel]]++
+
+		status := &capi.MachineStatus{}
+		err = steveV1.ConvertToK8sType(machine.Status, status)
+		if err != nil {
+			return err
+		}
+
+		if status.NodeRef == nil {
+			continue
+		}
+
+		if other, ok := nodeMachines[status.NodeRef.Name]; ok {
+			errs = append(errs, fmt.Errorf("machines %s and %s of cluster %s both back node %s", other, machine.Name, clusterName, status.NodeRef.Name))
+		}
+
+		nodeMachines[status.NodeRef.Name] = machine.Name
+	}
+
+	if cluster.Spec.RKEConfig != nil {
+		for _, pool := range cluster.Spec.RKEConfig.MachinePools {
+			errs = append(errs, verifyPoolQuantity(clusterName, pool, poolMachines[pool.Name]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifyNoOrphanedPlans is a helper function that verifies that every machine plan secret of a cluster belongs to one
+// of its machines, so no plan was left behind by a rancher leader that lost its lease halfway through reconciling it.
+func VerifyNoOrphanedPlans(client *rancher.Client, clusterName string) error {
+	machines, err := listMachines(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	machineNames := map[string]bool{}
+	for _, machine := range machines {
+		machineNames[machine.Name] = true
+	}
+
+	planSecrets, err := client.WranglerContext.Core.Secret().List(namespaces.FleetDefault, metav1.ListOptions{
+		LabelSelector: clusterNameLabel + "=" + clusterName,
+		FieldSelector: "type=" + machinePlanType,
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, secret := range planSecrets.Items {
+		if secret.DeletionTimestamp != nil {
+			continue
+		}
+
+		machineName := secret.Labels[machineNameLabel]
+		if !machineNames[machineName] {
+			errs = append(errs, fmt.Errorf("plan secret %s of cluster %s belongs to machine %q, which does not exist", secret.Name, clusterName, machineName))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// listMachines is a private helper function that returns the machines of a node driver cluster.
+func listMachines(client *rancher.Client, clusterName string) ([]steveV1.SteveAPIObject, error) {
+	query, err := url.ParseQuery("labelSelector=" + capi.ClusterNameLabel + "=" + clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	machineList, err := client.Steve.SteveType(stevetypes.Machine).NamespacedSteveClient(namespaces.FleetDefault).List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return machineList.Data, nil
+}
+
+// verifyPoolQuantity is a private helper function that verifies that a machine pool has as many machines as its
+// quantity.
+func verifyPoolQuantity(clusterName string, pool provv1.RKEMachinePool, machines int) error {
+	quantity := int32(1)
+	if pool.Quantity != nil {
+		quantity = *pool.Quantity
+	}
+
+	if int32(machines) != quantity {
+		return fmt.Errorf("machine pool %s of cluster %s has %d machines, expected %d", pool.Name, clusterName, machines, quantity)
+	}
+
+	return nil
+}
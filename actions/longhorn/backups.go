@@ -0,0 +1,235 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/s3server"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// BackupStateCompleted is the state of a longhorn backup stored in the backup target
+	BackupStateCompleted = "Completed"
+	// BackupStateError is the state of a failed longhorn backup
+	BackupStateError = "Error"
+
+	defaultBackupTarget   = "default"
+	backupVolumeLabel     = "backup-volume"
+	backupSecretName      = "longhorn-backup-target"
+	accessKeyIDEnv        = "AWS_ACCESS_KEY_ID"
+	secretAccessKeyEnv    = "AWS_SECRET_ACCESS_KEY"
+	endpointsEnv          = "AWS_ENDPOINTS"
+	certEnv               = "AWS_CERT"
+	fromBackupParameter   = "fromBackup"
+	backupPollInterval    = "30s"
+	backupTargetAvailable = "available"
+)
+
+// Backup is a longhorn backup of a volume, stored in the backup target. URL is the backup url a volume is restored from.
+type Backup struct {
+	Name     string
+	Snapshot string
+	Volume   string
+	URL      string
+}
+
+// ConfigureBackupTarget is a helper function that points the default longhorn backup target of a cluster to a folder of
+// the bucket of an S3 server, with a credential secret in the longhorn namespace, and waits for the target to be
+// available.
+func ConfigureBackupTarget(client *rancher.Client, clusterID string, s3Server *s3server.S3Server, folder string) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	secretData := map[string][]byte{
+		accessKeyIDEnv:     []byte(s3Server.AccessKey),
+		secretAccessKeyEnv: []byte(s3Server.SecretKey),
+		endpointsEnv:       []byte(s3Server.URL()),
+	}
+
+	if len(s3Server.CACert) > 0 {
+		secretData[certEnv] = s3Server.CACert
+	}
+
+	secret, err := wranglerContext.Core.Secret().Create(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString(backupSecretName),
+			Namespace: LonghornNamespace,
+		},
+		Data: secretData,
+		Type: corev1.SecretTypeOpaque,
+	})
+	if err != nil {
+		return err
+	}
+
+	backupTargetURL := fmt.Sprintf("s3://%s@%s/%s", s3Server.Bucket, s3Server.Region, strings.Trim(folder, "/"))
+
+	backupTarget, err := dynamicClient.Resource(BackupTargetGroupVersionResource).Namespace(LonghornNamespace).Get(context.TODO(), defaultBackupTarget, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	err = unstructured.SetNestedField(backupTarget.Object, backupTargetURL, "spec", "backupTargetURL")
+	if err != nil {
+		return err
+	}
+
+	err = unstructured.SetNestedField(backupTarget.Object, secret.Name, "spec", "credentialSecret")
+	if err != nil {
+		return err
+	}
+
+	err = unstructured.SetNestedField(backupTarget.Object, backupPollInterval, "spec", "pollInterval")
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Setting the longhorn backup target to %s", backupTargetURL)
+	_, err = dynamicClient.Resource(BackupTargetGroupVersionResource).Namespace(LonghornNamespace).Update(context.TODO(), backupTarget, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		backupTarget, err := dynamicClient.Resource(BackupTargetGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, defaultBackupTarget, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		available, _, _ := unstructured.NestedBool(backupTarget.Object, "status", backupTargetAvailable)
+
+		return available, nil
+	})
+}
+
+// CreateSnapshot is a helper function that takes a snapshot of a longhorn volume and waits for it to be ready to use.
+func CreateSnapshot(client *rancher.Client, clusterID, volumeName string) (string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": longhornGroup + "/" + longhornVersion,
+		"kind":       "Snapshot",
+		"metadata": map[string]interface{}{
+			"name":      namegen.AppendRandomString(volumeName),
+			"namespace": LonghornNamespace,
+		},
+		"spec": map[string]interface{}{
+			"volume":         volumeName,
+			"createSnapshot": true,
+		},
+	}}
+
+	logrus.Infof("Taking a snapshot of longhorn volume %s", volumeName)
+	snapshot, err = dynamicClient.Resource(SnapshotGroupVersionResource).Namespace(LonghornNamespace).Create(context.TODO(), snapshot, metav1.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	var snapshotError string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := dynamicClient.Resource(SnapshotGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, snapshot.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		snapshotError, _, _ = unstructured.NestedString(current.Object, "status", "error")
+		readyToUse, _, _ := unstructured.NestedBool(current.Object, "status", "readyToUse")
+
+		return readyToUse, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("snapshot %s of longhorn volume %s is not ready to use %s: %w", snapshot.GetName(), volumeName, snapshotError, err)
+	}
+
+	return snapshot.GetName(), nil
+}
+
+// CreateBackup is a helper function that backs up a snapshot of a longhorn volume to the backup target and waits for
+// the backup to be completed.
+func CreateBackup(client *rancher.Client, clusterID, volumeName, snapshotName string) (*Backup, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": longhornGroup + "/" + longhornVersion,
+		"kind":       "Backup",
+		"metadata": map[string]interface{}{
+			"name":      namegen.AppendRandomString(volumeName),
+			"namespace": LonghornNamespace,
+			"labels":    map[string]interface{}{backupVolumeLabel: volumeName},
+		},
+		"spec": map[string]interface{}{
+			"snapshotName": snapshotName,
+		},
+	}}
+
+	logrus.Infof("Backing up snapshot %s of longhorn volume %s", snapshotName, volumeName)
+	backup, err = dynamicClient.Resource(BackupGroupVersionResource).Namespace(LonghornNamespace).Create(context.TODO(), backup, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Backup{
+		Name:     backup.GetName(),
+		Snapshot: snapshotName,
+		Volume:   volumeName,
+	}
+
+	var state, backupError string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := dynamicClient.Resource(BackupGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, result.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		state, _, _ = unstructured.NestedString(current.Object, "status", "state")
+		backupError, _, _ = unstructured.NestedString(current.Object, "status", "error")
+		result.URL, _, _ = unstructured.NestedString(current.Object, "status", "url")
+
+		if state == BackupStateError {
+			return false, fmt.Errorf("backup %s of longhorn volume %s failed: %s", result.Name, volumeName, backupError)
+		}
+
+		return state == BackupStateCompleted && result.URL != "", nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup %s of longhorn volume %s is %s: %w", result.Name, volumeName, state, err)
+	}
+
+	return result, nil
+}
+
+// RestoreBackup is a helper function that restores a longhorn backup to a new volume of a namespace, provisioned by a
+// storage class restoring from the backup with a number of replicas.
+func RestoreBackup(client *rancher.Client, clusterID, namespace string, backup *Backup, replicas int, size string) (*Volume, error) {
+	storageClass, err := CreateStorageClass(client, clusterID, replicas, map[string]string{fromBackupParameter: backup.URL})
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Restoring backup %s of longhorn volume %s", backup.Name, backup.Volume)
+
+	return CreateVolume(client, clusterID, namespace, storageClass.Name, size)
+}
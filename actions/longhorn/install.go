@@ -0,0 +1,202 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+
+	catalogv1 "github.com/rancher/rancher/pkg/apis/catalog.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/pkg/api/steve/catalog/types"
+	"github.com/rancher/shepherd/pkg/wait"
+	"github.com/rancher/tests/actions/charts"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	ConfigurationFileKey = "longhornInput"
+
+	// Namespace that the longhorn chart is installed in
+	LonghornNamespace = "longhorn-system"
+	// Name of the longhorn chart
+	LonghornChartName = "longhorn"
+	// Name of the longhorn crd chart
+	LonghornCRDChartName = "longhorn-crd"
+	// Name of the storage class created by the longhorn chart
+	LonghornStorageClass = "longhorn"
+
+	defaultRegistrySettingID = "system-default-registry"
+	serverURLSettingID       = "server-url"
+)
+
+// Config is the longhorn chart version and the default settings it is installed with. An unset chart version is the
+// latest one, and unset settings keep the chart defaults.
+type Config struct {
+	ChartVersion        string `json:"chartVersion" yaml:"chartVersion"`
+	DefaultReplicaCount int    `json:"defaultReplicaCount" yaml:"defaultReplicaCount"`
+	DefaultDataPath     string `json:"defaultDataPath" yaml:"defaultDataPath"`
+}
+
+// InstallLonghornChart is a helper function that installs the longhorn and longhorn-crd charts from the rancher chart
+// catalog, and waits for every longhorn pod to be ready and the longhorn storage class to exist. Both charts are
+// uninstalled on session cleanup.
+func InstallLonghornChart(client *rancher.Client, installOptions *charts.InstallOptions, longhornConfig *Config) error {
+	serverSetting, err := client.Management.Setting.ByID(serverURLSettingID)
+	if err != nil {
+		return err
+	}
+
+	registrySetting, err := client.Management.Setting.ByID(defaultRegistrySettingID)
+	if err != nil {
+		return err
+	}
+
+	payload := &charts.PayloadOpts{
+		InstallOptions:  *installOptions,
+		Name:            LonghornChartName,
+		Namespace:       LonghornNamespace,
+		Host:            serverSetting.Value,
+		DefaultRegistry: registrySetting.Value,
+	}
+
+	chartInstallAction := newLonghornChartInstallAction(payload, longhornConfig)
+
+	catalogClient, err := client.GetClusterCatalogClient(installOptions.Cluster.ID)
+	if err != nil {
+		return err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		// the pre-delete hook of the longhorn chart refuses to uninstall it without the deleting confirmation flag
+		err := setSetting(client, installOptions.Cluster.ID, deletingConfirmationFlagSetting, "true")
+		if err != nil {
+			return err
+		}
+
+		defaultChartUninstallAction := charts.NewChartUninstallAction()
+
+		for _, chartName := range []string{LonghornChartName, LonghornCRDChartName} {
+			err := catalogClient.UninstallChart(chartName, LonghornNamespace, defaultChartUninstallAction)
+			if err != nil {
+				return err
+			}
+
+			err = waitForAppDeleted(catalogClient, chartName)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	err = charts.ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Installing longhorn %s on cluster %s", installOptions.Version, installOptions.Cluster.Name)
+	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
+	if err != nil {
+		return err
+	}
+
+	watchAppInterface, err := catalogClient.Apps(LonghornNamespace).Watch(context.TODO(), metav1.ListOptions{
+		FieldSelector:  "metadata.name=" + LonghornChartName,
+		TimeoutSeconds: &defaults.WatchTimeoutSeconds,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = wait.WatchWait(watchAppInterface, func(event watch.Event) (ready bool, err error) {
+		app := event.Object.(*catalogv1.App)
+
+		return app.Status.Summary.State == string(catalogv1.StatusDeployed), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return WaitForLonghornReady(client, installOptions.Cluster.ID)
+}
+
+// WaitForLonghornReady is a helper function that waits for every pod of the longhorn namespace to be ready and the
+// longhorn storage class to exist.
+func WaitForLonghornReady(client *rancher.Client, clusterID string) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := wranglerContext.Core.Pod().List(LonghornNamespace, metav1.ListOptions{})
+		if err != nil || len(pods.Items) == 0 {
+			return false, nil
+		}
+
+		for i := range pods.Items {
+			if pods.Items[i].Status.Phase != corev1.PodSucceeded && !connectivity.PodReady(&pods.Items[i]) {
+				return false, nil
+			}
+		}
+
+		_, err = dynamicClient.Resource(storageClassGroupVersionResource).Get(ctx, LonghornStorageClass, metav1.GetOptions{})
+
+		return err == nil, nil
+	})
+}
+
+// newLonghornChartInstallAction is a private helper function that returns the chart install action of the longhorn and
+// longhorn-crd charts.
+func newLonghornChartInstallAction(p *charts.PayloadOpts, longhornConfig *Config) *types.ChartInstallAction {
+	defaultSettings := map[string]interface{}{}
+	if longhornConfig != nil && longhornConfig.DefaultReplicaCount > 0 {
+		defaultSettings["defaultReplicaCount"] = longhornConfig.DefaultReplicaCount
+	}
+
+	if longhornConfig != nil && longhornConfig.DefaultDataPath != "" {
+		defaultSettings["defaultDataPath"] = longhornConfig.DefaultDataPath
+	}
+
+	var values map[string]interface{}
+	if len(defaultSettings) > 0 {
+		values = map[string]interface{}{"defaultSettings": defaultSettings}
+	}
+
+	chartInstall := charts.NewChartInstall(p.Name, p.Version, p.Cluster.ID, p.Cluster.Name, p.Host, catalog.RancherChartRepo, p.ProjectID, p.DefaultRegistry, values)
+	chartInstallCRD := charts.NewChartInstall(LonghornCRDChartName, p.Version, p.Cluster.ID, p.Cluster.Name, p.Host, catalog.RancherChartRepo, p.ProjectID, p.DefaultRegistry, nil)
+
+	return charts.NewChartInstallAction(p.Namespace, p.ProjectID, []types.ChartInstall{*chartInstallCRD, *chartInstall})
+}
+
+// waitForAppDeleted is a private helper function that waits for the app of an uninstalled chart to be deleted.
+func waitForAppDeleted(catalogClient *catalog.Client, chartName string) error {
+	watchAppInterface, err := catalogClient.Apps(LonghornNamespace).Watch(context.TODO(), metav1.ListOptions{
+		FieldSelector:  "metadata.name=" + chartName,
+		TimeoutSeconds: &defaults.WatchTimeoutSeconds,
+	})
+	if err != nil {
+		return err
+	}
+
+	return wait.WatchWait(watchAppInterface, func(event watch.Event) (ready bool, err error) {
+		if event.Type == watch.Error {
+			return false, fmt.Errorf("there was an error uninstalling the %s chart", chartName)
+		}
+
+		return event.Type == watch.Deleted, nil
+	})
+}
@@ -0,0 +1,124 @@
+package longhorn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/tests/actions/disasterrecovery"
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	etcdRoleLabel         = "rke.cattle.io/etcd-role"
+	controlPlaneRoleLabel = "rke.cattle.io/control-plane-role"
+	workerRoleLabel       = "rke.cattle.io/worker-role"
+)
+
+// ErrNoWorkerReplicaNode is returned by DestroyReplicaNode when no replica of the volume runs on a worker only machine.
+var ErrNoWorkerReplicaNode = errors.New("no replica runs on a worker only machine")
+
+// DestroyReplicaNode is a helper function that destroys the worker only machine of a node running a replica of a
+// longhorn volume, other than the node the volume is attached to, so the volume loses a replica while its workload
+// keeps running and the etcd and control plane nodes are untouched. The machine pool provisions a replacement machine.
+// It returns the name of the lost node, or ErrNoWorkerReplicaNode when no replica node can be destroyed.
+func DestroyReplicaNode(client *rancher.Client, clusterName, clusterID, volumeName string) (string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(context.TODO(), volumeName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	attachedNode, _, _ := unstructured.NestedString(volume.Object, "status", "currentNodeID")
+
+	nodes, err := replicaNodes(client, clusterID, volumeName)
+	if err != nil {
+		return "", err
+	}
+
+	machines, err := disasterrecovery.ListMachines(client, clusterName)
+	if err != nil {
+		return "", err
+	}
+
+	for _, machine := range machines {
+		nodeName := machineNodeName(&machine)
+		if nodeName == "" || nodeName == attachedNode || !slices.Contains(nodes, nodeName) || !isWorkerOnly(&machine) {
+			continue
+		}
+
+		logrus.Infof("Destroying node %s holding a replica of longhorn volume %s", nodeName, volumeName)
+
+		return nodeName, disasterrecovery.DestroyMachines(client, []steveV1.SteveAPIObject{machine})
+	}
+
+	return "", fmt.Errorf("longhorn volume %s with replicas on [%s], attached to %s: %w", volumeName, strings.Join(nodes, ", "), attachedNode, ErrNoWorkerReplicaNode)
+}
+
+// WaitForReplicaRebuild is a helper function that waits for a longhorn volume to rebuild the replica of a lost node on
+// another node, until it is healthy again with the expected number of running replicas, none of them on the lost node.
+func WaitForReplicaRebuild(client *rancher.Client, clusterID, volumeName, lostNode string, expected int) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for longhorn volume %s to rebuild the replica of node %s", volumeName, lostNode)
+	var nodes []string
+	var robustness string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.ThirtyMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, volumeName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		robustness, _, _ = unstructured.NestedString(volume.Object, "status", "robustness")
+
+		nodes, err = replicaNodes(client, clusterID, volumeName)
+		if err != nil {
+			return false, nil
+		}
+
+		return robustness == VolumeRobustnessHealthy && len(nodes) == expected && !slices.Contains(nodes, lostNode), nil
+	})
+	if err != nil {
+		return fmt.Errorf("longhorn volume %s is %s with running replicas on [%s], expected %d replicas off node %s: %w", volumeName, robustness,
+			strings.Join(nodes, ", "), expected, lostNode, err)
+	}
+
+	return nil
+}
+
+// machineNodeName is a private helper function that returns the name of the node of a machine.
+func machineNodeName(machine *steveV1.SteveAPIObject) string {
+	status, ok := machine.Status.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	nodeRef, ok := status["nodeRef"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	name, _ := nodeRef["name"].(string)
+
+	return name
+}
+
+// isWorkerOnly is a private helper function that returns whether a machine only has the worker role.
+func isWorkerOnly(machine *steveV1.SteveAPIObject) bool {
+	return machine.Labels[workerRoleLabel] == "true" && machine.Labels[etcdRoleLabel] != "true" && machine.Labels[controlPlaneRoleLabel] != "true"
+}
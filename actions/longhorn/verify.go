@@ -0,0 +1,99 @@
+package longhorn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/ingresses"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	volumeSteveType = "longhorn.io.volume"
+	frontendPath    = "api/v1/namespaces/" + LonghornNamespace + "/services/http:longhorn-frontend:80/proxy/"
+	frontendTitle   = "Longhorn"
+)
+
+var (
+	requiredResources = []string{"volumes", "replicas", "engines", "nodes", "settings", "snapshots", "backups", "backuptargets", "backupvolumes"}
+
+	customResourceDefinitionGroupVersionResource = schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	}
+)
+
+// VerifyRancherIntegration is a helper function that verifies how rancher integrates longhorn in a cluster: the longhorn
+// custom resource definitions are installed, rancher serves the longhorn volumes through steve, as the rancher UI lists
+// them, and the longhorn UI is reachable through the rancher service proxy, as the longhorn button of the rancher UI
+// opens it.
+func VerifyRancherIntegration(client *rancher.Client, clusterID string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	customResourceDefinitions, err := dynamicClient.Resource(customResourceDefinitionGroupVersionResource).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var resources []string
+	for _, customResourceDefinition := range customResourceDefinitions.Items {
+		group, _, _ := unstructured.NestedString(customResourceDefinition.Object, "spec", "group")
+		plural, _, _ := unstructured.NestedString(customResourceDefinition.Object, "spec", "names", "plural")
+		if group == longhornGroup {
+			resources = append(resources, plural)
+		}
+	}
+
+	var errs []error
+	for _, resource := range requiredResources {
+		if !slices.Contains(resources, resource) {
+			errs = append(errs, fmt.Errorf("custom resource definition %s.%s is not installed", resource, longhornGroup))
+		}
+	}
+
+	volumes, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	steveVolumes, err := steveClient.SteveType(volumeSteveType).List(nil)
+	if err != nil {
+		return err
+	}
+
+	var steveVolumeNames []string
+	for _, steveVolume := range steveVolumes.Data {
+		steveVolumeNames = append(steveVolumeNames, steveVolume.Name)
+	}
+
+	for _, volume := range volumes.Items {
+		if !slices.Contains(steveVolumeNames, volume.GetName()) {
+			errs = append(errs, fmt.Errorf("longhorn volume %s is not served by rancher as %s", volume.GetName(), volumeSteveType))
+		}
+	}
+
+	path := fmt.Sprintf("k8s/clusters/%s/%s", clusterID, frontendPath)
+	body, err := ingresses.GetExternalIngressResponse(client, client.RancherConfig.Host, path, true)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("longhorn UI is not reachable through rancher: %w", err))
+	} else if !strings.Contains(body, frontendTitle) {
+		errs = append(errs, fmt.Errorf("longhorn UI is not served through rancher on %s", path))
+	}
+
+	return errors.Join(errs...)
+}
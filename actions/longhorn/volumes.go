@@ -0,0 +1,556 @@
+package longhorn
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// Provisioner is the CSI driver of longhorn volumes
+	Provisioner = "driver.longhorn.io"
+
+	// VolumeStateAttached is the state of a longhorn volume attached to a node
+	VolumeStateAttached = "attached"
+	// VolumeRobustnessHealthy is the robustness of a longhorn volume with all of its replicas running
+	VolumeRobustnessHealthy = "healthy"
+	// ReplicaStateRunning is the state of a running longhorn replica
+	ReplicaStateRunning = "running"
+
+	longhornGroup                   = "longhorn.io"
+	longhornVersion                 = "v1beta2"
+	volumeLabel                     = "longhornvolume"
+	claimLabel                      = "longhorn.cattle.io/claim"
+	volumeName                      = "longhorn"
+	volumeMountPath                 = "/data"
+	deletingConfirmationFlagSetting = "deleting-confirmation-flag"
+	storageClassKind                = "StorageClass"
+	nodeConditionReady              = "Ready"
+)
+
+var (
+	// VolumeGroupVersionResource is the required Group Version Resource for accessing longhorn volumes in a cluster,
+	// using the dynamic client.
+	VolumeGroupVersionResource = longhornResource("volumes")
+	// ReplicaGroupVersionResource is the required Group Version Resource for accessing longhorn replicas in a cluster,
+	// using the dynamic client.
+	ReplicaGroupVersionResource = longhornResource("replicas")
+	// SnapshotGroupVersionResource is the required Group Version Resource for accessing longhorn snapshots in a cluster,
+	// using the dynamic client.
+	SnapshotGroupVersionResource = longhornResource("snapshots")
+	// BackupGroupVersionResource is the required Group Version Resource for accessing longhorn backups in a cluster,
+	// using the dynamic client.
+	BackupGroupVersionResource = longhornResource("backups")
+	// BackupTargetGroupVersionResource is the required Group Version Resource for accessing longhorn backup targets in
+	// a cluster, using the dynamic client.
+	BackupTargetGroupVersionResource = longhornResource("backuptargets")
+	// NodeGroupVersionResource is the required Group Version Resource for accessing longhorn nodes in a cluster, using
+	// the dynamic client.
+	NodeGroupVersionResource = longhornResource("nodes")
+	// SettingGroupVersionResource is the required Group Version Resource for accessing longhorn settings in a cluster,
+	// using the dynamic client.
+	SettingGroupVersionResource = longhornResource("settings")
+
+	storageClassGroupVersionResource = schema.GroupVersionResource{
+		Group:    "storage.k8s.io",
+		Version:  "v1",
+		Resource: "storageclasses",
+	}
+)
+
+// Volume is a longhorn volume provisioned for a persistent volume claim and mounted by a single pod deployment. Name is
+// the name of the longhorn volume, which is the name of its persistent volume.
+type Volume struct {
+	ClusterID             string
+	Namespace             string
+	Name                  string
+	StorageClass          string
+	PersistentVolumeClaim string
+	Deployment            string
+}
+
+// CreateStorageClass is a helper function that creates a longhorn storage class provisioning volumes with a number of
+// replicas, and allowing their expansion. Extra parameters, e.g. fromBackup, are added to the storage class parameters.
+// The storage class is deleted on session cleanup.
+func CreateStorageClass(client *rancher.Client, clusterID string, replicas int, parameters map[string]string) (*storagev1.StorageClass, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	allowVolumeExpansion := true
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	storageClass := &storagev1.StorageClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: storagev1.SchemeGroupVersion.String(),
+			Kind:       storageClassKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: namegen.AppendRandomString(LonghornStorageClass),
+		},
+		Provisioner: Provisioner,
+		Parameters: map[string]string{
+			"numberOfReplicas":    strconv.Itoa(replicas),
+			"staleReplicaTimeout": "30",
+		},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+		ReclaimPolicy:        &reclaimPolicy,
+	}
+
+	for key, value := range parameters {
+		storageClass.Parameters[key] = value
+	}
+
+	unstructuredStorageClass, err := runtime.DefaultUnstructuredConverter.ToUnstructured(storageClass)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dynamicClient.Resource(storageClassGroupVersionResource).Create(context.TODO(), &unstructured.Unstructured{Object: unstructuredStorageClass}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		return dynamicClient.Resource(storageClassGroupVersionResource).Delete(context.TODO(), storageClass.Name, metav1.DeleteOptions{})
+	})
+
+	return storageClass, nil
+}
+
+// CreateVolume is a helper function that creates a ReadWriteOnce persistent volume claim of a longhorn storage class in
+// a namespace, mounts it on /data of a single pod deployment, and waits for the longhorn volume to be attached and
+// healthy.
+func CreateVolume(client *rancher.Client, clusterID, namespace, storageClassName, size string) (*Volume, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Create(&corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString(volumeName),
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := int32(1)
+	labels := map[string]string{claimLabel: pvc.Name}
+
+	logrus.Infof("Creating a %s volume of storage class %s in namespace %s", size, storageClassName, namespace)
+	deployment, err := wranglerContext.Apps.Deployment().Create(&appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvc.Name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appv1.DeploymentStrategy{Type: appv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:         volumeName,
+							Image:        connectivity.GetProbeImage(),
+							Args:         []string{"pause"},
+							VolumeMounts: []corev1.VolumeMount{{Name: volumeName, MountPath: volumeMountPath}},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name:         volumeName,
+							VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name}},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	volume := &Volume{
+		ClusterID:             clusterID,
+		Namespace:             namespace,
+		StorageClass:          storageClassName,
+		PersistentVolumeClaim: pvc.Name,
+		Deployment:            deployment.Name,
+	}
+
+	_, err = volume.pod(client)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err = wranglerContext.Core.PersistentVolumeClaim().Get(namespace, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	volume.Name = pvc.Spec.VolumeName
+
+	return volume, WaitForVolumeHealthy(client, clusterID, volume.Name)
+}
+
+// WaitForVolumeHealthy is a helper function that waits for a longhorn volume to be attached with all of its replicas
+// running.
+func WaitForVolumeHealthy(client *rancher.Client, clusterID, name string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	var state, robustness string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		state, _, _ = unstructured.NestedString(volume.Object, "status", "state")
+		robustness, _, _ = unstructured.NestedString(volume.Object, "status", "robustness")
+
+		return state == VolumeStateAttached && robustness == VolumeRobustnessHealthy, nil
+	})
+	if err != nil {
+		return fmt.Errorf("longhorn volume %s is %s and %s, expected %s and %s: %w", name, state, robustness, VolumeStateAttached, VolumeRobustnessHealthy, err)
+	}
+
+	return nil
+}
+
+// VerifyReplicas is a helper function that verifies that a longhorn volume has the expected number of replicas, each of
+// them running on a different node.
+func VerifyReplicas(client *rancher.Client, clusterID, name string, expected int) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	numberOfReplicas, _, _ := unstructured.NestedInt64(volume.Object, "spec", "numberOfReplicas")
+	if int(numberOfReplicas) != expected {
+		return fmt.Errorf("longhorn volume %s has %d replicas, expected %d", name, numberOfReplicas, expected)
+	}
+
+	nodes, err := replicaNodes(client, clusterID, name)
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) != expected {
+		return fmt.Errorf("longhorn volume %s has running replicas on %d nodes [%s], expected %d", name, len(nodes), strings.Join(nodes, ", "), expected)
+	}
+
+	return nil
+}
+
+// WriteData is a helper function that writes a file of random data to a volume and returns its sha256 checksum.
+func (v *Volume) WriteData(client *rancher.Client, file string, sizeMB int) (string, error) {
+	restConfig, podName, err := v.execTarget(client)
+	if err != nil {
+		return "", err
+	}
+
+	return pods.ExecScript(restConfig, v.Namespace, podName, fmt.Sprintf("head -c %d /dev/urandom > %s/%s && sync && sha256sum %[2]s/%[3]s | cut -d ' ' -f 1", sizeMB*1024*1024, volumeMountPath, file))
+}
+
+// Checksum is a helper function that returns the sha256 checksum of a file of a volume.
+func (v *Volume) Checksum(client *rancher.Client, file string) (string, error) {
+	restConfig, podName, err := v.execTarget(client)
+	if err != nil {
+		return "", err
+	}
+
+	return pods.ExecScript(restConfig, v.Namespace, podName, fmt.Sprintf("sha256sum %s/%s | cut -d ' ' -f 1", volumeMountPath, file))
+}
+
+// Restart is a helper function that deletes the pod of a volume and waits for its replacement to be ready, with the
+// volume attached again.
+func (v *Volume) Restart(client *rancher.Client) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, v.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	pod, err := v.pod(client)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Restarting pod %s of longhorn volume %s", pod.Name, v.Name)
+	err = wranglerContext.Core.Pod().Delete(v.Namespace, pod.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		_, err := wranglerContext.Core.Pod().Get(v.Namespace, pod.Name, metav1.GetOptions{})
+
+		return apierrors.IsNotFound(err), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = v.pod(client)
+	if err != nil {
+		return err
+	}
+
+	return WaitForVolumeHealthy(client, v.ClusterID, v.Name)
+}
+
+// Expand is a helper function that expands the persistent volume claim of a volume, and waits for the longhorn volume
+// and the filesystem mounted by its pod to be resized.
+func (v *Volume) Expand(client *rancher.Client, size string) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, v.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(v.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	quantity := resource.MustParse(size)
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Get(v.Namespace, v.PersistentVolumeClaim, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = quantity
+
+	logrus.Infof("Expanding longhorn volume %s to %s", v.Name, size)
+	_, err = wranglerContext.Core.PersistentVolumeClaim().Update(pvc)
+	if err != nil {
+		return err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		pvc, err := wranglerContext.Core.PersistentVolumeClaim().Get(v.Namespace, v.PersistentVolumeClaim, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		capacity := pvc.Status.Capacity[corev1.ResourceStorage]
+		if capacity.Cmp(quantity) < 0 {
+			return false, nil
+		}
+
+		volume, err := dynamicClient.Resource(VolumeGroupVersionResource).Namespace(LonghornNamespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		volumeSize, _, _ := unstructured.NestedString(volume.Object, "spec", "size")
+
+		return volumeSize == strconv.FormatInt(quantity.Value(), 10), nil
+	})
+	if err != nil {
+		return fmt.Errorf("longhorn volume %s was not expanded to %s: %w", v.Name, size, err)
+	}
+
+	restConfig, podName, err := v.execTarget(client)
+	if err != nil {
+		return err
+	}
+
+	output, err := pods.ExecScript(restConfig, v.Namespace, podName, fmt.Sprintf("df -k %s | tail -n 1 | awk '{print $2}'", volumeMountPath))
+	if err != nil {
+		return err
+	}
+
+	filesystemKB, err := strconv.ParseInt(output, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unexpected filesystem size %q of longhorn volume %s: %w", output, v.Name, err)
+	}
+
+	// the filesystem keeps a few percent of the volume for its metadata
+	if filesystemKB*1024 < quantity.Value()*9/10 {
+		return fmt.Errorf("filesystem of longhorn volume %s is %dKB after expanding it to %s", v.Name, filesystemKB, size)
+	}
+
+	return nil
+}
+
+// pod is a private helper function that waits for the pod of the deployment of a volume to be ready and returns it.
+func (v *Volume) pod(client *rancher.Client) (*corev1.Pod, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, v.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var readyPod *corev1.Pod
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := wranglerContext.Core.Pod().List(v.Namespace, metav1.ListOptions{LabelSelector: claimLabel + "=" + v.PersistentVolumeClaim})
+		if err != nil {
+			return false, nil
+		}
+
+		for i := range pods.Items {
+			if pods.Items[i].DeletionTimestamp == nil && connectivity.PodReady(&pods.Items[i]) {
+				readyPod = &pods.Items[i]
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s/%s of longhorn volume %s has no ready pod: %w", v.Namespace, v.Deployment, v.Name, err)
+	}
+
+	return readyPod, nil
+}
+
+// execTarget is a private helper function that returns the rest config of the cluster of a volume and the name of its
+// ready pod, to run scripts in the pod with pods.ExecScript.
+func (v *Volume) execTarget(client *rancher.Client) (*rest.Config, string, error) {
+	pod, err := v.pod(client)
+	if err != nil {
+		return nil, "", err
+	}
+
+	clientConfig, err := kubeconfig.GetKubeconfig(client, v.ClusterID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	restConfig, err := (*clientConfig).ClientConfig()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return restConfig, pod.Name, nil
+}
+
+// SchedulableNodes is a helper function that returns how many longhorn nodes of a cluster are ready and allow replicas
+// to be scheduled on them.
+func SchedulableNodes(client *rancher.Client, clusterID string) (int, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	nodes, err := dynamicClient.Resource(NodeGroupVersionResource).Namespace(LonghornNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	schedulable := 0
+	for _, node := range nodes.Items {
+		allowScheduling, _, _ := unstructured.NestedBool(node.Object, "spec", "allowScheduling")
+		conditions, _, _ := unstructured.NestedSlice(node.Object, "status", "conditions")
+
+		ready := false
+		for _, condition := range conditions {
+			condition, ok := condition.(map[string]interface{})
+			if ok && condition["type"] == nodeConditionReady && condition["status"] == string(corev1.ConditionTrue) {
+				ready = true
+			}
+		}
+
+		if allowScheduling && ready {
+			schedulable++
+		}
+	}
+
+	return schedulable, nil
+}
+
+// replicaNodes is a private helper function that returns the nodes of the running replicas of a longhorn volume.
+func replicaNodes(client *rancher.Client, clusterID, name string) ([]string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := dynamicClient.Resource(ReplicaGroupVersionResource).Namespace(LonghornNamespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: volumeLabel + "=" + name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []string
+	for _, replica := range replicas.Items {
+		state, _, _ := unstructured.NestedString(replica.Object, "status", "currentState")
+		nodeID, _, _ := unstructured.NestedString(replica.Object, "spec", "nodeID")
+		if state == ReplicaStateRunning && nodeID != "" && !slices.Contains(nodes, nodeID) {
+			nodes = append(nodes, nodeID)
+		}
+	}
+
+	return nodes, nil
+}
+
+// setSetting is a private helper function that sets the value of a longhorn setting.
+func setSetting(client *rancher.Client, clusterID, name, value string) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return err
+	}
+
+	setting, err := dynamicClient.Resource(SettingGroupVersionResource).Namespace(LonghornNamespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	setting.Object["value"] = value
+
+	_, err = dynamicClient.Resource(SettingGroupVersionResource).Namespace(LonghornNamespace).Update(context.TODO(), setting, metav1.UpdateOptions{})
+
+	return err
+}
+
+// longhornResource is a private helper function that returns the Group Version Resource of a longhorn resource.
+func longhornResource(resource string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    longhornGroup,
+		Version:  longhornVersion,
+		Resource: resource,
+	}
+}
@@ -0,0 +1,218 @@
+package faults
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/nodes"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	RKE2ServerService = "rke2-server"
+	RKE2AgentService  = "rke2-agent"
+	K3SServerService  = "k3s"
+	K3SAgentService   = "k3s-agent"
+
+	// DefaultKeepFreePercent leaves less free space than the default nodefs.available<10% hard eviction threshold of
+	// the kubelet.
+	DefaultKeepFreePercent = 5
+	// DefaultFillPath is on the filesystem the kubelet watches for disk pressure.
+	DefaultFillPath = "/var/lib/kubelet"
+
+	faultPrefix  = "fault"
+	fillFileName = "fault-fill"
+	scriptDir    = "/run"
+)
+
+// Fault is a fault injected into a node over SSH. Inject and Revert are shell scripts run as root; Revert is empty for
+// faults that revert themselves, like a reboot.
+type Fault struct {
+	Name   string
+	Inject string
+	Revert string
+}
+
+// Injection is a fault injected into a node, with the systemd timer on the node that reverts it at RevertAt. The
+// timer runs on the node, so the fault is reverted even when the test stops or loses its SSH connection.
+type Injection struct {
+	Fault    Fault
+	Node     *nodes.Node
+	RevertAt time.Time
+	unit     string
+}
+
+// Reboot is a constructor that returns a fault rebooting a node, which reverts itself once the node is back up.
+func Reboot() Fault {
+	return Fault{
+		Name:   "reboot",
+		Inject: "systemctl reboot",
+	}
+}
+
+// ServiceStop is a constructor that returns a fault stopping a systemd service, e.g. the rke2 or k3s service of a
+// node, which stops its kubelet while its containers keep running. Revert starts the service again.
+func ServiceStop(service string) Fault {
+	return Fault{
+		Name:   "stop-" + service,
+		Inject: "systemctl stop " + service,
+		Revert: "systemctl start " + service,
+	}
+}
+
+// NetworkPartition is a constructor that returns a fault dropping all traffic between a node and the peer addresses
+// with iptables, or ip6tables for IPv6 peers. Traffic to other addresses, including SSH from the test, is untouched.
+// Revert deletes the rules.
+func NetworkPartition(peers ...string) Fault {
+	comment := namegen.AppendRandomString(faultPrefix + "-partition")
+
+	var inject, revert strings.Builder
+	for _, peer := range peers {
+		command := "iptables"
+		if strings.Contains(peer, ":") {
+			command = "ip6tables"
+		}
+
+		for _, rule := range []string{"INPUT -s " + peer, "OUTPUT -d " + peer} {
+			inject.WriteString(fmt.Sprintf("%s -I %s -m comment --comment %s -j DROP\n", command, rule, comment))
+			revert.WriteString(fmt.Sprintf("%s -D %s -m comment --comment %s -j DROP\n", command, rule, comment))
+		}
+	}
+
+	return Fault{
+		Name:   "partition-" + strings.Join(peers, ","),
+		Inject: inject.String(),
+		Revert: revert.String(),
+	}
+}
+
+// DiskFill is a constructor that returns a fault filling the filesystem of a path until keepFreePercent of it is free,
+// triggering disk pressure eviction when the path is on the filesystem the kubelet watches. Revert deletes the file.
+func DiskFill(path string, keepFreePercent int) Fault {
+	file := path + "/" + fillFileName
+
+	return Fault{
+		Name: "fill-" + path,
+		Inject: fmt.Sprintf(`avail=$(df --output=avail -B1 %[1]s | tail -1)
+size=$(df --output=size -B1 %[1]s | tail -1)
+fill=$((avail - size * %[2]d / 100))
+if [ "$fill" -gt 0 ]; then fallocate -l "$fill" %[3]s; fi
+`, path, keepFreePercent, file),
+		Revert: "rm -f " + file,
+	}
+}
+
+// ClockSkew is a constructor that returns a fault moving the clock of a node by an offset, with time synchronization
+// disabled so it is not corrected. Revert moves the clock back and enables time synchronization again.
+func ClockSkew(offset time.Duration) Fault {
+	seconds := int64(offset.Seconds())
+
+	return Fault{
+		Name:   "skew-" + offset.String(),
+		Inject: fmt.Sprintf("timedatectl set-ntp false\ndate -s \"@$(($(date +%%s) + %d))\"\n", seconds),
+		Revert: fmt.Sprintf("date -s \"@$(($(date +%%s) - %d))\"\ntimedatectl set-ntp true\n", seconds),
+	}
+}
+
+// ClusterService is a helper function that returns the active rke2 or k3s service of a node.
+func ClusterService(node *nodes.Node) (string, error) {
+	for _, service := range []string{RKE2ServerService, RKE2AgentService, K3SServerService, K3SAgentService} {
+		_, err := node.ExecuteCommand("systemctl is-active --quiet " + service)
+		if err == nil {
+			return service, nil
+		}
+	}
+
+	return "", fmt.Errorf("node %s has no active rke2 or k3s service", node.PublicIPAddress)
+}
+
+// Inject is a helper function that schedules the revert of a fault on a node after revertAfter, then injects it.
+// The revert is scheduled first, so it runs even if the fault cuts the node off.
+func Inject(node *nodes.Node, fault Fault, revertAfter time.Duration) (*Injection, error) {
+	injection := &Injection{
+		Fault:    fault,
+		Node:     node,
+		RevertAt: time.Now().Add(revertAfter),
+	}
+
+	if fault.Revert != "" {
+		injection.unit = namegen.AppendRandomString(faultPrefix)
+		script := scriptDir + "/" + injection.unit + ".sh"
+
+		logrus.Infof("Scheduling the revert of fault %s on node %s in %s", fault.Name, node.PublicIPAddress, revertAfter)
+		_, err := runAsRoot(node, fmt.Sprintf("printf '%%s' '%s' | base64 -d > %s\nsystemd-run --unit=%s --on-active=%ds /bin/sh -c '%s'\n",
+			base64.StdEncoding.EncodeToString([]byte(fault.Revert)), script, injection.unit, int(revertAfter.Seconds()), revertCommand(injection.unit)))
+		if err != nil {
+			return nil, fmt.Errorf("scheduling the revert of fault %s on node %s: %w", fault.Name, node.PublicIPAddress, err)
+		}
+	}
+
+	logrus.Infof("Injecting fault %s on node %s", fault.Name, node.PublicIPAddress)
+	output, err := runAsRoot(node, fault.Inject)
+	if err != nil && !errors.Is(err, &ssh.ExitMissingError{}) {
+		return nil, fmt.Errorf("injecting fault %s on node %s: %w: %s", fault.Name, node.PublicIPAddress, err, output)
+	}
+
+	return injection, nil
+}
+
+// Revert is a helper function that reverts a fault before its timer does, and cancels the timer. The fault is reverted
+// once, so calling Revert after the timer fired, or twice, is a no-op.
+func (i *Injection) Revert() error {
+	if i.unit == "" {
+		return nil
+	}
+
+	logrus.Infof("Reverting fault %s on node %s", i.Fault.Name, i.Node.PublicIPAddress)
+	output, err := runAsRoot(i.Node, fmt.Sprintf("systemctl stop %s.timer\n%s\n", i.unit, revertCommand(i.unit)))
+	if err != nil {
+		return fmt.Errorf("reverting fault %s on node %s: %w: %s", i.Fault.Name, i.Node.PublicIPAddress, err, output)
+	}
+
+	return nil
+}
+
+// WaitForRevert is a helper function that waits for the timer of a fault to revert it.
+func (i *Injection) WaitForRevert() error {
+	if i.unit == "" {
+		return nil
+	}
+
+	timeout := time.Until(i.RevertAt) + defaults.TwoMinuteTimeout
+
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, timeout, true, func(ctx context.Context) (bool, error) {
+		if time.Now().Before(i.RevertAt) {
+			return false, nil
+		}
+
+		output, err := i.Node.ExecuteCommand(fmt.Sprintf("systemctl show -p ActiveState --value %s.service", i.unit))
+		if err != nil {
+			return false, nil
+		}
+
+		state := strings.TrimSpace(output)
+
+		return state == "inactive" || state == "failed", nil
+	})
+}
+
+// revertCommand is a private helper function that returns the command running the revert script of a fault. The
+// script is renamed before it runs, so only the first of the timer and Revert runs it, e.g. a clock skew is not undone
+// twice.
+func revertCommand(unit string) string {
+	return fmt.Sprintf("if mv %[1]s/%[2]s.sh %[1]s/%[2]s.run 2>/dev/null; then sh %[1]s/%[2]s.run; fi", scriptDir, unit)
+}
+
+// runAsRoot is a private helper function that runs a shell script as root on a node. The script is passed encoded,
+// so it needs no quoting.
+func runAsRoot(node *nodes.Node, script string) (string, error) {
+	return node.ExecuteCommand(fmt.Sprintf("printf '%%s' '%s' | base64 -d | sudo sh", base64.StdEncoding.EncodeToString([]byte(script))))
+}
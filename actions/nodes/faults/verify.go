@@ -0,0 +1,202 @@
+package faults
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/pkg/nodes"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	etcdRoleLabel = "node-role.kubernetes.io/etcd"
+	etcdHealthy   = "true"
+
+	// etcdCommand runs a request against the local etcd member of an rke2 or k3s server with its client certificate.
+	etcdCommand = `d=/var/lib/rancher/rke2/server/tls/etcd; [ -d "$d" ] || d=/var/lib/rancher/k3s/server/tls/etcd; ` +
+		`curl -s -m 10 --cacert "$d/server-ca.crt" --cert "$d/server-client.crt" --key "$d/server-client.key" `
+)
+
+// etcdMember is a member of an etcd cluster, as listed by the etcd gRPC gateway. Members that joined but never started
+// have no name.
+type etcdMember struct {
+	Name      string `json:"name"`
+	IsLearner bool   `json:"isLearner"`
+}
+
+// WaitForNodeReady is a helper function that waits for the Ready condition of a node of a cluster to be true, or to be
+// anything else when ready is false, e.g. to confirm a fault took effect.
+func WaitForNodeReady(client *rancher.Client, clusterID, nodeName string, ready bool) error {
+	return WaitForNodeCondition(client, clusterID, nodeName, corev1.NodeReady, ready)
+}
+
+// WaitForNodeCondition is a helper function that waits for a condition of a node of a cluster to be true, or to be
+// anything else when status is false, e.g. for DiskPressure while a disk fill fault is active. A node without the
+// condition counts as false.
+func WaitForNodeCondition(client *rancher.Client, clusterID, nodeName string, conditionType corev1.NodeConditionType, status bool) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for condition %s of node %s to be %t", conditionType, nodeName, status)
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		node, err := wranglerContext.Core.Node().Get(nodeName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == conditionType {
+				return (condition.Status == corev1.ConditionTrue) == status, nil
+			}
+		}
+
+		return !status, nil
+	})
+	if err != nil {
+		return fmt.Errorf("condition %s of node %s of cluster %s did not become %t: %w", conditionType, nodeName, clusterID, status, err)
+	}
+
+	return nil
+}
+
+// VerifyEtcdMembership is a helper function that verifies, from the etcd member of a server node, that the etcd
+// cluster is healthy and has a started voting member for every etcd node of the cluster.
+func VerifyEtcdMembership(client *rancher.Client, clusterID string, etcdNode *nodes.Node) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	etcdNodes, err := wranglerContext.Core.Node().List(metav1.ListOptions{LabelSelector: etcdRoleLabel + "=true"})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Verifying the etcd membership of %d etcd nodes from node %s", len(etcdNodes.Items), etcdNode.PublicIPAddress)
+	var errs []error
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		errs = nil
+
+		output, err := etcdNode.ExecuteCommand("sudo sh -c '" + etcdCommand + "https://127.0.0.1:2379/health'")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("etcd health: %w: %s", err, output))
+			return false, nil
+		}
+
+		health := struct {
+			Health string `json:"health"`
+		}{}
+		if err := json.Unmarshal([]byte(output), &health); err != nil || health.Health != etcdHealthy {
+			errs = append(errs, fmt.Errorf("etcd is not healthy: %s", output))
+			return false, nil
+		}
+
+		output, err = etcdNode.ExecuteCommand("sudo sh -c '" + etcdCommand + "-X POST -d {} https://127.0.0.1:2379/v3/cluster/member/list'")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("etcd member list: %w: %s", err, output))
+			return false, nil
+		}
+
+		memberList := struct {
+			Members []etcdMember `json:"members"`
+		}{}
+		if err := json.Unmarshal([]byte(output), &memberList); err != nil {
+			errs = append(errs, fmt.Errorf("parsing etcd member list %q: %w", output, err))
+			return false, nil
+		}
+
+		if len(memberList.Members) != len(etcdNodes.Items) {
+			errs = append(errs, fmt.Errorf("etcd has %d members, expected %d", len(memberList.Members), len(etcdNodes.Items)))
+		}
+
+		for _, node := range etcdNodes.Items {
+			if !hasEtcdMember(memberList.Members, node.Name) {
+				errs = append(errs, fmt.Errorf("etcd node %s has no started voting member", node.Name))
+			}
+		}
+
+		return len(errs) == 0, nil
+	})
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	return nil
+}
+
+// WaitForPodsRescheduled is a helper function that waits for the pods of the deployments of a namespace to be ready
+// and off a node, e.g. after the node was cut off long enough for its pods to be evicted.
+func WaitForPodsRescheduled(client *rancher.Client, clusterID, namespace, nodeName string) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for the deployments of namespace %s to be rescheduled off node %s", namespace, nodeName)
+	var errs []error
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.FifteenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		errs = nil
+
+		deployments, err := wranglerContext.Apps.Deployment().List(namespace, metav1.ListOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		for _, deployment := range deployments.Items {
+			replicas := int32(1)
+			if deployment.Spec.Replicas != nil {
+				replicas = *deployment.Spec.Replicas
+			}
+
+			if deployment.Status.AvailableReplicas != replicas {
+				errs = append(errs, fmt.Errorf("deployment %s/%s has %d of %d replicas available", namespace, deployment.Name, deployment.Status.AvailableReplicas, replicas))
+			}
+
+			selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+			if err != nil {
+				return false, err
+			}
+
+			pods, err := wranglerContext.Core.Pod().List(namespace, metav1.ListOptions{LabelSelector: selector.String()})
+			if err != nil {
+				return false, nil
+			}
+
+			for _, pod := range pods.Items {
+				if pod.Spec.NodeName == nodeName && pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning {
+					errs = append(errs, fmt.Errorf("pod %s/%s of deployment %s is still running on node %s", namespace, pod.Name, deployment.Name, nodeName))
+				}
+			}
+		}
+
+		return len(errs) == 0, nil
+	})
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+
+	return nil
+}
+
+// hasEtcdMember is a private helper function that returns whether a started voting etcd member belongs to a node.
+// rke2 and k3s name members after the node name with a random suffix.
+func hasEtcdMember(members []etcdMember, nodeName string) bool {
+	for _, member := range members {
+		if member.Name != "" && !member.IsLearner && strings.HasPrefix(member.Name, nodeName+"-") {
+			return true
+		}
+	}
+
+	return false
+}
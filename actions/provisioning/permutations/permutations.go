@@ -13,6 +13,7 @@ import (
 	"github.com/rancher/shepherd/pkg/session"
 	"github.com/rancher/tests/actions/cloudprovider"
 	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/dns"
 	"github.com/rancher/tests/actions/machinepools"
 	"github.com/rancher/tests/actions/provisioning"
 	"github.com/rancher/tests/actions/provisioninginput"
@@ -92,6 +93,9 @@ func RunTestPermutations(s *suite.Suite, testNamePrefix string, client *rancher.
 						logrus.Infof("Verifying cluster pods (%s)", clusterObject.Name)
 						pods.VerifyClusterPods(s.T(), client, clusterObject)
 
+						logrus.Infof("Verifying cluster DNS (%s)", clusterObject.Name)
+						dns.VerifyClusterDNS(s.T(), client, clusterObject)
+
 						logrus.Infof("Verifying cluster features (%s)", clusterObject.Name)
 						provisioning.VerifyDynamicCluster(s.T(), client, clusterObject)
 
@@ -127,6 +131,9 @@ func RunTestPermutations(s *suite.Suite, testNamePrefix string, client *rancher.
 						logrus.Infof("Verifying cluster pods (%s)", clusterObject.Name)
 						pods.VerifyClusterPods(s.T(), client, clusterObject)
 
+						logrus.Infof("Verifying cluster DNS (%s)", clusterObject.Name)
+						dns.VerifyClusterDNS(s.T(), client, clusterObject)
+
 						logrus.Infof("Verifying cluster features (%s)", clusterObject.Name)
 						provisioning.VerifyDynamicCluster(s.T(), client, clusterObject)
 
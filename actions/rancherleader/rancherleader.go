@@ -1,17 +1,26 @@
 package rancherleader
 
 import (
+	"context"
+	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/rancher/shepherd/clients/rancher"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/sirupsen/logrus"
 	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
 	KubeSystemNamespace = "kube-system"
 	LeaseName           = "cattle-controllers"
 	LeaseSteveType      = "coordination.k8s.io.lease"
+	RancherNamespace    = "cattle-system"
 )
 
 // GetRancherLeaderPodName is a helper function to retrieve the name of the rancher leader pod
@@ -32,3 +41,57 @@ func GetRancherLeaderPodName(client *rancher.Client) (string, error) {
 
 	return leaderPodName, nil
 }
+
+// DeleteRancherLeaderPod is a helper function that deletes the rancher leader pod, so a new leader has to be elected,
+// and returns its name.
+func DeleteRancherLeaderPod(client *rancher.Client) (string, error) {
+	leaderPodName, err := GetRancherLeaderPodName(client)
+	if err != nil {
+		return "", err
+	}
+
+	logrus.Infof("Deleting rancher leader pod %s", leaderPodName)
+	err = client.WranglerContext.Core.Pod().Delete(RancherNamespace, leaderPodName, &metav1.DeleteOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	return leaderPodName, nil
+}
+
+// WaitForNewRancherLeader is a helper function that waits for a rancher pod other than the previous leader to hold the
+// leader lease and be ready, and returns its name and how long the election took. It fails when the election takes
+// longer than the budget.
+func WaitForNewRancherLeader(client *rancher.Client, previousLeader string, budget time.Duration) (string, time.Duration, error) {
+	start := time.Now()
+
+	var leaderPodName string
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveHundredMillisecondTimeout, budget, true, func(ctx context.Context) (bool, error) {
+		var err error
+		leaderPodName, err = GetRancherLeaderPodName(client)
+		if err != nil || leaderPodName == "" || leaderPodName == previousLeader {
+			return false, nil
+		}
+
+		pod, err := client.WranglerContext.Core.Pod().Get(RancherNamespace, leaderPodName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady {
+				return condition.Status == corev1.ConditionTrue, nil
+			}
+		}
+
+		return false, nil
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		return "", elapsed, fmt.Errorf("no new rancher leader was elected within %s of deleting %s: %w", budget, previousLeader, err)
+	}
+
+	logrus.Infof("Rancher pod %s was elected leader in %s", leaderPodName, elapsed.Round(time.Second))
+
+	return leaderPodName, elapsed, nil
+}
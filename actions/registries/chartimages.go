@@ -0,0 +1,319 @@
+package registries
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	"github.com/rancher/shepherd/extensions/workloads/pods"
+	"github.com/rancher/shepherd/pkg/clientbase"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// DockerHubRegistry is the registry an image is pulled from when its name has no registry host
+	DockerHubRegistry = "docker.io"
+
+	replicaSetSteveType  = "apps.replicaset"
+	deploymentSteveType  = "apps.deployment"
+	statefulSetSteveType = "apps.statefulset"
+	daemonSetSteveType   = "apps.daemonset"
+	jobSteveType         = "batch.job"
+	cronJobSteveType     = "batch.cronjob"
+)
+
+// ownerSteveTypes maps the kinds that can sit between a chart resource and a pod to their steve types
+var ownerSteveTypes = map[string]string{
+	"ReplicaSet":  replicaSetSteveType,
+	"Deployment":  deploymentSteveType,
+	"StatefulSet": statefulSetSteveType,
+	"DaemonSet":   daemonSetSteveType,
+	"Job":         jobSteveType,
+	"CronJob":     cronJobSteveType,
+}
+
+// ImageSourceViolation is an image of a chart pod that is not pulled from one of the allowed registries.
+type ImageSourceViolation struct {
+	Chart     string
+	Namespace string
+	Pod       string
+	Container string
+	Image     string
+	Registry  string
+}
+
+func (v ImageSourceViolation) String() string {
+	return fmt.Sprintf("chart %s: pod %s/%s container %s uses image %s from %s", v.Chart, v.Namespace, v.Pod, v.Container, v.Image, v.Registry)
+}
+
+// ImageRegistry returns the registry host an image reference resolves to, defaulting to docker.io like the container runtime does.
+func ImageRegistry(image string) string {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) == 1 {
+		return DockerHubRegistry
+	}
+
+	host := parts[0]
+	if strings.ContainsAny(host, ".:") || host == "localhost" {
+		if host == "index.docker.io" || host == "registry-1.docker.io" {
+			return DockerHubRegistry
+		}
+
+		return host
+	}
+
+	return DockerHubRegistry
+}
+
+// CheckChartImageSources lists every pod created by the resources of a chart App, including their init and ephemeral
+// containers, and returns the images that are not pulled from one of the allowed registries.
+func CheckChartImageSources(client *rancher.Client, clusterID, appNamespace, appName string, allowedRegistries ...string) ([]ImageSourceViolation, error) {
+	catalogClient, err := client.GetClusterCatalogClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := catalogClient.Apps(appNamespace).Get(context.TODO(), appName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	chartResources := map[string]bool{}
+	for _, resource := range app.Spec.Resources {
+		namespace := resource.Namespace
+		if namespace == "" {
+			namespace = appNamespace
+		}
+		chartResources[resourceKey(resource.Kind, namespace, resource.Name)] = true
+	}
+
+	steveClient, err := client.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	podsList, err := steveClient.SteveType(pods.PodResourceSteveType).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resolver := &ownerResolver{
+		steveClient: steveClient,
+		owners:      map[string][]metav1.OwnerReference{},
+	}
+
+	allowed := map[string]bool{}
+	for _, registry := range allowedRegistries {
+		if registry != "" {
+			// the registry may be configured with a path, e.g. registry.example.com/mirror, only its host is compared
+			allowed[strings.SplitN(strings.TrimSuffix(registry, "/"), "/", 2)[0]] = true
+		}
+	}
+
+	var violations []ImageSourceViolation
+	for _, pod := range podsList.Data {
+		createdByChart, err := resolver.isCreatedBy(chartResources, "Pod", pod.Namespace, pod.Name, pod.OwnerReferences)
+		if err != nil {
+			return nil, err
+		}
+
+		if !createdByChart {
+			continue
+		}
+
+		podSpec := &corev1.PodSpec{}
+		err = v1.ConvertToK8sType(pod.Spec, podSpec)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, containerImage := range podImages(podSpec) {
+			registry := ImageRegistry(containerImage.image)
+			if allowed[registry] {
+				continue
+			}
+
+			violations = append(violations, ImageSourceViolation{
+				Chart:     appName,
+				Namespace: pod.Namespace,
+				Pod:       pod.Name,
+				Container: containerImage.container,
+				Image:     containerImage.image,
+				Registry:  registry,
+			})
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].String() < violations[j].String()
+	})
+
+	return violations, nil
+}
+
+// VerifyChartImageSources checks the image sources of a chart App and returns an error listing every image that is
+// pulled from a public registry instead of one of the allowed registries.
+func VerifyChartImageSources(client *rancher.Client, clusterID, appNamespace, appName string, allowedRegistries ...string) error {
+	violations, err := CheckChartImageSources(client, clusterID, appNamespace, appName, allowedRegistries...)
+	if err != nil {
+		return err
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		messages = append(messages, violation.String())
+	}
+
+	return fmt.Errorf("images not pulled from %v:\n%s", allowedRegistries, strings.Join(messages, "\n"))
+}
+
+// ClusterRegistries is a helper function that returns the private registries of the RKE2/K3s provisioning cluster of a
+// management cluster: the registries it has configs for, the hosts of its mirror endpoints, and the registries it
+// mirrors, as the images of a mirrored registry keep its name. It returns none for clusters without registries or a provisioning cluster.
+func ClusterRegistries(client *rancher.Client, clusterID string) ([]string, error) {
+	clusterList, err := client.Steve.SteveType(stevetypes.Provisioning).List(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusterList.Data {
+		status := &provv1.ClusterStatus{}
+		err = v1.ConvertToK8sType(cluster.Status, status)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.ClusterName != clusterID {
+			continue
+		}
+
+		spec := &provv1.ClusterSpec{}
+		err = v1.ConvertToK8sType(cluster.Spec, spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if spec.RKEConfig == nil || spec.RKEConfig.Registries == nil {
+			return nil, nil
+		}
+
+		var clusterRegistries []string
+		for registry := range spec.RKEConfig.Registries.Configs {
+			clusterRegistries = append(clusterRegistries, registry)
+		}
+
+		for registry, mirror := range spec.RKEConfig.Registries.Mirrors {
+			clusterRegistries = append(clusterRegistries, registry)
+			for _, endpoint := range mirror.Endpoints {
+				if endpointURL, err := url.Parse(endpoint); err == nil && endpointURL.Host != "" {
+					clusterRegistries = append(clusterRegistries, endpointURL.Host)
+				}
+			}
+		}
+
+		sort.Strings(clusterRegistries)
+
+		return clusterRegistries, nil
+	}
+
+	return nil, nil
+}
+
+// containerImage is the image of a container of a pod, named after the kind and name of the container.
+type containerImage struct {
+	container string
+	image     string
+}
+
+// podImages is a private helper function that returns the images of every container, init container and ephemeral
+// container of a pod. Containers of different kinds may share a name, so the kind is part of the container name.
+func podImages(podSpec *corev1.PodSpec) []containerImage {
+	var images []containerImage
+	for _, container := range podSpec.InitContainers {
+		images = append(images, containerImage{container: "init:" + container.Name, image: container.Image})
+	}
+
+	for _, container := range podSpec.Containers {
+		images = append(images, containerImage{container: container.Name, image: container.Image})
+	}
+
+	for _, container := range podSpec.EphemeralContainers {
+		images = append(images, containerImage{container: "ephemeral:" + container.Name, image: container.Image})
+	}
+
+	return images
+}
+
+// ownerResolver walks owner references from a pod up to the resources deployed by a chart, caching the owners it fetched.
+type ownerResolver struct {
+	steveClient *v1.Client
+	owners      map[string][]metav1.OwnerReference
+}
+
+// isCreatedBy is a private helper function that checks if an object, or any of its owners, is one of the chart resources.
+func (r *ownerResolver) isCreatedBy(chartResources map[string]bool, kind, namespace, name string, ownerReferences []metav1.OwnerReference) (bool, error) {
+	if chartResources[resourceKey(kind, namespace, name)] {
+		return true, nil
+	}
+
+	for _, owner := range ownerReferences {
+		if chartResources[resourceKey(owner.Kind, namespace, owner.Name)] {
+			return true, nil
+		}
+
+		ownerOwners, err := r.ownersOf(owner.Kind, namespace, owner.Name)
+		if err != nil {
+			return false, err
+		}
+
+		createdByChart, err := r.isCreatedBy(chartResources, owner.Kind, namespace, owner.Name, ownerOwners)
+		if err != nil || createdByChart {
+			return createdByChart, err
+		}
+	}
+
+	return false, nil
+}
+
+// ownersOf is a private helper function that returns the owner references of a workload object.
+func (r *ownerResolver) ownersOf(kind, namespace, name string) ([]metav1.OwnerReference, error) {
+	steveType, ok := ownerSteveTypes[kind]
+	if !ok {
+		return nil, nil
+	}
+
+	key := resourceKey(kind, namespace, name)
+	if owners, ok := r.owners[key]; ok {
+		return owners, nil
+	}
+
+	object, err := r.steveClient.SteveType(steveType).ByID(namespace + "/" + name)
+	if clientbase.IsNotFound(err) {
+		// e.g. a replicaset that was rolled out while the pod terminates
+		r.owners[key] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.owners[key] = object.OwnerReferences
+
+	return object.OwnerReferences, nil
+}
+
+// resourceKey is a private helper function that builds a unique key for a namespaced object.
+func resourceKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
@@ -0,0 +1,13 @@
+package registries
+
+const (
+	ChartImagesConfigurationFileKey = "chartImagesInput"
+)
+
+// ChartImagesConfig is the configuration of the chart image source checks. Registries are private registries the chart
+// images may be pulled from, on top of the system default registry and the registries of the cluster. When Airgap is
+// set, a chart install fails the check if there is no registry to check its images against.
+type ChartImagesConfig struct {
+	Airgap     bool     `json:"airgap" yaml:"airgap"`
+	Registries []string `json:"registries" yaml:"registries"`
+}
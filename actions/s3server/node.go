@@ -0,0 +1,65 @@
+package s3server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/nodes"
+	actionssecrets "github.com/rancher/tests/actions/secrets"
+	"github.com/sirupsen/logrus"
+)
+
+const nodeCertsPath = "/opt/s3server/certs"
+
+// DeployS3ServerOnNode is a helper function that runs MinIO with docker on a node, e.g. a custom cluster node or a
+// standalone instance reachable by downstream clusters that can not reach the local cluster, and creates its bucket.
+// The node must have docker installed. The endpoint is the public address of the node, falling back to its private
+// address, and the container and its certificates are removed on session cleanup.
+func DeployS3ServerOnNode(client *rancher.Client, node *nodes.Node, s3ServerConfig *Config) (*S3Server, error) {
+	s3Server := newS3Server(s3ServerConfig)
+
+	address := node.PublicIPAddress
+	if address == "" {
+		address = node.PrivateIPAddress
+	}
+
+	certificate, privateKey, err := actionssecrets.GenerateServerCert(s3ServerName, node.PublicIPAddress, node.PrivateIPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s3Server.CACert = certificate
+	s3Server.Endpoint = net.JoinHostPort(address, strconv.Itoa(s3Port))
+
+	containerName := namegenerator.AppendRandomString(s3ServerName)
+	command := fmt.Sprintf("sudo mkdir -p %[1]s && echo %[2]s | base64 -d | sudo tee %[1]s/%[3]s > /dev/null && echo %[4]s | base64 -d | sudo tee %[1]s/%[5]s > /dev/null && "+
+		"sudo docker run -d --name %[6]s -p %[7]d:%[7]d -e %[8]s=%[9]s -e %[10]s=%[11]s -v %[1]s:%[12]s:ro %[13]s server %[14]s --address :%[7]d --certs-dir %[12]s",
+		nodeCertsPath, base64.StdEncoding.EncodeToString(certificate), publicCertFile, base64.StdEncoding.EncodeToString(privateKey), privateKeyFile,
+		containerName, s3Port, rootUserEnv, s3Server.AccessKey, rootPasswordEnv, s3Server.SecretKey, certsPath, s3ServerConfig.Image, dataPath)
+
+	logrus.Infof("Running MinIO container %s on node %s", containerName, address)
+	output, err := node.ExecuteCommand(command)
+	if err != nil {
+		return nil, fmt.Errorf("running MinIO on node %s: %w: %s", address, err, output)
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		output, err := node.ExecuteCommand(fmt.Sprintf("sudo docker rm -f %s && sudo rm -rf %s", containerName, nodeCertsPath))
+		if err != nil {
+			return fmt.Errorf("removing MinIO from node %s: %w: %s", address, err, output)
+		}
+
+		return nil
+	})
+
+	err = s3Server.createBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3Server, nil
+}
@@ -0,0 +1,425 @@
+package s3server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	awssession "github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/charts"
+	kubenamespaces "github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/secrets"
+	"github.com/rancher/tests/actions/kubeapi/services"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	actionssecrets "github.com/rancher/tests/actions/secrets"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// ConfigurationFileKey is the key of the optional S3 server configuration in the config file
+	ConfigurationFileKey = "s3ServerInput"
+
+	// DefaultImage is the MinIO image deployed when no image is configured
+	DefaultImage = "minio/minio:RELEASE.2025-04-22T22-12-26Z"
+	// DefaultRegion is the region of the bucket when no region is configured. MinIO accepts any region, but the S3
+	// clients of RKE2, K3s and rancher-backup require one.
+	DefaultRegion = "us-east-1"
+
+	localClusterID      = "local"
+	s3ServerName        = "s3server"
+	s3Port              = 9000
+	certsVolume         = "certs"
+	dataVolume          = "data"
+	certsPath           = "/certs"
+	dataPath            = "/data"
+	publicCertFile      = "public.crt"
+	privateKeyFile      = "private.key"
+	rootUserEnv         = "MINIO_ROOT_USER"
+	rootPasswordEnv     = "MINIO_ROOT_PASSWORD"
+	backupAccessKey     = "accessKey"
+	backupSecretKey     = "secretKey"
+	cloudCredentialName = "s3-credential"
+)
+
+// Config is the optional S3 server configuration, e.g. to pull the MinIO image from a private registry in airgapped
+// environments. With SkipSSLVerify the CA certificate of the server is not handed to the clients, which then skip the
+// verification of its self-signed certificate instead.
+type Config struct {
+	Image         string `json:"image,omitempty" yaml:"image,omitempty"`
+	Region        string `json:"region,omitempty" yaml:"region,omitempty"`
+	SkipSSLVerify bool   `json:"skipSSLVerify,omitempty" yaml:"skipSSLVerify,omitempty"`
+}
+
+// S3Server is a MinIO server with a single bucket, serving https with a self-signed certificate. Endpoint is the
+// host:port it is reached on, from the test runner and from the nodes of downstream clusters alike.
+type S3Server struct {
+	Namespace     string
+	Endpoint      string
+	Bucket        string
+	Region        string
+	AccessKey     string
+	SecretKey     string
+	CACert        []byte
+	SkipSSLVerify bool
+}
+
+// LoadConfig is a helper function that loads the S3 server configuration, falling back to the defaults for every
+// unset field.
+func LoadConfig() *Config {
+	s3ServerConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, s3ServerConfig)
+
+	if s3ServerConfig.Image == "" {
+		s3ServerConfig.Image = DefaultImage
+	}
+
+	if s3ServerConfig.Region == "" {
+		s3ServerConfig.Region = DefaultRegion
+	}
+
+	return s3ServerConfig
+}
+
+// DeployS3Server is a helper function that deploys MinIO in a new namespace of the local cluster, exposed on a node
+// port, waits for it to be ready and creates its bucket. The certificate of the server is valid for the addresses of
+// every local node, and the endpoint is the external address of the node MinIO runs on, falling back to its internal
+// address. Every resource it creates, including the namespace, is deleted on session cleanup.
+func DeployS3Server(client *rancher.Client, s3ServerConfig *Config) (*S3Server, error) {
+	s3Server := newS3Server(s3ServerConfig)
+	s3Server.Namespace = namegenerator.AppendRandomString(s3ServerName)
+
+	nodeList, err := client.WranglerContext.Core.Node().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, node := range nodeList.Items {
+		for _, address := range node.Status.Addresses {
+			hosts = append(hosts, address.Address)
+		}
+	}
+
+	certificate, privateKey, err := actionssecrets.GenerateServerCert(s3ServerName, hosts...)
+	if err != nil {
+		return nil, err
+	}
+
+	s3Server.CACert = certificate
+
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: s3Server.Namespace,
+		},
+	}
+
+	logrus.Infof("Creating S3 server namespace %s", s3Server.Namespace)
+	_, err = dynamicClient.Resource(kubenamespaces.NamespaceGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(namespace), metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	configSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      s3ServerName,
+			Namespace: s3Server.Namespace,
+		},
+		Data: map[string][]byte{
+			rootUserEnv:     []byte(s3Server.AccessKey),
+			rootPasswordEnv: []byte(s3Server.SecretKey),
+			publicCertFile:  certificate,
+			privateKeyFile:  privateKey,
+		},
+	}
+
+	_, err = secrets.CreateSecretForCluster(client, configSecret, localClusterID, s3Server.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Deploying MinIO %s/%s", s3Server.Namespace, s3ServerName)
+	_, err = deployments.CreateDeployment(client, localClusterID, s3ServerName, s3Server.Namespace, podTemplate(s3ServerConfig.Image, configSecret.Name), 1)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := client.WranglerContext.Apps.Deployment().Get(s3Server.Namespace, s3ServerName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := services.CreateService(client, localClusterID, s3ServerName, s3Server.Namespace, corev1.ServiceSpec{
+		Type:     corev1.ServiceTypeNodePort,
+		Selector: deployment.Spec.Selector.MatchLabels,
+		Ports: []corev1.ServicePort{
+			{
+				Name:       "https",
+				Port:       s3Port,
+				TargetPort: intstr.FromInt32(s3Port),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := pods.WaitForReadyPod(client, s3Server.Namespace, deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := client.WranglerContext.Core.Node().Get(pod.Spec.NodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	s3Server.Endpoint = net.JoinHostPort(nodeAddress(node), strconv.Itoa(int(service.Spec.Ports[0].NodePort)))
+
+	err = s3Server.createBucket()
+	if err != nil {
+		return nil, err
+	}
+
+	return s3Server, nil
+}
+
+// URL returns the https url of the S3 server.
+func (s *S3Server) URL() string {
+	return "https://" + s.Endpoint
+}
+
+// ETCDSnapshotS3 is a helper function that creates an S3 cloud credential of the S3 server for the user of the client
+// and returns the etcd snapshot S3 settings of an RKE2 or K3s cluster storing its snapshots in the folder of the
+// bucket. The cloud credential must belong to the user provisioning the cluster.
+func (s *S3Server) ETCDSnapshotS3(client *rancher.Client, folder string) (*rkev1.ETCDSnapshotS3, error) {
+	s3Credential := &management.S3CredentialConfig{
+		AccessKey:            s.AccessKey,
+		SecretKey:            s.SecretKey,
+		DefaultBucket:        s.Bucket,
+		DefaultEndpoint:      s.Endpoint,
+		DefaultFolder:        folder,
+		DefaultRegion:        s.Region,
+		DefaultSkipSSLVerify: strconv.FormatBool(s.SkipSSLVerify),
+	}
+
+	if !s.SkipSSLVerify {
+		s3Credential.DefaultEndpointCA = string(s.CACert)
+	}
+
+	cloudCredential, err := client.Management.CloudCredential.Create(&management.CloudCredential{
+		Name:               namegenerator.AppendRandomString(cloudCredentialName),
+		S3CredentialConfig: s3Credential,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	etcdSnapshotS3 := &rkev1.ETCDSnapshotS3{
+		Endpoint:            s.Endpoint,
+		SkipSSLVerify:       s.SkipSSLVerify,
+		Bucket:              s.Bucket,
+		Region:              s.Region,
+		CloudCredentialName: cloudCredential.ID,
+		Folder:              folder,
+	}
+
+	if !s.SkipSSLVerify {
+		etcdSnapshotS3.EndpointCA = string(s.CACert)
+	}
+
+	return etcdSnapshotS3, nil
+}
+
+// RancherBackupOpts is a helper function that creates the credential secret of the S3 server in a namespace of the
+// local cluster, e.g. cattle-resources-system, and returns the rancher-backup options storing backups in the folder of
+// the bucket.
+func (s *S3Server) RancherBackupOpts(client *rancher.Client, namespace, folder string) (*charts.RancherBackupOpts, error) {
+	credentialSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegenerator.AppendRandomString(s3ServerName),
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{
+			backupAccessKey: []byte(s.AccessKey),
+			backupSecretKey: []byte(s.SecretKey),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+
+	_, err := secrets.CreateSecretForCluster(client, credentialSecret, localClusterID, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	backupOpts := &charts.RancherBackupOpts{
+		BucketName:                s.Bucket,
+		CredentialSecretName:      credentialSecret.Name,
+		CredentialSecretNamespace: namespace,
+		Enabled:                   true,
+		Endpoint:                  s.Endpoint,
+		Folder:                    folder,
+		Region:                    s.Region,
+		InsecureTLSSkipVerify:     s.SkipSSLVerify,
+	}
+
+	if !s.SkipSSLVerify {
+		backupOpts.EndpointCA = string(s.CACert)
+	}
+
+	return backupOpts, nil
+}
+
+// newS3Server is a private helper function that returns an S3 server with generated credentials and bucket name.
+func newS3Server(s3ServerConfig *Config) *S3Server {
+	return &S3Server{
+		Bucket:        namegenerator.AppendRandomString(s3ServerName),
+		Region:        s3ServerConfig.Region,
+		AccessKey:     namegenerator.RandStringLower(20),
+		SecretKey:     namegenerator.RandStringLower(40),
+		SkipSSLVerify: s3ServerConfig.SkipSSLVerify,
+	}
+}
+
+// createBucket is a private helper function that creates the bucket of the S3 server, retrying until MinIO accepts
+// requests.
+func (s *S3Server) createBucket() error {
+	sess, err := awssession.NewSessionWithOptions(awssession.Options{
+		Config: aws.Config{
+			Endpoint:         aws.String(s.URL()),
+			Region:           aws.String(s.Region),
+			Credentials:      credentials.NewStaticCredentials(s.AccessKey, s.SecretKey, ""),
+			S3ForcePathStyle: aws.Bool(true),
+		},
+		CustomCABundle: bytes.NewReader(s.CACert),
+	})
+	if err != nil {
+		return err
+	}
+
+	s3Client := s3.New(sess)
+
+	logrus.Infof("Creating bucket %s on S3 server %s", s.Bucket, s.Endpoint)
+	var bucketErr error
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		_, bucketErr = s3Client.CreateBucketWithContext(ctx, &s3.CreateBucketInput{
+			Bucket: aws.String(s.Bucket),
+		})
+
+		var awsErr awserr.Error
+		if errors.As(bucketErr, &awsErr) && awsErr.Code() == s3.ErrCodeBucketAlreadyOwnedByYou {
+			return true, nil
+		}
+
+		return bucketErr == nil, nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("creating bucket %s on S3 server %s", s.Bucket, s.Endpoint), bucketErr, err)
+	}
+
+	return nil
+}
+
+// podTemplate is a private helper function that returns the MinIO pod template, serving https with the certificate of
+// the config secret and reading its root credentials from it.
+func podTemplate(image, configSecretName string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  s3ServerName,
+					Image: image,
+					Args:  []string{"server", dataPath, "--address", fmt.Sprintf(":%d", s3Port), "--certs-dir", certsPath},
+					Env: []corev1.EnvVar{
+						secretEnvVar(rootUserEnv, configSecretName),
+						secretEnvVar(rootPasswordEnv, configSecretName),
+					},
+					Ports: []corev1.ContainerPort{
+						{Name: "https", ContainerPort: s3Port},
+					},
+					ReadinessProbe: &corev1.Probe{
+						ProbeHandler: corev1.ProbeHandler{
+							TCPSocket: &corev1.TCPSocketAction{
+								Port: intstr.FromInt32(s3Port),
+							},
+						},
+					},
+					VolumeMounts: []corev1.VolumeMount{
+						{Name: certsVolume, MountPath: certsPath, ReadOnly: true},
+						{Name: dataVolume, MountPath: dataPath},
+					},
+				},
+			},
+			Volumes: []corev1.Volume{
+				{
+					Name: certsVolume,
+					VolumeSource: corev1.VolumeSource{
+						Secret: &corev1.SecretVolumeSource{
+							SecretName: configSecretName,
+							Items: []corev1.KeyToPath{
+								{Key: publicCertFile, Path: publicCertFile},
+								{Key: privateKeyFile, Path: privateKeyFile},
+							},
+						},
+					},
+				},
+				{Name: dataVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+			},
+		},
+	}
+}
+
+// secretEnvVar is a private helper function that returns an environment variable read from the key of the same name
+// in a secret.
+func secretEnvVar(name, secretName string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  name,
+			},
+		},
+	}
+}
+
+// nodeAddress is a private helper function that returns the external address of a node, falling back to its internal
+// address.
+func nodeAddress(node *corev1.Node) string {
+	var internalAddress string
+	for _, address := range node.Status.Addresses {
+		switch address.Type {
+		case corev1.NodeExternalIP:
+			return address.Address
+		case corev1.NodeInternalIP:
+			if internalAddress == "" {
+				internalAddress = address.Address
+			}
+		}
+	}
+
+	return internalAddress
+}
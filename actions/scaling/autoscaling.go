@@ -0,0 +1,450 @@
+package scaling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apisV1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	"github.com/rancher/tests/actions/clusters"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// DefaultScaleDownUnneededTime is how long the cluster autoscaler waits by default before removing a node it does
+	// not need.
+	DefaultScaleDownUnneededTime = 10 * time.Minute
+
+	machineDeploymentSteveType = "cluster.x-k8s.io.machinedeployment"
+	machinePoolNameLabel       = "rke.cattle.io/rke-machine-pool-name"
+	toBeDeletedTaint           = "ToBeDeletedByClusterAutoscaler"
+	mirrorPodAnnotation        = "kubernetes.io/config.mirror"
+	scaleDownUnneededTimeArg   = "--scale-down-unneeded-time="
+	daemonSetKind              = "DaemonSet"
+)
+
+// Scaling is a scale up or scale down of an autoscaled machine pool, with the nodes it removed.
+type Scaling struct {
+	Pool         string
+	From         int32
+	To           int32
+	Duration     time.Duration
+	RemovedNodes []string
+}
+
+// nodeState is what was observed of a node of an autoscaled machine pool while it scaled.
+type nodeState struct {
+	cordoned bool
+	drained  bool
+	removed  bool
+}
+
+// scalingObserver records the nodes of a cluster and the pods a drain must evict from them from watches, so the cordon,
+// drain and removal of a node are seen even when the cluster autoscaler does them between two polls.
+type scalingObserver struct {
+	mu         sync.Mutex
+	nodeStates map[string]*nodeState
+	nodePods   map[string]map[string]bool
+}
+
+// AutoscalerMachinePool is a helper function that returns a machine pool of a cluster, which must be autoscaled.
+func AutoscalerMachinePool(cluster *v1.SteveAPIObject, poolName string) (*apisV1.RKEMachinePool, error) {
+	autoscalerMachinePools, err := getAutoscalerMachinePools(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, autoscalerMachinePool := range autoscalerMachinePools {
+		if autoscalerMachinePool.Name == poolName {
+			return &autoscalerMachinePool, nil
+		}
+	}
+
+	return nil, fmt.Errorf("cluster %s has no autoscaled worker machine pool %s", cluster.Name, poolName)
+}
+
+// VerifyAutoscalerAnnotations is a helper function that verifies that the machine deployment of an autoscaled machine
+// pool has the node group min and max size annotations the cluster autoscaler reads, matching the pool.
+func VerifyAutoscalerAnnotations(client *rancher.Client, cluster *v1.SteveAPIObject, poolName string) error {
+	pool, err := AutoscalerMachinePool(cluster, poolName)
+	if err != nil {
+		return err
+	}
+
+	machines, err := poolMachines(client, cluster.Name, poolName)
+	if err != nil {
+		return err
+	}
+
+	if len(machines) == 0 {
+		return fmt.Errorf("machine pool %s of cluster %s has no machines", poolName, cluster.Name)
+	}
+
+	machineDeploymentName := machines[0].Labels[capi.MachineDeploymentNameLabel]
+	machineDeploymentObject, err := client.Steve.SteveType(machineDeploymentSteveType).ByID(namespaces.FleetDefault + "/" + machineDeploymentName)
+	if err != nil {
+		return err
+	}
+
+	expected := map[string]string{
+		capi.AutoscalerMinSizeAnnotation: strconv.Itoa(int(*pool.AutoscalingMinSize)),
+		capi.AutoscalerMaxSizeAnnotation: strconv.Itoa(int(*pool.AutoscalingMaxSize)),
+	}
+
+	var errs []error
+	for annotation, value := range expected {
+		if machineDeploymentObject.Annotations[annotation] != value {
+			errs = append(errs, fmt.Errorf("machine deployment %s has annotation %s=%q, expected %q", machineDeploymentName, annotation,
+				machineDeploymentObject.Annotations[annotation], value))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ScaleDownUnneededTime is a helper function that returns how long the cluster autoscaler of a cluster waits before
+// removing a node it does not need, read from the arguments of its deployment.
+func ScaleDownUnneededTime(client *rancher.Client, cluster *v1.SteveAPIObject) (time.Duration, error) {
+	clusterID, err := clusters.ManagementClusterID(cluster)
+	if err != nil {
+		return 0, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	deployment, err := wranglerContext.Apps.Deployment().Get(namespaces.KubeSystem, AutoscalerDeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		for _, arg := range append(container.Command, container.Args...) {
+			if strings.HasPrefix(arg, scaleDownUnneededTimeArg) {
+				return time.ParseDuration(strings.TrimPrefix(arg, scaleDownUnneededTimeArg))
+			}
+		}
+	}
+
+	return DefaultScaleDownUnneededTime, nil
+}
+
+// WaitForLoadScaling is a helper function that waits for an autoscaled machine pool to scale to the expected quantity
+// and have as many ready nodes, and returns how long it took. It fails when the pool quantity goes outside of its
+// autoscaling bounds, or when the autoscaler removes a node that was not cordoned and drained first. The nodes and pods
+// of the cluster are watched, so a node removed between two polls is still checked, and a node whose removal is not
+// observed fails the scaling too.
+func WaitForLoadScaling(client *rancher.Client, cluster *v1.SteveAPIObject, poolName string, expectedQuantity int32, timeout time.Duration) (*Scaling, error) {
+	cluster, err := client.Steve.SteveType(stevetypes.Provisioning).ByID(cluster.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := AutoscalerMachinePool(cluster, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterID, err := clusters.ManagementClusterID(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	scaling := &Scaling{
+		Pool: poolName,
+		From: *pool.Quantity,
+		To:   expectedQuantity,
+	}
+
+	logrus.Infof("Waiting for machine pool %s to scale from %d to %d", poolName, scaling.From, expectedQuantity)
+
+	observer := &scalingObserver{
+		nodeStates: map[string]*nodeState{},
+		nodePods:   map[string]map[string]bool{},
+	}
+
+	watchCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go observer.watch(watchCtx, func() (watch.Interface, error) {
+		return wranglerContext.Core.Node().Watch(metav1.ListOptions{})
+	}, func(event watch.Event) {
+		if node, ok := event.Object.(*corev1.Node); ok {
+			observer.observeNode(event.Type, node)
+		}
+	})
+
+	go observer.watch(watchCtx, func() (watch.Interface, error) {
+		return wranglerContext.Core.Pod().Watch("", metav1.ListOptions{})
+	}, func(event watch.Event) {
+		if pod, ok := event.Object.(*corev1.Pod); ok {
+			observer.observePod(event.Type, pod)
+		}
+	})
+
+	start := time.Now()
+	seenNodes := map[string]bool{}
+	var boundsErr error
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, timeout, true, func(ctx context.Context) (bool, error) {
+		clusterObject, err := client.Steve.SteveType(stevetypes.Provisioning).ByID(cluster.ID)
+		if err != nil {
+			return false, nil
+		}
+
+		pool, err := AutoscalerMachinePool(clusterObject, poolName)
+		if err != nil {
+			return false, nil
+		}
+
+		if *pool.Quantity < *pool.AutoscalingMinSize || *pool.Quantity > *pool.AutoscalingMaxSize {
+			boundsErr = fmt.Errorf("machine pool %s scaled to %d, outside of its bounds [%d, %d]", poolName, *pool.Quantity,
+				*pool.AutoscalingMinSize, *pool.AutoscalingMaxSize)
+			return false, boundsErr
+		}
+
+		nodeNames, err := poolNodeNames(client, cluster.Name, poolName)
+		if err != nil {
+			return false, nil
+		}
+
+		current := map[string]bool{}
+		readyNodes := int32(0)
+		for _, nodeName := range nodeNames {
+			current[nodeName] = true
+			seenNodes[nodeName] = true
+
+			node, err := wranglerContext.Core.Node().Get(nodeName, metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+
+			if !isCordoned(node) && clusters.NodeReady(node) {
+				readyNodes++
+			}
+		}
+
+		for nodeName := range seenNodes {
+			if !current[nodeName] && !slices.Contains(scaling.RemovedNodes, nodeName) {
+				scaling.RemovedNodes = append(scaling.RemovedNodes, nodeName)
+			}
+		}
+
+		return *pool.Quantity == expectedQuantity && int32(len(nodeNames)) == expectedQuantity && readyNodes == expectedQuantity, nil
+	})
+	scaling.Duration = time.Since(start)
+	if boundsErr != nil {
+		return scaling, boundsErr
+	}
+
+	if err != nil {
+		return scaling, fmt.Errorf("machine pool %s of cluster %s did not scale to %d within %s: %w", poolName, cluster.Name, expectedQuantity, timeout, err)
+	}
+
+	var errs []error
+	for _, nodeName := range scaling.RemovedNodes {
+		// the machine of a node goes away before its node object, so the node may still be deleted after the pool scaled
+		err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+			return observer.nodeState(nodeName).removed, nil
+		})
+
+		state := observer.nodeState(nodeName)
+		if !state.removed {
+			errs = append(errs, fmt.Errorf("removal of node %s of machine pool %s was not observed: %w", nodeName, poolName, err))
+		} else if !state.cordoned {
+			errs = append(errs, fmt.Errorf("node %s of machine pool %s was removed without being cordoned", nodeName, poolName))
+		} else if !state.drained {
+			errs = append(errs, fmt.Errorf("node %s of machine pool %s was removed without being drained", nodeName, poolName))
+		}
+	}
+
+	logrus.Infof("Machine pool %s scaled from %d to %d in %s", poolName, scaling.From, scaling.To, scaling.Duration.Round(time.Second))
+
+	return scaling, errors.Join(errs...)
+}
+
+// watch is a helper function that passes the events of a watch to observe until ctx is done, reopening the watch
+// when the API server closes it.
+func (o *scalingObserver) watch(ctx context.Context, open func() (watch.Interface, error), observe func(watch.Event)) {
+	for ctx.Err() == nil {
+		watcher, err := open()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(defaults.FiveSecondTimeout):
+				continue
+			}
+		}
+
+		func() {
+			defer watcher.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case event, ok := <-watcher.ResultChan():
+					if !ok {
+						return
+					}
+
+					observe(event)
+				}
+			}
+		}()
+	}
+}
+
+// observeNode is a helper function that records whether a node is cordoned and, when it is removed, whether a drain
+// evicted its pods first.
+func (o *scalingObserver) observeNode(eventType watch.EventType, node *corev1.Node) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	state, ok := o.nodeStates[node.Name]
+	if !ok {
+		state = &nodeState{}
+		o.nodeStates[node.Name] = state
+	}
+
+	if isCordoned(node) {
+		state.cordoned = true
+	}
+
+	if eventType == watch.Deleted {
+		state.removed = true
+		state.drained = len(o.nodePods[node.Name]) == 0
+	}
+}
+
+// observePod is a helper function that records the pods of a node a drain must evict, until they are deleted.
+func (o *scalingObserver) observePod(eventType watch.EventType, pod *corev1.Pod) {
+	if pod.Spec.NodeName == "" {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	pods, ok := o.nodePods[pod.Spec.NodeName]
+	if !ok {
+		pods = map[string]bool{}
+		o.nodePods[pod.Spec.NodeName] = pods
+	}
+
+	key := pod.Namespace + "/" + pod.Name
+	if eventType == watch.Deleted || pod.DeletionTimestamp != nil || isDrainExempt(pod) {
+		delete(pods, key)
+	} else {
+		pods[key] = true
+	}
+}
+
+// nodeState is a helper function that returns what was observed of a node.
+func (o *scalingObserver) nodeState(nodeName string) nodeState {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	state, ok := o.nodeStates[nodeName]
+	if !ok {
+		return nodeState{}
+	}
+
+	return *state
+}
+
+// poolMachines is a private helper function that returns the machines of a machine pool of a cluster.
+func poolMachines(client *rancher.Client, clusterName, poolName string) ([]v1.SteveAPIObject, error) {
+	query, err := url.ParseQuery(fmt.Sprintf("labelSelector=%s=%s,%s=%s", capi.ClusterNameLabel, clusterName, machinePoolNameLabel, poolName))
+	if err != nil {
+		return nil, err
+	}
+
+	machineList, err := client.Steve.SteveType(stevetypes.Machine).NamespacedSteveClient(namespaces.FleetDefault).List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return machineList.Data, nil
+}
+
+// poolNodeNames is a private helper function that returns the names of the nodes of the machines of a machine pool,
+// including the machines being deleted.
+func poolNodeNames(client *rancher.Client, clusterName, poolName string) ([]string, error) {
+	machines, err := poolMachines(client, clusterName, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeNames []string
+	for _, machine := range machines {
+		status := &capi.MachineStatus{}
+		err = steveV1.ConvertToK8sType(machine.Status, status)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.NodeRef != nil {
+			nodeNames = append(nodeNames, status.NodeRef.Name)
+		}
+	}
+
+	return nodeNames, nil
+}
+
+// isCordoned is a private helper function that returns whether a node is unschedulable or tainted for deletion by the
+// cluster autoscaler.
+func isCordoned(node *corev1.Node) bool {
+	if node.Spec.Unschedulable {
+		return true
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == toBeDeletedTaint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isDrainExempt is a private helper function that returns whether a pod is one a drain leaves behind: a daemonset pod,
+// a static pod or a finished pod.
+func isDrainExempt(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return true
+	}
+
+	if _, ok := pod.Annotations[mirrorPodAnnotation]; ok {
+		return true
+	}
+
+	owner := metav1.GetControllerOf(pod)
+
+	return owner != nil && owner.Kind == daemonSetKind
+}
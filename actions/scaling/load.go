@@ -0,0 +1,156 @@
+package scaling
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/clusters"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	LoadImage = "registry.k8s.io/pause:3.10"
+
+	// loadPercent of the allocatable resources of a node is requested by each load pod, so no two of them fit on the
+	// same node.
+	loadPercent  = 60
+	loadName     = "autoscaler-load"
+	loadLabel    = "scaling.cattle.io/load"
+	workerRole   = "node-role.kubernetes.io/worker"
+	hostnameKey  = "kubernetes.io/hostname"
+	linuxOSLabel = "kubernetes.io/os"
+	linuxOS      = "linux"
+)
+
+// Load is a deployment of pods sized from the nodes of an autoscaled machine pool, each of them needing a node of its
+// own, that forces the pool to scale up to as many nodes as it has replicas.
+type Load struct {
+	ClusterID  string
+	Deployment *appv1.Deployment
+	CPU        resource.Quantity
+	Memory     resource.Quantity
+}
+
+// CreateLoad is a helper function that creates a load in a namespace of a cluster that needs nodeCount nodes of an
+// autoscaled machine pool. Each pod requests 60% of the allocatable CPU and memory of a node of the pool, so the pods
+// that do not fit on the existing nodes stay pending until the autoscaler adds nodes for them.
+func CreateLoad(client *rancher.Client, cluster *v1.SteveAPIObject, poolName, namespace string, nodeCount int32) (*Load, error) {
+	clusterID, err := clusters.ManagementClusterID(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeNames, err := poolNodeNames(client, cluster.Name, poolName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(nodeNames) == 0 {
+		return nil, fmt.Errorf("machine pool %s of cluster %s has no nodes to size the load from", poolName, cluster.Name)
+	}
+
+	node, err := wranglerContext.Core.Node().Get(nodeNames[0], metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	load := &Load{
+		ClusterID: clusterID,
+		CPU:       *resource.NewMilliQuantity(node.Status.Allocatable.Cpu().MilliValue()*loadPercent/100, resource.DecimalSI),
+		Memory:    *resource.NewQuantity(node.Status.Allocatable.Memory().Value()*loadPercent/100, resource.BinarySI),
+	}
+
+	name := namegen.AppendRandomString(loadName)
+	labels := map[string]string{loadLabel: name}
+
+	deployment := &appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appv1.DeploymentSpec{
+			Replicas: &nodeCount,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{
+						linuxOSLabel: linuxOS,
+						workerRole:   "true",
+					},
+					Affinity: &corev1.Affinity{
+						PodAntiAffinity: &corev1.PodAntiAffinity{
+							RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+								{
+									LabelSelector: &metav1.LabelSelector{MatchLabels: labels},
+									TopologyKey:   hostnameKey,
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  loadName,
+							Image: LoadImage,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    load.CPU,
+									corev1.ResourceMemory: load.Memory,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	logrus.Infof("Creating load %s of %d pods requesting %s CPU and %s memory each", name, nodeCount, load.CPU.String(), load.Memory.String())
+	load.Deployment, err = wranglerContext.Apps.Deployment().Create(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	return load, nil
+}
+
+// Delete is a helper function that deletes a load and waits for its pods to be gone, so its nodes become unneeded.
+func (l *Load) Delete(client *rancher.Client) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, l.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Deleting load %s", l.Deployment.Name)
+	err = wranglerContext.Apps.Deployment().Delete(l.Deployment.Namespace, l.Deployment.Name, &metav1.DeleteOptions{})
+	if err != nil {
+		return err
+	}
+
+	selector := loadLabel + "=" + l.Deployment.Name
+
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := wranglerContext.Core.Pod().List(l.Deployment.Namespace, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return false, nil
+		}
+
+		return len(pods.Items) == 0, nil
+	})
+}
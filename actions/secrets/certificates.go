@@ -1,6 +1,8 @@
 package secrets
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -18,6 +20,8 @@ const (
 	DefaultCommonName = "rancher.test.local"
 	LocalhostName     = "localhost"
 	LocalhostIP       = "127.0.0.1"
+
+	serverCertTTL = 7 * 24 * time.Hour
 )
 
 // GenerateSelfSignedCert creates a new self-signed certificate and private key
@@ -71,3 +75,59 @@ func GenerateSelfSignedCert() (string, string, error) {
 
 	return string(certPEM), string(keyPEM), nil
 }
+
+// GenerateServerCert creates a self-signed CA certificate for a server deployed by a test, valid for localhost and the
+// given hosts, and returns the certificate and its private key PEM encoded. Hosts may be DNS names or IP addresses, as
+// clients may reach the server on a node address, and their ports are ignored.
+func GenerateServerCert(commonName string, hosts ...string) ([]byte, []byte, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dnsNames := []string{LocalhostName}
+	ipAddresses := []net.IP{net.ParseIP(LocalhostIP)}
+	for _, host := range hosts {
+		hostName, _, err := net.SplitHostPort(host)
+		if err != nil {
+			hostName = host
+		}
+
+		if ip := net.ParseIP(hostName); ip != nil {
+			ipAddresses = append(ipAddresses, ip)
+		} else if hostName != "" {
+			dnsNames = append(dnsNames, hostName)
+		}
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              dnsNames,
+		IPAddresses:           ipAddresses,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(serverCertTTL),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encodedKey, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey}), nil
+}
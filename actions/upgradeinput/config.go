@@ -5,14 +5,17 @@ import "github.com/rancher/tests/actions/provisioninginput"
 type PSACT string
 
 const (
-	ConfigurationFileKey = "upgradeInput" // ConfigurationFileKey is used to parse the configuration of upgrade tests.
-	localClusterID       = "local"        // localClusterID is a string to used ignore this cluster in comparisons
-	LatestKey            = "latest"       // latestKey is a string to determine automatically version pooling to the latest possible
+	ConfigurationFileKey = "upgradeInput"             // ConfigurationFileKey is used to parse the configuration of upgrade tests.
+	localClusterID       = "local"                    // localClusterID is a string to used ignore this cluster in comparisons
+	LatestKey            = "latest"                   // latestKey is a string to determine automatically version pooling to the latest possible
+	DefaultProbeState    = "upgrade-probe-state.json" // DefaultProbeState is the file the upgrade probe state is written to when none is configured
 )
 
 // Config is a struct that stores multiple clusters and their testing options to load from the configuration file
 type Config struct {
-	Clusters []Cluster `json:"clusters" yaml:"clusters" default:"[]"`
+	Clusters              []Cluster `json:"clusters" yaml:"clusters" default:"[]"`
+	ProbeStateFile        string    `json:"probeStateFile" yaml:"probeStateFile" default:""`
+	VerifyUpgradeStrategy bool      `json:"verifyUpgradeStrategy" yaml:"verifyUpgradeStrategy" default:"false"`
 }
 
 // Cluster is a struct that's used to configure a single cluster to be used in an upgrade test
@@ -25,6 +28,7 @@ type Cluster struct {
 
 // Features is a struct that stores test case options for a single cluster
 type Features struct {
-	Chart   *bool `json:"chart" yaml:"chart" default:"false"`
-	Ingress *bool `json:"ingress" yaml:"ingress" default:"false"`
+	Chart   *bool    `json:"chart" yaml:"chart" default:"false"`
+	Ingress *bool    `json:"ingress" yaml:"ingress" default:"false"`
+	Probes  []string `json:"probes" yaml:"probes" default:"[]"`
 }
@@ -78,3 +78,25 @@ func listDownstreamClusters(client *rancher.Client) (clusterNames []string, err
 
 	return
 }
+
+// LoadProbeStateFile is a helper function that returns the file the upgrade probe state is written to by the pre-upgrade
+// run and read from by the post-upgrade run, falling back to DefaultProbeState.
+func LoadProbeStateFile() string {
+	upgradeConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, upgradeConfig)
+
+	if upgradeConfig.ProbeStateFile == "" {
+		return DefaultProbeState
+	}
+
+	return upgradeConfig.ProbeStateFile
+}
+
+// LoadVerifyUpgradeStrategy is a helper function that returns whether RKE2/K3s upgrades are watched against the upgrade
+// strategy of the cluster.
+func LoadVerifyUpgradeStrategy() bool {
+	upgradeConfig := new(Config)
+	config.LoadConfig(ConfigurationFileKey, upgradeConfig)
+
+	return upgradeConfig.VerifyUpgradeStrategy
+}
@@ -0,0 +1,67 @@
+package upgradeprobes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// execInDeployment is a private helper function that runs a shell script in a running pod of a deployment and returns
+// its output.
+func execInDeployment(client *rancher.Client, clusterID, namespace, deploymentName, script string) (string, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	existingDeployment, err := wranglerContext.Apps.Deployment().Get(namespace, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(existingDeployment.Spec.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	podList, err := wranglerContext.Core.Pod().List(namespace, metav1.ListOptions{
+		LabelSelector: labelSelector.String(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var podName string
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning {
+			podName = pod.Name
+			break
+		}
+	}
+
+	if podName == "" {
+		return "", fmt.Errorf("deployment %s/%s has no running pod", namespace, deploymentName)
+	}
+
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return "", err
+	}
+
+	restConfig, err := (*clientConfig).ClientConfig()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := kubeconfig.KubectlExec(restConfig, podName, namespace, []string{"sh", "-c", script})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(strings.ReplaceAll(output.String(), "\r", "")), nil
+}
@@ -0,0 +1,165 @@
+package upgradeprobes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	extensionsfleet "github.com/rancher/shepherd/extensions/fleet"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/rbac"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	FleetProbe = "fleet"
+
+	gitRepoKey          = "gitRepo"
+	deploymentsKey      = "deployments"
+	fleetExamplesRepo   = "https://github.com/rancher/fleet-examples"
+	fleetExamplesBranch = "master"
+	fleetExamplesPath   = "simple"
+	fleetClusterLabel   = "management.cattle.io/cluster-name"
+)
+
+// fleetProbe checks a GitRepo targeting the cluster stays ready with every deployment it created available.
+type fleetProbe struct {
+	namespace string
+	gitRepoID string
+}
+
+func (f *fleetProbe) Prepare(client *rancher.Client, clusterID string) error {
+	_, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	f.namespace = namespace.Name
+	gitRepo := &v1alpha1.GitRepo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString("upgrade-probe"),
+			Namespace: rbac.DefaultNamespace,
+		},
+		Spec: v1alpha1.GitRepoSpec{
+			Repo:            fleetExamplesRepo,
+			Branch:          fleetExamplesBranch,
+			Paths:           []string{fleetExamplesPath},
+			TargetNamespace: f.namespace,
+			Targets: []v1alpha1.GitTarget{
+				{
+					ClusterSelector: &metav1.LabelSelector{
+						MatchLabels: map[string]string{fleetClusterLabel: clusterID},
+					},
+				},
+			},
+		},
+	}
+
+	gitRepoObject, err := extensionsfleet.CreateFleetGitRepo(client, gitRepo)
+	if err != nil {
+		return err
+	}
+
+	f.gitRepoID = gitRepoObject.ID
+
+	return waitForGitRepoReady(client, f.gitRepoID)
+}
+
+func (f *fleetProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	err := deployments.WatchAndWaitDeployments(client, clusterID, f.namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	deploymentList, err := wranglerContext.Apps.Deployment().List(f.namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(deploymentList.Items) == 0 {
+		return nil, fmt.Errorf("gitRepo %s deployed no deployments to namespace %s", f.gitRepoID, f.namespace)
+	}
+
+	return State{
+		namespaceKey:   f.namespace,
+		gitRepoKey:     f.gitRepoID,
+		deploymentsKey: strconv.Itoa(len(deploymentList.Items)),
+	}, nil
+}
+
+func (f *fleetProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(namespaceKey, gitRepoKey, deploymentsKey)
+	if err != nil {
+		return err
+	}
+
+	namespace, gitRepoID, deploymentCount := values[0], values[1], values[2]
+
+	err = waitForGitRepoReady(client, gitRepoID)
+	if err != nil {
+		return err
+	}
+
+	err = deployments.WatchAndWaitDeployments(client, clusterID, namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	deploymentList, err := wranglerContext.Apps.Deployment().List(namespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	if strconv.Itoa(len(deploymentList.Items)) != deploymentCount {
+		return fmt.Errorf("gitRepo %s has %d deployments in namespace %s, expected %s", gitRepoID, len(deploymentList.Items), namespace, deploymentCount)
+	}
+
+	return nil
+}
+
+// waitForGitRepoReady is a private helper function that waits for every cluster targeted by a GitRepo to be ready.
+func waitForGitRepoReady(client *rancher.Client, gitRepoID string) error {
+	var gitRepoStatus *v1alpha1.GitRepoStatus
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		gitRepo, err := client.Steve.SteveType(extensionsfleet.FleetGitRepoResourceType).ByID(gitRepoID)
+		if err != nil {
+			return false, nil
+		}
+
+		gitRepoStatus = &v1alpha1.GitRepoStatus{}
+		err = steveV1.ConvertToK8sType(gitRepo.Status, gitRepoStatus)
+		if err != nil {
+			return false, err
+		}
+
+		return gitRepoStatus.DesiredReadyClusters > 0 && gitRepoStatus.ReadyClusters == gitRepoStatus.DesiredReadyClusters, nil
+	})
+	if err != nil {
+		if gitRepoStatus != nil {
+			err = errors.Join(fmt.Errorf("gitRepo %s has %d of %d clusters ready", gitRepoID, gitRepoStatus.ReadyClusters, gitRepoStatus.DesiredReadyClusters), err)
+		}
+
+		return err
+	}
+
+	return nil
+}
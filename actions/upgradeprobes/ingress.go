@@ -0,0 +1,119 @@
+package upgradeprobes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	extensionsingresses "github.com/rancher/shepherd/extensions/ingresses"
+	"github.com/rancher/tests/actions/kubeapi/ingresses"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/workloads/deployment"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	IngressProbe = "ingress"
+
+	ingressKey     = "ingress"
+	ingressHostKey = "host"
+	ingressRoot    = "/"
+)
+
+// ingressProbe checks an ingress keeps its generated hostname and routes to its deployment from outside the cluster.
+type ingressProbe struct {
+	namespace   string
+	ingressName string
+	host        string
+}
+
+func (i *ingressProbe) Prepare(client *rancher.Client, clusterID string) error {
+	_, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	i.namespace = namespace.Name
+	deploymentForIngress, err := deployment.CreateDeployment(client, clusterID, i.namespace, 1, "", "", false, false, false, true)
+	if err != nil {
+		return err
+	}
+
+	ingressTemplate, err := ingresses.CreateServiceAndIngressTemplateForDeployment(client, clusterID, i.namespace, deploymentForIngress)
+	if err != nil {
+		return err
+	}
+
+	ingressTemplate.Spec.Rules[0].HTTP.Paths[0].Path = ingressRoot
+	ingress, err := ingresses.CreateIngress(client, clusterID, ingressTemplate.Name, i.namespace, &ingressTemplate.Spec)
+	if err != nil {
+		return err
+	}
+
+	i.ingressName = ingress.Name
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		ingress, err := ingresses.GetIngressByName(client, clusterID, i.namespace, i.ingressName)
+		if err != nil || ingress == nil {
+			return false, nil
+		}
+
+		i.host = ingress.Spec.Rules[0].Host
+
+		return i.host != ingresses.IngressHostName, nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("ingress %s/%s hostname was not generated", i.namespace, i.ingressName), err)
+	}
+
+	return waitForIngressAccessible(client, i.host)
+}
+
+func (i *ingressProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	return State{
+		namespaceKey:   i.namespace,
+		ingressKey:     i.ingressName,
+		ingressHostKey: i.host,
+	}, nil
+}
+
+func (i *ingressProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(namespaceKey, ingressKey, ingressHostKey)
+	if err != nil {
+		return err
+	}
+
+	namespace, ingressName, host := values[0], values[1], values[2]
+
+	ingress, err := ingresses.GetIngressByName(client, clusterID, namespace, ingressName)
+	if err != nil {
+		return err
+	} else if ingress == nil {
+		return fmt.Errorf("ingress %s/%s was deleted during the upgrade", namespace, ingressName)
+	}
+
+	if ingress.Spec.Rules[0].Host != host {
+		return fmt.Errorf("ingress %s/%s host changed from %s to %s", namespace, ingressName, host, ingress.Spec.Rules[0].Host)
+	}
+
+	return waitForIngressAccessible(client, host)
+}
+
+// waitForIngressAccessible is a private helper function that waits for an ingress host to answer from outside the
+// cluster.
+func waitForIngressAccessible(client *rancher.Client, host string) error {
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		accessible, err := extensionsingresses.IsIngressExternallyAccessible(client, host, "", false)
+		if err != nil {
+			return false, nil
+		}
+
+		return accessible, nil
+	})
+	if err != nil {
+		return errors.Join(fmt.Errorf("ingress host %s is not accessible", host), err)
+	}
+
+	return nil
+}
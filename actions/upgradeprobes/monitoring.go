@@ -0,0 +1,120 @@
+package upgradeprobes
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	extensionscharts "github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/tests/actions/charts"
+	"github.com/rancher/tests/actions/projects"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	MonitoringProbe = "monitoring"
+
+	chartVersionKey   = "chartVersion"
+	systemProjectName = "System"
+)
+
+// monitoringProbe checks rancher-monitoring stays installed at the same version with its workloads available. The
+// chart is installed with the latest version first if the cluster does not have it yet.
+type monitoringProbe struct{}
+
+func (m *monitoringProbe) Prepare(client *rancher.Client, clusterID string) error {
+	chartStatus, err := extensionscharts.GetChartStatus(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	if err != nil {
+		return err
+	}
+
+	if !chartStatus.IsAlreadyInstalled {
+		cluster, err := client.Management.Cluster.ByID(clusterID)
+		if err != nil {
+			return err
+		}
+
+		clusterMeta, err := clusters.NewClusterMeta(client, cluster.Name)
+		if err != nil {
+			return err
+		}
+
+		systemProject, err := projects.GetProjectByName(client, clusterID, systemProjectName)
+		if err != nil {
+			return err
+		}
+
+		latestVersion, err := client.Catalog.GetLatestChartVersion(charts.RancherMonitoringName, catalog.RancherChartRepo)
+		if err != nil {
+			return err
+		}
+
+		installOptions := &charts.InstallOptions{
+			Cluster:   clusterMeta,
+			Version:   latestVersion,
+			ProjectID: systemProject.ID,
+		}
+
+		err = charts.InstallRancherMonitoringChart(client, installOptions, &charts.RancherMonitoringOpts{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return waitForMonitoringWorkloads(client, clusterID)
+}
+
+func (m *monitoringProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	chartStatus, err := extensionscharts.GetChartStatus(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !chartStatus.IsAlreadyInstalled {
+		return nil, fmt.Errorf("chart %s is not installed", charts.RancherMonitoringName)
+	}
+
+	return State{
+		chartVersionKey: chartStatus.ChartDetails.Spec.Chart.Metadata.Version,
+	}, nil
+}
+
+func (m *monitoringProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(chartVersionKey)
+	if err != nil {
+		return err
+	}
+
+	chartStatus, err := extensionscharts.GetChartStatus(client, clusterID, charts.RancherMonitoringNamespace, charts.RancherMonitoringName)
+	if err != nil {
+		return err
+	}
+
+	if !chartStatus.IsAlreadyInstalled {
+		return fmt.Errorf("chart %s was uninstalled during the upgrade", charts.RancherMonitoringName)
+	}
+
+	chartVersion := chartStatus.ChartDetails.Spec.Chart.Metadata.Version
+	if chartVersion != values[0] {
+		return fmt.Errorf("chart %s version changed from %s to %s", charts.RancherMonitoringName, values[0], chartVersion)
+	}
+
+	return waitForMonitoringWorkloads(client, clusterID)
+}
+
+// waitForMonitoringWorkloads is a private helper function that waits for every rancher-monitoring deployment,
+// daemonset and statefulset to be available.
+func waitForMonitoringWorkloads(client *rancher.Client, clusterID string) error {
+	err := extensionscharts.WatchAndWaitDeployments(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	err = extensionscharts.WatchAndWaitDaemonSets(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	return extensionscharts.WatchAndWaitStatefulSets(client, clusterID, charts.RancherMonitoringNamespace, metav1.ListOptions{})
+}
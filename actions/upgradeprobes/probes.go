@@ -0,0 +1,146 @@
+package upgradeprobes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/sirupsen/logrus"
+)
+
+// State is what a probe captured before the upgrade to verify against after it. It is written to disk between the
+// pre-upgrade and post-upgrade runs, so it only holds strings.
+type State map[string]string
+
+// Probe checks a single feature of a cluster survives an upgrade. Prepare creates the resources the probe needs before
+// the upgrade, Capture records their state and Verify checks the state after the upgrade. Prepare and Capture run in
+// the same process, Verify may run in another one with only the captured state.
+type Probe interface {
+	Prepare(client *rancher.Client, clusterID string) error
+	Capture(client *rancher.Client, clusterID string) (State, error)
+	Verify(client *rancher.Client, clusterID string, state State) error
+}
+
+var registry = map[string]func() Probe{
+	WorkloadsProbe:           func() Probe { return &workloadsProbe{} },
+	SecretsProbe:             func() Probe { return &secretsProbe{} },
+	IngressProbe:             func() Probe { return &ingressProbe{} },
+	ProjectScopedSecretProbe: func() Probe { return &projectScopedSecretProbe{} },
+	RBACProbe:                func() Probe { return &rbacProbe{} },
+	PVCProbe:                 func() Probe { return &pvcProbe{} },
+	FleetProbe:               func() Probe { return &fleetProbe{} },
+	MonitoringProbe:          func() Probe { return &monitoringProbe{} },
+}
+
+// Register is a helper function that adds a probe to the registry, so it can be enabled by name in the upgradeInput
+// config. Registering a name twice replaces the previous probe.
+func Register(name string, newProbe func() Probe) {
+	registry[name] = newProbe
+}
+
+// Names is a helper function that returns the names of every registered probe, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewProbes is a helper function that returns a new instance of every probe enabled by name. It errors on unknown
+// names rather than silently skipping them.
+func NewProbes(names []string) (map[string]Probe, error) {
+	probes := map[string]Probe{}
+	for _, name := range names {
+		newProbe, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown upgrade probe %q, expected one of %v", name, Names())
+		}
+
+		probes[name] = newProbe()
+	}
+
+	return probes, nil
+}
+
+// RunPreUpgrade is a helper function that prepares and captures every probe for a cluster and stores the captured
+// state in the state file under the cluster name. The resources of the probes must outlive the pre-upgrade run, so
+// they are created with a session that is never cleaned up. Every probe runs even if a previous one failed.
+func RunPreUpgrade(client *rancher.Client, clusterName string, probes map[string]Probe, stateFile StateFile) error {
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	probeClient, err := client.WithSession(session.NewSession())
+	if err != nil {
+		return err
+	}
+
+	if stateFile[clusterName] == nil {
+		stateFile[clusterName] = map[string]State{}
+	}
+
+	var probeErrors []error
+	for _, name := range sortedNames(probes) {
+		logrus.Infof("Preparing upgrade probe %s on cluster %s", name, clusterName)
+		err = probes[name].Prepare(probeClient, clusterID)
+		if err != nil {
+			probeErrors = append(probeErrors, fmt.Errorf("preparing upgrade probe %s: %w", name, err))
+			continue
+		}
+
+		logrus.Infof("Capturing upgrade probe %s on cluster %s", name, clusterName)
+		state, err := probes[name].Capture(probeClient, clusterID)
+		if err != nil {
+			probeErrors = append(probeErrors, fmt.Errorf("capturing upgrade probe %s: %w", name, err))
+			continue
+		}
+
+		stateFile[clusterName][name] = state
+	}
+
+	return errors.Join(probeErrors...)
+}
+
+// RunPostUpgrade is a helper function that verifies every probe for a cluster against the state captured before the
+// upgrade. A probe without captured state fails. Every probe runs even if a previous one failed.
+func RunPostUpgrade(client *rancher.Client, clusterName string, probes map[string]Probe, stateFile StateFile) error {
+	clusterID, err := clusters.GetClusterIDByName(client, clusterName)
+	if err != nil {
+		return err
+	}
+
+	var probeErrors []error
+	for _, name := range sortedNames(probes) {
+		state, ok := stateFile[clusterName][name]
+		if !ok {
+			probeErrors = append(probeErrors, fmt.Errorf("upgrade probe %s has no state captured for cluster %s", name, clusterName))
+			continue
+		}
+
+		logrus.Infof("Verifying upgrade probe %s on cluster %s", name, clusterName)
+		err = probes[name].Verify(client, clusterID, state)
+		if err != nil {
+			probeErrors = append(probeErrors, fmt.Errorf("verifying upgrade probe %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(probeErrors...)
+}
+
+// sortedNames is a private helper function that returns the probe names sorted, so probes run in a stable order.
+func sortedNames(probes map[string]Probe) []string {
+	names := make([]string, 0, len(probes))
+	for name := range probes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
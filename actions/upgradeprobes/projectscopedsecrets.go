@@ -0,0 +1,93 @@
+package upgradeprobes
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	secretsapi "github.com/rancher/tests/actions/kubeapi/secrets"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/rbac"
+	"github.com/rancher/tests/actions/secrets"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ProjectScopedSecretProbe = "projectScopedSecrets"
+
+	projectKey = "project"
+)
+
+// projectScopedSecretProbe checks a project-scoped secret keeps its data and stays propagated to every namespace of
+// its project.
+type projectScopedSecretProbe struct {
+	projectID   string
+	secretName  string
+	secretValue string
+}
+
+func (p *projectScopedSecretProbe) Prepare(client *rancher.Client, clusterID string) error {
+	project, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	p.projectID = project.Name
+	secondNamespace, err := projects.CreateNamespaceUsingWrangler(client, clusterID, p.projectID, nil)
+	if err != nil {
+		return err
+	}
+
+	p.secretValue = namegen.RandStringLower(16)
+	secret, err := secrets.CreateProjectScopedSecret(client, clusterID, p.projectID, map[string][]byte{secretDataKey: []byte(p.secretValue)}, corev1.SecretTypeOpaque)
+	if err != nil {
+		return err
+	}
+
+	p.secretName = secret.Name
+
+	return secrets.ValidatePropagatedNamespaceSecrets(client, clusterID, p.projectID, secret, []*corev1.Namespace{namespace, secondNamespace})
+}
+
+func (p *projectScopedSecretProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	return State{
+		projectKey:     p.projectID,
+		secretKey:      p.secretName,
+		secretValueKey: p.secretValue,
+	}, nil
+}
+
+func (p *projectScopedSecretProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(projectKey, secretKey, secretValueKey)
+	if err != nil {
+		return err
+	}
+
+	projectID, secretName, secretValue := values[0], values[1], values[2]
+
+	secret, err := secretsapi.GetSecretByName(client, rbac.LocalCluster, fmt.Sprintf("%s-%s", clusterID, projectID), secretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if string(secret.Data[secretDataKey]) != secretValue {
+		return fmt.Errorf("project-scoped secret %s/%s data changed during the upgrade", secret.Namespace, secretName)
+	}
+
+	err = secrets.ValidateProjectScopedSecretLabel(secret, projectID)
+	if err != nil {
+		return err
+	}
+
+	namespaces, err := projects.GetNamespacesInProject(client, clusterID, projectID)
+	if err != nil {
+		return err
+	}
+
+	if len(namespaces) == 0 {
+		return fmt.Errorf("project %s has no namespaces left", projectID)
+	}
+
+	return secrets.ValidatePropagatedNamespaceSecrets(client, clusterID, projectID, secret, namespaces)
+}
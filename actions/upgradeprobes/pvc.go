@@ -0,0 +1,160 @@
+package upgradeprobes
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/workloads"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/projects"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	PVCProbe = "pvc"
+
+	pvcKey         = "pvc"
+	volumeKey      = "volume"
+	dataKey        = "data"
+	pvcVolumeName  = "upgrade-probe-data"
+	pvcMountPath   = "/data"
+	pvcDataFile    = pvcMountPath + "/upgrade-probe"
+	pvcStorageSize = "1Gi"
+)
+
+// pvcProbe checks data written to a persistent volume claim of the default storage class before the upgrade is still
+// there after it, on the same volume.
+type pvcProbe struct {
+	namespace      string
+	pvcName        string
+	deploymentName string
+	volumeName     string
+	data           string
+}
+
+func (p *pvcProbe) Prepare(client *rancher.Client, clusterID string) error {
+	_, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	p.namespace = namespace.Name
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Create(&corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString("pvc-probe"),
+			Namespace: p.namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(pvcStorageSize)},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	p.pvcName = pvc.Name
+
+	container := workloads.NewContainer(probeContainerName, probeImage, corev1.PullIfNotPresent,
+		[]corev1.VolumeMount{{Name: pvcVolumeName, MountPath: pvcMountPath}}, nil, nil, nil, nil)
+	volumes := []corev1.Volume{
+		{
+			Name:         pvcVolumeName,
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: p.pvcName}},
+		},
+	}
+
+	p.deploymentName = namegen.AppendRandomString("pvc-probe")
+	_, err = deployments.CreateDeployment(client, clusterID, p.deploymentName, p.namespace, workloads.NewPodTemplate([]corev1.Container{container}, volumes, nil, nil, nil), 1)
+	if err != nil {
+		return err
+	}
+
+	err = deployments.WatchAndWaitDeployments(client, clusterID, p.namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + p.deploymentName,
+	})
+	if err != nil {
+		return err
+	}
+
+	p.data = namegen.RandStringLower(32)
+	_, err = execInDeployment(client, clusterID, p.namespace, p.deploymentName, fmt.Sprintf("echo %s > %s && sync", p.data, pvcDataFile))
+
+	return err
+}
+
+func (p *pvcProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Get(p.namespace, p.pvcName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return nil, fmt.Errorf("persistent volume claim %s/%s is %s, expected %s", p.namespace, p.pvcName, pvc.Status.Phase, corev1.ClaimBound)
+	}
+
+	return State{
+		namespaceKey:  p.namespace,
+		pvcKey:        p.pvcName,
+		deploymentKey: p.deploymentName,
+		volumeKey:     pvc.Spec.VolumeName,
+		dataKey:       p.data,
+	}, nil
+}
+
+func (p *pvcProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(namespaceKey, pvcKey, deploymentKey, volumeKey, dataKey)
+	if err != nil {
+		return err
+	}
+
+	namespace, pvcName, deploymentName, volumeName, data := values[0], values[1], values[2], values[3], values[4]
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Get(namespace, pvcName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if pvc.Status.Phase != corev1.ClaimBound || pvc.Spec.VolumeName != volumeName {
+		return fmt.Errorf("persistent volume claim %s/%s is %s to volume %s, expected %s to volume %s", namespace, pvcName, pvc.Status.Phase, pvc.Spec.VolumeName, corev1.ClaimBound, volumeName)
+	}
+
+	err = deployments.WatchAndWaitDeployments(client, clusterID, namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + deploymentName,
+	})
+	if err != nil {
+		return err
+	}
+
+	readData, err := execInDeployment(client, clusterID, namespace, deploymentName, "cat "+pvcDataFile)
+	if err != nil {
+		return err
+	}
+
+	if readData != data {
+		return fmt.Errorf("persistent volume claim %s/%s holds %q, expected %q", namespace, pvcName, readData, data)
+	}
+
+	return nil
+}
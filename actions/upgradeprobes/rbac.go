@@ -0,0 +1,123 @@
+package upgradeprobes
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
+	"github.com/rancher/shepherd/extensions/users"
+	"github.com/rancher/tests/actions/namespaces"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/rbac"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	RBACProbe = "rbac"
+
+	usernameKey      = "username"
+	passwordKey      = "password"
+	crtbNamespaceKey = "crtbNamespace"
+	crtbKey          = "crtb"
+	prtbNamespaceKey = "prtbNamespace"
+	prtbKey          = "prtb"
+)
+
+// rbacProbe checks a standard user keeps its cluster and project role template bindings, and the access they grant,
+// through the upgrade.
+type rbacProbe struct {
+	user      *management.User
+	namespace string
+	crtbName  string
+	crtbNS    string
+	prtbName  string
+	prtbNS    string
+}
+
+func (r *rbacProbe) Prepare(client *rancher.Client, clusterID string) error {
+	var err error
+	r.user, err = users.CreateUserWithRole(client, users.UserConfig(), rbac.StandardUser.String())
+	if err != nil {
+		return err
+	}
+
+	project, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	r.namespace = namespace.Name
+	crtb, err := rbac.CreateClusterRoleTemplateBinding(client, clusterID, r.user, rbac.ClusterMember.String())
+	if err != nil {
+		return err
+	}
+
+	r.crtbNS, r.crtbName = crtb.Namespace, crtb.Name
+	prtb, err := rbac.CreateProjectRoleTemplateBinding(client, r.user, project, rbac.ProjectMember.String())
+	if err != nil {
+		return err
+	}
+
+	r.prtbNS, r.prtbName = prtb.Namespace, prtb.Name
+
+	return verifyUserAccess(client, clusterID, r.user.Username, r.user.Password, r.namespace)
+}
+
+func (r *rbacProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	return State{
+		usernameKey:      r.user.Username,
+		passwordKey:      r.user.Password,
+		namespaceKey:     r.namespace,
+		crtbNamespaceKey: r.crtbNS,
+		crtbKey:          r.crtbName,
+		prtbNamespaceKey: r.prtbNS,
+		prtbKey:          r.prtbName,
+	}, nil
+}
+
+func (r *rbacProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(usernameKey, passwordKey, namespaceKey, crtbNamespaceKey, crtbKey, prtbNamespaceKey, prtbKey)
+	if err != nil {
+		return err
+	}
+
+	username, password, namespace := values[0], values[1], values[2]
+
+	_, err = client.WranglerContext.Mgmt.ClusterRoleTemplateBinding().Get(values[3], values[4], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("cluster role template binding %s/%s: %w", values[3], values[4], err)
+	}
+
+	_, err = client.WranglerContext.Mgmt.ProjectRoleTemplateBinding().Get(values[5], values[6], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("project role template binding %s/%s: %w", values[5], values[6], err)
+	}
+
+	return verifyUserAccess(client, clusterID, username, password, namespace)
+}
+
+// verifyUserAccess is a private helper function that logs in as the user and checks it can still see the cluster,
+// through its cluster membership, and the namespace, through its project membership.
+func verifyUserAccess(client *rancher.Client, clusterID, username, password, namespace string) error {
+	userClient, err := client.AsUser(&management.User{Username: username, Password: password})
+	if err != nil {
+		return fmt.Errorf("logging in as %s: %w", username, err)
+	}
+
+	_, err = userClient.Management.Cluster.ByID(clusterID)
+	if err != nil {
+		return fmt.Errorf("user %s can not get cluster %s: %w", username, clusterID, err)
+	}
+
+	steveClient, err := userClient.Steve.ProxyDownstream(clusterID)
+	if err != nil {
+		return err
+	}
+
+	_, err = steveClient.SteveType(namespaces.NamespaceSteveType).ByID(namespace)
+	if err != nil {
+		return fmt.Errorf("user %s can not get namespace %s: %w", username, namespace, err)
+	}
+
+	return nil
+}
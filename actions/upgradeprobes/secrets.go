@@ -0,0 +1,144 @@
+package upgradeprobes
+
+import (
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/workloads"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/secrets"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	SecretsProbe = "secrets"
+
+	secretKey          = "secret"
+	secretValueKey     = "secretValue"
+	secretDataKey      = "UPGRADE_PROBE"
+	secretMountPath    = "/etc/upgrade-probe"
+	secretVolumeName   = "upgrade-probe-secret"
+	probeContainerName = "upgrade-probe"
+	probeImage         = "nginx"
+)
+
+// secretsProbe checks a secret keeps its data and a pod consuming it both as a volume and as environment variables
+// still sees that data.
+type secretsProbe struct {
+	namespace      string
+	secretName     string
+	secretValue    string
+	deploymentName string
+}
+
+func (s *secretsProbe) Prepare(client *rancher.Client, clusterID string) error {
+	_, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	s.namespace = namespace.Name
+	s.secretValue = namegen.RandStringLower(16)
+
+	secret, err := secrets.CreateSecret(client, clusterID, s.namespace, map[string][]byte{secretDataKey: []byte(s.secretValue)}, corev1.SecretTypeOpaque, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	s.secretName = secret.Name
+
+	container := workloads.NewContainer(probeContainerName, probeImage, corev1.PullIfNotPresent,
+		[]corev1.VolumeMount{{Name: secretVolumeName, MountPath: secretMountPath, ReadOnly: true}},
+		[]corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: s.secretName}}}},
+		nil, nil, nil)
+	volumes := []corev1.Volume{
+		{
+			Name:         secretVolumeName,
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: s.secretName}},
+		},
+	}
+
+	s.deploymentName = namegen.AppendRandomString("secret-probe")
+	_, err = deployments.CreateDeployment(client, clusterID, s.deploymentName, s.namespace, workloads.NewPodTemplate([]corev1.Container{container}, volumes, nil, nil, nil), 1)
+	if err != nil {
+		return err
+	}
+
+	return deployments.WatchAndWaitDeployments(client, clusterID, s.namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + s.deploymentName,
+	})
+}
+
+func (s *secretsProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	err := verifySecretConsumed(client, clusterID, s.namespace, s.deploymentName, s.secretValue)
+	if err != nil {
+		return nil, err
+	}
+
+	return State{
+		namespaceKey:   s.namespace,
+		secretKey:      s.secretName,
+		secretValueKey: s.secretValue,
+		deploymentKey:  s.deploymentName,
+	}, nil
+}
+
+func (s *secretsProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(namespaceKey, secretKey, secretValueKey, deploymentKey)
+	if err != nil {
+		return err
+	}
+
+	namespace, secretName, secretValue, deploymentName := values[0], values[1], values[2], values[3]
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	secret, err := wranglerContext.Core.Secret().Get(namespace, secretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if string(secret.Data[secretDataKey]) != secretValue {
+		return fmt.Errorf("secret %s/%s data changed during the upgrade", namespace, secretName)
+	}
+
+	err = deployments.WatchAndWaitDeployments(client, clusterID, namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + deploymentName,
+	})
+	if err != nil {
+		return err
+	}
+
+	return verifySecretConsumed(client, clusterID, namespace, deploymentName, secretValue)
+}
+
+// verifySecretConsumed is a private helper function that checks the pod of the deployment reads the secret value both
+// from the mounted volume and from its environment.
+func verifySecretConsumed(client *rancher.Client, clusterID, namespace, deploymentName, secretValue string) error {
+	volumeValue, err := execInDeployment(client, clusterID, namespace, deploymentName, fmt.Sprintf("cat %s/%s", secretMountPath, secretDataKey))
+	if err != nil {
+		return err
+	}
+
+	if volumeValue != secretValue {
+		return fmt.Errorf("deployment %s/%s reads %q from the secret volume, expected %q", namespace, deploymentName, volumeValue, secretValue)
+	}
+
+	envValue, err := execInDeployment(client, clusterID, namespace, deploymentName, "printenv "+secretDataKey)
+	if err != nil {
+		return err
+	}
+
+	if envValue != secretValue {
+		return fmt.Errorf("deployment %s/%s reads %q from the secret environment variable, expected %q", namespace, deploymentName, envValue, secretValue)
+	}
+
+	return nil
+}
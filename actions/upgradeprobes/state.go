@@ -0,0 +1,52 @@
+package upgradeprobes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// StateFile is the state captured by every probe before the upgrade, keyed by cluster name and probe name.
+type StateFile map[string]map[string]State
+
+// LoadStateFile is a helper function that reads the state written by the pre-upgrade run.
+func LoadStateFile(filePath string) (StateFile, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("reading upgrade probe state, was the pre-upgrade run skipped? %w", err)
+	}
+
+	stateFile := StateFile{}
+	err = json.Unmarshal(content, &stateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return stateFile, nil
+}
+
+// Write is a helper function that writes the state to disk for the post-upgrade run, which may be another job.
+func (s StateFile) Write(filePath string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filePath, content, 0644)
+}
+
+// require is a private helper function that returns the values of the keys of a captured state, erroring on the
+// first missing key.
+func (s State) require(keys ...string) ([]string, error) {
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value, ok := s[key]
+		if !ok {
+			return nil, fmt.Errorf("captured state is missing %s", key)
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
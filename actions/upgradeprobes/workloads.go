@@ -0,0 +1,130 @@
+package upgradeprobes
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	extensionscharts "github.com/rancher/shepherd/extensions/charts"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/workloads/daemonset"
+	"github.com/rancher/tests/actions/workloads/deployment"
+	appv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	WorkloadsProbe = "workloads"
+
+	namespaceKey        = "namespace"
+	deploymentKey       = "deployment"
+	daemonsetKey        = "daemonset"
+	replicasKey         = "replicas"
+	imageKey            = "image"
+	workloadsReplicas   = 2
+	fieldSelectorByName = "metadata.name="
+)
+
+// workloadsProbe checks a deployment and a daemonset keep running their image with every replica available.
+type workloadsProbe struct {
+	namespace  string
+	deployment *appv1.Deployment
+	daemonset  *appv1.DaemonSet
+}
+
+func (w *workloadsProbe) Prepare(client *rancher.Client, clusterID string) error {
+	_, namespace, err := projects.CreateProjectAndNamespaceUsingWrangler(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	w.namespace = namespace.Name
+	w.deployment, err = deployment.CreateDeployment(client, clusterID, w.namespace, workloadsReplicas, "", "", false, false, false, true)
+	if err != nil {
+		return err
+	}
+
+	w.daemonset, err = daemonset.CreateDaemonset(client, clusterID, w.namespace, 1, "", "", false, false, true)
+
+	return err
+}
+
+func (w *workloadsProbe) Capture(client *rancher.Client, clusterID string) (State, error) {
+	return State{
+		namespaceKey:  w.namespace,
+		deploymentKey: w.deployment.Name,
+		daemonsetKey:  w.daemonset.Name,
+		replicasKey:   strconv.Itoa(workloadsReplicas),
+		imageKey:      w.deployment.Spec.Template.Spec.Containers[0].Image,
+	}, nil
+}
+
+func (w *workloadsProbe) Verify(client *rancher.Client, clusterID string, state State) error {
+	values, err := state.require(namespaceKey, deploymentKey, daemonsetKey, replicasKey, imageKey)
+	if err != nil {
+		return err
+	}
+
+	namespace, deploymentName, daemonsetName, replicas, image := values[0], values[1], values[2], values[3], values[4]
+
+	err = verifyDeployment(client, clusterID, namespace, deploymentName, replicas, image)
+	if err != nil {
+		return err
+	}
+
+	err = extensionscharts.WatchAndWaitDaemonSets(client, clusterID, namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + daemonsetName,
+	})
+	if err != nil {
+		return err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	daemonSet, err := wranglerContext.Apps.DaemonSet().Get(namespace, daemonsetName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if daemonSet.Spec.Template.Spec.Containers[0].Image != image {
+		return fmt.Errorf("daemonset %s/%s runs image %s, expected %s", namespace, daemonsetName, daemonSet.Spec.Template.Spec.Containers[0].Image, image)
+	}
+
+	return nil
+}
+
+// verifyDeployment is a private helper function that waits for every replica of a deployment to be available and
+// checks the replica count and image are the ones captured.
+func verifyDeployment(client *rancher.Client, clusterID, namespace, deploymentName, replicas, image string) error {
+	err := deployments.WatchAndWaitDeployments(client, clusterID, namespace, metav1.ListOptions{
+		FieldSelector: fieldSelectorByName + deploymentName,
+	})
+	if err != nil {
+		return err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return err
+	}
+
+	existingDeployment, err := wranglerContext.Apps.Deployment().Get(namespace, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if strconv.Itoa(int(existingDeployment.Status.AvailableReplicas)) != replicas {
+		return fmt.Errorf("deployment %s/%s has %d available replicas, expected %s", namespace, deploymentName, existingDeployment.Status.AvailableReplicas, replicas)
+	}
+
+	if existingDeployment.Spec.Template.Spec.Containers[0].Image != image {
+		return fmt.Errorf("deployment %s/%s runs image %s, expected %s", namespace, deploymentName, existingDeployment.Spec.Template.Spec.Containers[0].Image, image)
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package upgradestrategy
+
+import (
+	"context"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// FixtureTerminationGracePeriod is the termination grace period of the fixture pods. They only stop at the end of
+	// it, so a drain grace period overriding it is seen on the pods being deleted.
+	FixtureTerminationGracePeriod = int64(60)
+	// FixtureMaxUnavailable is how many fixture pods the pod disruption budget of the fixture lets a drain evict at once.
+	FixtureMaxUnavailable = 1
+
+	fixtureName       = "upgrade-strategy"
+	fixtureLabel      = "upgradestrategy.cattle.io/fixture"
+	fixtureVolumeName = "scratch"
+	fixtureVolumePath = "/scratch"
+	minFixturePods    = 2
+	workerRole        = "node-role.kubernetes.io/worker"
+	linuxOS           = "linux"
+
+	podDisruptionBudgetKind = "PodDisruptionBudget"
+)
+
+// PodDisruptionBudgetGroupVersionResource is the required Group Version Resource for accessing pod disruption budgets
+// in a cluster, using the dynamic client.
+var PodDisruptionBudgetGroupVersionResource = schema.GroupVersionResource{
+	Group:    "policy",
+	Version:  "v1",
+	Resource: "poddisruptionbudgets",
+}
+
+// Fixture is a deployment spread over the Linux worker nodes of a cluster, with a pod disruption budget, that shows how the
+// drains of an upgrade treat the pods of a node.
+type Fixture struct {
+	ClusterID           string
+	Namespace           string
+	Deployment          *appv1.Deployment
+	PodDisruptionBudget *policyv1.PodDisruptionBudget
+	EmptyDir            bool
+}
+
+// CreateFixture is a helper function that creates a fixture in a new namespace of a cluster, with as many pods as it
+// has Linux worker nodes, and waits for its pods to be ready. The pods mount an emptyDir volume only when every enabled drain
+// option of the upgrade strategy deletes emptyDir data, as a drain that does not would fail on them.
+func CreateFixture(client *rancher.Client, clusterID string, strategy rkev1.ClusterUpgradeStrategy) (*Fixture, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeSelector := map[string]string{
+		workerRole:           "true",
+		corev1.LabelOSStable: linuxOS,
+	}
+
+	workers, err := wranglerContext.Core.Node().List(metav1.ListOptions{LabelSelector: labels.SelectorFromSet(nodeSelector).String()})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := int32(max(len(workers.Items), minFixturePods))
+
+	namespaceName := namegen.AppendRandomString(fixtureName)
+	_, err = namespaces.CreateNamespace(client, clusterID, "", namespaceName, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := &Fixture{
+		ClusterID: clusterID,
+		Namespace: namespaceName,
+		EmptyDir:  deletesEmptyDirData(strategy.ControlPlaneDrainOptions) && deletesEmptyDirData(strategy.WorkerDrainOptions),
+	}
+
+	fixtureLabels := map[string]string{fixtureLabel: fixtureName}
+	gracePeriod := FixtureTerminationGracePeriod
+
+	podSpec := corev1.PodSpec{
+		TerminationGracePeriodSeconds: &gracePeriod,
+		NodeSelector:                  nodeSelector,
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{
+				MaxSkew:           1,
+				TopologyKey:       corev1.LabelHostname,
+				WhenUnsatisfiable: corev1.ScheduleAnyway,
+				LabelSelector:     &metav1.LabelSelector{MatchLabels: fixtureLabels},
+			},
+		},
+		Containers: []corev1.Container{
+			{
+				Name:  fixtureName,
+				Image: connectivity.GetProbeImage(),
+				Args:  []string{"pause"},
+				Lifecycle: &corev1.Lifecycle{
+					PreStop: &corev1.LifecycleHandler{
+						Exec: &corev1.ExecAction{Command: []string{"sh", "-c", "sleep 3600"}},
+					},
+				},
+			},
+		},
+	}
+
+	if fixture.EmptyDir {
+		podSpec.Volumes = []corev1.Volume{
+			{
+				Name:         fixtureVolumeName,
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			},
+		}
+		podSpec.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: fixtureVolumeName, MountPath: fixtureVolumePath}}
+	}
+
+	logrus.Infof("Creating upgrade strategy fixture of %d pods in namespace %s", replicas, namespaceName)
+	fixture.Deployment, err = wranglerContext.Apps.Deployment().Create(&appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fixtureName,
+			Namespace: namespaceName,
+			Labels:    fixtureLabels,
+		},
+		Spec: appv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: fixtureLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: fixtureLabels},
+				Spec:       podSpec,
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxUnavailable := intstr.FromInt32(FixtureMaxUnavailable)
+	fixture.PodDisruptionBudget = &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: policyv1.SchemeGroupVersion.String(),
+			Kind:       podDisruptionBudgetKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fixtureName,
+			Namespace: namespaceName,
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			MaxUnavailable: &maxUnavailable,
+			Selector:       &metav1.LabelSelector{MatchLabels: fixtureLabels},
+		},
+	}
+
+	// the shepherd scheme has no policy/v1 types, so the budget is converted without it
+	podDisruptionBudget, err := runtime.DefaultUnstructuredConverter.ToUnstructured(fixture.PodDisruptionBudget)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dynamicClient.Resource(PodDisruptionBudgetGroupVersionResource).Namespace(namespaceName).Create(context.TODO(),
+		&unstructured.Unstructured{Object: podDisruptionBudget}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := wranglerContext.Apps.Deployment().Get(namespaceName, fixtureName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		return deployment.Status.ReadyReplicas == replicas, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fixture, nil
+}
+
+// Delete is a helper function that deletes the namespace of a fixture, so its pod disruption budget does not hold back
+// the drains of later upgrades. A fixture that is already deleted is ignored.
+func (f *Fixture) Delete(client *rancher.Client) error {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, f.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Deleting upgrade strategy fixture namespace %s", f.Namespace)
+	err = wranglerContext.Core.Namespace().Delete(f.Namespace, &metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// deletesEmptyDirData is a private helper function that returns whether drain options delete emptyDir data, or do not
+// drain at all.
+func deletesEmptyDirData(drainOptions rkev1.DrainOptions) bool {
+	return !drainOptions.Enabled || drainOptions.DeleteEmptyDirData
+}
@@ -0,0 +1,444 @@
+package upgradestrategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	"github.com/rancher/shepherd/pkg/wrangler"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	capi "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// EventType is a change of a node seen while observing an upgrade.
+type EventType string
+
+// Role is the upgrade tier of a node: control plane for etcd and control plane nodes, worker for worker only nodes.
+type Role string
+
+const (
+	Cordoned              EventType = "Cordoned"
+	Uncordoned            EventType = "Uncordoned"
+	NotReady              EventType = "NotReady"
+	Ready                 EventType = "Ready"
+	DrainStarted          EventType = "DrainStarted"
+	DrainDone             EventType = "DrainDone"
+	PlanDelivered         EventType = "PlanDelivered"
+	PlanApplied           EventType = "PlanApplied"
+	KubeletVersionChanged EventType = "KubeletVersionChanged"
+	PodEvicted            EventType = "PodEvicted"
+
+	ControlPlane Role = "control plane"
+	Worker       Role = "worker"
+
+	observeInterval = 2 * time.Second
+
+	clusterNameLabel      = "rke.cattle.io/cluster-name"
+	machineNameLabel      = "rke.cattle.io/machine-name"
+	etcdRoleLabel         = "rke.cattle.io/etcd-role"
+	controlPlaneRoleLabel = "rke.cattle.io/control-plane-role"
+	workerRoleLabel       = "rke.cattle.io/worker-role"
+	machinePlanType       = "rke.cattle.io/machine-plan"
+	planKey               = "plan"
+	appliedPlanKey        = "appliedPlan"
+	drainDoneAnnotation   = "rke.cattle.io/drain-done"
+)
+
+// drainAnnotations are the annotations the planner sets on the plan secret of a machine while it drains its node.
+var drainAnnotations = []string{
+	"rke.cattle.io/pre-drain",
+	"rke.cattle.io/drain-options",
+	"rke.cattle.io/post-drain",
+	"rke.cattle.io/uncordon",
+}
+
+// majorPlanKeys are the parts of a plan whose change makes the planner apply it within the upgrade concurrency. Plans
+// changing only their files are applied to every node at once.
+var majorPlanKeys = []string{"instructions", "periodicInstructions", "probes"}
+
+// Event is a change of a node seen while observing an upgrade.
+type Event struct {
+	Time   time.Time
+	Node   string
+	Type   EventType
+	Detail string
+}
+
+// nodeState is what was last seen of a node of the cluster.
+type nodeState struct {
+	role           Role
+	cordoned       bool
+	ready          bool
+	inSync         bool
+	draining       bool
+	drainDone      bool
+	plan           []byte
+	kubeletVersion string
+}
+
+// unavailable is a private helper function that returns whether a node counts against the upgrade concurrency: its
+// plan is not applied yet, it is being drained, or it is cordoned or not ready.
+func (n *nodeState) unavailable() bool {
+	return !n.inSync || n.draining || n.cordoned || !n.ready
+}
+
+// evictedPod is a fixture pod seen being deleted.
+type evictedPod struct {
+	name        string
+	node        string
+	gracePeriod int64
+}
+
+// Observer records the cordons, drains, plan applications and kubelet version changes of the nodes of a cluster while
+// it is upgraded, along with the fixture pods evicted by the drains.
+type Observer struct {
+	client          *rancher.Client
+	clusterName     string
+	wranglerContext *wrangler.Context
+	fixture         *Fixture
+
+	mu                 sync.Mutex
+	events             []Event
+	nodes              map[string]*nodeState
+	fixturePods        map[string]string
+	drainedPods        map[string][]string
+	evictedPods        map[string]evictedPod
+	peakUnavailable    map[Role][]string
+	podDisruptionPeaks []string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Observe is a helper function that starts observing a cluster, which should then be upgraded. The fixture is optional;
+// without it no drain or pod disruption budget is verified.
+func Observe(client *rancher.Client, cluster *steveV1.SteveAPIObject, fixture *Fixture) (*Observer, error) {
+	clusterID, err := clusters.ManagementClusterID(cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	observer := &Observer{
+		client:          client,
+		clusterName:     cluster.Name,
+		wranglerContext: wranglerContext,
+		fixture:         fixture,
+		nodes:           map[string]*nodeState{},
+		fixturePods:     map[string]string{},
+		drainedPods:     map[string][]string{},
+		evictedPods:     map[string]evictedPod{},
+		peakUnavailable: map[Role][]string{},
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+
+	err = observer.observe(false)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Observing the upgrade of cluster %s", cluster.Name)
+	go func() {
+		defer close(observer.done)
+
+		ticker := time.NewTicker(observeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-observer.stop:
+				return
+			case <-ticker.C:
+				err := observer.observe(true)
+				if err != nil {
+					logrus.Debugf("Failed to observe cluster %s: %v", cluster.Name, err)
+				}
+			}
+		}
+	}()
+
+	return observer, nil
+}
+
+// WaitForKubeletVersion is a helper function that waits for every observed node to run the kubelet version, with its
+// plan applied and uncordoned, so the whole upgrade was observed.
+func (o *Observer) WaitForKubeletVersion(version string) error {
+	return kwait.PollUntilContextTimeout(context.TODO(), defaults.TenSecondTimeout, defaults.ThirtyMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		for _, node := range o.nodes {
+			if node.kubeletVersion != version || node.unavailable() {
+				return false, nil
+			}
+		}
+
+		return len(o.nodes) > 0, nil
+	})
+}
+
+// Stop is a helper function that stops observing the cluster and returns the events that were seen.
+func (o *Observer) Stop() []Event {
+	close(o.stop)
+	<-o.done
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	logrus.Infof("Stopped observing the upgrade of cluster %s after %d events", o.clusterName, len(o.events))
+
+	return append([]Event(nil), o.events...)
+}
+
+// observe is a private helper function that compares the nodes and fixture pods of the cluster to what was last seen,
+// recording events for the changes when record is set.
+func (o *Observer) observe(record bool) error {
+	roles, err := o.machineRoles()
+	if err != nil {
+		return err
+	}
+
+	planSecrets, err := o.client.WranglerContext.Core.Secret().List(namespaces.FleetDefault, metav1.ListOptions{
+		LabelSelector: clusterNameLabel + "=" + o.clusterName,
+		FieldSelector: "type=" + machinePlanType,
+	})
+	if err != nil {
+		return err
+	}
+
+	plans := map[string]*corev1.Secret{}
+	for i := range planSecrets.Items {
+		plans[planSecrets.Items[i].Labels[machineNameLabel]] = &planSecrets.Items[i]
+	}
+
+	nodeList, err := o.wranglerContext.Core.Node().List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	var pods []corev1.Pod
+	if o.fixture != nil {
+		podList, err := o.wranglerContext.Core.Pod().List(o.fixture.Namespace, metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		pods = podList.Items
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	unavailable := map[Role][]string{}
+	for _, node := range nodeList.Items {
+		machine, ok := roles[node.Name]
+		if !ok {
+			continue
+		}
+
+		state := &nodeState{
+			role:           machine.role,
+			cordoned:       node.Spec.Unschedulable,
+			ready:          clusters.NodeReady(&node),
+			inSync:         true,
+			kubeletVersion: node.Status.NodeInfo.KubeletVersion,
+		}
+
+		if secret, ok := plans[machine.name]; ok {
+			state.plan = secret.Data[planKey]
+			state.inSync = inSync(secret.Data[planKey], secret.Data[appliedPlanKey])
+			state.drainDone = secret.Annotations[drainDoneAnnotation] != ""
+			for _, annotation := range drainAnnotations {
+				if secret.Annotations[annotation] != "" {
+					state.draining = true
+				}
+			}
+		}
+
+		if previous, ok := o.nodes[node.Name]; ok && record {
+			o.recordNodeEvents(now, node.Name, previous, state)
+		}
+
+		o.nodes[node.Name] = state
+
+		if state.unavailable() {
+			unavailable[state.role] = append(unavailable[state.role], node.Name)
+		}
+	}
+
+	for role, nodes := range unavailable {
+		if len(nodes) > len(o.peakUnavailable[role]) {
+			o.peakUnavailable[role] = nodes
+		}
+	}
+
+	o.observePods(now, pods, record)
+
+	return nil
+}
+
+// recordNodeEvents is a private helper function that records the changes between two states of a node.
+func (o *Observer) recordNodeEvents(now time.Time, nodeName string, previous, current *nodeState) {
+	changes := []struct {
+		changed   bool
+		eventType EventType
+		detail    string
+	}{
+		{!previous.cordoned && current.cordoned, Cordoned, ""},
+		{previous.cordoned && !current.cordoned, Uncordoned, ""},
+		{previous.ready && !current.ready, NotReady, ""},
+		{!previous.ready && current.ready, Ready, ""},
+		{!previous.draining && current.draining, DrainStarted, ""},
+		{!previous.drainDone && current.drainDone, DrainDone, ""},
+		{!bytes.Equal(previous.plan, current.plan) && !current.inSync, PlanDelivered, ""},
+		{!previous.inSync && current.inSync, PlanApplied, ""},
+		{previous.kubeletVersion != current.kubeletVersion, KubeletVersionChanged, previous.kubeletVersion + " -> " + current.kubeletVersion},
+	}
+
+	for _, change := range changes {
+		if !change.changed {
+			continue
+		}
+
+		if change.eventType == DrainStarted {
+			for pod, node := range o.fixturePods {
+				if node == nodeName {
+					o.drainedPods[nodeName] = append(o.drainedPods[nodeName], pod)
+				}
+			}
+		}
+
+		o.addEvent(now, nodeName, change.eventType, change.detail)
+	}
+}
+
+// observePods is a private helper function that records the fixture pods seen being deleted, and when they were, how
+// many fixture pods were left healthy.
+func (o *Observer) observePods(now time.Time, pods []corev1.Pod, record bool) {
+	if o.fixture == nil {
+		return
+	}
+
+	healthy := 0
+	evicted := false
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil {
+			o.fixturePods[pod.Name] = pod.Spec.NodeName
+			if connectivity.PodReady(&pod) {
+				healthy++
+			}
+
+			continue
+		}
+
+		if _, ok := o.evictedPods[pod.Name]; ok || !record {
+			continue
+		}
+
+		gracePeriod := int64(-1)
+		if pod.DeletionGracePeriodSeconds != nil {
+			gracePeriod = *pod.DeletionGracePeriodSeconds
+		}
+
+		o.evictedPods[pod.Name] = evictedPod{name: pod.Name, node: pod.Spec.NodeName, gracePeriod: gracePeriod}
+		o.addEvent(now, pod.Spec.NodeName, PodEvicted, fmt.Sprintf("%s/%s with a grace period of %ds", pod.Namespace, pod.Name, gracePeriod))
+		evicted = true
+	}
+
+	desiredHealthy := int(*o.fixture.Deployment.Spec.Replicas) - FixtureMaxUnavailable
+	if evicted && healthy < desiredHealthy {
+		o.podDisruptionPeaks = append(o.podDisruptionPeaks, fmt.Sprintf("%s: %d healthy fixture pods, the budget needs %d",
+			now.Format(time.RFC3339), healthy, desiredHealthy))
+	}
+}
+
+// addEvent is a private helper function that records an event.
+func (o *Observer) addEvent(now time.Time, nodeName string, eventType EventType, detail string) {
+	logrus.Debugf("Upgrade of cluster %s: node %s %s %s", o.clusterName, nodeName, eventType, detail)
+	o.events = append(o.events, Event{Time: now, Node: nodeName, Type: eventType, Detail: detail})
+}
+
+// machine is the name and role of the machine of a node.
+type machine struct {
+	name string
+	role Role
+}
+
+// machineRoles is a private helper function that returns the machine of each node of the cluster.
+func (o *Observer) machineRoles() (map[string]machine, error) {
+	query, err := url.ParseQuery("labelSelector=" + capi.ClusterNameLabel + "=" + o.clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	machineList, err := o.client.Steve.SteveType(stevetypes.Machine).NamespacedSteveClient(namespaces.FleetDefault).List(query)
+	if err != nil {
+		return nil, err
+	}
+
+	machines := map[string]machine{}
+	for _, machineObject := range machineList.Data {
+		status := &capi.MachineStatus{}
+		err = steveV1.ConvertToK8sType(machineObject.Status, status)
+		if err != nil {
+			return nil, err
+		}
+
+		if status.NodeRef == nil {
+			continue
+		}
+
+		role := Worker
+		if machineObject.Labels[etcdRoleLabel] == "true" || machineObject.Labels[controlPlaneRoleLabel] == "true" {
+			role = ControlPlane
+		} else if machineObject.Labels[workerRoleLabel] != "true" {
+			continue
+		}
+
+		machines[status.NodeRef.Name] = machine{name: machineObject.Name, role: role}
+	}
+
+	return machines, nil
+}
+
+// inSync is a private helper function that returns whether a plan was applied, ignoring changes that are only to its
+// files, as the planner applies those outside of the upgrade concurrency.
+func inSync(plan, appliedPlan []byte) bool {
+	if bytes.Equal(plan, appliedPlan) {
+		return true
+	}
+
+	var desired, applied map[string]json.RawMessage
+	if json.Unmarshal(plan, &desired) != nil || json.Unmarshal(appliedPlan, &applied) != nil {
+		return false
+	}
+
+	for _, key := range majorPlanKeys {
+		if !bytes.Equal(desired[key], applied[key]) {
+			return false
+		}
+	}
+
+	return true
+}
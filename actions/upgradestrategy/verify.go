@@ -0,0 +1,130 @@
+package upgradestrategy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+)
+
+// Verify is a helper function that verifies that an observed upgrade respected the upgrade strategy: no more control
+// plane or worker nodes were unavailable at once than their concurrency, fixture pods were only evicted from nodes the
+// drain options drain, with the drain grace period, and every fixture pod on a drained node was evicted, emptyDir
+// volumes included. Unless the drain disables eviction, the evictions must also have kept to the pod disruption
+// budget of the fixture.
+func (o *Observer) Verify(strategy rkev1.ClusterUpgradeStrategy) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var errs []error
+	errs = append(errs, o.verifyConcurrency(ControlPlane, strategy.ControlPlaneConcurrency))
+	errs = append(errs, o.verifyConcurrency(Worker, strategy.WorkerConcurrency))
+
+	if o.fixture != nil {
+		errs = append(errs, o.verifyDrain(strategy))
+	}
+
+	return errors.Join(errs...)
+}
+
+// verifyConcurrency is a private helper function that verifies that no more nodes of a role were unavailable at once
+// than the concurrency allows.
+func (o *Observer) verifyConcurrency(role Role, concurrency string) error {
+	count := 0
+	for _, node := range o.nodes {
+		if node.role == role {
+			count++
+		}
+	}
+
+	limit, err := concurrencyLimit(concurrency, count)
+	if err != nil {
+		return err
+	}
+
+	peak := o.peakUnavailable[role]
+	if limit > 0 && len(peak) > limit {
+		return fmt.Errorf("%d %s nodes were unavailable at once (%s), the concurrency %q allows %d", len(peak), role,
+			strings.Join(peak, ", "), concurrency, limit)
+	}
+
+	return nil
+}
+
+// verifyDrain is a private helper function that verifies the fixture pods evicted during the upgrade against the
+// drain options of their nodes.
+func (o *Observer) verifyDrain(strategy rkev1.ClusterUpgradeStrategy) error {
+	var errs []error
+	budgeted := false
+	for _, pod := range o.evictedPods {
+		node, ok := o.nodes[pod.node]
+		if !ok {
+			continue
+		}
+
+		drainOptions := strategy.WorkerDrainOptions
+		if node.role == ControlPlane {
+			drainOptions = strategy.ControlPlaneDrainOptions
+		}
+
+		if !drainOptions.Enabled {
+			errs = append(errs, fmt.Errorf("fixture pod %s was evicted from %s node %s, which is not drained", pod.name, node.role, pod.node))
+			continue
+		}
+
+		expected := FixtureTerminationGracePeriod
+		if drainOptions.GracePeriod >= 0 {
+			expected = int64(drainOptions.GracePeriod)
+		}
+
+		// a grace period of 0 is deleted with the minimum grace period of 1s
+		if pod.gracePeriod != expected && !(expected == 0 && pod.gracePeriod <= 1) {
+			errs = append(errs, fmt.Errorf("fixture pod %s was evicted from node %s with a grace period of %ds, expected %ds", pod.name, pod.node,
+				pod.gracePeriod, expected))
+		}
+
+		if !drainOptions.DisableEviction {
+			budgeted = true
+		}
+	}
+
+	if budgeted && len(o.podDisruptionPeaks) > 0 {
+		errs = append(errs, fmt.Errorf("the drains evicted fixture pods beyond their pod disruption budget: %s", strings.Join(o.podDisruptionPeaks, "; ")))
+	}
+
+	for nodeName, pods := range o.drainedPods {
+		for _, pod := range pods {
+			if _, ok := o.evictedPods[pod]; !ok {
+				errs = append(errs, fmt.Errorf("fixture pod %s was not evicted when node %s was drained (emptyDir volume: %t)", pod, nodeName, o.fixture.EmptyDir))
+			}
+		}
+	}
+
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+
+	return errors.Join(errs...)
+}
+
+// concurrencyLimit is a private helper function that returns how many of count nodes an upgrade concurrency lets be
+// unavailable at once, the way the planner reads it: empty is 1, 0 is unlimited and percentages are rounded up.
+func concurrencyLimit(concurrency string, count int) (int, error) {
+	if concurrency == "" {
+		return 1, nil
+	}
+
+	limit, err := strconv.Atoi(concurrency)
+	if err == nil {
+		return limit, nil
+	}
+
+	percentage, err := strconv.ParseFloat(strings.TrimSuffix(concurrency, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("concurrency %q must be a number or a percentage: %w", concurrency, err)
+	}
+
+	return max(int(math.Ceil(float64(count)*percentage/100)), 1), nil
+}
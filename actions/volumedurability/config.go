@@ -0,0 +1,13 @@
+package volumedurability
+
+const (
+	ConfigurationFileKey = "volumeDurabilityInput"
+)
+
+// Config is the dataset written to the volumes of a cluster. StorageClasses defaults to the default storage class of
+// the cluster, Files to 5 and FileSizeKB to 1024.
+type Config struct {
+	StorageClasses []string `json:"storageClasses" yaml:"storageClasses"`
+	Files          int      `json:"files" yaml:"files"`
+	FileSizeKB     int      `json:"fileSizeKB" yaml:"fileSizeKB"`
+}
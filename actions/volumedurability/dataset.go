@@ -0,0 +1,373 @@
+package volumedurability
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/storageclasses"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	datasetName        = "durability"
+	datasetLabel       = "volumedurability.cattle.io/storage-class"
+	datasetVolumeName  = "data"
+	datasetMountPath   = "/data"
+	datasetDir         = datasetMountPath + "/dataset"
+	datasetStorageSize = "1Gi"
+	defaultFiles       = 5
+	defaultFileSizeKB  = 1024
+	workerRole         = "node-role.kubernetes.io/worker"
+	linuxOSLabel       = "kubernetes.io/os"
+	linuxOS            = "linux"
+
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	betaDefaultStorageClassAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// Volume is a persistent volume claim of a storage class holding a dataset, mounted by a single pod deployment. Node is
+// the node the pod last ran on, and NodeLocal is set when the persistent volume is pinned to that node, as local-path
+// volumes are. Checksums maps the files of the dataset to their sha256 checksum.
+type Volume struct {
+	StorageClass          string
+	PersistentVolumeClaim string
+	PersistentVolume      string
+	Deployment            string
+	Node                  string
+	NodeLocal             bool
+	Checksums             map[string]string
+}
+
+// Dataset is a set of files written with their checksums to a volume of each storage class of a cluster, that are
+// expected to survive operations on the cluster such as node replacements, upgrades and etcd snapshot restores.
+type Dataset struct {
+	ClusterID string
+	Namespace string
+	Volumes   []*Volume
+}
+
+// WriteDataset is a helper function that creates a volume of each configured storage class in a new namespace of a
+// cluster, writes random files to it and records their checksums. Each volume is a 1Gi ReadWriteOnce claim mounted by
+// a single pod deployment on the worker nodes, recreated rather than rolled so the claim is only attached once.
+func WriteDataset(client *rancher.Client, clusterID string, config *Config) (*Dataset, error) {
+	files, fileSizeKB := defaultFiles, defaultFileSizeKB
+	if config.Files > 0 {
+		files = config.Files
+	}
+
+	if config.FileSizeKB > 0 {
+		fileSizeKB = config.FileSizeKB
+	}
+
+	storageClassNames, err := datasetStorageClasses(client, clusterID, config.StorageClasses)
+	if err != nil {
+		return nil, err
+	}
+
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := clusterRestConfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceName := namegen.AppendRandomString(datasetName)
+	_, err = namespaces.CreateNamespace(client, clusterID, "", namespaceName, "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dataset := &Dataset{
+		ClusterID: clusterID,
+		Namespace: namespaceName,
+	}
+
+	for _, storageClassName := range storageClassNames {
+		volume, err := createVolume(client, clusterID, namespaceName, storageClassName)
+		if err != nil {
+			return nil, err
+		}
+
+		dataset.Volumes = append(dataset.Volumes, volume)
+	}
+
+	script := fmt.Sprintf("mkdir -p %[1]s && for i in $(seq 1 %[2]d); do head -c %[3]d /dev/urandom > %[1]s/file-$i || exit 1; done && sync && cd %[1]s && sha256sum file-*",
+		datasetDir, files, fileSizeKB*1024)
+
+	for _, volume := range dataset.Volumes {
+		pod, err := waitForVolumePod(client, clusterID, namespaceName, volume.Deployment, nil)
+		if err != nil {
+			return nil, fmt.Errorf("volume of storage class %s: %w", volume.StorageClass, err)
+		}
+
+		logrus.Infof("Writing %d files of %dKB to the volume of storage class %s on node %s", files, fileSizeKB, volume.StorageClass, pod.Spec.NodeName)
+		output, err := pods.ExecScript(restConfig, namespaceName, pod.Name, script)
+		if err != nil {
+			return nil, err
+		}
+
+		volume.Checksums = parseChecksums(output)
+		if len(volume.Checksums) != files {
+			return nil, fmt.Errorf("wrote %d of %d files to the volume of storage class %s: %s", len(volume.Checksums), files, volume.StorageClass, output)
+		}
+
+		pvc, err := wranglerContext.Core.PersistentVolumeClaim().Get(namespaceName, volume.PersistentVolumeClaim, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		persistentVolume, err := wranglerContext.Core.PersistentVolume().Get(pvc.Spec.VolumeName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		volume.Node = pod.Spec.NodeName
+		volume.PersistentVolume = persistentVolume.Name
+		volume.NodeLocal = pinnedToNode(persistentVolume)
+	}
+
+	return dataset, nil
+}
+
+// datasetStorageClasses is a private helper function that returns the configured storage classes, after checking the
+// cluster has them, or the default storage class of the cluster when none are configured.
+func datasetStorageClasses(client *rancher.Client, clusterID string, configured []string) ([]string, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructuredList, err := dynamicClient.Resource(storageclasses.StorageClassGroupVersionResource).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var existing, defaultClasses []string
+	for _, unstructuredStorageClass := range unstructuredList.Items {
+		storageClass := &storagev1.StorageClass{}
+		err = runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredStorageClass.Object, storageClass)
+		if err != nil {
+			return nil, err
+		}
+
+		existing = append(existing, storageClass.Name)
+		if storageClass.Annotations[defaultStorageClassAnnotation] == "true" || storageClass.Annotations[betaDefaultStorageClassAnnotation] == "true" {
+			defaultClasses = append(defaultClasses, storageClass.Name)
+		}
+	}
+
+	if len(configured) == 0 {
+		if len(defaultClasses) == 0 {
+			return nil, fmt.Errorf("cluster %s has no default storage class, it has %s", clusterID, strings.Join(existing, ", "))
+		}
+
+		return defaultClasses, nil
+	}
+
+	for _, name := range configured {
+		if !slices.Contains(existing, name) {
+			return nil, fmt.Errorf("cluster %s has no storage class %s, it has %s", clusterID, name, strings.Join(existing, ", "))
+		}
+	}
+
+	return configured, nil
+}
+
+// createVolume is a private helper function that creates the persistent volume claim of a storage class and the
+// deployment mounting it.
+func createVolume(client *rancher.Client, clusterID, namespace, storageClassName string) (*Volume, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	pvc, err := wranglerContext.Core.PersistentVolumeClaim().Create(&corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString(datasetName),
+			Namespace: namespace,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &storageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(datasetStorageSize)},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := int32(1)
+	labels := map[string]string{datasetLabel: storageClassName}
+
+	logrus.Infof("Creating a volume of storage class %s in namespace %s", storageClassName, namespace)
+	deployment, err := wranglerContext.Apps.Deployment().Create(&appv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      namegen.AppendRandomString(datasetName),
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: appv1.DeploymentStrategy{Type: appv1.RecreateDeploymentStrategyType},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					NodeSelector: map[string]string{
+						linuxOSLabel: linuxOS,
+						workerRole:   "true",
+					},
+					Containers: []corev1.Container{
+						{
+							Name:         datasetName,
+							Image:        connectivity.GetProbeImage(),
+							Args:         []string{"pause"},
+							VolumeMounts: []corev1.VolumeMount{{Name: datasetVolumeName, MountPath: datasetMountPath}},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name:         datasetVolumeName,
+							VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.Name}},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Volume{
+		StorageClass:          storageClassName,
+		PersistentVolumeClaim: pvc.Name,
+		Deployment:            deployment.Name,
+	}, nil
+}
+
+// waitForVolumePod is a private helper function that waits for a ready pod of the deployment of a volume, other than
+// the excluded pods, and returns it. When none is ready in time, the error carries the last warning event of the
+// pending pod, such as a volume still attached to another node.
+func waitForVolumePod(client *rancher.Client, clusterID, namespace, deploymentName string, excluded []string) (*corev1.Pod, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := wranglerContext.Apps.Deployment().Get(namespace, deploymentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var readyPod, pendingPod *corev1.Pod
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TenMinuteTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := wranglerContext.Core.Pod().List(namespace, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return false, nil
+		}
+
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.DeletionTimestamp != nil || slices.Contains(excluded, string(pod.UID)) {
+				continue
+			}
+
+			if connectivity.PodReady(pod) {
+				readyPod = pod
+				return true, nil
+			}
+
+			pendingPod = pod
+		}
+
+		return false, nil
+	})
+	if err == nil {
+		return readyPod, nil
+	}
+
+	if pendingPod == nil {
+		return nil, fmt.Errorf("deployment %s/%s has no pod: %w", namespace, deploymentName, err)
+	}
+
+	events, listErr := wranglerContext.Core.Event().List(namespace, metav1.ListOptions{FieldSelector: "involvedObject.name=" + pendingPod.Name})
+	if listErr == nil {
+		for i := len(events.Items) - 1; i >= 0; i-- {
+			if events.Items[i].Type == corev1.EventTypeWarning {
+				return nil, fmt.Errorf("pod %s/%s is not ready, %s: %s: %w", namespace, pendingPod.Name, events.Items[i].Reason, events.Items[i].Message, err)
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("pod %s/%s is %s: %w", namespace, pendingPod.Name, pendingPod.Status.Phase, err)
+}
+
+// clusterRestConfig is a private helper function that returns the rest config of a cluster, to exec into its pods.
+func clusterRestConfig(client *rancher.Client, clusterID string) (*rest.Config, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return (*clientConfig).ClientConfig()
+}
+
+// parseChecksums is a private helper function that parses the output of sha256sum into the checksums of the files.
+func parseChecksums(output string) map[string]string {
+	checksums := map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			checksums[fields[1]] = fields[0]
+		}
+	}
+
+	return checksums
+}
+
+// pinnedToNode is a private helper function that returns whether a persistent volume can only be used from a single
+// node, as its node affinity requires a hostname.
+func pinnedToNode(persistentVolume *corev1.PersistentVolume) bool {
+	if persistentVolume.Spec.NodeAffinity == nil || persistentVolume.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+
+	for _, term := range persistentVolume.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expression := range term.MatchExpressions {
+			if expression.Key == corev1.LabelHostname {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,172 @@
+package volumedurability
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/workloads/pods"
+	"github.com/sirupsen/logrus"
+	appv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Result is the outcome of verifying the dataset of a volume. Reattach is how long the volume took to be mounted by the
+// rescheduled pod, from the deletion of the previous pod. Lost and Corrupted are the files that are missing or whose
+// checksum changed. NodeGone is set when the volume is pinned to a node that is no longer in the cluster, so all of its
+// files are lost with the node.
+type Result struct {
+	StorageClass string
+	PreviousNode string
+	Node         string
+	Reattach     time.Duration
+	Lost         []string
+	Corrupted    []string
+	NodeGone     bool
+}
+
+// Verify is a helper function that reschedules the pod of each volume of a dataset, waits for the volume to be
+// reattached to the new pod and verifies the checksums of the files read from it. ReadWriteOnce volumes can take a few
+// minutes to detach from a node that went away, so each pod gets 10 minutes to be ready. The files of volumes pinned to
+// a node that was removed are reported as lost. It fails when the dataset has no volume.
+func (d *Dataset) Verify(client *rancher.Client) ([]Result, error) {
+	if len(d.Volumes) == 0 {
+		return nil, fmt.Errorf("dataset in namespace %s has no volume to verify", d.Namespace)
+	}
+
+	var results []Result
+	var errs []error
+	for _, volume := range d.Volumes {
+		result, err := d.verifyVolume(client, volume)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("volume of storage class %s: %w", volume.StorageClass, err))
+			continue
+		}
+
+		results = append(results, *result)
+
+		if result.NodeGone {
+			errs = append(errs, fmt.Errorf("volume of storage class %s lost its %d files with node %s, which was removed", volume.StorageClass, len(result.Lost), result.PreviousNode))
+		} else if len(result.Lost) > 0 || len(result.Corrupted) > 0 {
+			errs = append(errs, fmt.Errorf("volume of storage class %s on node %s lost %d files [%s] and corrupted %d files [%s]", volume.StorageClass, result.Node,
+				len(result.Lost), strings.Join(result.Lost, ", "), len(result.Corrupted), strings.Join(result.Corrupted, ", ")))
+		}
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// verifyVolume is a private helper function that reschedules the pod of a volume and compares the checksums read from
+// the new pod with the recorded ones.
+func (d *Dataset) verifyVolume(client *rancher.Client, volume *Volume) (*Result, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, d.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{
+		StorageClass: volume.StorageClass,
+		PreviousNode: volume.Node,
+	}
+
+	if volume.NodeLocal {
+		_, err = wranglerContext.Core.Node().Get(volume.Node, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			result.NodeGone = true
+			for file := range volume.Checksums {
+				result.Lost = append(result.Lost, file)
+			}
+
+			slices.Sort(result.Lost)
+
+			return result, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deployment, err := wranglerContext.Apps.Deployment().Get(d.Namespace, volume.Deployment, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	previousPods, err := deletePods(client, d.ClusterID, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	pod, err := waitForVolumePod(client, d.ClusterID, d.Namespace, volume.Deployment, previousPods)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Reattach = time.Since(start)
+	result.Node = pod.Spec.NodeName
+	volume.Node = pod.Spec.NodeName
+
+	logrus.Infof("Volume of storage class %s was reattached on node %s after %s, verifying %d files", volume.StorageClass, result.Node,
+		result.Reattach.Round(time.Second), len(volume.Checksums))
+	restConfig, err := clusterRestConfig(client, d.ClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := pods.ExecScript(restConfig, d.Namespace, pod.Name, fmt.Sprintf("cd %s 2>/dev/null && sha256sum file-* 2>/dev/null; true", datasetDir))
+	if err != nil {
+		return nil, err
+	}
+
+	checksums := parseChecksums(output)
+	for file, checksum := range volume.Checksums {
+		readChecksum, ok := checksums[file]
+		if !ok {
+			result.Lost = append(result.Lost, file)
+		} else if readChecksum != checksum {
+			result.Corrupted = append(result.Corrupted, file)
+		}
+	}
+
+	slices.Sort(result.Lost)
+	slices.Sort(result.Corrupted)
+
+	return result, nil
+}
+
+// deletePods is a private helper function that deletes the pods of a deployment, so they are rescheduled, and returns
+// their UIDs.
+func deletePods(client *rancher.Client, clusterID string, deployment *appv1.Deployment) ([]string, error) {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := wranglerContext.Core.Pod().List(deployment.Namespace, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	for _, pod := range pods.Items {
+		uids = append(uids, string(pod.UID))
+
+		err = wranglerContext.Core.Pod().Delete(pod.Namespace, pod.Name, &metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return uids, nil
+}
@@ -0,0 +1,107 @@
+package pods
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+// execChunkSize keeps every base64 chunk written in a pod well below the kernel's single argument limit
+const execChunkSize = 64 * 1024
+
+// WaitForReadyPod is a helper function that waits for a pod of the local cluster matching a label selector to be ready
+// and returns it. Pods that are being deleted are ignored.
+func WaitForReadyPod(client *rancher.Client, namespace string, selector *metav1.LabelSelector) (*corev1.Pod, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var readyPod *corev1.Pod
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		podList, err := client.WranglerContext.Core.Pod().List(namespace, metav1.ListOptions{
+			LabelSelector: labelSelector.String(),
+		})
+		if err != nil {
+			return false, err
+		}
+
+		for i, pod := range podList.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+					readyPod = &podList.Items[i]
+					return true, nil
+				}
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("pod %s in namespace %s is not ready", labelSelector.String(), namespace), err)
+	}
+
+	return readyPod, nil
+}
+
+// ExecScript is a helper function that runs a shell script in a pod and returns its trimmed output. The output is
+// part of the returned error when the script fails.
+func ExecScript(restConfig *rest.Config, namespace, podName, script string) (string, error) {
+	output, err := kubeconfig.KubectlExec(restConfig, podName, namespace, []string{"sh", "-c", script})
+	if err != nil {
+		var out string
+		if output != nil {
+			out = output.String()
+		}
+
+		return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(out))
+	}
+
+	return strings.TrimSpace(strings.ReplaceAll(output.String(), "\r", "")), nil
+}
+
+// WriteFile is a helper function that writes a file inside a pod, creating its parent directories. The content is
+// sent base64 encoded in chunks, as exec only takes the command line.
+func WriteFile(restConfig *rest.Config, namespace, podName, filePath string, content []byte) error {
+	target := ShellQuote(filePath)
+	encodedTarget := ShellQuote(filePath + ".b64")
+
+	_, err := ExecScript(restConfig, namespace, podName, fmt.Sprintf("mkdir -p %s && : > %s", ShellQuote(path.Dir(filePath)), encodedTarget))
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	for start := 0; start < len(encoded); start += execChunkSize {
+		end := min(start+execChunkSize, len(encoded))
+
+		_, err = ExecScript(restConfig, namespace, podName, fmt.Sprintf("printf '%%s' %s >> %s", ShellQuote(encoded[start:end]), encodedTarget))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ExecScript(restConfig, namespace, podName, fmt.Sprintf("base64 -d %s > %s && rm %s", encodedTarget, target, encodedTarget))
+
+	return err
+}
+
+// ShellQuote is a helper function that single quotes a value for sh.
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
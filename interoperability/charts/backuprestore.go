@@ -2,6 +2,7 @@ package charts
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"time"
 
@@ -124,6 +125,11 @@ func InstallRancherBackupChart(client *rancher.Client, installOptions *charts.In
 		})
 	})
 
+	err = charts.ValidateChartInstallActionValues(catalogClient, catalog.RancherChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, catalog.RancherChartRepo)
 	if err != nil {
 		return err
@@ -149,7 +155,8 @@ func InstallRancherBackupChart(client *rancher.Client, installOptions *charts.In
 	if err != nil {
 		return err
 	}
-	return nil
+
+	return charts.VerifyChartImageSources(client, installOptions.Cluster.ID, backupChartNamespace, backupChartName)
 }
 
 // newBackupChartInstallAction is a private helper function that returns chart install action with backup and payload options.
@@ -157,16 +164,27 @@ func newBackupChartInstallAction(p *charts.PayloadOpts, withStorage bool, ranche
 	// If BRO is installed without any storage options selected, then only the basic chart install options are sent
 	backupValues := map[string]interface{}{}
 	if withStorage {
+		s3Values := map[string]any{
+			"bucketName":                rancherBackupOpts.BucketName,
+			"credentialSecretName":      rancherBackupOpts.CredentialSecretName,
+			"credentialSecretNamespace": rancherBackupOpts.CredentialSecretNamespace,
+			"enabled":                   rancherBackupOpts.Enabled,
+			"endpoint":                  rancherBackupOpts.Endpoint,
+			"folder":                    rancherBackupOpts.Folder,
+			"region":                    rancherBackupOpts.Region,
+		}
+
+		// The endpoint CA of self-signed S3 servers is sent base64 encoded, as the chart expects it
+		if rancherBackupOpts.EndpointCA != "" {
+			s3Values["endpointCA"] = base64.StdEncoding.EncodeToString([]byte(rancherBackupOpts.EndpointCA))
+		}
+
+		if rancherBackupOpts.InsecureTLSSkipVerify {
+			s3Values["insecureTLSSkipVerify"] = true
+		}
+
 		backupValues = map[string]any{
-			"s3": map[string]any{
-				"bucketName":                rancherBackupOpts.BucketName,
-				"credentialSecretName":      rancherBackupOpts.CredentialSecretName,
-				"credentialSecretNamespace": rancherBackupOpts.CredentialSecretNamespace,
-				"enabled":                   rancherBackupOpts.Enabled,
-				"endpoint":                  rancherBackupOpts.Endpoint,
-				"folder":                    rancherBackupOpts.Folder,
-				"region":                    rancherBackupOpts.Region,
-			},
+			"s3": s3Values,
 		}
 	}
 	chartInstall := charts.NewChartInstall(p.Name, p.InstallOptions.Version, p.InstallOptions.Cluster.ID, p.InstallOptions.Cluster.Name, p.Host, rancherChartsName, p.ProjectID, p.DefaultRegistry, backupValues)
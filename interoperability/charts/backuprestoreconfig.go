@@ -10,6 +10,8 @@ type BackupRestoreConfig struct {
 	S3FolderName              string `json:"s3FolderName" yaml:"s3FolderName"`
 	S3Region                  string `json:"s3Region" yaml:"s3Region"`
 	S3Endpoint                string `json:"s3Endpoint" yaml:"s3Endpoint"`
+	S3EndpointCA              string `json:"s3EndpointCA" yaml:"s3EndpointCA"`
+	S3SkipSSLVerify           bool   `json:"s3SkipSSLVerify" yaml:"s3SkipSSLVerify"`
 	VolumeName                string `json:"volumeName" yaml:"volumeName"`
 	CredentialSecretNamespace string `json:"credentialSecretNamespace" yaml:"credentialSecretNamespace"`
 	ResourceSetName           string `json:"resourceSetName" yaml:"resourceSetName"`
@@ -57,6 +57,11 @@ func InstallStackStateServerChart(client *rancher.Client, installOptions *charts
 		return err
 	}
 
+	err = charts.ValidateChartInstallActionValues(catalogClient, StackStateServerChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, StackStateServerChartRepo)
 	if err != nil {
 		log.Info("Error installing the StackState chart")
@@ -86,7 +91,8 @@ func InstallStackStateServerChart(client *rancher.Client, installOptions *charts
 		log.Info("Unable to obtain the status of the installed app ")
 		return err
 	}
-	return nil
+
+	return charts.VerifyChartImageSources(client, installOptions.Cluster.ID, StackStateServerNamespace, StackStateServerChartRepo)
 }
 
 // InstallStackstateAgentChart is a private helper function that returns chart install action with stack state agent and payload options.
@@ -188,6 +194,11 @@ func InstallStackstateAgentChart(client *rancher.Client, installOptions *charts.
 		})
 	})
 
+	err = charts.ValidateChartInstallActionValues(catalogClient, RancherPartnerChartRepo, chartInstallAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.InstallChart(chartInstallAction, RancherPartnerChartRepo)
 	if err != nil {
 		log.Info("Errored installing the chart")
@@ -218,7 +229,8 @@ func InstallStackstateAgentChart(client *rancher.Client, installOptions *charts.
 		log.Info("Unable to obtain the status of the installed app ")
 		return err
 	}
-	return nil
+
+	return charts.VerifyChartImageSources(client, installOptions.Cluster.ID, StackstateNamespace, StackstateK8sAgent)
 }
 
 // newStackstateAgentChartInstallAction is a helper function that returns an array of charts.NewChartInstallActions for installing the stackstate agent charts
@@ -272,6 +284,11 @@ func UpgradeStackstateAgentChart(client *rancher.Client, installOptions *charts.
 		return err
 	}
 
+	err = charts.ValidateChartUpgradeActionValues(catalogClient, RancherPartnerChartRepo, chartUpgradeAction)
+	if err != nil {
+		return err
+	}
+
 	err = catalogClient.UpgradeChart(chartUpgradeAction, RancherPartnerChartRepo)
 	if err != nil {
 		return err
@@ -0,0 +1,209 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	extensionsfleet "github.com/rancher/shepherd/extensions/fleet"
+	"github.com/sirupsen/logrus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	BundleDeploymentResourceSteveType = "fleet.cattle.io.bundledeployment"
+	BundleDeploymentModifiedState     = "Modified"
+	driftedValueSuffix                = "-drifted"
+)
+
+// DriftResources are the resources deployed by a GitRepo that are changed out-of-band to create drift, along with
+// the state they were deployed with by fleet.
+type DriftResources struct {
+	Namespace      string
+	DeploymentName string
+	Replicas       int32
+	ImageVersion   string
+	ConfigMapName  string
+	ConfigMapKey   string
+	ConfigMapValue string
+	ServiceName    string
+}
+
+// CreateDriftGitRepo is a helper function that creates a GitRepo with drift correction enabled or disabled and waits
+// for it to be deployed to the steve Cluster.
+func CreateDriftGitRepo(client *rancher.Client, gitRepo *v1alpha1.GitRepo, correctDrift bool, k8sClusterID, steveClusterID string) (*steveV1.SteveAPIObject, error) {
+	gitRepo.Spec.CorrectDrift = &v1alpha1.CorrectDrift{Enabled: correctDrift}
+
+	logrus.Infof("Creating gitRepo %s with drift correction set to %t", gitRepo.Name, correctDrift)
+	gitRepoObject, err := extensionsfleet.CreateFleetGitRepo(client, gitRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	err = VerifyGitRepo(client, gitRepoObject.ID, k8sClusterID, steveClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gitRepoObject, nil
+}
+
+// CreateDrift is a helper function that changes the deployed resources out-of-band. The deployment is scaled up, the
+// configmap value is edited and the service is deleted.
+func CreateDrift(client *rancher.Client, clusterID string, resources *DriftResources) error {
+	wranglerContext, err := client.WranglerContext.DownStreamClusterWranglerContext(clusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Scaling deployment %s/%s to %d replicas", resources.Namespace, resources.DeploymentName, resources.Replicas+1)
+	deployment, err := wranglerContext.Apps.Deployment().Get(resources.Namespace, resources.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	replicas := resources.Replicas + 1
+	deployment.Spec.Replicas = &replicas
+	_, err = wranglerContext.Apps.Deployment().Update(deployment)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Editing configmap %s/%s", resources.Namespace, resources.ConfigMapName)
+	configMap, err := wranglerContext.Core.ConfigMap().Get(resources.Namespace, resources.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	configMap.Data[resources.ConfigMapKey] = resources.ConfigMapValue + driftedValueSuffix
+	_, err = wranglerContext.Core.ConfigMap().Update(configMap)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Deleting service %s/%s", resources.Namespace, resources.ServiceName)
+
+	return wranglerContext.Core.Service().Delete(resources.Namespace, resources.ServiceName, &metav1.DeleteOptions{})
+}
+
+// WaitForBundleDeploymentsModified is a helper function that waits for every BundleDeployment of a GitRepo to report
+// whether its resources were modified. A BundleDeployment is modified when it lists modified resources or its display
+// state is modified; with drift correction enabled, fleet may revert the resources before it reports them, so only wait
+// for modified BundleDeployments when the drift is kept.
+func WaitForBundleDeploymentsModified(client *rancher.Client, gitRepoName string, modified bool) error {
+	query := url.Values{"labelSelector": {v1alpha1.RepoLabel + "=" + gitRepoName}}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), time.Second, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		bundleDeployments, err := client.Steve.SteveType(BundleDeploymentResourceSteveType).List(query)
+		if err != nil {
+			return false, err
+		}
+
+		if len(bundleDeployments.Data) == 0 {
+			return false, nil
+		}
+
+		for _, bundleDeployment := range bundleDeployments.Data {
+			status := &v1alpha1.BundleDeploymentStatus{}
+			err = steveV1.ConvertToK8sType(bundleDeployment.Status, status)
+			if err != nil {
+				return false, err
+			}
+
+			isModified := len(status.ModifiedStatus) > 0 || status.Display.State == BundleDeploymentModifiedState
+			if isModified != modified {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// VerifyDriftCorrected is a helper function that waits for fleet to revert every out-of-band change made by CreateDrift.
+func VerifyDriftCorrected(client *rancher.Client, clusterID string, resources *DriftResources) error {
+	var lastErr error
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		lastErr = checkDriftResources(client, clusterID, resources, false)
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("drift was not corrected: %w", lastErr)
+	}
+
+	imageVersion, err := GetDeploymentVersion(client, resources.Namespace+"/"+resources.DeploymentName, clusterID)
+	if err != nil {
+		return err
+	}
+
+	if imageVersion != resources.ImageVersion {
+		return fmt.Errorf("deployment %s/%s has image version %s, expected %s", resources.Namespace, resources.DeploymentName, imageVersion, resources.ImageVersion)
+	}
+
+	return nil
+}
+
+// VerifyDriftPersisted is a helper function that checks every out-of-band change made by CreateDrift is left alone by
+// fleet for the given duration, as expected when drift correction is disabled.
+func VerifyDriftPersisted(client *rancher.Client, clusterID string, resources *DriftResources, duration time.Duration) error {
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, duration, true, func(ctx context.Context) (done bool, err error) {
+		return false, checkDriftResources(client, clusterID, resources, true)
+	})
+	if kwait.Interrupted(err) {
+		return nil
+	}
+
+	return err
+}
+
+// checkDriftResources is a private helper function that checks the resources are either all drifted or all in the
+// state fleet deployed them with.
+func checkDriftResources(client *rancher.Client, clusterID string, resources *DriftResources, drifted bool) error {
+	wranglerContext, err := client.WranglerContext.DownStreamClusterWranglerContext(clusterID)
+	if err != nil {
+		return err
+	}
+
+	expectedReplicas := resources.Replicas
+	expectedValue := resources.ConfigMapValue
+	if drifted {
+		expectedReplicas++
+		expectedValue += driftedValueSuffix
+	}
+
+	deployment, err := wranglerContext.Apps.Deployment().Get(resources.Namespace, resources.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if *deployment.Spec.Replicas != expectedReplicas {
+		return fmt.Errorf("deployment %s/%s has %d replicas, expected %d", resources.Namespace, resources.DeploymentName, *deployment.Spec.Replicas, expectedReplicas)
+	}
+
+	configMap, err := wranglerContext.Core.ConfigMap().Get(resources.Namespace, resources.ConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if configMap.Data[resources.ConfigMapKey] != expectedValue {
+		return fmt.Errorf("configmap %s/%s has %s=%s, expected %s", resources.Namespace, resources.ConfigMapName, resources.ConfigMapKey, configMap.Data[resources.ConfigMapKey], expectedValue)
+	}
+
+	_, err = wranglerContext.Core.Service().Get(resources.Namespace, resources.ServiceName, metav1.GetOptions{})
+	if drifted && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("service %s/%s was recreated", resources.Namespace, resources.ServiceName)
+	}
+
+	if !drifted && err != nil {
+		return err
+	}
+
+	return nil
+}
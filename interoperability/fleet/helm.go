@@ -0,0 +1,63 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	FleetYAMLFile         = "fleet.yaml"
+	ChartVersionConfigKey = "chartVersion"
+)
+
+// HelmChartSource is the Helm chart a fleet.yaml deploys instead of the manifests of its repo. Chart is either an
+// oci:// reference, with no Repo, or the name of a chart in the Helm repo at Repo.
+type HelmChartSource struct {
+	Chart       string `yaml:"chart"`
+	Repo        string `yaml:"repo,omitempty"`
+	Version     string `yaml:"version"`
+	ReleaseName string `yaml:"releaseName,omitempty"`
+}
+
+// NewHelmFleetYAML is a helper function that returns a fleet.yaml deploying a chart from an OCI registry or a Helm repo.
+// The version is pinned, so upgrading the chart is done by committing a fleet.yaml with a new version.
+func NewHelmFleetYAML(source HelmChartSource) ([]byte, error) {
+	return yaml.Marshal(map[string]HelmChartSource{"helm": source})
+}
+
+// VerifyChartVersion is a helper function that waits for the chart version deployed in a cluster to match. The chart
+// must render a configmap with its version under the chartVersion key, like the fleet-test-chart fixture does.
+func VerifyChartVersion(client *rancher.Client, clusterID, namespace, configMapName, version string) error {
+	wranglerContext, err := client.WranglerContext.DownStreamClusterWranglerContext(clusterID)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Waiting for chart version %s to be deployed in namespace %s", version, namespace)
+	var deployedVersion string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		configMap, err := wranglerContext.Core.ConfigMap().Get(namespace, configMapName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		deployedVersion = configMap.Data[ChartVersionConfigKey]
+
+		return deployedVersion == version, nil
+	})
+	if err != nil {
+		return fmt.Errorf("expected chart version %s in configmap %s/%s, got %q: %w", version, namespace, configMapName, deployedVersion, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+//go:build (validation || infra.rke2k3s || cluster.any) && !stress && !extended && !sanity
+
+package chaos
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/cloudcredentials"
+	extClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/clusters/kubernetesversions"
+	shepherdsnapshot "github.com/rancher/shepherd/extensions/etcdsnapshot"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/leaderfailover"
+	"github.com/rancher/tests/actions/logging"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/qase"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LeaderFailoverTestSuite struct {
+	suite.Suite
+	session        *session.Session
+	client         *rancher.Client
+	cattleConfig   map[string]any
+	clusterConfig  *clusters.ClusterConfig
+	failover       leaderfailover.Failover
+	upgradeVersion string
+	rke2Cluster    *v1.SteveAPIObject
+}
+
+func (l *LeaderFailoverTestSuite) TearDownSuite() {
+	l.session.Cleanup()
+}
+
+func (l *LeaderFailoverTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	l.session = testSession
+
+	client, err := rancher.NewClient("", l.session)
+	require.NoError(l.T(), err)
+
+	l.client = client
+
+	l.cattleConfig = config.LoadConfigFromFile(os.Getenv(config.ConfigEnvironmentKey))
+
+	l.cattleConfig, err = defaults.LoadPackageDefaults(l.cattleConfig, "")
+	require.NoError(l.T(), err)
+
+	loggingConfig := new(logging.Logging)
+	operations.LoadObjectFromMap(logging.LoggingKey, l.cattleConfig, loggingConfig)
+
+	err = logging.SetLogger(loggingConfig)
+	require.NoError(l.T(), err)
+
+	failoverConfig := new(leaderfailover.Config)
+	operations.LoadObjectFromMap(leaderfailover.ConfigurationFileKey, l.cattleConfig, failoverConfig)
+	if !failoverConfig.Enabled {
+		l.T().Skip("Leader failover tests delete the Rancher leader pod and are not enabled, skipping the tests")
+	}
+
+	l.clusterConfig = new(clusters.ClusterConfig)
+	operations.LoadObjectFromMap(defaults.ClusterConfigKey, l.cattleConfig, l.clusterConfig)
+
+	versions, err := kubernetesversions.Default(l.client, extClusters.RKE2ClusterType.String(), nil)
+	require.NoError(l.T(), err)
+
+	l.upgradeVersion = versions[0]
+
+	if l.clusterConfig.KubernetesVersion == "" {
+		allVersions, err := kubernetesversions.ListRKE2AllVersions(l.client)
+		require.NoError(l.T(), err)
+
+		l.clusterConfig.KubernetesVersion, err = clusters.PreviousMinorVersion(allVersions, l.upgradeVersion)
+		require.NoError(l.T(), err)
+	}
+
+	l.failover = leaderfailover.Failover{Repeat: 2}
+}
+
+func (l *LeaderFailoverTestSuite) TestLeaderFailover() {
+	l.Run("RKE2_Leader_Failover_Provisioning", func() {
+		provider := provisioning.CreateProvider(l.clusterConfig.Provider)
+		credentialSpec := cloudcredentials.LoadCloudCredential(string(provider.Name))
+		machineConfigSpec := provider.LoadMachineConfigFunc(l.cattleConfig)
+
+		logrus.Info("Provisioning RKE2 cluster")
+		cluster, elections, err := leaderfailover.ProvisionCluster(l.client, l.failover, provider, credentialSpec, l.clusterConfig, machineConfigSpec)
+		require.NoError(l.T(), err)
+		require.NotEmpty(l.T(), elections)
+
+		l.rke2Cluster = cluster
+		l.verifyCluster()
+	})
+
+	l.Run("RKE2_Leader_Failover_Upgrade", func() {
+		require.NotNil(l.T(), l.rke2Cluster, "provisioning the cluster failed")
+
+		minorVersions, err := clusters.UpgradeMinorVersions(l.clusterConfig.KubernetesVersion, l.upgradeVersion)
+		if err != nil || minorVersions != 1 {
+			l.T().Skipf("Cluster %s on %s cannot be upgraded one minor version to %s", l.rke2Cluster.Name, l.clusterConfig.KubernetesVersion, l.upgradeVersion)
+		}
+
+		elections, err := leaderfailover.UpgradeCluster(l.client, l.failover, l.rke2Cluster.Name, l.upgradeVersion)
+		require.NoError(l.T(), err)
+		require.NotEmpty(l.T(), elections)
+
+		l.verifyCluster()
+	})
+
+	l.Run("RKE2_Leader_Failover_Snapshot_Restore", func() {
+		require.NotNil(l.T(), l.rke2Cluster, "provisioning the cluster failed")
+
+		snapshots, err := shepherdsnapshot.CreateRKE2K3SSnapshot(l.client, l.rke2Cluster.Name)
+		require.NoError(l.T(), err)
+		require.NotEmpty(l.T(), snapshots)
+
+		elections, err := leaderfailover.RestoreSnapshot(l.client, l.failover, l.rke2Cluster.Name, snapshots[0].ID)
+		require.NoError(l.T(), err)
+		require.NotEmpty(l.T(), elections)
+
+		l.verifyCluster()
+	})
+
+	params := provisioning.GetProvisioningSchemaParams(l.client, l.cattleConfig)
+	for _, name := range []string{"RKE2_Leader_Failover_Provisioning", "RKE2_Leader_Failover_Upgrade", "RKE2_Leader_Failover_Snapshot_Restore"} {
+		err := qase.UpdateSchemaParameters(name, params)
+		if err != nil {
+			logrus.Warningf("Failed to upload schema parameters %s", err)
+		}
+	}
+}
+
+// verifyCluster checks that the rancher leader failover left no duplicate machines or orphaned plans behind.
+func (l *LeaderFailoverTestSuite) verifyCluster() {
+	err := leaderfailover.VerifyNoDuplicateMachines(l.client, l.rke2Cluster.Name)
+	require.NoError(l.T(), err)
+
+	err = leaderfailover.VerifyNoOrphanedPlans(l.client, l.rke2Cluster.Name)
+	require.NoError(l.T(), err)
+}
+
+func TestLeaderFailoverTestSuite(t *testing.T) {
+	suite.Run(t, new(LeaderFailoverTestSuite))
+}
@@ -0,0 +1,301 @@
+//go:build (validation || infra.rke2k3s || cluster.any) && !stress && !extended && !sanity
+
+package chaos
+
+import (
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/sshkeys"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/nodes"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/connectivity"
+	clusterapi "github.com/rancher/tests/actions/kubeapi/clusters"
+	"github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/actions/nodes/faults"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	labelEtcd = "labelSelector=node-role.kubernetes.io/etcd=true"
+
+	faultRevertAfter = 3 * time.Minute
+	clockSkewOffset  = 10 * time.Minute
+	maxClockDrift    = time.Minute
+
+	faultWorkloadName     = "fault-workload"
+	faultWorkloadReplicas = 2
+)
+
+type NodeFaultsTestSuite struct {
+	suite.Suite
+	session    *session.Session
+	client     *rancher.Client
+	clusterID  string
+	workerName string
+	worker     *nodes.Node
+	etcdNode   *nodes.Node
+	peers      []string
+	namespace  string
+}
+
+func (n *NodeFaultsTestSuite) TearDownSuite() {
+	n.session.Cleanup()
+}
+
+func (n *NodeFaultsTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	n.session = testSession
+
+	client, err := rancher.NewClient("", n.session)
+	require.NoError(n.T(), err)
+
+	n.client = client
+
+	require.NotEmpty(n.T(), n.client.RancherConfig.ClusterName, "Cluster name to run the fault tests against is not set")
+
+	n.clusterID, err = extClusters.GetClusterIDByName(n.client, n.client.RancherConfig.ClusterName)
+	require.NoError(n.T(), err)
+
+	workers := n.listNodes(clusters.LabelWorker)
+	require.NotEmpty(n.T(), workers, "cluster %s has no worker node", n.client.RancherConfig.ClusterName)
+
+	etcdNodes := n.listNodes(labelEtcd)
+	require.NotEmpty(n.T(), etcdNodes, "cluster %s has no etcd node", n.client.RancherConfig.ClusterName)
+
+	n.workerName = workers[0].Name
+	n.worker, err = sshkeys.GetSSHNodeFromMachine(n.client, &workers[0])
+	require.NoError(n.T(), err)
+
+	n.etcdNode, err = sshkeys.GetSSHNodeFromMachine(n.client, &etcdNodes[0])
+	require.NoError(n.T(), err)
+
+	for _, node := range n.listNodes("") {
+		if node.Name == n.workerName {
+			continue
+		}
+
+		status := &corev1.NodeStatus{}
+		err = v1.ConvertToK8sType(node.Status, status)
+		require.NoError(n.T(), err)
+
+		for _, address := range status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				n.peers = append(n.peers, address.Address)
+			}
+		}
+	}
+
+	namespace, err := namespaces.CreateNamespace(n.client, n.clusterID, "", namegen.AppendRandomString("fault"), "", nil, nil)
+	require.NoError(n.T(), err)
+
+	n.namespace = namespace.Name
+
+	_, err = deployments.CreateDeployment(n.client, n.clusterID, faultWorkloadName, n.namespace, n.faultWorkloadTemplate(), faultWorkloadReplicas)
+	require.NoError(n.T(), err)
+}
+
+func (n *NodeFaultsTestSuite) TestNodeFaults() {
+	n.Run("Reboot", func() {
+		n.placeWorkloadOnWorker()
+
+		_, err := faults.Inject(n.worker, faults.Reboot(), faultRevertAfter)
+		require.NoError(n.T(), err)
+
+		err = faults.WaitForNodeReady(n.client, n.clusterID, n.workerName, false)
+		require.NoError(n.T(), err)
+
+		n.verifyRecovery(true)
+	})
+
+	n.Run("Service_Stop", func() {
+		n.placeWorkloadOnWorker()
+
+		service, err := faults.ClusterService(n.worker)
+		require.NoError(n.T(), err)
+
+		injection, err := faults.Inject(n.worker, faults.ServiceStop(service), faultRevertAfter)
+		require.NoError(n.T(), err)
+
+		err = faults.WaitForNodeReady(n.client, n.clusterID, n.workerName, false)
+		require.NoError(n.T(), err)
+
+		err = injection.WaitForRevert()
+		require.NoError(n.T(), err)
+
+		n.verifyRecovery(true)
+	})
+
+	n.Run("Network_Partition", func() {
+		if len(n.peers) == 0 {
+			n.T().Skip("Network partition test requires a node other than the worker")
+		}
+
+		n.placeWorkloadOnWorker()
+
+		injection, err := faults.Inject(n.worker, faults.NetworkPartition(n.peers...), faultRevertAfter)
+		require.NoError(n.T(), err)
+
+		err = faults.WaitForNodeReady(n.client, n.clusterID, n.workerName, false)
+		require.NoError(n.T(), err)
+
+		err = injection.WaitForRevert()
+		require.NoError(n.T(), err)
+
+		n.verifyRecovery(true)
+	})
+
+	n.Run("Disk_Fill", func() {
+		n.placeWorkloadOnWorker()
+
+		injection, err := faults.Inject(n.worker, faults.DiskFill(faults.DefaultFillPath, faults.DefaultKeepFreePercent), faultRevertAfter)
+		require.NoError(n.T(), err)
+
+		err = faults.WaitForNodeCondition(n.client, n.clusterID, n.workerName, corev1.NodeDiskPressure, true)
+		require.NoError(n.T(), err)
+
+		err = injection.WaitForRevert()
+		require.NoError(n.T(), err)
+
+		n.verifyRecovery(true)
+	})
+
+	n.Run("Clock_Skew", func() {
+		injection, err := faults.Inject(n.worker, faults.ClockSkew(clockSkewOffset), faultRevertAfter)
+		require.NoError(n.T(), err)
+
+		require.Greater(n.T(), n.clockDrift(), maxClockDrift, "clock of node %s was not skewed", n.workerName)
+
+		err = injection.Revert()
+		require.NoError(n.T(), err)
+
+		err = injection.Revert()
+		require.NoError(n.T(), err)
+
+		require.Less(n.T(), n.clockDrift(), maxClockDrift, "clock of node %s was not reverted once", n.workerName)
+
+		n.verifyRecovery(false)
+	})
+}
+
+// listNodes returns the nodes of the cluster matching a label selector query, or all of them when it is empty.
+func (n *NodeFaultsTestSuite) listNodes(labelSelector string) []v1.SteveAPIObject {
+	steveClient, err := n.client.Steve.ProxyDownstream(n.clusterID)
+	require.NoError(n.T(), err)
+
+	query, err := url.ParseQuery(labelSelector)
+	require.NoError(n.T(), err)
+
+	nodeList, err := steveClient.SteveType("node").List(query)
+	require.NoError(n.T(), err)
+
+	return nodeList.Data
+}
+
+// clockDrift returns how far the clock of the worker is from the clock of the test runner.
+func (n *NodeFaultsTestSuite) clockDrift() time.Duration {
+	output, err := n.worker.ExecuteCommand("date +%s")
+	require.NoError(n.T(), err)
+
+	seconds, err := strconv.ParseInt(strings.TrimSpace(output), 10, 64)
+	require.NoError(n.T(), err)
+
+	return time.Duration(math.Abs(float64(seconds-time.Now().Unix()))) * time.Second
+}
+
+// faultWorkloadTemplate returns the pod template of the workload of the suite. Its pods prefer the worker and are
+// evicted as soon as a node is not ready or unreachable, instead of after the default five minutes.
+func (n *NodeFaultsTestSuite) faultWorkloadTemplate() corev1.PodTemplateSpec {
+	podSpec := connectivity.NewProbePod(n.namespace, "", connectivity.GetProbeImage()).Spec
+
+	podSpec.Affinity = &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{
+				Weight: 100,
+				Preference: corev1.NodeSelectorTerm{
+					MatchExpressions: []corev1.NodeSelectorRequirement{{
+						Key:      corev1.LabelHostname,
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{n.workerName},
+					}},
+				},
+			}},
+		},
+	}
+
+	tolerationSeconds := int64(0)
+	for _, taint := range []string{corev1.TaintNodeNotReady, corev1.TaintNodeUnreachable} {
+		podSpec.Tolerations = append(podSpec.Tolerations, corev1.Toleration{
+			Key:               taint,
+			Operator:          corev1.TolerationOpExists,
+			Effect:            corev1.TaintEffectNoExecute,
+			TolerationSeconds: &tolerationSeconds,
+		})
+	}
+
+	return corev1.PodTemplateSpec{Spec: podSpec}
+}
+
+// placeWorkloadOnWorker recreates the pods of the workload of the suite, so they run on the worker again before a
+// fault that should evict them.
+func (n *NodeFaultsTestSuite) placeWorkloadOnWorker() {
+	wranglerContext, err := clusterapi.GetClusterWranglerContext(n.client, n.clusterID)
+	require.NoError(n.T(), err)
+
+	podList, err := wranglerContext.Core.Pod().List(n.namespace, metav1.ListOptions{})
+	require.NoError(n.T(), err)
+
+	for _, pod := range podList.Items {
+		err = wranglerContext.Core.Pod().Delete(n.namespace, pod.Name, &metav1.DeleteOptions{})
+		require.NoError(n.T(), err)
+	}
+
+	err = deployments.WatchAndWaitDeployments(n.client, n.clusterID, n.namespace, metav1.ListOptions{})
+	require.NoError(n.T(), err)
+
+	podList, err = wranglerContext.Core.Pod().List(n.namespace, metav1.ListOptions{})
+	require.NoError(n.T(), err)
+
+	onWorker := false
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName == n.workerName && pod.DeletionTimestamp == nil {
+			onWorker = true
+		}
+	}
+
+	require.True(n.T(), onWorker, "no pod of the workload was scheduled on node %s", n.workerName)
+}
+
+// verifyRecovery checks that the worker is ready again and that etcd has all of its members. When the fault evicted
+// the pods of the worker, it also checks the workload of the suite was rescheduled off the worker.
+func (n *NodeFaultsTestSuite) verifyRecovery(evicted bool) {
+	logrus.Infof("Verifying node %s recovered", n.workerName)
+	err := faults.WaitForNodeReady(n.client, n.clusterID, n.workerName, true)
+	require.NoError(n.T(), err)
+
+	err = faults.VerifyEtcdMembership(n.client, n.clusterID, n.etcdNode)
+	require.NoError(n.T(), err)
+
+	if evicted {
+		err = faults.WaitForPodsRescheduled(n.client, n.clusterID, n.namespace, n.workerName)
+		require.NoError(n.T(), err)
+	}
+}
+
+func TestNodeFaultsTestSuite(t *testing.T) {
+	suite.Run(t, new(NodeFaultsTestSuite))
+}
@@ -2,17 +2,21 @@ package backup_restore
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
 
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/rancher/shepherd/extensions/clusters/kubernetesversions"
 	actionscharts "github.com/rancher/tests/actions/charts"
 	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/s3server"
 	"github.com/rancher/tests/actions/secrets"
 	"github.com/rancher/tests/actions/workloads/pods"
 	"github.com/rancher/tests/interoperability/charts"
@@ -32,6 +36,7 @@ import (
 	"github.com/rancher/shepherd/clients/rancher/catalog"
 	management "github.com/rancher/shepherd/clients/rancher/generated/management/v3"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/pkg/clientbase"
 	"github.com/rancher/shepherd/pkg/config"
 	namegen "github.com/rancher/shepherd/pkg/namegenerator"
 	corev1 "k8s.io/api/core/v1"
@@ -39,9 +44,8 @@ import (
 )
 
 var (
-	backupName          = namegen.AppendRandomString("backup")
-	secretName          = namegen.AppendRandomString("bro-secret")
-	backupRestoreConfig = new(charts.BackupRestoreConfig)
+	backupName = namegen.AppendRandomString("backup")
+	secretName = namegen.AppendRandomString("bro-secret")
 
 	rules = []management.PolicyRule{
 		{
@@ -60,11 +64,11 @@ const (
 	resourceCount    = 2
 	cniCalico        = "calico"
 	provider         = "aws"
+
+	awsEndpointSuffix = "amazonaws.com"
 )
 
 func setBackupObject(broConfig *charts.BackupRestoreConfig) bv1.Backup {
-	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, broConfig)
-
 	backup := bv1.Backup{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: backupName,
@@ -77,8 +81,6 @@ func setBackupObject(broConfig *charts.BackupRestoreConfig) bv1.Backup {
 }
 
 func setRestoreObject(broConfig *charts.BackupRestoreConfig) bv1.Restore {
-	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, broConfig)
-
 	restore := bv1.Restore{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: "restore-",
@@ -91,7 +93,7 @@ func setRestoreObject(broConfig *charts.BackupRestoreConfig) bv1.Restore {
 	return restore
 }
 
-func installBroChart(client *rancher.Client) error {
+func installBroChart(client *rancher.Client, broConfig *charts.BackupRestoreConfig) error {
 	project, err := projects.GetProjectByName(client, cluster, "System")
 	if err != nil {
 		return err
@@ -107,25 +109,25 @@ func installBroChart(client *rancher.Client) error {
 		return err
 	}
 
-	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, backupRestoreConfig)
-
 	chartInstallOptions := actionscharts.InstallOptions{
 		Cluster:   cluster,
 		Version:   latestBackupVersion,
 		ProjectID: project.ID,
 	}
 	chartFeatureOptions := &actionscharts.RancherBackupOpts{
-		VolumeName:                backupRestoreConfig.VolumeName,
-		BucketName:                backupRestoreConfig.S3BucketName,
+		VolumeName:                broConfig.VolumeName,
+		BucketName:                broConfig.S3BucketName,
 		CredentialSecretName:      secretName,
-		CredentialSecretNamespace: backupRestoreConfig.CredentialSecretNamespace,
+		CredentialSecretNamespace: broConfig.CredentialSecretNamespace,
 		Enabled:                   backupEnabled,
-		Endpoint:                  backupRestoreConfig.S3Endpoint,
-		Folder:                    backupRestoreConfig.S3FolderName,
-		Region:                    backupRestoreConfig.S3Region,
+		Endpoint:                  broConfig.S3Endpoint,
+		Folder:                    broConfig.S3FolderName,
+		Region:                    broConfig.S3Region,
+		EndpointCA:                broConfig.S3EndpointCA,
+		InsecureTLSSkipVerify:     broConfig.S3SkipSSLVerify,
 	}
 
-	_, err = createOpaqueS3Secret(client.Steve)
+	_, err = createOpaqueS3Secret(client.Steve, broConfig)
 	if err != nil {
 		return err
 	}
@@ -145,11 +147,9 @@ func installBroChart(client *rancher.Client) error {
 	return err
 }
 
-func createOpaqueS3Secret(steveClient *v1.Client) (string, error) {
-	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, backupRestoreConfig)
-
+func createOpaqueS3Secret(steveClient *v1.Client, broConfig *charts.BackupRestoreConfig) (string, error) {
 	logrus.Infof("Creating an opaque secret with name: %v", secretName)
-	secretTemplate := secrets.NewSecretTemplate(secretName, backupRestoreConfig.CredentialSecretNamespace, map[string][]byte{"accessKey": []byte(backupRestoreConfig.AccessKey), "secretKey": []byte(backupRestoreConfig.SecretKey)}, corev1.SecretTypeOpaque, nil, nil)
+	secretTemplate := secrets.NewSecretTemplate(secretName, broConfig.CredentialSecretNamespace, map[string][]byte{"accessKey": []byte(broConfig.AccessKey), "secretKey": []byte(broConfig.SecretKey)}, corev1.SecretTypeOpaque, nil, nil)
 	createdSecret, err := steveClient.SteveType(secrets.SecretSteveType).Create(secretTemplate)
 	if err != nil {
 		return "", err
@@ -191,7 +191,7 @@ func createRancherResources(client *rancher.Client, clusterID string, context st
 	return userList, projList, roleList, nil
 }
 
-func createAndValidateBackup(client *rancher.Client, bucket string, config *charts.BackupRestoreConfig) (*v1.SteveAPIObject, string, error) {
+func createAndValidateBackup(client *rancher.Client, config *charts.BackupRestoreConfig) (*v1.SteveAPIObject, string, error) {
 	backup := setBackupObject(config)
 	backupTemplate := bv1.NewBackup("", backupName, backup)
 	completedBackup, err := client.Steve.SteveType(backupSteveType).Create(backupTemplate)
@@ -215,12 +215,12 @@ func createAndValidateBackup(client *rancher.Client, bucket string, config *char
 	backupFileName := backupK8Obj.Status.Filename
 
 	client.Session.RegisterCleanupFunc(func() error {
-		err = deleteAWSObject(bucket, backupFileName)
+		err = deleteS3Object(config, backupFileName)
 		if err != nil {
 			return err
 		}
 
-		_, err = checkAWSS3Object(bucket, backupFileName)
+		_, err = checkS3Object(config, backupFileName)
 		if err != nil {
 			s3Error := err.Error()
 			errorText := strings.Contains(s3Error, "404")
@@ -327,16 +327,73 @@ func createRKE2dsCluster(t *testing.T, client *rancher.Client) (*v1.SteveAPIObje
 	return steveObject, testClusterConfig, nil
 }
 
-func checkAWSS3Object(bucket string, key string) (bool, error) {
-	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO())
+// useS3Server is a helper function that points the backup storage location of the config to the bucket of an S3
+// server deployed by the test, for runs without an S3 bucket of their own.
+func useS3Server(broConfig *charts.BackupRestoreConfig, s3Server *s3server.S3Server) {
+	broConfig.S3BucketName = s3Server.Bucket
+	broConfig.S3Endpoint = s3Server.Endpoint
+	broConfig.S3Region = s3Server.Region
+	broConfig.AccessKey = s3Server.AccessKey
+	broConfig.SecretKey = s3Server.SecretKey
+	broConfig.S3SkipSSLVerify = s3Server.SkipSSLVerify
+	if !s3Server.SkipSSLVerify {
+		broConfig.S3EndpointCA = string(s3Server.CACert)
+	}
+}
+
+// newS3Client is a helper function that returns an S3 client of the backup storage location. Endpoints other than AWS,
+// e.g. a MinIO server, are reached with path-style requests, trusting the endpoint CA of the config.
+func newS3Client(broConfig *charts.BackupRestoreConfig) (*s3.Client, error) {
+	var loadOptions []func(*awsConfig.LoadOptions) error
+	if broConfig.S3Region != "" {
+		loadOptions = append(loadOptions, awsConfig.WithRegion(broConfig.S3Region))
+	}
+
+	if broConfig.AccessKey != "" {
+		loadOptions = append(loadOptions, awsConfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(broConfig.AccessKey, broConfig.SecretKey, "")))
+	}
+
+	if broConfig.S3EndpointCA != "" {
+		loadOptions = append(loadOptions, awsConfig.WithCustomCABundle(strings.NewReader(broConfig.S3EndpointCA)))
+	}
+
+	if broConfig.S3SkipSSLVerify {
+		loadOptions = append(loadOptions, awsConfig.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}))
+	}
+
+	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO(), loadOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewFromConfig(sdkConfig, func(options *s3.Options) {
+		if broConfig.S3Endpoint != "" && !strings.HasSuffix(broConfig.S3Endpoint, awsEndpointSuffix) {
+			options.BaseEndpoint = aws.String("https://" + broConfig.S3Endpoint)
+			options.UsePathStyle = true
+		}
+	}), nil
+}
+
+// s3ObjectKey is a helper function that returns the key of a backup file in the folder of the backup storage location.
+func s3ObjectKey(broConfig *charts.BackupRestoreConfig, backupFileName string) string {
+	if broConfig.S3FolderName == "" {
+		return backupFileName
+	}
+
+	return broConfig.S3FolderName + "/" + backupFileName
+}
+
+func checkS3Object(broConfig *charts.BackupRestoreConfig, backupFileName string) (bool, error) {
+	s3Client, err := newS3Client(broConfig)
 	if err != nil {
 		return false, err
 	}
 
-	s3Client := s3.NewFromConfig(sdkConfig)
 	_, err = s3Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket: aws.String(broConfig.S3BucketName),
+		Key:    aws.String(s3ObjectKey(broConfig, backupFileName)),
 	})
 
 	if err != nil {
@@ -345,16 +402,15 @@ func checkAWSS3Object(bucket string, key string) (bool, error) {
 	return true, nil
 }
 
-func deleteAWSObject(bucket string, key string) error {
-	sdkConfig, err := awsConfig.LoadDefaultConfig(context.TODO())
+func deleteS3Object(broConfig *charts.BackupRestoreConfig, backupFileName string) error {
+	svc, err := newS3Client(broConfig)
 	if err != nil {
 		return err
 	}
-	svc := s3.NewFromConfig(sdkConfig)
 
 	input := &s3.DeleteObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+		Bucket: aws.String(broConfig.S3BucketName),
+		Key:    aws.String(s3ObjectKey(broConfig, backupFileName)),
 	}
 
 	_, err = svc.DeleteObject(context.TODO(), input)
@@ -396,3 +452,34 @@ func verifyRancherResources(client *rancher.Client, curUserList []*management.Us
 
 	return errors.Join(errs...)
 }
+
+// verifyRancherResourcesRemoved checks that every one of the given users, projects and role templates no longer exists
+func verifyRancherResourcesRemoved(client *rancher.Client, userList []*management.User, projList []*management.Project, roleList []*management.RoleTemplate) error {
+	var errs []error
+
+	logrus.Info("Verifying user resources were removed...")
+	for _, user := range userList {
+		_, err := client.Management.User.ByID(user.ID)
+		if !clientbase.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("user %s was not removed: %v", user.ID, err))
+		}
+	}
+
+	logrus.Info("Verifying project resources were removed...")
+	for _, proj := range projList {
+		_, err := client.Management.Project.ByID(proj.ID)
+		if !clientbase.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("project %s was not removed: %v", proj.ID, err))
+		}
+	}
+
+	logrus.Info("Verifying role resources were removed...")
+	for _, role := range roleList {
+		_, err := client.Management.RoleTemplate.ByID(role.ID)
+		if !clientbase.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("role %s was not removed: %v", role.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
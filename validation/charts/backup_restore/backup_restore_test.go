@@ -15,6 +15,7 @@ import (
 	"github.com/rancher/shepherd/pkg/session"
 	"github.com/rancher/tests/actions/projects"
 	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/s3server"
 	"github.com/rancher/tests/actions/workloads/pods"
 	"github.com/rancher/tests/interoperability/charts"
 	"github.com/stretchr/testify/assert"
@@ -45,7 +46,15 @@ func (b *BackupTestSuite) SetupSuite() {
 	subSession := b.session.NewSession()
 	defer subSession.Cleanup()
 
-	b.broConfig = backupRestoreConfig
+	b.broConfig = new(charts.BackupRestoreConfig)
+	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, b.broConfig)
+	if b.broConfig.S3BucketName == "" {
+		logrus.Info("No S3 bucket is provided, deploying a local S3 server")
+		s3Server, err := s3server.DeployS3Server(b.client, s3server.LoadConfig())
+		require.NoError(b.T(), err)
+
+		useS3Server(b.broConfig, s3Server)
+	}
 }
 
 func (b *BackupTestSuite) TestS3InPlaceRestore() {
@@ -55,14 +64,13 @@ func (b *BackupTestSuite) TestS3InPlaceRestore() {
 	project, err := projects.GetProjectByName(b.client, cluster, "System")
 	require.NoError(b.T(), err)
 
-	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, backupRestoreConfig)
-
 	logrus.Info("Checking if the backup chart is already installed...")
 	initialBackupChart, err := shepCharts.GetChartStatus(b.client, project.ClusterID, "cattle-resources-system", "rancher-backup")
 	require.NoError(b.T(), err)
 
 	if !initialBackupChart.IsAlreadyInstalled {
-		installBroChart(b.client)
+		err = installBroChart(b.client, b.broConfig)
+		require.NoError(b.T(), err)
 	}
 
 	b.client, err = b.client.ReLogin()
@@ -81,12 +89,11 @@ func (b *BackupTestSuite) TestS3InPlaceRestore() {
 	require.NoError(b.T(), err)
 
 	logrus.Info("Creating a backup of the local cluster...")
-	s3StorageLocation := backupRestoreConfig.S3BucketName + "/" + backupRestoreConfig.S3FolderName
-	_, backupFileName, err := createAndValidateBackup(b.client, s3StorageLocation, b.broConfig)
+	_, backupFileName, err := createAndValidateBackup(b.client, b.broConfig)
 	require.NoError(b.T(), err)
 
-	logrus.Info("Validating backup file is in AWS S3...")
-	backupPresent, err := checkAWSS3Object(s3StorageLocation, backupFileName)
+	logrus.Info("Validating backup file is in S3...")
+	backupPresent, err := checkS3Object(b.broConfig, backupFileName)
 	require.NoError(b.T(), err)
 	assert.True(b.T(), backupPresent)
 
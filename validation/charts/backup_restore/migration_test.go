@@ -0,0 +1,162 @@
+//go:build (validation || infra.any || cluster.any || extended) && !sanity && !stress
+
+package backup_restore
+
+import (
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	shepCharts "github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/extensions/cloudcredentials"
+	"github.com/rancher/shepherd/extensions/cloudcredentials/aws"
+	"github.com/rancher/shepherd/pkg/config"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/backuprestore"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/rbac"
+	"github.com/rancher/tests/actions/s3server"
+	"github.com/rancher/tests/interoperability/charts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationTestSuite struct {
+	suite.Suite
+	client          *rancher.Client
+	session         *session.Session
+	migrationConfig *backuprestore.MigrationConfig
+	broConfig       *charts.BackupRestoreConfig
+}
+
+func (m *MigrationTestSuite) TearDownSuite() {
+	m.session.Cleanup()
+}
+
+func (m *MigrationTestSuite) SetupSuite() {
+	m.session = session.NewSession()
+
+	client, err := rancher.NewClient("", m.session)
+	require.NoError(m.T(), err)
+
+	m.client = client
+
+	m.migrationConfig = new(backuprestore.MigrationConfig)
+	config.LoadConfig(backuprestore.MigrationConfigurationFileKey, m.migrationConfig)
+
+	m.broConfig = new(charts.BackupRestoreConfig)
+	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, m.broConfig)
+
+	if m.migrationConfig.ResourceSetName == "" {
+		m.migrationConfig.ResourceSetName = m.broConfig.ResourceSetName
+	}
+	m.migrationConfig.SetDefaults()
+
+	if m.migrationConfig.TargetKubeconfig != "" && m.broConfig.S3BucketName == "" {
+		logrus.Info("No S3 bucket is provided, deploying a local S3 server")
+		s3Server, err := s3server.DeployS3Server(m.client, s3server.LoadConfig())
+		require.NoError(m.T(), err)
+
+		useS3Server(m.broConfig, s3Server)
+	}
+}
+
+func (m *MigrationTestSuite) TestS3Migration() {
+	if m.migrationConfig.TargetKubeconfig == "" || m.migrationConfig.Hostname == "" {
+		m.T().Skip("Target cluster kubeconfig and hostname are not provided, skipping the test")
+	}
+
+	project, err := projects.GetProjectByName(m.client, cluster, "System")
+	require.NoError(m.T(), err)
+
+	logrus.Info("Checking if the backup chart is already installed...")
+	initialBackupChart, err := shepCharts.GetChartStatus(m.client, project.ClusterID, "cattle-resources-system", "rancher-backup")
+	require.NoError(m.T(), err)
+
+	if !initialBackupChart.IsAlreadyInstalled {
+		err = installBroChart(m.client, m.broConfig)
+		require.NoError(m.T(), err)
+	}
+
+	m.client, err = m.client.ReLogin()
+	require.NoError(m.T(), err)
+
+	logrus.Info("Creating two users, projects, and role templates...")
+	userList, projList, roleList, err := createRancherResources(m.client, project.ClusterID, "cluster")
+	require.NoError(m.T(), err)
+
+	logrus.Info("Creating cluster and project role template bindings...")
+	_, err = rbac.CreateClusterRoleTemplateBinding(m.client, project.ClusterID, userList[0], rbac.ClusterMember.String())
+	require.NoError(m.T(), err)
+
+	bindingProject, _, err := projects.CreateProjectAndNamespaceUsingWrangler(m.client, project.ClusterID)
+	require.NoError(m.T(), err)
+
+	_, err = rbac.CreateProjectRoleTemplateBinding(m.client, userList[1], bindingProject, rbac.ProjectMember.String())
+	require.NoError(m.T(), err)
+
+	logrus.Info("Creating a cloud credential...")
+	_, err = aws.CreateAWSCloudCredentials(m.client, cloudcredentials.CloudCredential{
+		AmazonEC2CredentialConfig: &cloudcredentials.AmazonEC2CredentialConfig{
+			AccessKey:     namegen.RandStringLower(20),
+			SecretKey:     namegen.RandStringLower(40),
+			DefaultRegion: m.broConfig.S3Region,
+		},
+	})
+	require.NoError(m.T(), err)
+
+	logrus.Info("Capturing the Rancher state before the migration...")
+	migrationState, err := backuprestore.CaptureMigrationState(m.client)
+	require.NoError(m.T(), err)
+
+	sourceRestConfig, err := backuprestore.LocalClusterConfig()
+	require.NoError(m.T(), err)
+
+	var encryptionConfig []byte
+	var encryptionConfigSecretName string
+	if m.migrationConfig.EncryptBackup {
+		logrus.Info("Creating an encryption config secret...")
+		encryptionConfig, err = backuprestore.NewEncryptionConfig()
+		require.NoError(m.T(), err)
+
+		encryptionConfigSecretName = namegen.AppendRandomString("encryption-config")
+		err = backuprestore.CreateEncryptionConfigSecret(sourceRestConfig, encryptionConfigSecretName, encryptionConfig)
+		require.NoError(m.T(), err)
+	}
+
+	logrus.Info("Creating a backup of the local cluster...")
+	backupFilename, err := backuprestore.CreateBackup(sourceRestConfig, m.migrationConfig.ResourceSetName, encryptionConfigSecretName)
+	require.NoError(m.T(), err)
+
+	targetRestConfig, err := backuprestore.TargetClusterConfig(m.migrationConfig)
+	require.NoError(m.T(), err)
+
+	logrus.Infof("Migrating Rancher to the target cluster using backup file: %v", backupFilename)
+	err = backuprestore.MigrateRancher(targetRestConfig, m.migrationConfig, backupFilename, &backuprestore.S3Location{
+		BucketName:            m.broConfig.S3BucketName,
+		Folder:                m.broConfig.S3FolderName,
+		Region:                m.broConfig.S3Region,
+		Endpoint:              m.broConfig.S3Endpoint,
+		EndpointCA:            m.broConfig.S3EndpointCA,
+		InsecureTLSSkipVerify: m.broConfig.S3SkipSSLVerify,
+		AccessKey:             m.broConfig.AccessKey,
+		SecretKey:             m.broConfig.SecretKey,
+	}, encryptionConfig)
+	require.NoError(m.T(), err)
+
+	migratedClient, err := backuprestore.NewTargetClient(m.client, m.migrationConfig)
+	require.NoError(m.T(), err)
+
+	logrus.Info("Validating Rancher resources on the migrated server...")
+	err = verifyRancherResources(migratedClient, userList, projList, roleList)
+	require.NoError(m.T(), err)
+
+	logrus.Info("Validating role bindings, cloud credentials, and downstream clusters on the migrated server...")
+	err = backuprestore.VerifyMigrationState(migratedClient, migrationState)
+	require.NoError(m.T(), err)
+}
+
+func TestMigrationTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationTestSuite))
+}
@@ -0,0 +1,114 @@
+//go:build (validation || infra.any || cluster.any || extended) && !sanity && !stress
+
+package backup_restore
+
+import (
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	shepCharts "github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/backuprestore"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/interoperability/charts"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RollbackTestSuite struct {
+	suite.Suite
+	client         *rancher.Client
+	session        *session.Session
+	rollbackConfig *backuprestore.Config
+	broConfig      *charts.BackupRestoreConfig
+}
+
+func (r *RollbackTestSuite) TearDownSuite() {
+	r.session.Cleanup()
+}
+
+func (r *RollbackTestSuite) SetupSuite() {
+	r.session = session.NewSession()
+
+	client, err := rancher.NewClient("", r.session)
+	require.NoError(r.T(), err)
+
+	r.client = client
+
+	r.rollbackConfig = new(backuprestore.Config)
+	config.LoadConfig(backuprestore.ConfigurationFileKey, r.rollbackConfig)
+	r.rollbackConfig.SetDefaults()
+
+	r.broConfig = new(charts.BackupRestoreConfig)
+	config.LoadConfig(charts.BackupRestoreConfigurationFileKey, r.broConfig)
+}
+
+func (r *RollbackTestSuite) TestUpgradeRollback() {
+	if r.rollbackConfig.ChartRepo == "" || r.rollbackConfig.ChartVersion == "" {
+		r.T().Skip("Chart repo and version to upgrade to are not provided, skipping the test")
+	}
+
+	project, err := projects.GetProjectByName(r.client, cluster, "System")
+	require.NoError(r.T(), err)
+
+	logrus.Info("Checking if the backup chart is already installed...")
+	initialBackupChart, err := shepCharts.GetChartStatus(r.client, project.ClusterID, "cattle-resources-system", "rancher-backup")
+	require.NoError(r.T(), err)
+
+	if !initialBackupChart.IsAlreadyInstalled {
+		err = installBroChart(r.client, r.broConfig)
+		require.NoError(r.T(), err)
+	}
+
+	r.client, err = r.client.ReLogin()
+	require.NoError(r.T(), err)
+
+	logrus.Info("Creating two users, projects, and role templates before the upgrade...")
+	userList, projList, roleList, err := createRancherResources(r.client, project.ClusterID, "cluster")
+	require.NoError(r.T(), err)
+
+	logrus.Info("Capturing the state of the downstream clusters...")
+	clusterStates, err := backuprestore.CaptureClusterStates(r.client)
+	require.NoError(r.T(), err)
+
+	restConfig, err := backuprestore.LocalClusterConfig()
+	require.NoError(r.T(), err)
+
+	logrus.Info("Creating a backup of the local cluster...")
+	backupFilename, err := backuprestore.CreateBackup(restConfig, r.rollbackConfig.ResourceSetName, r.rollbackConfig.EncryptionConfigSecretName)
+	require.NoError(r.T(), err)
+
+	err = backuprestore.UpgradeRancher(r.rollbackConfig)
+	require.NoError(r.T(), err)
+
+	r.client, err = r.client.ReLogin()
+	require.NoError(r.T(), err)
+
+	logrus.Info("Creating two more users, projects, and role templates after the upgrade...")
+	userListPostUpgrade, projListPostUpgrade, roleListPostUpgrade, err := createRancherResources(r.client, project.ClusterID, "cluster")
+	require.NoError(r.T(), err)
+
+	logrus.Infof("Rolling back Rancher using backup file: %v", backupFilename)
+	err = backuprestore.RollbackRancher(r.client, restConfig, r.rollbackConfig, backupFilename)
+	require.NoError(r.T(), err)
+
+	r.client, err = r.client.ReLogin()
+	require.NoError(r.T(), err)
+
+	logrus.Info("Validating Rancher resources...")
+	err = verifyRancherResources(r.client, userList, projList, roleList)
+	require.NoError(r.T(), err)
+
+	err = verifyRancherResourcesRemoved(r.client, userListPostUpgrade, projListPostUpgrade, roleListPostUpgrade)
+	require.NoError(r.T(), err)
+
+	logrus.Info("Validating downstream clusters are back to their pre-upgrade state...")
+	err = backuprestore.VerifyClusterStates(r.client, clusterStates)
+	require.NoError(r.T(), err)
+}
+
+func TestRollbackTestSuite(t *testing.T) {
+	suite.Run(t, new(RollbackTestSuite))
+}
@@ -0,0 +1,523 @@
+package charts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// GatekeeperPolicyConfigurationFileKey is used to parse the configuration of the gatekeeper policy library tests.
+	GatekeeperPolicyConfigurationFileKey = "gatekeeperPolicyInput"
+	// defaultGatekeeperPolicyLibraryPath is the policy library shipped with this repo, used when none is configured
+	defaultGatekeeperPolicyLibraryPath = "./resources/gatekeeper-library"
+
+	gatekeeperTemplateFileName   = "template.yaml"
+	gatekeeperConstraintFileName = "constraint.yaml"
+	gatekeeperSamplesDirName     = "samples"
+	gatekeeperAllowedPrefix      = "example_allowed"
+	gatekeeperDisallowedPrefix   = "example_disallowed"
+	gatekeeperAdmissionSuffix    = "-admission"
+	gatekeeperDeniedMessage      = "denied the request"
+)
+
+// ConstraintTemplateGroupVersionResource is the required Group Version Resource for accessing gatekeeper constraint templates
+// in a cluster, using the dynamic client.
+var ConstraintTemplateGroupVersionResource = schema.GroupVersionResource{
+	Group:    "templates.gatekeeper.sh",
+	Version:  "v1",
+	Resource: "constrainttemplates",
+}
+
+// GatekeeperPolicyConfig is the configuration of the gatekeeper policy library tests.
+type GatekeeperPolicyConfig struct {
+	LibraryPath string `json:"libraryPath" yaml:"libraryPath"`
+}
+
+// GatekeeperPolicy is a single ConstraintTemplate of a policy library together with its sample cases.
+type GatekeeperPolicy struct {
+	Name     string
+	Template *unstructured.Unstructured
+	Cases    []GatekeeperPolicyCase
+}
+
+// GatekeeperPolicyCase is a Constraint with the sample manifests that it is expected to admit and to deny.
+type GatekeeperPolicyCase struct {
+	Name       string
+	Constraint *unstructured.Unstructured
+	Allowed    []*unstructured.Unstructured
+	Disallowed []*unstructured.Unstructured
+}
+
+// ConstraintViolation is a single violation reported by the gatekeeper audit in a constraint status.
+type ConstraintViolation struct {
+	EnforcementAction string `json:"enforcementAction"`
+	Group             string `json:"group"`
+	Kind              string `json:"kind"`
+	Message           string `json:"message"`
+	Name              string `json:"name"`
+	Namespace         string `json:"namespace"`
+	Version           string `json:"version"`
+}
+
+// ConstraintPodStatus is the per-pod enforcement status of a constraint.
+type ConstraintPodStatus struct {
+	ID       string `json:"id"`
+	Enforced bool   `json:"enforced"`
+}
+
+// AuditedConstraintStatus is the typed status of a constraint after it was audited.
+type AuditedConstraintStatus struct {
+	AuditTimestamp  string                `json:"auditTimestamp"`
+	ByPod           []ConstraintPodStatus `json:"byPod"`
+	TotalViolations int64                 `json:"totalViolations"`
+	Violations      []ConstraintViolation `json:"violations"`
+}
+
+// LoadGatekeeperPolicyLibrary reads a policy library laid out like the upstream gatekeeper-library:
+//
+//	<library>/<policy>/template.yaml
+//	<library>/<policy>/samples/<case>/constraint.yaml
+//	<library>/<policy>/samples/<case>/example_allowed*.yaml
+//	<library>/<policy>/samples/<case>/example_disallowed*.yaml
+//
+// Directories without a template.yaml are walked recursively so policies can be grouped in categories.
+func LoadGatekeeperPolicyLibrary(libraryPath string) ([]GatekeeperPolicy, error) {
+	var policies []GatekeeperPolicy
+
+	err := filepath.WalkDir(libraryPath, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || entry.Name() != gatekeeperTemplateFileName {
+			return nil
+		}
+
+		policy, err := loadGatekeeperPolicy(filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+
+		policies = append(policies, *policy)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("no gatekeeper policies found in %s", libraryPath)
+	}
+
+	return policies, nil
+}
+
+// loadGatekeeperPolicy is a private helper function that reads the template and sample cases of a single policy directory.
+func loadGatekeeperPolicy(policyPath string) (*GatekeeperPolicy, error) {
+	templates, err := readManifests(filepath.Join(policyPath, gatekeeperTemplateFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(templates) != 1 {
+		return nil, fmt.Errorf("expected exactly one ConstraintTemplate in %s, found %d", policyPath, len(templates))
+	}
+
+	policy := &GatekeeperPolicy{
+		Name:     filepath.Base(policyPath),
+		Template: templates[0],
+	}
+
+	caseDirs, err := os.ReadDir(filepath.Join(policyPath, gatekeeperSamplesDirName))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, caseDir := range caseDirs {
+		if !caseDir.IsDir() {
+			continue
+		}
+
+		policyCase, err := loadGatekeeperPolicyCase(filepath.Join(policyPath, gatekeeperSamplesDirName, caseDir.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		policy.Cases = append(policy.Cases, *policyCase)
+	}
+
+	if len(policy.Cases) == 0 {
+		return nil, fmt.Errorf("policy %s has no sample cases", policy.Name)
+	}
+
+	return policy, nil
+}
+
+// loadGatekeeperPolicyCase is a private helper function that reads the constraint and good and bad samples of a sample case.
+func loadGatekeeperPolicyCase(casePath string) (*GatekeeperPolicyCase, error) {
+	constraints, err := readManifests(filepath.Join(casePath, gatekeeperConstraintFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(constraints) != 1 {
+		return nil, fmt.Errorf("expected exactly one Constraint in %s, found %d", casePath, len(constraints))
+	}
+
+	policyCase := &GatekeeperPolicyCase{
+		Name:       filepath.Base(casePath),
+		Constraint: constraints[0],
+	}
+
+	files, err := os.ReadDir(casePath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(file.Name(), gatekeeperAllowedPrefix):
+			samples, err := readManifests(filepath.Join(casePath, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			policyCase.Allowed = append(policyCase.Allowed, samples...)
+		case strings.HasPrefix(file.Name(), gatekeeperDisallowedPrefix):
+			samples, err := readManifests(filepath.Join(casePath, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			policyCase.Disallowed = append(policyCase.Disallowed, samples...)
+		}
+	}
+
+	return policyCase, nil
+}
+
+// readManifests is a private helper function that decodes every YAML document of a file into unstructured objects.
+func readManifests(path string) ([]*unstructured.Unstructured, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []*unstructured.Unstructured
+	decoder := k8syaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 4096)
+	for {
+		object := map[string]interface{}{}
+		err := decoder.Decode(&object)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+
+		if len(object) == 0 {
+			continue
+		}
+
+		objects = append(objects, &unstructured.Unstructured{Object: object})
+	}
+
+	return objects, nil
+}
+
+// GatekeeperPolicyRunner applies policy library cases to a downstream cluster and asserts their admission and audit results.
+type GatekeeperPolicyRunner struct {
+	sampleNamespace string
+	dynamicClient   dynamic.Interface
+	restMapperFunc  func() (meta.RESTMapper, error)
+}
+
+// NewGatekeeperPolicyRunner is a constructor that creates a runner for the cluster gatekeeper was installed in.
+// Namespaced samples that do not set a namespace are created in sampleNamespace.
+func NewGatekeeperPolicyRunner(client *rancher.Client, clusterID, sampleNamespace string) (*GatekeeperPolicyRunner, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig, err := kubeconfig.GetKubeconfig(client, clusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GatekeeperPolicyRunner{
+		sampleNamespace: sampleNamespace,
+		dynamicClient:   dynamicClient,
+		restMapperFunc: func() (meta.RESTMapper, error) {
+			restConfig, err := (*clientConfig).ClientConfig()
+			if err != nil {
+				return nil, err
+			}
+
+			restGetter, err := kubeconfig.NewRestGetter(restConfig, *clientConfig)
+			if err != nil {
+				return nil, err
+			}
+
+			return restGetter.ToRESTMapper()
+		},
+	}, nil
+}
+
+// ApplyTemplate creates the ConstraintTemplate of a policy and waits until gatekeeper has created its constraint CRD.
+func (r *GatekeeperPolicyRunner) ApplyTemplate(policy *GatekeeperPolicy) error {
+	templateResource := r.dynamicClient.Resource(ConstraintTemplateGroupVersionResource)
+
+	_, err := templateResource.Namespace("").Create(context.TODO(), policy.Template.DeepCopy(), metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), 2*time.Second, 2*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		template, err := templateResource.Get(ctx, policy.Template.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+
+		created, _, _ := unstructured.NestedBool(template.Object, "status", "created")
+
+		return created, nil
+	})
+}
+
+// ApplyConstraint creates the constraint of a policy case and waits until every gatekeeper pod reports it as enforced.
+func (r *GatekeeperPolicyRunner) ApplyConstraint(policyCase *GatekeeperPolicyCase) error {
+	var constraintResource dynamic.ResourceInterface
+
+	// the constraint CRD is generated from the template, so the REST mapping may take a while to be discoverable
+	err := kwait.PollUntilContextTimeout(context.TODO(), 2*time.Second, 2*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		constraintResource, err = r.resourceFor(policyCase.Constraint)
+		if err != nil {
+			return false, nil
+		}
+
+		_, err = constraintResource.Create(ctx, policyCase.Constraint.DeepCopy(), metav1.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create constraint %s: %w", policyCase.Constraint.GetName(), err)
+	}
+
+	return kwait.PollUntilContextTimeout(context.TODO(), 2*time.Second, 2*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		status, err := r.constraintStatus(ctx, constraintResource, policyCase.Constraint.GetName())
+		if err != nil || len(status.ByPod) == 0 {
+			return false, nil
+		}
+
+		for _, podStatus := range status.ByPod {
+			if !podStatus.Enforced {
+				return false, nil
+			}
+		}
+
+		return true, nil
+	})
+}
+
+// CreateSamples creates the given samples for real, so they exist in the cluster and can be reported by the audit.
+func (r *GatekeeperPolicyRunner) CreateSamples(samples []*unstructured.Unstructured) error {
+	for _, sample := range samples {
+		resource, err := r.resourceFor(sample)
+		if err != nil {
+			return err
+		}
+
+		_, err = resource.Create(context.TODO(), r.withSampleNamespace(sample), metav1.CreateOptions{})
+		if err != nil && !k8serrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create sample %s %s: %w", sample.GetKind(), sample.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// VerifyAdmission submits every sample of a case as a server side dry run, and returns an error for each good sample
+// that was denied and each bad sample that was admitted by the gatekeeper webhook.
+func (r *GatekeeperPolicyRunner) VerifyAdmission(policyCase *GatekeeperPolicyCase) error {
+	var errs []error
+
+	for _, sample := range policyCase.Allowed {
+		err := r.dryRunCreate(sample)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[%s] good sample %s %s was denied: %w", policyCase.Name, sample.GetKind(), sample.GetName(), err))
+		}
+	}
+
+	for _, sample := range policyCase.Disallowed {
+		err := r.dryRunCreate(sample)
+		if err == nil {
+			errs = append(errs, fmt.Errorf("[%s] bad sample %s %s was admitted", policyCase.Name, sample.GetKind(), sample.GetName()))
+			continue
+		}
+
+		if !strings.Contains(err.Error(), gatekeeperDeniedMessage) || !strings.Contains(err.Error(), policyCase.Constraint.GetName()) {
+			errs = append(errs, fmt.Errorf("[%s] bad sample %s %s was rejected for the wrong reason: %w", policyCase.Name, sample.GetKind(), sample.GetName(), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// VerifyAudit waits for an audit that ran after the constraint was enforced and checks that every bad sample is
+// listed in the constraint violations.
+func (r *GatekeeperPolicyRunner) VerifyAudit(policyCase *GatekeeperPolicyCase, enforcedAt time.Time) error {
+	constraintResource, err := r.resourceFor(policyCase.Constraint)
+	if err != nil {
+		return err
+	}
+
+	var status *AuditedConstraintStatus
+	err = kwait.PollUntilContextTimeout(context.TODO(), 5*time.Second, 5*time.Minute, true, func(ctx context.Context) (done bool, err error) {
+		status, err = r.constraintStatus(ctx, constraintResource, policyCase.Constraint.GetName())
+		if err != nil || status.AuditTimestamp == "" {
+			return false, nil
+		}
+
+		auditTime, err := time.Parse(time.RFC3339, status.AuditTimestamp)
+		if err != nil {
+			return false, nil
+		}
+
+		return auditTime.After(enforcedAt), nil
+	})
+	if err != nil {
+		return fmt.Errorf("[%s] audit did not run after the constraint was enforced: %w", policyCase.Name, err)
+	}
+
+	var missing []string
+	for _, sample := range policyCase.Disallowed {
+		namespace := r.withSampleNamespace(sample).GetNamespace()
+		if !hasViolation(status.Violations, sample.GetKind(), sample.GetName(), namespace) {
+			missing = append(missing, fmt.Sprintf("%s %s/%s", sample.GetKind(), namespace, sample.GetName()))
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("[%s] audit is missing expected violations for: %s", policyCase.Name, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// dryRunCreate is a private helper function that submits a renamed copy of a sample with dryRun=All, so admission
+// is evaluated without persisting anything or clashing with samples created for the audit.
+func (r *GatekeeperPolicyRunner) dryRunCreate(sample *unstructured.Unstructured) error {
+	resource, err := r.resourceFor(sample)
+	if err != nil {
+		return err
+	}
+
+	admissionSample := r.withSampleNamespace(sample)
+	admissionSample.SetName(admissionSample.GetName() + gatekeeperAdmissionSuffix)
+
+	_, err = resource.Create(context.TODO(), admissionSample, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+
+	return err
+}
+
+// withSampleNamespace is a private helper function that returns a copy of a sample, defaulting its namespace for namespaced kinds.
+func (r *GatekeeperPolicyRunner) withSampleNamespace(sample *unstructured.Unstructured) *unstructured.Unstructured {
+	sampleCopy := sample.DeepCopy()
+
+	mapping, err := r.mappingFor(sample)
+	if err == nil && mapping.Scope.Name() == meta.RESTScopeNameNamespace && sampleCopy.GetNamespace() == "" {
+		sampleCopy.SetNamespace(r.sampleNamespace)
+	}
+
+	return sampleCopy
+}
+
+// resourceFor is a private helper function that returns the dynamic resource client matching an object's kind and scope.
+func (r *GatekeeperPolicyRunner) resourceFor(object *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	mapping, err := r.mappingFor(object)
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := ""
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		namespace = object.GetNamespace()
+		if namespace == "" {
+			namespace = r.sampleNamespace
+		}
+	}
+
+	return r.dynamicClient.Resource(mapping.Resource).Namespace(namespace), nil
+}
+
+// mappingFor is a private helper function that resolves the REST mapping of an object's kind.
+func (r *GatekeeperPolicyRunner) mappingFor(object *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	mapper, err := r.restMapperFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	gvk := object.GroupVersionKind()
+
+	return mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
+
+// constraintStatus is a private helper function that gets a constraint and converts its status to AuditedConstraintStatus.
+func (r *GatekeeperPolicyRunner) constraintStatus(ctx context.Context, constraintResource dynamic.ResourceInterface, name string) (*AuditedConstraintStatus, error) {
+	constraint, err := constraintResource.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	statusMap, found, err := unstructured.NestedMap(constraint.Object, "status")
+	if err != nil || !found {
+		return nil, fmt.Errorf("constraint %s has no status yet", name)
+	}
+
+	status := &AuditedConstraintStatus{}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(statusMap, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// hasViolation is a private helper function that checks whether an object is listed in the audit violations.
+func hasViolation(violations []ConstraintViolation, kind, name, namespace string) bool {
+	for _, violation := range violations {
+		if violation.Kind == kind && violation.Name == name && violation.Namespace == namespace {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+//go:build (validation || infra.rke1 || cluster.any || stress) && !infra.any && !infra.aks && !infra.eks && !infra.gke && !infra.rke2k3s && !sanity && !extended
+
+package charts
+
+import (
+	"time"
+
+	extencharts "github.com/rancher/shepherd/extensions/charts"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/charts"
+	"github.com/rancher/tests/actions/namespaces"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (g *GateKeeperTestSuite) TestGatekeeperPolicyLibrary() {
+	subSession := g.session.NewSession()
+	defer subSession.Cleanup()
+
+	client, err := g.client.WithSession(subSession)
+	require.NoError(g.T(), err)
+
+	policyConfig := new(GatekeeperPolicyConfig)
+	config.LoadConfig(GatekeeperPolicyConfigurationFileKey, policyConfig)
+	if policyConfig.LibraryPath == "" {
+		policyConfig.LibraryPath = defaultGatekeeperPolicyLibraryPath
+	}
+
+	g.T().Logf("Loading gatekeeper policy library from %s", policyConfig.LibraryPath)
+	policies, err := LoadGatekeeperPolicyLibrary(policyConfig.LibraryPath)
+	require.NoError(g.T(), err)
+
+	g.T().Log("Installing latest version of gatekeeper chart")
+	err = charts.InstallRancherGatekeeperChart(client, g.gatekeeperChartInstallOptions)
+	require.NoError(g.T(), err)
+
+	g.T().Log("Waiting for gatekeeper chart deployments to have expected number of available replicas")
+	err = extencharts.WatchAndWaitDeployments(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	require.NoError(g.T(), err)
+
+	g.T().Log("Waiting for gatekeeper chart DaemonSets to have expected number of available nodes")
+	err = extencharts.WatchAndWaitDaemonSets(client, g.project.ClusterID, charts.RancherGatekeeperNamespace, metav1.ListOptions{})
+	require.NoError(g.T(), err)
+
+	g.T().Log("Creating namespace for the policy samples")
+	sampleNamespace, err := namespaces.CreateNamespace(client, namegenerator.AppendRandomString("gatekeeper-policy"), "{}", map[string]string{}, map[string]string{}, g.project)
+	require.NoError(g.T(), err)
+
+	runner, err := NewGatekeeperPolicyRunner(client, g.project.ClusterID, sampleNamespace.Name)
+	require.NoError(g.T(), err)
+
+	for _, policy := range policies {
+		g.Run(policy.Name, func() {
+			g.T().Logf("Applying constraint template %s", policy.Template.GetName())
+			err := runner.ApplyTemplate(&policy)
+			require.NoError(g.T(), err)
+
+			for _, policyCase := range policy.Cases {
+				g.Run(policyCase.Name, func() {
+					g.T().Log("Creating bad samples before the constraint exists so the audit can report them")
+					err := runner.CreateSamples(policyCase.Disallowed)
+					require.NoError(g.T(), err)
+
+					g.T().Logf("Applying constraint %s", policyCase.Constraint.GetName())
+					err = runner.ApplyConstraint(&policyCase)
+					require.NoError(g.T(), err)
+					enforcedAt := time.Now()
+
+					g.T().Log("Asserting that admission admits every good sample and denies every bad sample")
+					err = runner.VerifyAdmission(&policyCase)
+					assert.NoError(g.T(), err)
+
+					g.T().Log("Waiting for gatekeeper audit and asserting that it lists the expected violations")
+					err = runner.VerifyAudit(&policyCase, enforcedAt)
+					assert.NoError(g.T(), err)
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+//go:build validation
+
+package fleet
+
+import (
+	"testing"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extensionscluster "github.com/rancher/shepherd/extensions/clusters"
+	extensionsfleet "github.com/rancher/shepherd/extensions/fleet"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/chartserver"
+	"github.com/rancher/tests/actions/gitserver"
+	projectsapi "github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	testChartName       = "fleet-test-chart"
+	testChartVersion    = "0.1.0"
+	upgradeChartVersion = "0.2.0"
+)
+
+type FleetChartSourceTestSuite struct {
+	suite.Suite
+	client       *rancher.Client
+	session      *session.Session
+	clusterID    string
+	gitServer    *gitserver.GitServer
+	chartServers map[bool]*chartserver.ChartServer
+}
+
+func (f *FleetChartSourceTestSuite) TearDownSuite() {
+	f.session.Cleanup()
+}
+
+func (f *FleetChartSourceTestSuite) SetupSuite() {
+	f.session = session.NewSession()
+
+	client, err := rancher.NewClient("", f.session)
+	require.NoError(f.T(), err)
+
+	f.client = client
+
+	clusterObject, _, _ := extensionscluster.GetProvisioningClusterByName(f.client, f.client.RancherConfig.ClusterName, fleet.Namespace)
+	if clusterObject != nil {
+		status := &provv1.ClusterStatus{}
+		err := steveV1.ConvertToK8sType(clusterObject.Status, status)
+		require.NoError(f.T(), err)
+
+		f.clusterID = status.ClusterName
+	} else {
+		f.clusterID, err = extensionscluster.GetClusterIDByName(f.client, f.client.RancherConfig.ClusterName)
+		require.NoError(f.T(), err)
+	}
+
+	f.gitServer, err = gitserver.DeployGitServer(f.client, gitserver.LoadConfig())
+	require.NoError(f.T(), err)
+
+	f.chartServers = map[bool]*chartserver.ChartServer{}
+	for _, auth := range []bool{false, true} {
+		chartServer, err := chartserver.DeployChartServer(f.client, chartserver.LoadConfig(), auth)
+		require.NoError(f.T(), err)
+
+		for _, version := range []string{testChartVersion, upgradeChartVersion} {
+			err = chartServer.PushChart(testChartName, version)
+			require.NoError(f.T(), err)
+		}
+
+		f.chartServers[auth] = chartServer
+	}
+}
+
+func (f *FleetChartSourceTestSuite) TestChartSourceDeploymentAndUpgrade() {
+	tests := []struct {
+		name string
+		oci  bool
+		auth bool
+	}{
+		{"OCI registry", true, false},
+		{"OCI registry with basic auth", true, true},
+		{"Helm repo", false, false},
+		{"Helm repo with basic auth", false, true},
+	}
+
+	for _, tt := range tests {
+		f.Run(tt.name, func() {
+			testSession := session.NewSession()
+			defer testSession.Cleanup()
+
+			client, err := f.client.WithSession(testSession)
+			require.NoError(f.T(), err)
+
+			_, namespace, err := projectsapi.CreateProjectAndNamespace(client, f.clusterID)
+			require.NoError(f.T(), err)
+
+			chartServer := f.chartServers[tt.auth]
+			source := fleet.HelmChartSource{
+				Chart:       chartServer.OCIChartURL(testChartName),
+				Version:     testChartVersion,
+				ReleaseName: testChartName,
+			}
+
+			if !tt.oci {
+				source.Chart = testChartName
+				source.Repo = chartServer.HelmRepoURL()
+			}
+
+			repoName := namegenerator.AppendRandomString(testChartName)
+			err = f.gitServer.CreateRepo(repoName, false)
+			require.NoError(f.T(), err)
+
+			fleetYAML, err := fleet.NewHelmFleetYAML(source)
+			require.NoError(f.T(), err)
+
+			_, err = f.gitServer.Commit(repoName, "Deploy "+testChartName+" "+testChartVersion, map[string][]byte{fleet.FleetYAMLFile: fleetYAML})
+			require.NoError(f.T(), err)
+
+			fleetGitRepo := newLocalGitRepo(f.gitServer.HTTPURL(repoName), namespace.Name, client.RancherConfig.ClusterName)
+			fleetGitRepo.Spec.HelmSecretName, err = chartServer.CreateHelmSecret(fleet.Namespace)
+			require.NoError(f.T(), err)
+
+			logrus.Infof("Deploying chart %s from %s", source.Chart, fleetGitRepo.Spec.Repo)
+			gitRepoObject, err := extensionsfleet.CreateFleetGitRepo(client, fleetGitRepo)
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyGitRepo(client, gitRepoObject.ID, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyChartVersion(client, f.clusterID, namespace.Name, testChartName, testChartVersion)
+			require.NoError(f.T(), err)
+
+			source.Version = upgradeChartVersion
+			fleetYAML, err = fleet.NewHelmFleetYAML(source)
+			require.NoError(f.T(), err)
+
+			_, err = f.gitServer.Commit(repoName, "Upgrade "+testChartName+" to "+upgradeChartVersion, map[string][]byte{fleet.FleetYAMLFile: fleetYAML})
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyChartVersion(client, f.clusterID, namespace.Name, testChartName, upgradeChartVersion)
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyGitRepo(client, gitRepoObject.ID, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+			require.NoError(f.T(), err)
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFleetChartSourceTestSuite(t *testing.T) {
+	suite.Run(t, new(FleetChartSourceTestSuite))
+}
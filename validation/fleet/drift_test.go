@@ -0,0 +1,125 @@
+//go:build validation
+
+package fleet
+
+import (
+	"testing"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extensionscluster "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/gitserver"
+	projectsapi "github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FleetDriftTestSuite struct {
+	suite.Suite
+	client    *rancher.Client
+	session   *session.Session
+	clusterID string
+	gitServer *gitserver.GitServer
+}
+
+func (f *FleetDriftTestSuite) TearDownSuite() {
+	f.session.Cleanup()
+}
+
+func (f *FleetDriftTestSuite) SetupSuite() {
+	f.session = session.NewSession()
+
+	client, err := rancher.NewClient("", f.session)
+	require.NoError(f.T(), err)
+
+	f.client = client
+
+	clusterObject, _, _ := extensionscluster.GetProvisioningClusterByName(f.client, f.client.RancherConfig.ClusterName, fleet.Namespace)
+	if clusterObject != nil {
+		status := &provv1.ClusterStatus{}
+		err := steveV1.ConvertToK8sType(clusterObject.Status, status)
+		require.NoError(f.T(), err)
+
+		f.clusterID = status.ClusterName
+	} else {
+		f.clusterID, err = extensionscluster.GetClusterIDByName(f.client, f.client.RancherConfig.ClusterName)
+		require.NoError(f.T(), err)
+	}
+
+	f.gitServer, err = gitserver.DeployGitServer(f.client, gitserver.LoadConfig())
+	require.NoError(f.T(), err)
+}
+
+func (f *FleetDriftTestSuite) TestDriftCorrection() {
+	tests := []struct {
+		name         string
+		correctDrift bool
+	}{
+		{"drift correction enabled", true},
+		{"drift correction disabled", false},
+	}
+
+	for _, tt := range tests {
+		f.Run(tt.name, func() {
+			testSession := session.NewSession()
+			defer testSession.Cleanup()
+
+			client, err := f.client.WithSession(testSession)
+			require.NoError(f.T(), err)
+
+			_, namespace, err := projectsapi.CreateProjectAndNamespace(client, f.clusterID)
+			require.NoError(f.T(), err)
+
+			repoName := namegenerator.AppendRandomString(simpleBundle)
+			_, err = f.gitServer.SeedRepo(repoName, simpleBundle, false)
+			require.NoError(f.T(), err)
+
+			fleetGitRepo := newLocalGitRepo(f.gitServer.HTTPURL(repoName), namespace.Name, client.RancherConfig.ClusterName)
+			_, err = fleet.CreateDriftGitRepo(client, fleetGitRepo, tt.correctDrift, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+			require.NoError(f.T(), err)
+
+			resources := &fleet.DriftResources{
+				Namespace:      namespace.Name,
+				DeploymentName: simpleBundle,
+				Replicas:       1,
+				ConfigMapName:  simpleConfigMapName,
+				ConfigMapKey:   "revision",
+				ConfigMapValue: "1",
+				ServiceName:    simpleBundle,
+			}
+
+			resources.ImageVersion, err = fleet.GetDeploymentVersion(client, namespace.Name+"/"+simpleBundle, f.clusterID)
+			require.NoError(f.T(), err)
+
+			err = fleet.CreateDrift(client, f.clusterID, resources)
+			require.NoError(f.T(), err)
+
+			if tt.correctDrift {
+				err = fleet.VerifyDriftCorrected(client, f.clusterID, resources)
+				require.NoError(f.T(), err)
+
+				err = fleet.WaitForBundleDeploymentsModified(client, fleetGitRepo.Name, false)
+				require.NoError(f.T(), err)
+
+				return
+			}
+
+			err = fleet.WaitForBundleDeploymentsModified(client, fleetGitRepo.Name, true)
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyDriftPersisted(client, f.clusterID, resources, defaults.TwoMinuteTimeout)
+			require.NoError(f.T(), err)
+		})
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFleetDriftTestSuite(t *testing.T) {
+	suite.Run(t, new(FleetDriftTestSuite))
+}
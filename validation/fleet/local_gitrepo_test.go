@@ -0,0 +1,193 @@
+//go:build validation || pit.daily
+
+package fleet
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extensionscluster "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults"
+	extensionsfleet "github.com/rancher/shepherd/extensions/fleet"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/gitserver"
+	projectsapi "github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	simpleBundle        = "simple"
+	simpleConfigMapName = "simple-config"
+)
+
+type FleetLocalRepoTestSuite struct {
+	suite.Suite
+	client    *rancher.Client
+	session   *session.Session
+	clusterID string
+	gitServer *gitserver.GitServer
+}
+
+func (f *FleetLocalRepoTestSuite) TearDownSuite() {
+	f.session.Cleanup()
+}
+
+func (f *FleetLocalRepoTestSuite) SetupSuite() {
+	f.session = session.NewSession()
+
+	client, err := rancher.NewClient("", f.session)
+	require.NoError(f.T(), err)
+
+	f.client = client
+
+	clusterObject, _, _ := extensionscluster.GetProvisioningClusterByName(f.client, f.client.RancherConfig.ClusterName, fleet.Namespace)
+	if clusterObject != nil {
+		status := &provv1.ClusterStatus{}
+		err := steveV1.ConvertToK8sType(clusterObject.Status, status)
+		require.NoError(f.T(), err)
+
+		f.clusterID = status.ClusterName
+	} else {
+		f.clusterID, err = extensionscluster.GetClusterIDByName(f.client, f.client.RancherConfig.ClusterName)
+		require.NoError(f.T(), err)
+	}
+
+	f.gitServer, err = gitserver.DeployGitServer(f.client, gitserver.LoadConfig())
+	require.NoError(f.T(), err)
+}
+
+func (f *FleetLocalRepoTestSuite) TestLocalGitRepoDeployment() {
+	tests := []struct {
+		name    string
+		private bool
+		ssh     bool
+	}{
+		{"http", false, false},
+		{"http with basic auth", true, false},
+		{"ssh", true, true},
+	}
+
+	for _, tt := range tests {
+		f.Run(tt.name, func() {
+			testSession := session.NewSession()
+			defer testSession.Cleanup()
+
+			client, err := f.client.WithSession(testSession)
+			require.NoError(f.T(), err)
+
+			_, namespace, err := projectsapi.CreateProjectAndNamespace(client, f.clusterID)
+			require.NoError(f.T(), err)
+
+			repoName := namegenerator.AppendRandomString(simpleBundle)
+			_, err = f.gitServer.SeedRepo(repoName, simpleBundle, tt.private)
+			require.NoError(f.T(), err)
+
+			fleetGitRepo := newLocalGitRepo(f.gitServer.HTTPURL(repoName), namespace.Name, client.RancherConfig.ClusterName)
+			if tt.ssh {
+				fleetGitRepo.Spec.Repo = f.gitServer.SSHURL(repoName)
+				fleetGitRepo.Spec.ClientSecretName, err = f.gitServer.CreateSSHAuthSecret(fleet.Namespace)
+				require.NoError(f.T(), err)
+			} else if tt.private {
+				fleetGitRepo.Spec.ClientSecretName, err = f.gitServer.CreateHTTPAuthSecret(fleet.Namespace)
+				require.NoError(f.T(), err)
+			}
+
+			logrus.Infof("Deploying local fleet gitRepo %s", fleetGitRepo.Spec.Repo)
+			gitRepoObject, err := extensionsfleet.CreateFleetGitRepo(client, fleetGitRepo)
+			require.NoError(f.T(), err)
+
+			err = fleet.VerifyGitRepo(client, gitRepoObject.ID, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+			require.NoError(f.T(), err)
+		})
+	}
+}
+
+func (f *FleetLocalRepoTestSuite) TestLocalGitRepoCommitUpdate() {
+	testSession := session.NewSession()
+	defer testSession.Cleanup()
+
+	client, err := f.client.WithSession(testSession)
+	require.NoError(f.T(), err)
+
+	_, namespace, err := projectsapi.CreateProjectAndNamespace(client, f.clusterID)
+	require.NoError(f.T(), err)
+
+	repoName := namegenerator.AppendRandomString(simpleBundle)
+	_, err = f.gitServer.SeedRepo(repoName, simpleBundle, false)
+	require.NoError(f.T(), err)
+
+	fleetGitRepo := newLocalGitRepo(f.gitServer.HTTPURL(repoName), namespace.Name, client.RancherConfig.ClusterName)
+
+	logrus.Infof("Deploying local fleet gitRepo %s", fleetGitRepo.Spec.Repo)
+	gitRepoObject, err := extensionsfleet.CreateFleetGitRepo(client, fleetGitRepo)
+	require.NoError(f.T(), err)
+
+	err = fleet.VerifyGitRepo(client, gitRepoObject.ID, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+	require.NoError(f.T(), err)
+
+	updatedConfigMap := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + simpleConfigMapName + "\ndata:\n  revision: \"2\"\n")
+	commit, err := f.gitServer.Commit(repoName, "Bump simple revision", map[string][]byte{"configmap.yaml": updatedConfigMap})
+	require.NoError(f.T(), err)
+
+	logrus.Infof("Waiting for fleet to deploy commit %s", commit)
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		gitRepo, err := client.Steve.SteveType(extensionsfleet.FleetGitRepoResourceType).ByID(gitRepoObject.ID)
+		if err != nil {
+			return false, err
+		}
+
+		gitStatus := &v1alpha1.GitRepoStatus{}
+		err = steveV1.ConvertToK8sType(gitRepo.Status, gitStatus)
+		if err != nil {
+			return false, err
+		}
+
+		return gitStatus.Commit == commit, nil
+	})
+	require.NoError(f.T(), err)
+
+	err = fleet.VerifyGitRepo(client, gitRepoObject.ID, f.clusterID, fleet.Namespace+"/"+client.RancherConfig.ClusterName)
+	require.NoError(f.T(), err)
+
+	downstreamContext, err := client.WranglerContext.DownStreamClusterWranglerContext(f.clusterID)
+	require.NoError(f.T(), err)
+
+	configMap, err := downstreamContext.Core.ConfigMap().Get(namespace.Name, simpleConfigMapName, metav1.GetOptions{})
+	require.NoError(f.T(), err)
+	require.Equal(f.T(), "2", configMap.Data["revision"])
+}
+
+// newLocalGitRepo is a private helper function that returns a GitRepo deploying the root of a git server repo to a
+// single cluster.
+func newLocalGitRepo(repoURL, targetNamespace, clusterName string) *v1alpha1.GitRepo {
+	return &v1alpha1.GitRepo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fleet.FleetMetaName + namegenerator.RandStringLower(5),
+			Namespace: fleet.Namespace,
+		},
+		Spec: v1alpha1.GitRepoSpec{
+			Repo:            repoURL,
+			Branch:          gitserver.DefaultBranch,
+			TargetNamespace: targetNamespace,
+			PollingInterval: &metav1.Duration{Duration: 15 * time.Second},
+			Targets:         []v1alpha1.GitTarget{{ClusterName: clusterName}},
+		},
+	}
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFleetLocalRepoTestSuite(t *testing.T) {
+	suite.Run(t, new(FleetLocalRepoTestSuite))
+}
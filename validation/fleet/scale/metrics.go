@@ -0,0 +1,151 @@
+package scale
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/kubeconfig"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	fleetSystemNamespace   = "cattle-fleet-system"
+	apiRequestsMetric      = "apiserver_request_total"
+	fleetAPIGroupLabel     = `group="fleet.cattle.io"`
+	apiServerMetricsPath   = "/metrics"
+	fleetControllerPrefix  = "fleet-controller"
+	gitjobControllerPrefix = "gitjob"
+)
+
+var podMetricsGroupVersionResource = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "pods",
+}
+
+// ResourceUsage is the peak resource usage of a fleet controller pod sampled during the benchmark.
+type ResourceUsage struct {
+	MaxCPUMillicores int64 `json:"maxCPUMillicores"`
+	MaxMemoryBytes   int64 `json:"maxMemoryBytes"`
+	Samples          int   `json:"samples"`
+}
+
+// APIRequestCounts are the requests served by the local cluster kube-apiserver during the benchmark.
+type APIRequestCounts struct {
+	Total int64 `json:"total"`
+	Fleet int64 `json:"fleet"`
+}
+
+// SampleControllerUsage is a helper function that samples the metrics-server usage of the fleet-controller and gitjob
+// pods and records the peak usage per pod.
+func SampleControllerUsage(client *rancher.Client, usage map[string]*ResourceUsage) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return err
+	}
+
+	podMetricsList, err := dynamicClient.Resource(podMetricsGroupVersionResource).Namespace(fleetSystemNamespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, podMetrics := range podMetricsList.Items {
+		podName := podMetrics.GetName()
+		if !strings.HasPrefix(podName, fleetControllerPrefix) && !strings.HasPrefix(podName, gitjobControllerPrefix) {
+			continue
+		}
+
+		containers, _, err := kubeunstructured.NestedSlice(podMetrics.Object, "containers")
+		if err != nil {
+			return err
+		}
+
+		var cpuMillicores, memoryBytes int64
+		for _, container := range containers {
+			containerUsage, _, err := kubeunstructured.NestedStringMap(container.(map[string]interface{}), "usage")
+			if err != nil {
+				return err
+			}
+
+			cpu, err := resource.ParseQuantity(containerUsage["cpu"])
+			if err != nil {
+				return err
+			}
+
+			memory, err := resource.ParseQuantity(containerUsage["memory"])
+			if err != nil {
+				return err
+			}
+
+			cpuMillicores += cpu.MilliValue()
+			memoryBytes += memory.Value()
+		}
+
+		podUsage, ok := usage[podName]
+		if !ok {
+			podUsage = &ResourceUsage{}
+			usage[podName] = podUsage
+		}
+
+		podUsage.MaxCPUMillicores = max(podUsage.MaxCPUMillicores, cpuMillicores)
+		podUsage.MaxMemoryBytes = max(podUsage.MaxMemoryBytes, memoryBytes)
+		podUsage.Samples++
+	}
+
+	return nil
+}
+
+// GetAPIRequestCounts is a helper function that reads the request counters of the local cluster kube-apiserver, in
+// total and for the fleet API group. The difference between two reads is the number of requests made in between.
+func GetAPIRequestCounts(client *rancher.Client) (*APIRequestCounts, error) {
+	clientConfig, err := kubeconfig.GetKubeconfig(client, localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	restConfig, err := (*clientConfig).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := clientset.Discovery().RESTClient().Get().AbsPath(apiServerMetricsPath).DoRaw(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	counts := &APIRequestCounts{}
+	scanner := bufio.NewScanner(bytes.NewReader(metrics))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, apiRequestsMetric+"{") {
+			continue
+		}
+
+		valueIndex := strings.LastIndex(line, " ")
+		value, err := strconv.ParseFloat(line[valueIndex+1:], 64)
+		if err != nil {
+			return nil, err
+		}
+
+		counts.Total += int64(value)
+		if strings.Contains(line[:valueIndex], fleetAPIGroupLabel) {
+			counts.Fleet += int64(value)
+		}
+	}
+
+	return counts, scanner.Err()
+}
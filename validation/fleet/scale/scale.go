@@ -0,0 +1,398 @@
+package scale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	extensionsfleet "github.com/rancher/shepherd/extensions/fleet"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/tests/actions/gitserver"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// ScaleConfigurationFileKey is the key of the fleet scale benchmark configuration in the config file
+	ScaleConfigurationFileKey = "fleetScaleInput"
+
+	defaultBundle          = "configmap"
+	defaultResultsFile     = "fleet-scale-results.json"
+	defaultTimeoutMinutes  = 30
+	groupLabel             = "fleet-scale-group"
+	bundleNamespaceLabel   = "fleet.cattle.io/bundle-namespace"
+	bundleNameLabel        = "fleet.cattle.io/bundle-name"
+	fleetYAML              = "fleet.yaml"
+	pollInterval           = 2 * time.Second
+	usageSampleEveryNPolls = 5
+)
+
+var clusterGroupGroupVersionResource = v1alpha1.SchemeGroupVersion.WithResource("clustergroups")
+
+// Config is the fleet scale benchmark configuration. GitRepos × BundlesPerGitRepo bundles are deployed, every GitRepo
+// targeting one of the cluster groups. With SimulatedClusters set, agent-only clusters are registered from the local
+// cluster and spread over SimulatedClusterGroups groups, otherwise ClusterGroups select existing clusters.
+type Config struct {
+	GitRepos               int                 `json:"gitRepos" yaml:"gitRepos"`
+	BundlesPerGitRepo      int                 `json:"bundlesPerGitRepo" yaml:"bundlesPerGitRepo"`
+	ClusterGroups          []map[string]string `json:"clusterGroups" yaml:"clusterGroups"`
+	SimulatedClusters      int                 `json:"simulatedClusters" yaml:"simulatedClusters"`
+	SimulatedClusterGroups int                 `json:"simulatedClusterGroups" yaml:"simulatedClusterGroups"`
+	Bundle                 string              `json:"bundle" yaml:"bundle"`
+	ResultsFile            string              `json:"resultsFile" yaml:"resultsFile"`
+	TimeoutMinutes         int                 `json:"timeoutMinutes" yaml:"timeoutMinutes"`
+}
+
+// BundleDeploymentResult is the time a BundleDeployment took to be ready, from the creation of its GitRepo.
+type BundleDeploymentResult struct {
+	GitRepo        string  `json:"gitRepo"`
+	Bundle         string  `json:"bundle"`
+	Cluster        string  `json:"cluster"`
+	SecondsToReady float64 `json:"secondsToReady"`
+}
+
+// Percentiles summarizes the time-to-Ready of every BundleDeployment, in seconds.
+type Percentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// Results are the benchmark results written to the results file for trend tracking.
+type Results struct {
+	StartedAt         time.Time                 `json:"startedAt"`
+	FleetVersion      string                    `json:"fleetVersion"`
+	Config            Config                    `json:"config"`
+	Clusters          int                       `json:"clusters"`
+	TotalSeconds      float64                   `json:"totalSeconds"`
+	TimeToReady       Percentiles               `json:"timeToReady"`
+	BundleDeployments []BundleDeploymentResult  `json:"bundleDeployments"`
+	ControllerUsage   map[string]*ResourceUsage `json:"controllerUsage"`
+	APIRequests       *APIRequestCounts         `json:"apiRequests,omitempty"`
+}
+
+// LoadConfig is a helper function that loads the benchmark configuration, falling back to a single small run.
+func LoadConfig() *Config {
+	scaleConfig := new(Config)
+	config.LoadConfig(ScaleConfigurationFileKey, scaleConfig)
+
+	if scaleConfig.GitRepos == 0 {
+		scaleConfig.GitRepos = 1
+	}
+
+	if scaleConfig.BundlesPerGitRepo == 0 {
+		scaleConfig.BundlesPerGitRepo = 1
+	}
+
+	if scaleConfig.SimulatedClusters > 0 && scaleConfig.SimulatedClusterGroups == 0 {
+		scaleConfig.SimulatedClusterGroups = 1
+	}
+
+	if scaleConfig.Bundle == "" {
+		scaleConfig.Bundle = defaultBundle
+	}
+
+	if scaleConfig.ResultsFile == "" {
+		scaleConfig.ResultsFile = defaultResultsFile
+	}
+
+	if scaleConfig.TimeoutMinutes == 0 {
+		scaleConfig.TimeoutMinutes = defaultTimeoutMinutes
+	}
+
+	return scaleConfig
+}
+
+// SimulatedGroupLabels returns the labels of the simulated cluster groups.
+func (c *Config) SimulatedGroupLabels() []map[string]string {
+	groupLabels := make([]map[string]string, 0, c.SimulatedClusterGroups)
+	for i := 0; i < c.SimulatedClusterGroups; i++ {
+		groupLabels = append(groupLabels, map[string]string{groupLabel: fmt.Sprintf("group-%d", i)})
+	}
+
+	return groupLabels
+}
+
+// CreateClusterGroups is a helper function that creates a fleet ClusterGroup per label selector and returns their names.
+// An empty selector selects every cluster of the workspace.
+func CreateClusterGroups(client *rancher.Client, selectors []map[string]string) ([]string, error) {
+	if len(selectors) == 0 {
+		selectors = []map[string]string{{}}
+	}
+
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupNames []string
+	for _, selector := range selectors {
+		clusterGroup := &v1alpha1.ClusterGroup{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: v1alpha1.SchemeGroupVersion.String(),
+				Kind:       "ClusterGroup",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      namegenerator.AppendRandomString("fleet-scale"),
+				Namespace: fleet.Namespace,
+			},
+			Spec: v1alpha1.ClusterGroupSpec{
+				Selector: &metav1.LabelSelector{MatchLabels: selector},
+			},
+		}
+
+		unstructuredGroup, err := runtime.DefaultUnstructuredConverter.ToUnstructured(clusterGroup)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = dynamicClient.Resource(clusterGroupGroupVersionResource).Namespace(fleet.Namespace).Create(context.TODO(), &kubeunstructured.Unstructured{Object: unstructuredGroup}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		groupNames = append(groupNames, clusterGroup.Name)
+	}
+
+	return groupNames, nil
+}
+
+// GetClusterGroupSize is a helper function that waits for fleet to count the clusters of a ClusterGroup and returns it.
+func GetClusterGroupSize(client *rancher.Client, groupName string) (int, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return 0, err
+	}
+
+	var clusterCount int64
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.TwoMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		clusterGroup, err := dynamicClient.Resource(clusterGroupGroupVersionResource).Namespace(fleet.Namespace).Get(ctx, groupName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		clusterCount, _, err = kubeunstructured.NestedInt64(clusterGroup.Object, "status", "clusterCount")
+		if err != nil {
+			return false, err
+		}
+
+		return clusterCount > 0, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cluster group %s has no clusters: %w", groupName, err)
+	}
+
+	return int(clusterCount), nil
+}
+
+// SeedBenchmarkRepo is a helper function that creates a git server repo with the fixture bundle copied into
+// bundlesPerGitRepo directories. Every copy deploys to its own namespace. Simulated clusters share the local cluster,
+// so the namespace is also made unique per simulated cluster. It returns the bundle paths.
+func SeedBenchmarkRepo(gitServer *gitserver.GitServer, repoName, bundle string, bundlesPerGitRepo int, simulatedClusters []string) ([]string, error) {
+	fixtureFiles, err := gitServer.FixtureFiles(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	var paths []string
+	for i := 0; i < bundlesPerGitRepo; i++ {
+		bundlePath := fmt.Sprintf("bundle-%d", i)
+		paths = append(paths, bundlePath)
+
+		for filePath, content := range fixtureFiles {
+			files[bundlePath+"/"+filePath] = content
+		}
+
+		namespace := fmt.Sprintf("%s-%d", repoName, i)
+		fleetConfig := map[string]interface{}{"defaultNamespace": namespace}
+
+		var customizations []map[string]interface{}
+		for _, clusterName := range simulatedClusters {
+			customizations = append(customizations, map[string]interface{}{
+				"name":        clusterName,
+				"clusterName": clusterName,
+				"namespace":   namespace + "-" + clusterName,
+			})
+		}
+
+		if len(customizations) > 0 {
+			fleetConfig["targetCustomizations"] = customizations
+		}
+
+		fleetConfigYAML, err := yaml.Marshal(fleetConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		files[bundlePath+"/"+fleetYAML] = fleetConfigYAML
+	}
+
+	err = gitServer.CreateRepo(repoName, false)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = gitServer.Commit(repoName, fmt.Sprintf("Seed %d %s bundles", bundlesPerGitRepo, bundle), files)
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// CreateBenchmarkGitRepo is a helper function that creates a GitRepo deploying every bundle path of a git server repo
+// to a cluster group.
+func CreateBenchmarkGitRepo(client *rancher.Client, gitServer *gitserver.GitServer, repoName string, paths []string, clusterGroup string) (*steveV1.SteveAPIObject, error) {
+	gitRepo := &v1alpha1.GitRepo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      repoName,
+			Namespace: fleet.Namespace,
+		},
+		Spec: v1alpha1.GitRepoSpec{
+			Repo:    gitServer.HTTPURL(repoName),
+			Branch:  gitserver.DefaultBranch,
+			Paths:   paths,
+			Targets: []v1alpha1.GitTarget{{ClusterGroup: clusterGroup}},
+		},
+	}
+
+	return extensionsfleet.CreateFleetGitRepo(client, gitRepo)
+}
+
+// WaitForBundleDeploymentsReady is a helper function that waits for the expected number of BundleDeployments of the
+// GitRepos to be ready. It records the time-to-Ready of each one from the creation of its GitRepo and samples the
+// fleet controllers resource usage while waiting.
+func WaitForBundleDeploymentsReady(client *rancher.Client, gitRepoCreated map[string]time.Time, expected int, timeout time.Duration, usage map[string]*ResourceUsage) ([]BundleDeploymentResult, error) {
+	query := url.Values{"labelSelector": {bundleNamespaceLabel + "=" + fleet.Namespace}}
+	ready := map[string]BundleDeploymentResult{}
+
+	polls := 0
+	err := kwait.PollUntilContextTimeout(context.TODO(), pollInterval, timeout, true, func(ctx context.Context) (done bool, err error) {
+		if polls%usageSampleEveryNPolls == 0 {
+			err = SampleControllerUsage(client, usage)
+			if err != nil {
+				logrus.Warnf("Unable to sample fleet controller usage: %v", err)
+			}
+		}
+		polls++
+
+		bundleDeployments, err := client.Steve.SteveType(fleet.BundleDeploymentResourceSteveType).List(query)
+		if err != nil {
+			return false, err
+		}
+
+		for _, bundleDeployment := range bundleDeployments.Data {
+			gitRepoName := bundleDeployment.Labels[v1alpha1.RepoLabel]
+			createdAt, ok := gitRepoCreated[gitRepoName]
+			if !ok {
+				continue
+			}
+
+			if _, ok := ready[bundleDeployment.ID]; ok {
+				continue
+			}
+
+			spec := &v1alpha1.BundleDeploymentSpec{}
+			err = steveV1.ConvertToK8sType(bundleDeployment.Spec, spec)
+			if err != nil {
+				return false, err
+			}
+
+			status := &v1alpha1.BundleDeploymentStatus{}
+			err = steveV1.ConvertToK8sType(bundleDeployment.Status, status)
+			if err != nil {
+				return false, err
+			}
+
+			if !status.Ready || status.AppliedDeploymentID != spec.DeploymentID {
+				continue
+			}
+
+			ready[bundleDeployment.ID] = BundleDeploymentResult{
+				GitRepo:        gitRepoName,
+				Bundle:         bundleDeployment.Labels[bundleNameLabel],
+				Cluster:        bundleDeployment.Labels[v1alpha1.ClusterLabel],
+				SecondsToReady: time.Since(createdAt).Seconds(),
+			}
+		}
+
+		logrus.Infof("%d/%d bundle deployments ready", len(ready), expected)
+
+		return len(ready) >= expected, nil
+	})
+
+	results := make([]BundleDeploymentResult, 0, len(ready))
+	for _, result := range ready {
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].SecondsToReady < results[j].SecondsToReady
+	})
+
+	if err != nil {
+		return results, fmt.Errorf("%d/%d bundle deployments ready: %w", len(ready), expected, err)
+	}
+
+	return results, nil
+}
+
+// NewPercentiles is a constructor that summarizes time-to-Ready results sorted in ascending order.
+func NewPercentiles(results []BundleDeploymentResult) Percentiles {
+	if len(results) == 0 {
+		return Percentiles{}
+	}
+
+	percentile := func(p float64) float64 {
+		index := int(p*float64(len(results)+1)) - 1
+		index = max(0, min(index, len(results)-1))
+
+		return results[index].SecondsToReady
+	}
+
+	return Percentiles{
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P99: percentile(0.99),
+		Max: results[len(results)-1].SecondsToReady,
+	}
+}
+
+// WriteResults is a helper function that writes the benchmark results as JSON.
+func WriteResults(results *Results, resultsFile string) error {
+	resultsJSON, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Writing fleet scale results to %s", resultsFile)
+
+	return os.WriteFile(resultsFile, resultsJSON, 0644)
+}
+
+// splitID is a private helper function that splits a steve ID into its namespace and name.
+func splitID(id string) (string, string) {
+	namespace, name, found := strings.Cut(id, "/")
+	if !found {
+		return "", id
+	}
+
+	return namespace, name
+}
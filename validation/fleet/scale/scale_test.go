@@ -0,0 +1,137 @@
+//go:build validation || stress
+
+package scale
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/gitserver"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const (
+	fixturesPath = "../resources/gitrepos"
+	repoPrefix   = "fleet-scale"
+)
+
+type FleetScaleTestSuite struct {
+	suite.Suite
+	client      *rancher.Client
+	session     *session.Session
+	scaleConfig *Config
+	gitServer   *gitserver.GitServer
+}
+
+func (f *FleetScaleTestSuite) TearDownSuite() {
+	f.session.Cleanup()
+}
+
+func (f *FleetScaleTestSuite) SetupSuite() {
+	f.session = session.NewSession()
+
+	client, err := rancher.NewClient("", f.session)
+	require.NoError(f.T(), err)
+
+	f.client = client
+	f.scaleConfig = LoadConfig()
+
+	gitServerConfig := gitserver.LoadConfig()
+	if gitServerConfig.FixturesPath == gitserver.DefaultFixturesPath {
+		gitServerConfig.FixturesPath = fixturesPath
+	}
+
+	f.gitServer, err = gitserver.DeployGitServer(f.client, gitServerConfig)
+	require.NoError(f.T(), err)
+}
+
+func (f *FleetScaleTestSuite) TestFleetScale() {
+	results := &Results{
+		StartedAt:       time.Now(),
+		Config:          *f.scaleConfig,
+		ControllerUsage: map[string]*ResourceUsage{},
+	}
+
+	var err error
+	results.FleetVersion, err = fleet.GetDeploymentVersion(f.client, fleet.FleetControllerName, fleet.LocalName)
+	require.NoError(f.T(), err)
+
+	groupSelectors := f.scaleConfig.ClusterGroups
+	var simulatedClusters []string
+	if f.scaleConfig.SimulatedClusters > 0 {
+		groupSelectors = f.scaleConfig.SimulatedGroupLabels()
+		simulatedClusters, err = RegisterSimulatedClusters(f.client, f.scaleConfig.SimulatedClusters, groupSelectors)
+		require.NoError(f.T(), err)
+	}
+
+	clusterGroups, err := CreateClusterGroups(f.client, groupSelectors)
+	require.NoError(f.T(), err)
+
+	groupSizes := map[string]int{}
+	for _, clusterGroup := range clusterGroups {
+		groupSizes[clusterGroup], err = GetClusterGroupSize(f.client, clusterGroup)
+		require.NoError(f.T(), err)
+
+		results.Clusters += groupSizes[clusterGroup]
+	}
+
+	repoPaths := map[string][]string{}
+	var repoNames []string
+	for i := 0; i < f.scaleConfig.GitRepos; i++ {
+		repoName := namegenerator.AppendRandomString(repoPrefix)
+		repoPaths[repoName], err = SeedBenchmarkRepo(f.gitServer, repoName, f.scaleConfig.Bundle, f.scaleConfig.BundlesPerGitRepo, simulatedClusters)
+		require.NoError(f.T(), err)
+
+		repoNames = append(repoNames, repoName)
+	}
+
+	apiRequestsBefore, err := GetAPIRequestCounts(f.client)
+	if err != nil {
+		logrus.Warnf("Unable to read kube-apiserver request counts, they will not be reported: %v", err)
+	}
+
+	gitRepoCreated := map[string]time.Time{}
+	expectedBundleDeployments := 0
+	for i, repoName := range repoNames {
+		clusterGroup := clusterGroups[i%len(clusterGroups)]
+
+		gitRepoCreated[repoName] = time.Now()
+		_, err = CreateBenchmarkGitRepo(f.client, f.gitServer, repoName, repoPaths[repoName], clusterGroup)
+		require.NoError(f.T(), err)
+
+		expectedBundleDeployments += f.scaleConfig.BundlesPerGitRepo * groupSizes[clusterGroup]
+	}
+
+	timeout := time.Duration(f.scaleConfig.TimeoutMinutes) * time.Minute
+	results.BundleDeployments, err = WaitForBundleDeploymentsReady(f.client, gitRepoCreated, expectedBundleDeployments, timeout, results.ControllerUsage)
+	results.TotalSeconds = time.Since(results.StartedAt).Seconds()
+	results.TimeToReady = NewPercentiles(results.BundleDeployments)
+
+	if apiRequestsBefore != nil {
+		apiRequestsAfter, apiErr := GetAPIRequestCounts(f.client)
+		if apiErr == nil {
+			results.APIRequests = &APIRequestCounts{
+				Total: apiRequestsAfter.Total - apiRequestsBefore.Total,
+				Fleet: apiRequestsAfter.Fleet - apiRequestsBefore.Fleet,
+			}
+		}
+	}
+
+	require.NoError(f.T(), WriteResults(results, f.scaleConfig.ResultsFile))
+	require.NoError(f.T(), err)
+
+	logrus.Infof("%d bundle deployments ready, time to ready p50 %.1fs p90 %.1fs p99 %.1fs max %.1fs", len(results.BundleDeployments),
+		results.TimeToReady.P50, results.TimeToReady.P90, results.TimeToReady.P99, results.TimeToReady.Max)
+}
+
+// In order for 'go test' to run this suite, we need to create
+// a normal test function and pass our suite to suite.Run
+func TestFleetScaleTestSuite(t *testing.T) {
+	suite.Run(t, new(FleetScaleTestSuite))
+}
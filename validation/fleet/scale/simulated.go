@@ -0,0 +1,319 @@
+package scale
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/rancher/fleet/pkg/apis/fleet.cattle.io/v1alpha1"
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/defaults"
+	"github.com/rancher/shepherd/extensions/unstructured"
+	"github.com/rancher/shepherd/pkg/namegenerator"
+	kubenamespaces "github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/kubeapi/workloads/deployments"
+	"github.com/rancher/tests/interoperability/fleet"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeunstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	localClusterID          = "local"
+	localAgentNamespace     = "cattle-fleet-local-system"
+	agentName               = "fleet-agent"
+	agentBootstrapSecret    = "fleet-agent-bootstrap"
+	agentConfigKey          = "config"
+	registrationValuesKey   = "values"
+	inClusterAPIServerURL   = "https://kubernetes.default.svc.cluster.local"
+	rootCAConfigMap         = "kube-root-ca.crt"
+	rootCAKey               = "ca.crt"
+	simulatedClusterLabel   = "fleet-scale-simulated"
+	registrationTokenTTL    = time.Hour
+	clusterAdminClusterRole = "cluster-admin"
+)
+
+var (
+	clusterRegistrationTokenGroupVersionResource = v1alpha1.SchemeGroupVersion.WithResource("clusterregistrationtokens")
+	clusterRoleBindingGroupVersionResource       = rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings")
+	serviceAccountGroupVersionResource           = corev1.SchemeGroupVersion.WithResource("serviceaccounts")
+	secretGroupVersionResource                   = corev1.SchemeGroupVersion.WithResource("secrets")
+	configMapGroupVersionResource                = corev1.SchemeGroupVersion.WithResource("configmaps")
+)
+
+// RegisterSimulatedClusters is a helper function that registers agent-only fleet clusters backed by the local cluster.
+// Every simulated cluster is a fleet agent, cloned from the local agent, running in its own namespace with its own
+// agent scope. It is labeled with the cluster group it belongs to. It returns the fleet cluster names.
+func RegisterSimulatedClusters(client *rancher.Client, count int, groupLabels []map[string]string) ([]string, error) {
+	agentTemplate, err := localAgentPodTemplate(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusterNames []string
+	for i := 0; i < count; i++ {
+		agentNamespace := namegenerator.AppendRandomString("fleet-sim")
+		labels := map[string]string{simulatedClusterLabel: agentNamespace}
+		for key, value := range groupLabels[i%len(groupLabels)] {
+			labels[key] = value
+		}
+
+		logrus.Infof("Registering simulated cluster %d/%d in namespace %s", i+1, count, agentNamespace)
+		err = deploySimulatedAgent(client, agentNamespace, labels, *agentTemplate.DeepCopy())
+		if err != nil {
+			return nil, err
+		}
+
+		clusterName, err := waitForSimulatedCluster(client, agentNamespace)
+		if err != nil {
+			return nil, err
+		}
+
+		clusterNames = append(clusterNames, clusterName)
+	}
+
+	return clusterNames, nil
+}
+
+// deploySimulatedAgent is a private helper function that creates a cluster registration token and deploys a fleet
+// agent that registers with it.
+func deploySimulatedAgent(client *rancher.Client, agentNamespace string, labels map[string]string, template corev1.PodTemplateSpec) error {
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return err
+	}
+
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: agentNamespace}}
+	_, err = dynamicClient.Resource(kubenamespaces.NamespaceGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(namespace), metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	registrationValues, err := createRegistrationToken(client, agentNamespace)
+	if err != nil {
+		return err
+	}
+
+	apiServerURL, _ := registrationValues["apiServerURL"].(string)
+	apiServerCA, _ := registrationValues["apiServerCA"].(string)
+	if apiServerURL == "" {
+		apiServerURL = inClusterAPIServerURL
+	}
+
+	if apiServerCA == "" {
+		rootCA, err := client.WranglerContext.Core.ConfigMap().Get(agentNamespace, rootCAConfigMap, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		apiServerCA = rootCA.Data[rootCAKey]
+	}
+
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: agentBootstrapSecret, Namespace: agentNamespace},
+		Data: map[string][]byte{
+			"token":                       []byte(fmt.Sprint(registrationValues["token"])),
+			"clusterNamespace":            []byte(fmt.Sprint(registrationValues["clusterNamespace"])),
+			"systemRegistrationNamespace": []byte(fmt.Sprint(registrationValues["systemRegistrationNamespace"])),
+			"apiServerURL":                []byte(apiServerURL),
+			"apiServerCA":                 []byte(apiServerCA),
+		},
+	}
+
+	agentConfig, err := json.Marshal(map[string]interface{}{
+		"labels":   labels,
+		"clientID": agentNamespace,
+	})
+	if err != nil {
+		return err
+	}
+
+	agentConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: agentName, Namespace: agentNamespace},
+		Data:       map[string]string{agentConfigKey: string(agentConfig)},
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: agentName, Namespace: agentNamespace},
+	}
+
+	namespacedObjects := []struct {
+		resource schema.GroupVersionResource
+		object   runtime.Object
+	}{
+		{secretGroupVersionResource, bootstrapSecret},
+		{configMapGroupVersionResource, agentConfigMap},
+		{serviceAccountGroupVersionResource, serviceAccount},
+	}
+
+	for _, namespacedObject := range namespacedObjects {
+		_, err = dynamicClient.Resource(namespacedObject.resource).Namespace(agentNamespace).Create(context.TODO(), unstructured.MustToUnstructured(namespacedObject.object), metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+	}
+
+	clusterRoleBinding := &rbacv1.ClusterRoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: agentNamespace},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     clusterAdminClusterRole,
+		},
+		Subjects: []rbacv1.Subject{
+			{Kind: rbacv1.ServiceAccountKind, Name: agentName, Namespace: agentNamespace},
+		},
+	}
+
+	_, err = dynamicClient.Resource(clusterRoleBindingGroupVersionResource).Namespace("").Create(context.TODO(), unstructured.MustToUnstructured(clusterRoleBinding), metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	template.Spec.ServiceAccountName = agentName
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Env = setEnv(template.Spec.Containers[i].Env, "NAMESPACE", agentNamespace)
+		template.Spec.Containers[i].Env = setEnv(template.Spec.Containers[i].Env, "AGENT_SCOPE", agentNamespace)
+	}
+
+	_, err = deployments.CreateDeployment(client, localClusterID, agentName, agentNamespace, template, 1)
+
+	return err
+}
+
+// createRegistrationToken is a private helper function that creates a fleet cluster registration token in the fleet
+// workspace and returns the registration values fleet generates for it.
+func createRegistrationToken(client *rancher.Client, tokenName string) (map[string]interface{}, error) {
+	dynamicClient, err := client.GetDownStreamClusterClient(localClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &v1alpha1.ClusterRegistrationToken{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "ClusterRegistrationToken",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: tokenName, Namespace: fleet.Namespace},
+		Spec: v1alpha1.ClusterRegistrationTokenSpec{
+			TTL: &metav1.Duration{Duration: registrationTokenTTL},
+		},
+	}
+
+	// fleet types are not registered in the shepherd scheme, so the token is converted without it
+	unstructuredToken, err := runtime.DefaultUnstructuredConverter.ToUnstructured(token)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenResource := dynamicClient.Resource(clusterRegistrationTokenGroupVersionResource).Namespace(fleet.Namespace)
+	_, err = tokenResource.Create(context.TODO(), &kubeunstructured.Unstructured{Object: unstructuredToken}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var secretName string
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.OneMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		unstructuredToken, err := tokenResource.Get(ctx, tokenName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+
+		secretName, _, err = kubeunstructured.NestedString(unstructuredToken.Object, "status", "secretName")
+		if err != nil {
+			return false, err
+		}
+
+		return secretName != "", nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := client.WranglerContext.Core.Secret().Get(fleet.Namespace, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(secret.Data[registrationValuesKey], &values)
+	if err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// waitForSimulatedCluster is a private helper function that waits for the fleet cluster of a simulated agent to be
+// registered and returns its name. The fleet cluster is deleted on session cleanup.
+func waitForSimulatedCluster(client *rancher.Client, agentNamespace string) (string, error) {
+	query := url.Values{"labelSelector": {simulatedClusterLabel + "=" + agentNamespace}}
+
+	var clusterID string
+	err := kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		clusters, err := client.Steve.SteveType(fleet.FleetClusterResourceType).List(query)
+		if err != nil {
+			return false, err
+		}
+
+		if len(clusters.Data) == 0 {
+			return false, nil
+		}
+
+		clusterID = clusters.Data[0].ID
+
+		return true, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("simulated cluster %s did not register: %w", agentNamespace, err)
+	}
+
+	client.Session.RegisterCleanupFunc(func() error {
+		cluster, err := client.Steve.SteveType(fleet.FleetClusterResourceType).ByID(clusterID)
+		if err != nil {
+			return err
+		}
+
+		return client.Steve.SteveType(fleet.FleetClusterResourceType).Delete(cluster)
+	})
+
+	_, clusterName := splitID(clusterID)
+
+	return clusterName, nil
+}
+
+// localAgentPodTemplate is a private helper function that returns the pod template of the local cluster fleet agent,
+// which runs as a statefulset in recent fleet versions and as a deployment before.
+func localAgentPodTemplate(client *rancher.Client) (*corev1.PodTemplateSpec, error) {
+	statefulSet, err := client.WranglerContext.Apps.StatefulSet().Get(localAgentNamespace, agentName, metav1.GetOptions{})
+	if err == nil {
+		return &statefulSet.Spec.Template, nil
+	}
+
+	deployment, err := client.WranglerContext.Apps.Deployment().Get(localAgentNamespace, agentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &deployment.Spec.Template, nil
+}
+
+// setEnv is a private helper function that sets an environment variable, replacing it if it is already set.
+func setEnv(env []corev1.EnvVar, name, value string) []corev1.EnvVar {
+	for i := range env {
+		if env[i].Name == name {
+			env[i] = corev1.EnvVar{Name: name, Value: value}
+			return env
+		}
+	}
+
+	return append(env, corev1.EnvVar{Name: name, Value: value})
+}
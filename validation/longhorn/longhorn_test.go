@@ -0,0 +1,257 @@
+//go:build (validation || infra.any || cluster.any || extended || pit.daily) && !sanity && !stress
+
+package longhorn
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/clients/rancher/catalog"
+	"github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/config"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/charts"
+	"github.com/rancher/tests/actions/disasterrecovery"
+	"github.com/rancher/tests/actions/kubeapi/namespaces"
+	"github.com/rancher/tests/actions/longhorn"
+	"github.com/rancher/tests/actions/s3server"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	dataFile     = "longhorn-data"
+	dataSizeMB   = 64
+	volumeSize   = "1Gi"
+	expandedSize = "2Gi"
+	backupFolder = "longhorn"
+)
+
+type LonghornTestSuite struct {
+	suite.Suite
+	client         *rancher.Client
+	session        *session.Session
+	cluster        *clusters.ClusterMeta
+	longhornConfig *longhorn.Config
+	installed      bool
+}
+
+func (l *LonghornTestSuite) TearDownSuite() {
+	l.session.Cleanup()
+}
+
+func (l *LonghornTestSuite) SetupSuite() {
+	l.session = session.NewSession()
+
+	client, err := rancher.NewClient("", l.session)
+	require.NoError(l.T(), err)
+
+	l.client = client
+
+	clusterName := client.RancherConfig.ClusterName
+	require.NotEmptyf(l.T(), clusterName, "Cluster name to install should be set")
+
+	l.cluster, err = clusters.NewClusterMeta(client, clusterName)
+	require.NoError(l.T(), err)
+
+	l.longhornConfig = new(longhorn.Config)
+	config.LoadConfig(longhorn.ConfigurationFileKey, l.longhornConfig)
+
+	catalogClient, err := l.client.GetClusterCatalogClient(l.cluster.ID)
+	require.NoError(l.T(), err)
+
+	_, err = catalogClient.Apps(longhorn.LonghornNamespace).Get(context.TODO(), longhorn.LonghornChartName, metav1.GetOptions{})
+	if err == nil {
+		logrus.Infof("Longhorn is already installed on cluster %s", clusterName)
+		return
+	}
+
+	require.True(l.T(), apierrors.IsNotFound(err), err)
+
+	if l.longhornConfig.ChartVersion == "" {
+		l.longhornConfig.ChartVersion, err = l.client.Catalog.GetLatestChartVersion(longhorn.LonghornChartName, catalog.RancherChartRepo)
+		require.NoError(l.T(), err)
+	}
+
+	err = longhorn.InstallLonghornChart(l.client, &charts.InstallOptions{
+		Cluster: l.cluster,
+		Version: l.longhornConfig.ChartVersion,
+	}, l.longhornConfig)
+	require.NoError(l.T(), err)
+
+	l.installed = true
+}
+
+func (l *LonghornTestSuite) TestLonghornInstallation() {
+	if !l.installed {
+		l.T().Skipf("Longhorn was already installed on cluster %s", l.cluster.Name)
+	}
+
+	err := longhorn.WaitForLonghornReady(l.client, l.cluster.ID)
+	require.NoError(l.T(), err)
+
+	volume := l.createVolume(longhorn.LonghornStorageClass)
+
+	_, err = volume.WriteData(l.client, dataFile, dataSizeMB)
+	require.NoError(l.T(), err)
+}
+
+func (l *LonghornTestSuite) TestLonghornUIAccess() {
+	l.createVolume(longhorn.LonghornStorageClass)
+
+	err := longhorn.VerifyRancherIntegration(l.client, l.cluster.ID)
+	require.NoError(l.T(), err)
+}
+
+func (l *LonghornTestSuite) TestVolumeReplicas() {
+	tests := []struct {
+		name     string
+		replicas int
+	}{
+		{"Longhorn_Volume_1_Replica", 1},
+		{"Longhorn_Volume_2_Replicas", 2},
+		{"Longhorn_Volume_3_Replicas", 3},
+	}
+
+	for _, tt := range tests {
+		l.Run(tt.name, func() {
+			l.skipWithoutSchedulableNodes(tt.replicas)
+
+			storageClass, err := longhorn.CreateStorageClass(l.client, l.cluster.ID, tt.replicas, nil)
+			require.NoError(l.T(), err)
+
+			volume := l.createVolume(storageClass.Name)
+
+			err = longhorn.VerifyReplicas(l.client, l.cluster.ID, volume.Name, tt.replicas)
+			require.NoError(l.T(), err)
+
+			checksum, err := volume.WriteData(l.client, dataFile, dataSizeMB)
+			require.NoError(l.T(), err)
+
+			err = volume.Restart(l.client)
+			require.NoError(l.T(), err)
+
+			restartedChecksum, err := volume.Checksum(l.client, dataFile)
+			require.NoError(l.T(), err)
+			require.Equal(l.T(), checksum, restartedChecksum)
+		})
+	}
+}
+
+func (l *LonghornTestSuite) TestVolumeExpansion() {
+	storageClass, err := longhorn.CreateStorageClass(l.client, l.cluster.ID, 2, nil)
+	require.NoError(l.T(), err)
+
+	volume := l.createVolume(storageClass.Name)
+
+	checksum, err := volume.WriteData(l.client, dataFile, dataSizeMB)
+	require.NoError(l.T(), err)
+
+	err = volume.Expand(l.client, expandedSize)
+	require.NoError(l.T(), err)
+
+	expandedChecksum, err := volume.Checksum(l.client, dataFile)
+	require.NoError(l.T(), err)
+	require.Equal(l.T(), checksum, expandedChecksum)
+}
+
+func (l *LonghornTestSuite) TestSnapshotBackupRestore() {
+	l.skipWithoutSchedulableNodes(2)
+
+	s3Server, err := s3server.DeployS3Server(l.client, s3server.LoadConfig())
+	require.NoError(l.T(), err)
+
+	err = longhorn.ConfigureBackupTarget(l.client, l.cluster.ID, s3Server, backupFolder)
+	require.NoError(l.T(), err)
+
+	storageClass, err := longhorn.CreateStorageClass(l.client, l.cluster.ID, 2, nil)
+	require.NoError(l.T(), err)
+
+	volume := l.createVolume(storageClass.Name)
+
+	checksum, err := volume.WriteData(l.client, dataFile, dataSizeMB)
+	require.NoError(l.T(), err)
+
+	snapshot, err := longhorn.CreateSnapshot(l.client, l.cluster.ID, volume.Name)
+	require.NoError(l.T(), err)
+
+	backup, err := longhorn.CreateBackup(l.client, l.cluster.ID, volume.Name, snapshot)
+	require.NoError(l.T(), err)
+
+	restoredVolume, err := longhorn.RestoreBackup(l.client, l.cluster.ID, volume.Namespace, backup, 2, volumeSize)
+	require.NoError(l.T(), err)
+
+	restoredChecksum, err := restoredVolume.Checksum(l.client, dataFile)
+	require.NoError(l.T(), err)
+	require.Equal(l.T(), checksum, restoredChecksum)
+}
+
+func (l *LonghornTestSuite) TestReplicaRebuild() {
+	machines, err := disasterrecovery.ListMachines(l.client, l.cluster.Name)
+	require.NoError(l.T(), err)
+
+	if len(machines) == 0 {
+		l.T().Skipf("Cluster %s has no machines to destroy", l.cluster.Name)
+	}
+
+	l.skipWithoutSchedulableNodes(2)
+
+	storageClass, err := longhorn.CreateStorageClass(l.client, l.cluster.ID, 2, nil)
+	require.NoError(l.T(), err)
+
+	volume := l.createVolume(storageClass.Name)
+
+	checksum, err := volume.WriteData(l.client, dataFile, dataSizeMB)
+	require.NoError(l.T(), err)
+
+	lostNode, err := longhorn.DestroyReplicaNode(l.client, l.cluster.Name, l.cluster.ID, volume.Name)
+	if errors.Is(err, longhorn.ErrNoWorkerReplicaNode) {
+		l.T().Skipf("Cluster %s has no worker only replica node to destroy: %v", l.cluster.Name, err)
+	}
+	require.NoError(l.T(), err)
+
+	err = longhorn.WaitForReplicaRebuild(l.client, l.cluster.ID, volume.Name, lostNode, 2)
+	require.NoError(l.T(), err)
+
+	rebuiltChecksum, err := volume.Checksum(l.client, dataFile)
+	require.NoError(l.T(), err)
+	require.Equal(l.T(), checksum, rebuiltChecksum)
+
+	err = disasterrecovery.WaitForReplacementMachines(l.client, l.cluster.Name, nil, len(machines))
+	require.NoError(l.T(), err)
+
+	err = longhorn.WaitForLonghornReady(l.client, l.cluster.ID)
+	require.NoError(l.T(), err)
+}
+
+// skipWithoutSchedulableNodes skips the test when the cluster has fewer longhorn nodes that can schedule replicas than
+// a volume needs.
+func (l *LonghornTestSuite) skipWithoutSchedulableNodes(replicas int) {
+	schedulableNodes, err := longhorn.SchedulableNodes(l.client, l.cluster.ID)
+	require.NoError(l.T(), err)
+
+	if schedulableNodes < replicas {
+		l.T().Skipf("Cluster %s has %d schedulable longhorn nodes, %d replicas need as many", l.cluster.Name, schedulableNodes, replicas)
+	}
+}
+
+// createVolume creates a longhorn volume of a storage class in a new namespace.
+func (l *LonghornTestSuite) createVolume(storageClassName string) *longhorn.Volume {
+	namespace, err := namespaces.CreateNamespace(l.client, l.cluster.ID, "", namegen.AppendRandomString("longhorn"), "", nil, nil)
+	require.NoError(l.T(), err)
+
+	volume, err := longhorn.CreateVolume(l.client, l.cluster.ID, namespace.Name, storageClassName, volumeSize)
+	require.NoError(l.T(), err)
+
+	return volume
+}
+
+func TestLonghornTestSuite(t *testing.T) {
+	suite.Run(t, new(LonghornTestSuite))
+}
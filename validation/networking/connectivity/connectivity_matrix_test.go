@@ -0,0 +1,220 @@
+//go:build (validation || infra.rke2k3s || cluster.any) && !stress && !extended && !sanity
+
+package connectivity
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/cloudcredentials"
+	extensionClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	namegen "github.com/rancher/shepherd/pkg/namegenerator"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/connectivity"
+	"github.com/rancher/tests/actions/logging"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/provisioninginput"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	allowLabel = "connectivity.cattle.io/allow"
+	cniKey     = "cni"
+)
+
+type matrixCluster struct {
+	name string
+	id   string
+	cni  string
+}
+
+type ConnectivityMatrixTestSuite struct {
+	suite.Suite
+	session      *session.Session
+	client       *rancher.Client
+	cattleConfig map[string]any
+	clusters     []matrixCluster
+}
+
+func (c *ConnectivityMatrixTestSuite) TearDownSuite() {
+	c.session.Cleanup()
+}
+
+func (c *ConnectivityMatrixTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	c.session = testSession
+
+	client, err := rancher.NewClient("", testSession)
+	require.NoError(c.T(), err)
+
+	c.client = client
+
+	provisioningConfig := new(provisioninginput.Config)
+	config.LoadConfig(provisioninginput.ConfigurationFileKey, provisioningConfig)
+
+	if len(provisioningConfig.CNIs) == 0 {
+		clusterName := client.RancherConfig.ClusterName
+		require.NotEmpty(c.T(), clusterName, "Cluster name to run the connectivity matrix on is not set")
+
+		clusterID, err := extensionClusters.GetClusterIDByName(c.client, clusterName)
+		require.NoError(c.T(), err)
+
+		c.clusters = append(c.clusters, matrixCluster{name: clusterName, id: clusterID, cni: clusterCNI(c.client, clusterName)})
+
+		return
+	}
+
+	c.cattleConfig = config.LoadConfigFromFile(os.Getenv(config.ConfigEnvironmentKey))
+
+	c.cattleConfig, err = defaults.LoadPackageDefaults(c.cattleConfig, "")
+	require.NoError(c.T(), err)
+
+	loggingConfig := new(logging.Logging)
+	operations.LoadObjectFromMap(logging.LoggingKey, c.cattleConfig, loggingConfig)
+
+	err = logging.SetLogger(loggingConfig)
+	require.NoError(c.T(), err)
+
+	c.cattleConfig, err = defaults.SetK8sDefault(c.client, defaults.RKE2, c.cattleConfig)
+	require.NoError(c.T(), err)
+
+	for _, cni := range provisioningConfig.CNIs {
+		clusterConfig := new(clusters.ClusterConfig)
+		operations.LoadObjectFromMap(defaults.ClusterConfigKey, c.cattleConfig, clusterConfig)
+		require.NotNil(c.T(), clusterConfig.Provider)
+
+		clusterConfig.CNI = cni
+		clusterConfig.EnableNetworkPolicy = true
+
+		provider := provisioning.CreateProvider(clusterConfig.Provider)
+		credentialSpec := cloudcredentials.LoadCloudCredential(string(provider.Name))
+		machineConfigSpec := provider.LoadMachineConfigFunc(c.cattleConfig)
+
+		logrus.Infof("Provisioning an RKE2 cluster with CNI %s", cni)
+		cluster, err := provisioning.CreateProvisioningCluster(c.client, provider, credentialSpec, clusterConfig, machineConfigSpec, nil)
+		require.NoError(c.T(), err)
+
+		provisioning.VerifyClusterReady(c.T(), c.client, cluster)
+
+		clusterID, err := extensionClusters.GetClusterIDByName(c.client, cluster.Name)
+		require.NoError(c.T(), err)
+
+		c.clusters = append(c.clusters, matrixCluster{name: cluster.Name, id: clusterID, cni: cni})
+	}
+}
+
+func (c *ConnectivityMatrixTestSuite) TestConnectivityMatrix() {
+	for _, cluster := range c.clusters {
+		var probes *connectivity.Probes
+
+		cniName := cluster.cni
+		if cniName == "" {
+			cniName = "Default_CNI"
+		}
+
+		c.Run("Probes_"+cniName, func() {
+			projectA, err := c.client.Management.Project.Create(projects.NewProjectConfig(cluster.id))
+			require.NoError(c.T(), err)
+
+			projectB, err := c.client.Management.Project.Create(projects.NewProjectConfig(cluster.id))
+			require.NoError(c.T(), err)
+
+			projectAName := strings.Split(projectA.ID, ":")[1]
+			projectBName := strings.Split(projectB.ID, ":")[1]
+
+			logrus.Infof("Deploying probe pods on cluster %s", cluster.name)
+			probes, err = connectivity.DeployProbes(c.client, cluster.id, []connectivity.ProbeNamespace{
+				{Project: projectAName},
+				{Project: projectAName},
+				{Project: projectBName, Labels: map[string]string{allowLabel: "true"}},
+			})
+			require.NoError(c.T(), err)
+
+			err = connectivity.ValidateConnectivity(c.client, probes, cluster.cni)
+			require.NoError(c.T(), err)
+		})
+
+		c.Run("Ingress_Network_Policy_"+cniName, func() {
+			require.NotNil(c.T(), probes, "Probe pods were not deployed")
+
+			tcp := corev1.ProtocolTCP
+			port := intstr.FromInt(connectivity.Port(connectivity.TCP))
+
+			logrus.Infof("Allowing ingress to namespace %s only from labeled namespaces over TCP", probes.Namespaces[0].Name)
+			_, err := connectivity.CreateNetworkPolicy(c.client, cluster.id, &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      namegen.AppendRandomString("ingress"),
+					Namespace: probes.Namespaces[0].Name,
+				},
+				Spec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+					Ingress: []networkingv1.NetworkPolicyIngressRule{{
+						From: []networkingv1.NetworkPolicyPeer{{
+							NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{allowLabel: "true"}},
+						}},
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+					}},
+				},
+			})
+			require.NoError(c.T(), err)
+
+			err = connectivity.ValidateConnectivity(c.client, probes, cluster.cni)
+			require.NoError(c.T(), err)
+		})
+
+		c.Run("Egress_Network_Policy_"+cniName, func() {
+			require.NotNil(c.T(), probes, "Probe pods were not deployed")
+
+			udp := corev1.ProtocolUDP
+
+			logrus.Infof("Allowing egress from namespace %s only over UDP", probes.Namespaces[1].Name)
+			_, err := connectivity.CreateNetworkPolicy(c.client, cluster.id, &networkingv1.NetworkPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      namegen.AppendRandomString("egress"),
+					Namespace: probes.Namespaces[1].Name,
+				},
+				Spec: networkingv1.NetworkPolicySpec{
+					PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+					Egress: []networkingv1.NetworkPolicyEgressRule{{
+						Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp}},
+					}},
+				},
+			})
+			require.NoError(c.T(), err)
+
+			err = connectivity.ValidateConnectivity(c.client, probes, cluster.cni)
+			require.NoError(c.T(), err)
+		})
+	}
+}
+
+// clusterCNI is a private helper function that returns the CNI of an RKE2 or K3s cluster, which is empty for other
+// clusters.
+func clusterCNI(client *rancher.Client, clusterName string) string {
+	cluster, _, err := extensionClusters.GetProvisioningClusterByName(client, clusterName, namespaces.FleetDefault)
+	if err != nil || cluster.Spec.RKEConfig == nil || cluster.Spec.RKEConfig.MachineGlobalConfig.Data == nil {
+		return ""
+	}
+
+	cni, _ := cluster.Spec.RKEConfig.MachineGlobalConfig.Data[cniKey].(string)
+
+	return cni
+}
+
+func TestConnectivityMatrixTestSuite(t *testing.T) {
+	suite.Run(t, new(ConnectivityMatrixTestSuite))
+}
@@ -0,0 +1,259 @@
+//go:build (validation || infra.rke2k3s || cluster.any) && !stress && !extended && !sanity
+
+package ingress
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/extensions/cloudcredentials"
+	extensionClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/ingresscontroller"
+	kubeingresses "github.com/rancher/tests/actions/kubeapi/ingresses"
+	"github.com/rancher/tests/actions/logging"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+const (
+	ingressConformanceConfigKey = "ingressConformance"
+	unclaimedClass              = "unclaimed"
+
+	backendA       = "backend-a"
+	backendB       = "backend-b"
+	backendC       = "backend-c"
+	defaultBackend = "backend-default"
+
+	pathsHost     = "paths" + ingresscontroller.HostSuffix
+	wildcardHost  = "*.wildcard" + ingresscontroller.HostSuffix
+	wildcardMatch = "match.wildcard" + ingresscontroller.HostSuffix
+	wildcardApex  = "wildcard" + ingresscontroller.HostSuffix
+	unclaimedHost = "unclaimed" + ingresscontroller.HostSuffix
+	unmatchedHost = "unmatched" + ingresscontroller.HostSuffix
+)
+
+// ingressConformanceConfig provisions an RKE2 and a K3s cluster from clusterConfig to run the suite against both
+// bundled ingress controllers when provision is set. Otherwise the suite runs on rancher.clusterName.
+type ingressConformanceConfig struct {
+	Provision bool `json:"provision" yaml:"provision"`
+}
+
+type conformanceCluster struct {
+	name string
+	id   string
+}
+
+type IngressConformanceTestSuite struct {
+	suite.Suite
+	session  *session.Session
+	client   *rancher.Client
+	clusters []conformanceCluster
+}
+
+func (i *IngressConformanceTestSuite) TearDownSuite() {
+	i.session.Cleanup()
+}
+
+func (i *IngressConformanceTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	i.session = testSession
+
+	client, err := rancher.NewClient("", testSession)
+	require.NoError(i.T(), err)
+
+	i.client = client
+
+	conformanceConfig := new(ingressConformanceConfig)
+	config.LoadConfig(ingressConformanceConfigKey, conformanceConfig)
+
+	if !conformanceConfig.Provision {
+		clusterName := client.RancherConfig.ClusterName
+		require.NotEmpty(i.T(), clusterName, "Cluster name to run the ingress conformance suite on is not set")
+
+		clusterID, err := extensionClusters.GetClusterIDByName(i.client, clusterName)
+		require.NoError(i.T(), err)
+
+		i.clusters = append(i.clusters, conformanceCluster{name: clusterName, id: clusterID})
+
+		return
+	}
+
+	for _, k8sType := range []string{defaults.RKE2, defaults.K3S} {
+		cattleConfig := config.LoadConfigFromFile(os.Getenv(config.ConfigEnvironmentKey))
+
+		cattleConfig, err = defaults.LoadPackageDefaults(cattleConfig, "")
+		require.NoError(i.T(), err)
+
+		loggingConfig := new(logging.Logging)
+		operations.LoadObjectFromMap(logging.LoggingKey, cattleConfig, loggingConfig)
+
+		err = logging.SetLogger(loggingConfig)
+		require.NoError(i.T(), err)
+
+		cattleConfig, err = defaults.SetK8sDefault(i.client, k8sType, cattleConfig)
+		require.NoError(i.T(), err)
+
+		clusterConfig := new(clusters.ClusterConfig)
+		operations.LoadObjectFromMap(defaults.ClusterConfigKey, cattleConfig, clusterConfig)
+		require.NotEmpty(i.T(), clusterConfig.Provider)
+
+		provider := provisioning.CreateProvider(clusterConfig.Provider)
+		credentialSpec := cloudcredentials.LoadCloudCredential(string(provider.Name))
+		machineConfigSpec := provider.LoadMachineConfigFunc(cattleConfig)
+
+		logrus.Infof("Provisioning a %s cluster", k8sType)
+		cluster, err := provisioning.CreateProvisioningCluster(i.client, provider, credentialSpec, clusterConfig, machineConfigSpec, nil)
+		require.NoError(i.T(), err)
+
+		provisioning.VerifyClusterReady(i.T(), i.client, cluster)
+
+		clusterID, err := extensionClusters.GetClusterIDByName(i.client, cluster.Name)
+		require.NoError(i.T(), err)
+
+		i.clusters = append(i.clusters, conformanceCluster{name: cluster.Name, id: clusterID})
+	}
+}
+
+func (i *IngressConformanceTestSuite) TestIngressConformance() {
+	for _, cluster := range i.clusters {
+		class, err := ingresscontroller.BundledIngressClass(i.client, cluster.id)
+		require.NoError(i.T(), err)
+
+		controller := ingresscontroller.ControllerName(class)
+
+		var fixture *ingresscontroller.Fixture
+		var pathsIngress *networkingv1.Ingress
+
+		i.Run("Path_Types_"+controller, func() {
+			logrus.Infof("Deploying ingress backends on cluster %s", cluster.name)
+			fixture, err = ingresscontroller.NewFixture(i.client, cluster.id, backendA, backendB, backendC, defaultBackend)
+			require.NoError(i.T(), err)
+
+			pathsIngress, err = fixture.CreateIngress(i.client, "paths", networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{ingresscontroller.Rule(pathsHost,
+					fixture.Path("/exact", networkingv1.PathTypeExact, backendA),
+					fixture.Path("/prefix", networkingv1.PathTypePrefix, backendB),
+					fixture.Path("/specific", networkingv1.PathTypeImplementationSpecific, backendC),
+				)},
+			})
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "Exact path", Host: pathsHost, Path: "/exact", Backend: backendA},
+				{Name: "Exact path with a subpath", Host: pathsHost, Path: "/exact/sub"},
+				{Name: "Prefix path", Host: pathsHost, Path: "/prefix", Backend: backendB},
+				{Name: "Prefix path with a trailing slash", Host: pathsHost, Path: "/prefix/", Backend: backendB},
+				{Name: "Prefix path with a subpath", Host: pathsHost, Path: "/prefix/sub", Backend: backendB},
+				{Name: "ImplementationSpecific path with a subpath", Host: pathsHost, Path: "/specific/sub", Backend: backendC},
+				{Name: "Unmatched path", Host: pathsHost, Path: "/other"},
+			})
+			require.NoError(i.T(), err)
+		})
+
+		i.Run("Host_Wildcard_"+controller, func() {
+			require.NotNil(i.T(), fixture, "Ingress backends were not deployed")
+
+			_, err := fixture.CreateIngress(i.client, "wildcard", networkingv1.IngressSpec{
+				Rules: []networkingv1.IngressRule{ingresscontroller.Rule(wildcardHost,
+					fixture.Path("/", networkingv1.PathTypePrefix, backendA),
+				)},
+			})
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "Wildcard host", Host: wildcardMatch, Path: "/", Backend: backendA},
+				{Name: "Wildcard apex host", Host: wildcardApex, Path: "/"},
+			})
+			require.NoError(i.T(), err)
+		})
+
+		i.Run("TLS_Termination_"+controller, func() {
+			require.NotNil(i.T(), fixture, "Ingress backends were not deployed")
+
+			err := fixture.CreateTLSSecret(i.client)
+			require.NoError(i.T(), err)
+
+			_, err = fixture.CreateIngress(i.client, "tls", networkingv1.IngressSpec{
+				TLS: []networkingv1.IngressTLS{{
+					Hosts:      []string{fixture.TLSHost},
+					SecretName: fixture.TLSSecret,
+				}},
+				Rules: []networkingv1.IngressRule{ingresscontroller.Rule(fixture.TLSHost,
+					fixture.Path("/", networkingv1.PathTypePrefix, backendB),
+				)},
+			})
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "TLS host with the secret certificate", Host: fixture.TLSHost, Path: "/", TLS: true, Backend: backendB},
+			})
+			require.NoError(i.T(), err)
+		})
+
+		i.Run("IngressClass_Selection_"+controller, func() {
+			require.NotNil(i.T(), fixture, "Ingress backends were not deployed")
+
+			className := unclaimedClass
+			_, err := fixture.CreateIngress(i.client, "unclaimed", networkingv1.IngressSpec{
+				IngressClassName: &className,
+				Rules: []networkingv1.IngressRule{ingresscontroller.Rule(unclaimedHost,
+					fixture.Path("/", networkingv1.PathTypePrefix, backendA),
+				)},
+			})
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "Ingress of the bundled class", Host: pathsHost, Path: "/exact", Backend: backendA},
+				{Name: "Ingress of an unclaimed class", Host: unclaimedHost, Path: "/"},
+			})
+			require.NoError(i.T(), err)
+		})
+
+		i.Run("Backend_Update_"+controller, func() {
+			require.NotNil(i.T(), pathsIngress, "Paths ingress was not created")
+
+			updatedIngress := pathsIngress.DeepCopy()
+			updatedIngress.Spec.Rules[0].HTTP.Paths[1].Backend = fixture.IngressBackend(backendC)
+
+			logrus.Infof("Updating the prefix path backend of ingress %s/%s", pathsIngress.Namespace, pathsIngress.Name)
+			_, err := kubeingresses.UpdateIngress(i.client, cluster.id, pathsIngress.Namespace, pathsIngress, updatedIngress)
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "Prefix path with the updated backend", Host: pathsHost, Path: "/prefix/sub", Backend: backendC},
+				{Name: "Exact path with its unchanged backend", Host: pathsHost, Path: "/exact", Backend: backendA},
+			})
+			require.NoError(i.T(), err)
+		})
+
+		// The default backend answers every unmatched host once it is created, so it is verified last.
+		i.Run("Default_Backend_"+controller, func() {
+			require.NotNil(i.T(), fixture, "Ingress backends were not deployed")
+
+			ingressBackend := fixture.IngressBackend(defaultBackend)
+			_, err := fixture.CreateIngress(i.client, "default", networkingv1.IngressSpec{
+				DefaultBackend: &ingressBackend,
+			})
+			require.NoError(i.T(), err)
+
+			err = fixture.VerifyCases(i.client, []ingresscontroller.Case{
+				{Name: "Unmatched host", Host: unmatchedHost, Path: "/", Backend: defaultBackend},
+				{Name: "Matched host", Host: pathsHost, Path: "/exact", Backend: backendA},
+			})
+			require.NoError(i.T(), err)
+		})
+	}
+}
+
+func TestIngressConformanceTestSuite(t *testing.T) {
+	suite.Run(t, new(IngressConformanceTestSuite))
+}
@@ -0,0 +1,144 @@
+//go:build validation || prime
+
+package rke2k3s
+
+import (
+	"testing"
+	"time"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	shepherdClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/projects"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/provisioninginput"
+	"github.com/rancher/tests/actions/qase"
+	"github.com/rancher/tests/actions/scaling"
+	resources "github.com/rancher/tests/validation/provisioning/resources/provisioncluster"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoScalingLoad(t *testing.T) {
+	s := autoScalingSetup(t)
+
+	nodeRolesStandard := []provisioninginput.MachinePools{
+		provisioninginput.EtcdMachinePool,
+		provisioninginput.ControlPlaneMachinePool,
+		provisioninginput.WorkerMachinePool,
+	}
+
+	nodeRolesStandard[0].MachinePoolConfig.Quantity = 3
+	nodeRolesStandard[1].MachinePoolConfig.Quantity = 2
+	nodeRolesStandard[2].MachinePoolConfig.Quantity = 1
+
+	tests := []struct {
+		name         string
+		client       *rancher.Client
+		clusterType  string
+		nodeRoles    []provisioninginput.MachinePools
+		minNodeCount int32
+		maxNodeCount int32
+	}{
+		{"RKE2_Auto_Scale_Load", s.standardUserClient, defaults.RKE2, nodeRolesStandard, 1, 3},
+		{"K3S_Auto_Scale_Load", s.standardUserClient, defaults.K3S, nodeRolesStandard, 1, 3},
+	}
+
+	for _, tt := range tests {
+		var err error
+		t.Cleanup(func() {
+			logrus.Infof("Running cleanup (%s)", tt.name)
+			s.session.Cleanup()
+		})
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			clusterConfig := new(clusters.ClusterConfig)
+			operations.LoadObjectFromMap(defaults.ClusterConfigKey, s.cattleConfig, clusterConfig)
+
+			clusterConfig.MachinePools = tt.nodeRoles
+			clusterConfig.MachinePools[2].MachinePoolConfig.AutoscalingMinSize = &tt.minNodeCount
+			clusterConfig.MachinePools[2].MachinePoolConfig.AutoscalingMaxSize = &tt.maxNodeCount
+
+			provider := provisioning.CreateProvider(clusterConfig.Provider)
+			machineConfigSpec := provider.LoadMachineConfigFunc(s.cattleConfig)
+
+			logrus.Infof("Provisioning %s cluster", tt.clusterType)
+			cluster, err := resources.ProvisionRKE2K3SCluster(t, s.client, tt.clusterType, provider, *clusterConfig, machineConfigSpec, nil, true, false)
+			require.NoError(t, err)
+
+			logrus.Infof("Verifying cluster autoscaler (%s)", cluster.Name)
+			scaling.VerifyAutoscaler(t, s.client, cluster)
+
+			poolName := autoscaledPoolName(t, cluster)
+
+			logrus.Infof("Verifying autoscaler annotations of machine pool %s (%s)", poolName, cluster.Name)
+			err = scaling.VerifyAutoscalerAnnotations(s.client, cluster, poolName)
+			require.NoError(t, err)
+
+			v3ClusterID, err := shepherdClusters.GetClusterIDByName(s.client, cluster.Name)
+			require.NoError(t, err)
+
+			_, namespace, err := projects.CreateProjectAndNamespace(s.client, v3ClusterID)
+			require.NoError(t, err)
+
+			load, err := scaling.CreateLoad(s.client, cluster, poolName, namespace.Name, tt.maxNodeCount)
+			require.NoError(t, err)
+
+			logrus.Infof("Waiting for cluster to scale up (%s)", cluster.Name)
+			scaleUp, err := scaling.WaitForLoadScaling(s.client, cluster, poolName, tt.maxNodeCount, time.Minute*20)
+			require.NoError(t, err)
+
+			logrus.Infof("Verifying the cluster is ready (%s)", cluster.Name)
+			provisioning.VerifyClusterReady(t, s.client, cluster)
+
+			logrus.Infof("Cluster %s scaled up in %s", cluster.Name, scaleUp.Duration.Round(time.Second))
+
+			t.Skip("Scaling down skipped due to https://github.com/rancher/rancher/issues/52665")
+
+			unneededTime, err := scaling.ScaleDownUnneededTime(s.client, cluster)
+			require.NoError(t, err)
+
+			err = load.Delete(s.client)
+			require.NoError(t, err)
+
+			logrus.Infof("Waiting for cluster to scale down after %s (%s)", unneededTime, cluster.Name)
+			scaleDown, err := scaling.WaitForLoadScaling(s.client, cluster, poolName, tt.minNodeCount, unneededTime+time.Minute*30)
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, scaleDown.Duration, unneededTime)
+			require.Len(t, scaleDown.RemovedNodes, int(tt.maxNodeCount-tt.minNodeCount))
+
+			logrus.Infof("Verifying the cluster is ready (%s)", cluster.Name)
+			provisioning.VerifyClusterReady(t, s.client, cluster)
+
+			logrus.Infof("Cluster %s scaled down in %s", cluster.Name, scaleDown.Duration.Round(time.Second))
+		})
+
+		params := provisioning.GetProvisioningSchemaParams(tt.client, s.cattleConfig)
+		err = qase.UpdateSchemaParameters(tt.name, params)
+		if err != nil {
+			logrus.Warningf("Failed to upload schema parameters %s", err)
+		}
+	}
+}
+
+// autoscaledPoolName returns the name of the autoscaled worker machine pool of a cluster.
+func autoscaledPoolName(t *testing.T, cluster *v1.SteveAPIObject) string {
+	clusterSpec := &provv1.ClusterSpec{}
+	err := v1.ConvertToK8sType(cluster.Spec, clusterSpec)
+	require.NoError(t, err)
+
+	for _, machinePool := range clusterSpec.RKEConfig.MachinePools {
+		if machinePool.WorkerRole && machinePool.AutoscalingMaxSize != nil {
+			return machinePool.Name
+		}
+	}
+
+	require.FailNow(t, "cluster has no autoscaled worker machine pool", cluster.Name)
+
+	return ""
+}
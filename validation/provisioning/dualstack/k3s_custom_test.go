@@ -14,6 +14,7 @@ import (
 	"github.com/rancher/shepherd/pkg/session"
 	"github.com/rancher/tests/actions/clusters"
 	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/dualstack"
 	"github.com/rancher/tests/actions/logging"
 	"github.com/rancher/tests/actions/provisioning"
 	"github.com/rancher/tests/actions/provisioninginput"
@@ -151,6 +152,9 @@ func TestCustomK3SDualstack(t *testing.T) {
 
 			logrus.Infof("Verifying cluster pods (%s)", cluster.Name)
 			pods.VerifyClusterPods(t, tt.client, cluster)
+
+			logrus.Infof("Verifying IP families and connectivity (%s)", cluster.Name)
+			dualstack.VerifyDualStack(t, tt.client, cluster, dualstack.ExpectedFamilies(clusterConfig.Networking, clusterConfig.IPv6Cluster))
 		})
 
 		params := provisioning.GetCustomSchemaParams(tt.client, k.cattleConfig)
@@ -139,7 +139,16 @@ func TestHardened(t *testing.T) {
 			cis.SetupHardenedChart(tt.client, k.project.ClusterID, k.chartInstallOptions, chartName, chartNamespace)
 
 			logrus.Infof("Running CIS scan on cluster (%s)", cluster.Name)
-			cis.RunCISScan(tt.client, k.project.ClusterID, tt.scanProfileName)
+			report, err := cis.RunCISScanAndGetReport(tt.client, k.project.ClusterID, tt.scanProfileName)
+			require.NoError(t, err)
+
+			logrus.Infof("Comparing CIS scan report with the %s allowlist (%s)", tt.scanProfileName, cluster.Name)
+			diff, err := cis.VerifyScanReport(report, defaults.K3S, tt.scanProfileName)
+			require.NoError(t, err)
+
+			if len(diff.Resolved) > 0 {
+				logrus.Warn(diff.String())
+			}
 		})
 
 		params := provisioning.GetCustomSchemaParams(tt.client, k.cattleConfig)
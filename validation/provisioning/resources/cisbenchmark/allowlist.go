@@ -0,0 +1,132 @@
+package charts
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// allowlists holds the known-acceptable failures per distro and scan profile, laid out as allowlists/<distro>/<profile>.yaml
+//
+//go:embed allowlists
+var allowlists embed.FS
+
+// Allowlist is the set of checks that are known to fail for a scan profile on a distro.
+type Allowlist struct {
+	Distro           string           `json:"distro" yaml:"distro"`
+	Profile          string           `json:"profile" yaml:"profile"`
+	BenchmarkVersion string           `json:"benchmarkVersion" yaml:"benchmarkVersion"`
+	Checks           []AllowlistEntry `json:"checks" yaml:"checks"`
+}
+
+// AllowlistEntry is a single known failing check with the reason it is acceptable.
+type AllowlistEntry struct {
+	ID     string `json:"id" yaml:"id"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// ReportDiff is the difference between the failing checks of a scan report and an allowlist.
+type ReportDiff struct {
+	Allowlist *Allowlist
+	// NewFailures are failing checks that are not in the allowlist
+	NewFailures []CheckResult
+	// Resolved are allowlisted checks that no longer fail
+	Resolved []AllowlistEntry
+}
+
+// LoadAllowlist loads the allowlist of a scan profile for a distro.
+func LoadAllowlist(distro, profile string) (*Allowlist, error) {
+	content, err := allowlists.ReadFile(path.Join("allowlists", distro, profile+".yaml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no CIS allowlist found for profile %s on %s, add allowlists/%s/%s.yaml", profile, distro, distro, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist := &Allowlist{}
+	err = yaml.Unmarshal(content, allowlist)
+	if err != nil {
+		return nil, err
+	}
+
+	return allowlist, nil
+}
+
+// CompareReport compares the failing checks of a scan report with an allowlist.
+func CompareReport(report *ScanReport, allowlist *Allowlist) *ReportDiff {
+	diff := &ReportDiff{Allowlist: allowlist}
+
+	allowed := map[string]bool{}
+	for _, entry := range allowlist.Checks {
+		allowed[entry.ID] = true
+	}
+
+	failing := map[string]bool{}
+	for _, check := range report.FailedChecks() {
+		failing[check.ID] = true
+		if !allowed[check.ID] {
+			diff.NewFailures = append(diff.NewFailures, check)
+		}
+	}
+
+	for _, entry := range allowlist.Checks {
+		if !failing[entry.ID] {
+			diff.Resolved = append(diff.Resolved, entry)
+		}
+	}
+
+	return diff
+}
+
+// String renders the diff in a readable form, prefixing new failures with "+" and resolved checks with "-".
+func (d *ReportDiff) String() string {
+	var builder strings.Builder
+
+	if len(d.NewFailures) > 0 {
+		fmt.Fprintf(&builder, "%d failing checks are not in the %s/%s allowlist:\n", len(d.NewFailures), d.Allowlist.Distro, d.Allowlist.Profile)
+		for _, check := range d.NewFailures {
+			fmt.Fprintf(&builder, "+ %s [%s] %s\n", check.ID, check.State, check.Description)
+			if len(check.Nodes) > 0 {
+				fmt.Fprintf(&builder, "    nodes: %s\n", strings.Join(check.Nodes, ", "))
+			}
+			if check.Remediation != "" {
+				fmt.Fprintf(&builder, "    remediation: %s\n", strings.TrimSpace(check.Remediation))
+			}
+		}
+	}
+
+	if len(d.Resolved) > 0 {
+		fmt.Fprintf(&builder, "%d allowlisted checks no longer fail and can be removed from the allowlist:\n", len(d.Resolved))
+		for _, entry := range d.Resolved {
+			fmt.Fprintf(&builder, "- %s (%s)\n", entry.ID, entry.Reason)
+		}
+	}
+
+	return builder.String()
+}
+
+// VerifyScanReport compares a scan report with the allowlist of its profile and distro, and returns an error containing
+// the readable diff if any check failed that is not allowlisted.
+func VerifyScanReport(report *ScanReport, distro, profile string) (*ReportDiff, error) {
+	allowlist, err := LoadAllowlist(distro, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if allowlist.BenchmarkVersion != "" && allowlist.BenchmarkVersion != report.Version {
+		return nil, fmt.Errorf("scan ran benchmark version %s but the %s/%s allowlist is for %s", report.Version, distro, profile, allowlist.BenchmarkVersion)
+	}
+
+	diff := CompareReport(report, allowlist)
+	if len(diff.NewFailures) > 0 {
+		return diff, fmt.Errorf("CIS scan has new failing checks:\n%s", diff.String())
+	}
+
+	return diff, nil
+}
@@ -1,22 +1,26 @@
 package charts
 
 import (
+	"fmt"
 	"time"
 
 	cis "github.com/rancher/cis-operator/pkg/apis/cis.cattle.io/v1"
 	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
 	extensionscharts "github.com/rancher/shepherd/extensions/charts"
 	"github.com/rancher/shepherd/extensions/defaults"
 	namegen "github.com/rancher/shepherd/pkg/namegenerator"
 	"github.com/rancher/tests/actions/charts"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kwait "k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
 	System                   = "System"
+	RKE1                     = "rke1"
 	pass                     = "pass"
 	scan                     = "scan"
 	defaultRegistrySettingID = "system-default-registry"
@@ -75,6 +79,25 @@ func SetupHardenedChart(client *rancher.Client, projectClusterID string, chartIn
 
 // RunCISScan runs the CIS Benchmark scan with the specified profile name.
 func RunCISScan(client *rancher.Client, projectClusterID, scanProfileName string) error {
+	_, err := createAndWaitForScan(client, projectClusterID, scanProfileName)
+
+	return err
+}
+
+// RunCISScanAndGetReport runs the CIS Benchmark scan with the specified profile name, waits for it to complete and
+// returns its parsed ClusterScanReport.
+func RunCISScanAndGetReport(client *rancher.Client, projectClusterID, scanProfileName string) (*ScanReport, error) {
+	scanName, err := createAndWaitForScan(client, projectClusterID, scanProfileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return GetClusterScanReport(client, projectClusterID, scanName)
+}
+
+// createAndWaitForScan is a private helper function that creates a ClusterScan and waits until its run completed. It
+// returns the name of the scan.
+func createAndWaitForScan(client *rancher.Client, projectClusterID, scanProfileName string) (string, error) {
 	logrus.Debugf("Running CIS Benchmark scan: %s", scanProfileName)
 
 	cisScan := cis.ClusterScan{
@@ -89,12 +112,12 @@ func RunCISScan(client *rancher.Client, projectClusterID, scanProfileName string
 
 	steveclient, err := client.Steve.ProxyDownstream(projectClusterID)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	scan, err := steveclient.SteveType(cisBenchmarkSteveType).Create(cisScan)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	err = kwait.PollUntilContextTimeout(context.TODO(), 1*time.Second, defaults.TenMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
@@ -103,15 +126,32 @@ func RunCISScan(client *rancher.Client, projectClusterID, scanProfileName string
 			return false, err
 		}
 
-		if !scanResp.ObjectMeta.State.Transitioning {
-			return true, nil
+		scanStatus := &cis.ClusterScanStatus{}
+		err = steveV1.ConvertToK8sType(scanResp.Status, scanStatus)
+		if err != nil {
+			return false, err
+		}
+
+		// a scan that is not transitioning may not have run yet, so it is only done once its run completed
+		for _, scanCondition := range scanStatus.Conditions {
+			if scanCondition.Status != corev1.ConditionTrue {
+				continue
+			}
+
+			if scanCondition.Type == string(cis.ClusterScanConditionFailed) {
+				return false, fmt.Errorf("CIS Benchmark scan %s failed: %s", scan.Name, scanCondition.Message)
+			}
+
+			if scanCondition.Type == string(cis.ClusterScanConditionRunCompleted) && scanStatus.LastRunTimestamp != "" {
+				return true, nil
+			}
 		}
 
 		return false, nil
 	})
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	return nil
+	return scan.Name, nil
 }
@@ -0,0 +1,146 @@
+package charts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	cis "github.com/rancher/cis-operator/pkg/apis/cis.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	steveV1 "github.com/rancher/shepherd/clients/rancher/v1"
+	"github.com/rancher/shepherd/extensions/defaults"
+	kwait "k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	cisBenchmarkReportSteveType = "cis.cattle.io.clusterscanreport"
+
+	// CheckStatePass is the state of a check that passed on every node it ran on.
+	CheckStatePass = "pass"
+	// CheckStateFail is the state of a check that failed on every node it ran on.
+	CheckStateFail = "fail"
+	// CheckStateMixed is the state of a check that passed on some nodes and failed on others.
+	CheckStateMixed = "mixed"
+	// CheckStateWarn is the state of a manual check.
+	CheckStateWarn = "warn"
+	// CheckStateSkip is the state of a check that was skipped by the profile.
+	CheckStateSkip = "skip"
+	// CheckStateNotApplicable is the state of a check that does not apply to the cluster distro.
+	CheckStateNotApplicable = "notApplicable"
+)
+
+// ScanReport is the typed content of the reportJSON field of a ClusterScanReport.
+type ScanReport struct {
+	Version       string              `json:"version"`
+	Total         int                 `json:"total"`
+	Pass          int                 `json:"pass"`
+	Fail          int                 `json:"fail"`
+	Skip          int                 `json:"skip"`
+	Warn          int                 `json:"warn"`
+	NotApplicable int                 `json:"notApplicable"`
+	Nodes         map[string][]string `json:"nodes"`
+	Results       []CheckGroup        `json:"results"`
+}
+
+// CheckGroup is a section of the benchmark, e.g. "Control Plane Security Configuration".
+type CheckGroup struct {
+	ID          string        `json:"id"`
+	Description string        `json:"description"`
+	Checks      []CheckResult `json:"checks"`
+}
+
+// CheckResult is the result of a single benchmark check.
+type CheckResult struct {
+	ID                 string            `json:"id"`
+	Description        string            `json:"description"`
+	Remediation        string            `json:"remediation"`
+	State              string            `json:"state"`
+	Scored             bool              `json:"scored"`
+	NodeType           []string          `json:"node_type"`
+	Nodes              []string          `json:"nodes"`
+	Audit              string            `json:"audit"`
+	ExpectedResult     string            `json:"expected_result"`
+	ActualValuePerNode map[string]string `json:"actual_value_per_node"`
+}
+
+// IsFailing returns true when the check failed on at least one node.
+func (c *CheckResult) IsFailing() bool {
+	return c.State == CheckStateFail || c.State == CheckStateMixed
+}
+
+// FailedChecks returns every check of the report that failed on at least one node, sorted by check ID.
+func (r *ScanReport) FailedChecks() []CheckResult {
+	var failed []CheckResult
+	for _, group := range r.Results {
+		for _, check := range group.Checks {
+			if check.IsFailing() {
+				failed = append(failed, check)
+			}
+		}
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].ID < failed[j].ID
+	})
+
+	return failed
+}
+
+// ParseScanReport parses the reportJSON of a ClusterScanReport into a ScanReport.
+func ParseScanReport(reportJSON string) (*ScanReport, error) {
+	report := &ScanReport{}
+	err := json.Unmarshal([]byte(reportJSON), report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CIS scan report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetClusterScanReport waits for the ClusterScanReport owned by the given ClusterScan to exist and parses it.
+func GetClusterScanReport(client *rancher.Client, projectClusterID, scanName string) (*ScanReport, error) {
+	steveclient, err := client.Steve.ProxyDownstream(projectClusterID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reportObject *steveV1.SteveAPIObject
+	err = kwait.PollUntilContextTimeout(context.TODO(), defaults.FiveSecondTimeout, defaults.FiveMinuteTimeout, true, func(ctx context.Context) (done bool, err error) {
+		reports, err := steveclient.SteveType(cisBenchmarkReportSteveType).List(nil)
+		if err != nil {
+			return false, nil
+		}
+
+		for i := range reports.Data {
+			if isOwnedByScan(reports.Data[i], scanName) {
+				reportObject = &reports.Data[i]
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("no ClusterScanReport found for scan %s: %w", scanName, err)
+	}
+
+	reportSpec := &cis.ClusterScanReportSpec{}
+	err = steveV1.ConvertToK8sType(reportObject.Spec, reportSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseScanReport(reportSpec.ReportJSON)
+}
+
+// isOwnedByScan is a private helper function that checks if a ClusterScanReport was created by the given ClusterScan.
+func isOwnedByScan(reportObject steveV1.SteveAPIObject, scanName string) bool {
+	for _, owner := range reportObject.OwnerReferences {
+		if owner.Kind == "ClusterScan" && owner.Name == scanName {
+			return true
+		}
+	}
+
+	return false
+}
@@ -10,6 +10,7 @@ import (
 	extClusters "github.com/rancher/shepherd/extensions/clusters"
 	"github.com/rancher/shepherd/extensions/clusters/kubernetesversions"
 	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/dns"
 	"github.com/rancher/tests/actions/machinepools"
 	"github.com/rancher/tests/actions/provisioning"
 	"github.com/rancher/tests/actions/workloads/pods"
@@ -49,6 +50,7 @@ func ProvisionRKE2K3SCluster(t *testing.T, client *rancher.Client, clusterType s
 
 		provisioning.VerifyClusterReady(t, client, clusterObject)
 		pods.VerifyClusterPods(t, client, clusterObject)
+		dns.VerifyClusterDNS(t, client, clusterObject)
 	} else {
 		credentialSpec := cloudcredentials.LoadCloudCredential(string(provider.Name))
 
@@ -57,6 +59,7 @@ func ProvisionRKE2K3SCluster(t *testing.T, client *rancher.Client, clusterType s
 
 		provisioning.VerifyClusterReady(t, client, clusterObject)
 		pods.VerifyClusterPods(t, client, clusterObject)
+		dns.VerifyClusterDNS(t, client, clusterObject)
 	}
 
 	return clusterObject, nil
@@ -150,7 +150,16 @@ func (c *HardenedRKE1ClusterProvisioningTestSuite) TestProvisioningRKE1HardenedC
 			cis.SetupHardenedChart(tt.client, c.project.ClusterID, c.chartInstallOptions, chartName, chartNamespace)
 
 			logrus.Infof("Running CIS scan on cluster (%s)", clusterMeta.Name)
-			cis.RunCISScan(tt.client, c.project.ClusterID, tt.scanProfileName)
+			report, err := cis.RunCISScanAndGetReport(tt.client, c.project.ClusterID, tt.scanProfileName)
+			require.NoError(c.T(), err)
+
+			logrus.Infof("Comparing CIS scan report with the %s allowlist (%s)", tt.scanProfileName, clusterMeta.Name)
+			diff, err := cis.VerifyScanReport(report, cis.RKE1, tt.scanProfileName)
+			require.NoError(c.T(), err)
+
+			if len(diff.Resolved) > 0 {
+				logrus.Warn(diff.String())
+			}
 		})
 	}
 }
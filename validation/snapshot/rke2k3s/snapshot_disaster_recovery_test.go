@@ -0,0 +1,128 @@
+//go:build (validation || extended || infra.any || cluster.any) && !sanity && !stress
+
+package rke2k3s
+
+import (
+	"os"
+	"testing"
+
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/disasterrecovery"
+	"github.com/rancher/tests/actions/logging"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/provisioninginput"
+	"github.com/rancher/tests/actions/qase"
+	"github.com/rancher/tests/actions/s3server"
+	resources "github.com/rancher/tests/validation/provisioning/resources/provisioncluster"
+	standard "github.com/rancher/tests/validation/provisioning/resources/standarduser"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DisasterRecoveryTestSuite struct {
+	suite.Suite
+	session      *session.Session
+	client       *rancher.Client
+	cattleConfig map[string]any
+	rke2Cluster  *v1.SteveAPIObject
+}
+
+func (s *DisasterRecoveryTestSuite) TearDownSuite() {
+	s.session.Cleanup()
+}
+
+func (s *DisasterRecoveryTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	s.session = testSession
+
+	client, err := rancher.NewClient("", s.session)
+	require.NoError(s.T(), err)
+
+	s.client = client
+
+	standardUserClient, _, _, err := standard.CreateStandardUser(s.client)
+	require.NoError(s.T(), err)
+
+	s.cattleConfig = config.LoadConfigFromFile(os.Getenv(config.ConfigEnvironmentKey))
+
+	s.cattleConfig, err = defaults.LoadPackageDefaults(s.cattleConfig, "")
+	require.NoError(s.T(), err)
+
+	loggingConfig := new(logging.Logging)
+	operations.LoadObjectFromMap(logging.LoggingKey, s.cattleConfig, loggingConfig)
+
+	err = logging.SetLogger(loggingConfig)
+	require.NoError(s.T(), err)
+
+	clusterConfig := new(clusters.ClusterConfig)
+	operations.LoadObjectFromMap(defaults.ClusterConfigKey, s.cattleConfig, clusterConfig)
+
+	if clusterConfig.ETCD == nil || clusterConfig.ETCD.S3 == nil {
+		logrus.Info("No etcd S3 settings are provided, deploying a local S3 server")
+		s3Server, err := s3server.DeployS3Server(s.client, s3server.LoadConfig())
+		require.NoError(s.T(), err)
+
+		if clusterConfig.ETCD == nil {
+			clusterConfig.ETCD = &rkev1.ETCD{}
+		}
+
+		clusterConfig.ETCD.S3, err = s3Server.ETCDSnapshotS3(standardUserClient, s3SnapshotFolder)
+		require.NoError(s.T(), err)
+	}
+
+	nodeRolesDedicated := []provisioninginput.MachinePools{
+		provisioninginput.EtcdMachinePool,
+		provisioninginput.ControlPlaneMachinePool,
+		provisioninginput.WorkerMachinePool,
+	}
+
+	nodeRolesDedicated[0].MachinePoolConfig.Quantity = 3
+	nodeRolesDedicated[1].MachinePoolConfig.Quantity = 2
+	nodeRolesDedicated[2].MachinePoolConfig.Quantity = 1
+
+	clusterConfig.MachinePools = nodeRolesDedicated
+
+	provider := provisioning.CreateProvider(clusterConfig.Provider)
+	machineConfigSpec := provider.LoadMachineConfigFunc(s.cattleConfig)
+
+	logrus.Info("Provisioning RKE2 cluster")
+	s.rke2Cluster, err = resources.ProvisionRKE2K3SCluster(s.T(), standardUserClient, extClusters.RKE2ClusterType.String(), provider, *clusterConfig, machineConfigSpec, nil, true, false)
+	require.NoError(s.T(), err)
+}
+
+func (s *DisasterRecoveryTestSuite) TestDisasterRecovery() {
+	tests := []struct {
+		name     string
+		scenario disasterrecovery.Scenario
+	}{
+		{"RKE2_DR_ETCD_Quorum_Loss", disasterrecovery.EtcdQuorumLoss},
+		{"RKE2_DR_Control_Plane_Loss", disasterrecovery.ControlPlaneLoss},
+		{"RKE2_DR_Total_Loss", disasterrecovery.TotalLoss},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			err := disasterrecovery.RunScenario(s.client, s.rke2Cluster.Name, tt.scenario)
+			require.NoError(s.T(), err)
+		})
+
+		params := provisioning.GetProvisioningSchemaParams(s.client, s.cattleConfig)
+		err := qase.UpdateSchemaParameters(tt.name, params)
+		if err != nil {
+			logrus.Warningf("Failed to upload schema parameters %s", err)
+		}
+	}
+}
+
+func TestDisasterRecoveryTestSuite(t *testing.T) {
+	suite.Run(t, new(DisasterRecoveryTestSuite))
+}
@@ -137,6 +137,41 @@ func (s *SnapshotRestoreTestSuite) TestSnapshotRestore() {
 	}
 }
 
+func (s *SnapshotRestoreTestSuite) TestSnapshotRestoreDataIntegrity() {
+	snapshotRestoreConfigRKE2 := snapshotRestoreConfigs()
+	snapshotRestoreConfigK3s := snapshotRestoreConfigs()
+	tests := []struct {
+		name         string
+		etcdSnapshot *etcdsnapshot.Config
+		clusterID    string
+	}{
+		{"RKE2_Restore_ETCD_Data_Integrity", snapshotRestoreConfigRKE2[0], s.rke2Cluster.ID},
+		{"RKE2_Restore_ETCD_K8sVersion_Data_Integrity", snapshotRestoreConfigRKE2[1], s.rke2Cluster.ID},
+		{"RKE2_Restore_Upgrade_Strategy_Data_Integrity", snapshotRestoreConfigRKE2[2], s.rke2Cluster.ID},
+		{"K3S_Restore_ETCD_Data_Integrity", snapshotRestoreConfigK3s[0], s.k3sCluster.ID},
+		{"K3S_Restore_ETCD_K8sVersion_Data_Integrity", snapshotRestoreConfigK3s[1], s.k3sCluster.ID},
+		{"K3S_Restore_Upgrade_Strategy_Data_Integrity", snapshotRestoreConfigK3s[2], s.k3sCluster.ID},
+	}
+
+	for _, tt := range tests {
+		tt.etcdSnapshot.VerifyDataIntegrity = true
+
+		cluster, err := s.client.Steve.SteveType(stevetypes.Provisioning).ByID(tt.clusterID)
+		require.NoError(s.T(), err)
+
+		s.Run(tt.name, func() {
+			err := etcdsnapshot.CreateAndValidateSnapshotRestore(s.client, cluster.Name, tt.etcdSnapshot, containerImage)
+			require.NoError(s.T(), err)
+		})
+
+		params := provisioning.GetProvisioningSchemaParams(s.client, s.cattleConfig)
+		err = qase.UpdateSchemaParameters(tt.name, params)
+		if err != nil {
+			logrus.Warningf("Failed to upload schema parameters %s", err)
+		}
+	}
+}
+
 func TestSnapshotRestoreTestSuite(t *testing.T) {
 	suite.Run(t, new(SnapshotRestoreTestSuite))
 }
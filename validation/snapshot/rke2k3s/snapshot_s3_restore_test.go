@@ -6,6 +6,7 @@ import (
 	"os"
 	"testing"
 
+	rkev1 "github.com/rancher/rancher/pkg/apis/rke.cattle.io/v1"
 	"github.com/rancher/shepherd/clients/rancher"
 	v1 "github.com/rancher/shepherd/clients/rancher/v1"
 	extClusters "github.com/rancher/shepherd/extensions/clusters"
@@ -19,6 +20,7 @@ import (
 	"github.com/rancher/tests/actions/logging"
 	"github.com/rancher/tests/actions/provisioning"
 	"github.com/rancher/tests/actions/qase"
+	"github.com/rancher/tests/actions/s3server"
 	resources "github.com/rancher/tests/validation/provisioning/resources/provisioncluster"
 	standard "github.com/rancher/tests/validation/provisioning/resources/standarduser"
 	"github.com/sirupsen/logrus"
@@ -27,6 +29,8 @@ import (
 	"github.com/stretchr/testify/suite"
 )
 
+const s3SnapshotFolder = "snapshots"
+
 type S3SnapshotRestoreTestSuite struct {
 	suite.Suite
 	session      *session.Session
@@ -66,6 +70,19 @@ func (s *S3SnapshotRestoreTestSuite) SetupSuite() {
 	clusterConfig := new(clusters.ClusterConfig)
 	operations.LoadObjectFromMap(defaults.ClusterConfigKey, s.cattleConfig, clusterConfig)
 
+	if clusterConfig.ETCD == nil || clusterConfig.ETCD.S3 == nil {
+		logrus.Info("No etcd S3 settings are provided, deploying a local S3 server")
+		s3Server, err := s3server.DeployS3Server(s.client, s3server.LoadConfig())
+		require.NoError(s.T(), err)
+
+		if clusterConfig.ETCD == nil {
+			clusterConfig.ETCD = &rkev1.ETCD{}
+		}
+
+		clusterConfig.ETCD.S3, err = s3Server.ETCDSnapshotS3(standardUserClient, s3SnapshotFolder)
+		require.NoError(s.T(), err)
+	}
+
 	provider := provisioning.CreateProvider(clusterConfig.Provider)
 	machineConfigSpec := provider.LoadMachineConfigFunc(s.cattleConfig)
 
@@ -0,0 +1,164 @@
+//go:build (validation || extended || infra.any || cluster.any) && !sanity && !stress
+
+package storage
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	provv1 "github.com/rancher/rancher/pkg/apis/provisioning.cattle.io/v1"
+	"github.com/rancher/shepherd/clients/rancher"
+	v1 "github.com/rancher/shepherd/clients/rancher/v1"
+	extClusters "github.com/rancher/shepherd/extensions/clusters"
+	"github.com/rancher/shepherd/extensions/clusters/kubernetesversions"
+	"github.com/rancher/shepherd/extensions/defaults/namespaces"
+	"github.com/rancher/shepherd/extensions/defaults/stevetypes"
+	shepherdsnapshot "github.com/rancher/shepherd/extensions/etcdsnapshot"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/config/operations"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/clusters"
+	"github.com/rancher/tests/actions/config/defaults"
+	"github.com/rancher/tests/actions/etcdsnapshot"
+	"github.com/rancher/tests/actions/logging"
+	"github.com/rancher/tests/actions/provisioning"
+	"github.com/rancher/tests/actions/scalinginput"
+	"github.com/rancher/tests/actions/volumedurability"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DataDurabilityTestSuite struct {
+	suite.Suite
+	session          *session.Session
+	client           *rancher.Client
+	cattleConfig     map[string]any
+	durabilityConfig *volumedurability.Config
+	cluster          *v1.SteveAPIObject
+	clusterID        string
+}
+
+func (d *DataDurabilityTestSuite) TearDownSuite() {
+	d.session.Cleanup()
+}
+
+func (d *DataDurabilityTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	d.session = testSession
+
+	client, err := rancher.NewClient("", d.session)
+	require.NoError(d.T(), err)
+
+	d.client = client
+
+	d.cattleConfig = config.LoadConfigFromFile(os.Getenv(config.ConfigEnvironmentKey))
+
+	d.cattleConfig, err = defaults.LoadPackageDefaults(d.cattleConfig, "")
+	require.NoError(d.T(), err)
+
+	loggingConfig := new(logging.Logging)
+	operations.LoadObjectFromMap(logging.LoggingKey, d.cattleConfig, loggingConfig)
+
+	err = logging.SetLogger(loggingConfig)
+	require.NoError(d.T(), err)
+
+	d.durabilityConfig = new(volumedurability.Config)
+	operations.LoadObjectFromMap(volumedurability.ConfigurationFileKey, d.cattleConfig, d.durabilityConfig)
+
+	d.cluster, err = client.Steve.SteveType(stevetypes.Provisioning).ByID(namespaces.FleetDefault + "/" + d.client.RancherConfig.ClusterName)
+	require.NoError(d.T(), err)
+
+	d.clusterID, err = extClusters.GetClusterIDByName(d.client, d.cluster.Name)
+	require.NoError(d.T(), err)
+}
+
+func (d *DataDurabilityTestSuite) TestDataDurability() {
+	d.Run("RKE2K3S_Data_Durability_Replace_Worker", func() {
+		dataset, err := volumedurability.WriteDataset(d.client, d.clusterID, d.durabilityConfig)
+		require.NoError(d.T(), err)
+
+		if !slices.ContainsFunc(dataset.Volumes, func(volume *volumedurability.Volume) bool { return !volume.NodeLocal }) {
+			d.T().Skip("Every volume of the dataset is local to its node, none can survive a worker replacement")
+		}
+
+		err = scalinginput.ReplaceNodes(d.client, d.cluster.Name, false, false, true)
+		require.NoError(d.T(), err)
+
+		logrus.Infof("Verifying the cluster is ready (%s)", d.cluster.Name)
+		provisioning.VerifyClusterReady(d.T(), d.client, d.cluster)
+
+		d.verifyDataset(dataset)
+	})
+
+	d.Run("RKE2K3S_Data_Durability_Upgrade", func() {
+		clusterSpec := &provv1.ClusterSpec{}
+		err := v1.ConvertToK8sType(d.cluster.Spec, clusterSpec)
+		require.NoError(d.T(), err)
+
+		clusterType := extClusters.RKE2ClusterType.String()
+		if strings.Contains(clusterSpec.KubernetesVersion, extClusters.K3SClusterType.String()) {
+			clusterType = extClusters.K3SClusterType.String()
+		}
+
+		versions, err := kubernetesversions.Default(d.client, clusterType, nil)
+		require.NoError(d.T(), err)
+
+		minorVersions, err := clusters.UpgradeMinorVersions(clusterSpec.KubernetesVersion, versions[0])
+		if err != nil || minorVersions > 1 {
+			d.T().Skipf("Cluster %s on %s cannot be upgraded to %s", d.cluster.Name, clusterSpec.KubernetesVersion, versions[0])
+		}
+
+		dataset, err := volumedurability.WriteDataset(d.client, d.clusterID, d.durabilityConfig)
+		require.NoError(d.T(), err)
+
+		logrus.Infof("Upgrading cluster %s to %s", d.cluster.Name, versions[0])
+		_, err = provisioning.UpgradeClusterK8sVersion(d.client, &d.cluster.Name, &versions[0])
+		require.NoError(d.T(), err)
+
+		d.verifyDataset(dataset)
+	})
+
+	d.Run("RKE2K3S_Data_Durability_Snapshot_Restore", func() {
+		dataset, err := volumedurability.WriteDataset(d.client, d.clusterID, d.durabilityConfig)
+		require.NoError(d.T(), err)
+
+		snapshots, err := shepherdsnapshot.CreateRKE2K3SSnapshot(d.client, d.cluster.Name)
+		require.NoError(d.T(), err)
+		require.NotEmpty(d.T(), snapshots)
+
+		clusterObject, _, err := extClusters.GetProvisioningClusterByName(d.client, d.cluster.Name, namespaces.FleetDefault)
+		require.NoError(d.T(), err)
+
+		err = etcdsnapshot.RestoreAndValidateSnapshotV2Prov(d.client, snapshots[0].ID, &etcdsnapshot.Config{
+			SnapshotRestore:   "none",
+			RecurringRestores: 1,
+		}, clusterObject, d.clusterID)
+		require.NoError(d.T(), err)
+
+		d.verifyDataset(dataset)
+	})
+}
+
+// verifyDataset checks that every file of the dataset was read back intact from a rescheduled pod.
+func (d *DataDurabilityTestSuite) verifyDataset(dataset *volumedurability.Dataset) {
+	results, err := dataset.Verify(d.client)
+	for _, result := range results {
+		if result.NodeGone {
+			logrus.Errorf("Storage class %s: node %s was removed with its local volume, %d lost files", result.StorageClass, result.PreviousNode, len(result.Lost))
+			continue
+		}
+
+		logrus.Infof("Storage class %s: reattached from node %s to %s in %s, %d lost and %d corrupted files", result.StorageClass, result.PreviousNode,
+			result.Node, result.Reattach.Round(time.Second), len(result.Lost), len(result.Corrupted))
+	}
+
+	require.NoError(d.T(), err)
+}
+
+func TestDataDurabilityTestSuite(t *testing.T) {
+	suite.Run(t, new(DataDurabilityTestSuite))
+}
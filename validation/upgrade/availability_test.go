@@ -0,0 +1,83 @@
+//go:build validation
+
+package upgrade
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/config"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/apiavailability"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RancherAvailabilityTestSuite struct {
+	suite.Suite
+	session *session.Session
+	client  *rancher.Client
+	cfg     *apiavailability.Config
+}
+
+func (r *RancherAvailabilityTestSuite) TearDownSuite() {
+	r.session.Cleanup()
+}
+
+func (r *RancherAvailabilityTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	r.session = testSession
+
+	client, err := rancher.NewClient("", testSession)
+	require.NoError(r.T(), err)
+
+	r.client = client
+
+	r.cfg = new(apiavailability.Config)
+	config.LoadConfig(apiavailability.ConfigurationFileKey, r.cfg)
+}
+
+func (r *RancherAvailabilityTestSuite) TestUpgradeAvailability() {
+	if r.cfg.ChartRepo == "" || r.cfg.ChartVersion == "" {
+		r.T().Skip("Chart repo and version to upgrade to are not provided, skipping the test")
+	}
+
+	if r.cfg.DowntimeBudgetSeconds <= 0 {
+		r.T().Skip("Downtime budget is not provided, skipping the test")
+	}
+
+	budget := time.Duration(r.cfg.DowntimeBudgetSeconds) * time.Second
+
+	// the rollback returns to the release before the upgrade, so it only runs once the upgrade rolled out
+	var upgraded bool
+	r.Run("Upgrade", func() {
+		report, err := apiavailability.MeasureUpgrade(r.client, r.cfg)
+		require.NoError(r.T(), err)
+
+		upgraded = true
+
+		err = report.VerifyDowntimeBudget(budget)
+		require.NoError(r.T(), err)
+	})
+
+	r.Run("Rollback", func() {
+		if !r.cfg.Rollback {
+			r.T().Skip("Rollback is not enabled, skipping the rollback")
+		}
+
+		if !upgraded {
+			r.T().Skip("Upgrade did not roll out, skipping the rollback")
+		}
+
+		report, err := apiavailability.MeasureRollback(r.client, r.cfg)
+		require.NoError(r.T(), err)
+
+		err = report.VerifyDowntimeBudget(budget)
+		require.NoError(r.T(), err)
+	})
+}
+
+func TestRancherAvailabilityTestSuite(t *testing.T) {
+	suite.Run(t, new(RancherAvailabilityTestSuite))
+}
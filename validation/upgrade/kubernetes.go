@@ -20,6 +20,7 @@ import (
 	"github.com/rancher/tests/actions/clusters"
 	"github.com/rancher/tests/actions/provisioning"
 	"github.com/rancher/tests/actions/upgradeinput"
+	"github.com/rancher/tests/actions/upgradestrategy"
 	"github.com/rancher/tests/actions/workloads/pods"
 
 	kcluster "github.com/rancher/shepherd/extensions/kubeapi/cluster"
@@ -122,9 +123,45 @@ func upgradeRKE2K3SCluster(t *testing.T, client *rancher.Client, clusterID strin
 	err = v1.ConvertToK8sType(updatedCluster, &updatedClusterObj)
 	require.NoError(t, err)
 
+	clusterStatus := &provv1.ClusterStatus{}
+	err = v1.ConvertToK8sType(clusterResp.Status, clusterStatus)
+	require.NoError(t, err)
+
+	strategy := updatedClusterObj.Spec.RKEConfig.UpgradeStrategy
+
+	var fixture *upgradestrategy.Fixture
+	var observer *upgradestrategy.Observer
+	if upgradeinput.LoadVerifyUpgradeStrategy() {
+		fixture, err = upgradestrategy.CreateFixture(client, clusterStatus.ClusterName, strategy)
+		require.NoError(t, err)
+
+		t.Cleanup(func() {
+			err := fixture.Delete(client)
+			if err != nil {
+				logrus.Warnf("Failed to delete the upgrade strategy fixture %s", err)
+			}
+		})
+
+		observer, err = upgradestrategy.Observe(client, clusterResp, fixture)
+		require.NoError(t, err)
+	}
+
 	updatedClusterResp, err := extensionscluster.UpdateK3SRKE2Cluster(client, updatedCluster, updatedClusterObj)
 	require.NoError(t, err)
 
+	if observer != nil {
+		err = observer.WaitForKubeletVersion(clustersConfig.KubernetesVersion)
+		observer.Stop()
+		require.NoError(t, err)
+
+		logrus.Infof("Verifying the upgrade strategy was respected (%s)", clusterResp.Name)
+		err = observer.Verify(strategy)
+		require.NoError(t, err)
+
+		err = fixture.Delete(client)
+		require.NoError(t, err)
+	}
+
 	updatedClusterSpec := &provv1.ClusterSpec{}
 	err = v1.ConvertToK8sType(updatedClusterResp.Spec, updatedClusterSpec)
 	require.NoError(t, err)
@@ -0,0 +1,87 @@
+//go:build validation || pit.daily
+
+package upgrade
+
+import (
+	"testing"
+
+	"github.com/rancher/shepherd/clients/rancher"
+	"github.com/rancher/shepherd/pkg/session"
+	"github.com/rancher/tests/actions/upgradeinput"
+	"github.com/rancher/tests/actions/upgradeprobes"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type UpgradeProbeTestSuite struct {
+	suite.Suite
+	session  *session.Session
+	client   *rancher.Client
+	clusters []upgradeinput.Cluster
+}
+
+func (u *UpgradeProbeTestSuite) TearDownSuite() {
+	u.session.Cleanup()
+}
+
+func (u *UpgradeProbeTestSuite) SetupSuite() {
+	testSession := session.NewSession()
+	u.session = testSession
+
+	client, err := rancher.NewClient("", testSession)
+	require.NoError(u.T(), err)
+
+	u.client = client
+
+	clusters, err := upgradeinput.LoadUpgradeKubernetesConfig(client)
+	require.NoError(u.T(), err)
+
+	u.clusters = clusters
+}
+
+func (u *UpgradeProbeTestSuite) TestProbesPreUpgrade() {
+	stateFile := upgradeprobes.StateFile{}
+	for _, cluster := range u.clusters {
+		cluster := cluster
+		testName := "Pre Upgrade probes for the cluster " + cluster.Name
+		u.Run(testName, func() {
+			probes, err := upgradeprobes.NewProbes(cluster.FeaturesToTest.Probes)
+			require.NoError(u.T(), err)
+
+			if len(probes) == 0 {
+				u.T().Skipf("No upgrade probes enabled for the cluster %s", cluster.Name)
+			}
+
+			err = upgradeprobes.RunPreUpgrade(u.client, cluster.Name, probes, stateFile)
+			require.NoError(u.T(), err)
+		})
+	}
+
+	err := stateFile.Write(upgradeinput.LoadProbeStateFile())
+	require.NoError(u.T(), err)
+}
+
+func (u *UpgradeProbeTestSuite) TestProbesPostUpgrade() {
+	stateFile, err := upgradeprobes.LoadStateFile(upgradeinput.LoadProbeStateFile())
+	require.NoError(u.T(), err)
+
+	for _, cluster := range u.clusters {
+		cluster := cluster
+		testName := "Post Upgrade probes for the cluster " + cluster.Name
+		u.Run(testName, func() {
+			probes, err := upgradeprobes.NewProbes(cluster.FeaturesToTest.Probes)
+			require.NoError(u.T(), err)
+
+			if len(probes) == 0 {
+				u.T().Skipf("No upgrade probes enabled for the cluster %s", cluster.Name)
+			}
+
+			err = upgradeprobes.RunPostUpgrade(u.client, cluster.Name, probes, stateFile)
+			require.NoError(u.T(), err)
+		})
+	}
+}
+
+func TestUpgradeProbeTestSuite(t *testing.T) {
+	suite.Run(t, new(UpgradeProbeTestSuite))
+}